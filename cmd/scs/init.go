@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/db"
+)
+
+// The files written by `scs init`, in the order they're written.
+var initFiles = []string{"config.toml", "room.toml", "roles.toml", "characters.toml", "music.toml"}
+
+// The role given to the admin user made by `scs init`.
+const initAdminRole = "Admin"
+
+// Answers to the setup questions.
+type initAnswers struct {
+	name        string
+	desc        string
+	maxPlayers  int
+	portWS      int
+	portTCP     int
+	portRPC     int
+	adminUser   string
+	adminPasswd string
+}
+
+// Runs the first-run setup: asks for the basic settings, writes the config files with
+// defaults for everything else, and adds the first admin user. Existing config files
+// are only overwritten if `force` is set.
+func runInit(in io.Reader, out io.Writer, force bool) error {
+	configDir, err := config.Dir()
+	if err != nil {
+		return fmt.Errorf("Couldn't find config directory (%w).", err)
+	}
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return fmt.Errorf("Couldn't find data directory (%w).", err)
+	}
+	if !force {
+		for _, f := range initFiles {
+			if _, err := os.Stat(filepath.Join(configDir, f)); err == nil {
+				return fmt.Errorf("%v already exists. Use --force to overwrite it.", filepath.Join(configDir, f))
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "Setting up a new server in %v. Press enter to keep the default in brackets.\n", configDir)
+	p := prompter{in: bufio.NewScanner(in), out: out}
+	def := config.ServerDefault()
+	a := initAnswers{
+		name:       p.ask("Server name", def.Name),
+		desc:       p.ask("Description", def.Desc),
+		maxPlayers: p.askInt("Maximum players", def.MaxPlayers),
+		portWS:     p.askInt("WebSocket port", def.PortWS),
+		portTCP:    p.askInt("Legacy (TCP) port, 0 to disable", def.PortTCP),
+		portRPC:    p.askInt("RPC port, 0 to disable", def.PortRPC),
+		adminUser:  p.ask("Admin username", "admin"),
+	}
+	for a.adminPasswd == "" && p.err == nil {
+		a.adminPasswd = p.ask("Admin password", "")
+	}
+	if p.err != nil {
+		return fmt.Errorf("Couldn't read answers (%w).", p.err)
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("Couldn't make config directory (%w).", err)
+	}
+	contents := map[string]string{
+		"config.toml":     initConfig(a),
+		"room.toml":       initRooms,
+		"roles.toml":      initRoles,
+		"characters.toml": initChars,
+		"music.toml":      initMusic,
+	}
+	for _, f := range initFiles {
+		if err := os.WriteFile(filepath.Join(configDir, f), []byte(contents[f]), 0644); err != nil {
+			return fmt.Errorf("Couldn't write %v (%w).", f, err)
+		}
+		fmt.Fprintf(out, "Wrote %v.\n", filepath.Join(configDir, f))
+	}
+
+	database, err := db.Init(filepath.Join(dataDir, "database.sqlite"))
+	if err != nil {
+		return fmt.Errorf("Couldn't initialize database (%w).", err)
+	}
+	defer database.Close()
+	if err := database.AddAuth(a.adminUser, a.adminPasswd, initAdminRole); err != nil {
+		return fmt.Errorf("Couldn't add the admin user. Does it already exist? (%w)", err)
+	}
+	fmt.Fprintf(out, "Added the user '%v' with the role '%v'. Log in with /login.\n", a.adminUser, initAdminRole)
+	fmt.Fprintln(out, "Done! The files in bin/config_sample document every other setting.")
+	return nil
+}
+
+// Asks questions through the terminal. After the first error, every answer is the default.
+type prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+	err error
+}
+
+func (p *prompter) ask(question string, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.out, "%v [%v]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%v: ", question)
+	}
+	if p.err != nil {
+		return def
+	}
+	if !p.in.Scan() {
+		p.err = p.in.Err()
+		if p.err == nil {
+			p.err = io.ErrUnexpectedEOF
+		}
+		return def
+	}
+	if ans := strings.TrimSpace(p.in.Text()); ans != "" {
+		return ans
+	}
+	return def
+}
+
+func (p *prompter) askInt(question string, def int) int {
+	for {
+		ans := p.ask(question, strconv.Itoa(def))
+		n, err := strconv.Atoi(ans)
+		if err == nil && n >= 0 {
+			return n
+		}
+		fmt.Fprintln(p.out, "Please answer with a non-negative number.")
+	}
+}
+
+func initConfig(a initAnswers) string {
+	return fmt.Sprintf(`# Generated by "scs init". See bin/config_sample/config.toml for every setting.
+name = %q
+description = %q
+server_username = "SCS"
+max_players = %v
+ws_port = %v
+legacy_port = %v
+rpc_port = %v
+allow_ao = true
+allow_sc = true
+asset_url = ""
+motd = %q
+language = "en"
+log_level = "info"
+`, a.name, a.desc, a.maxPlayers, a.portWS, a.portTCP, a.portRPC, "Welcome to "+a.name+"!")
+}
+
+const initRooms = `# Generated by "scs init". See bin/config_sample/room.toml for every setting.
+[[room]]
+name = "Lobby"
+description = "The lobby."
+background = "gs4"
+adjacent_rooms = ["Courtroom"]
+
+[[room]]
+name = "Courtroom"
+description = "A courtroom."
+background = "gs4"
+adjacent_rooms = ["Lobby"]
+`
+
+const initRoles = `# Generated by "scs init". See bin/config_sample/roles.toml for every setting.
+[[role]]
+name = "Room Manager"
+permissions = ["status", "lock", "description", "background", "ambiance", "characters", "music", "polls", "settings", "transcripts"]
+
+[[role]]
+name = "Moderator"
+permissions = ["kick", "ban", "mute", "see_ipids", "bypass_locks", "hear_modcall"]
+
+[[role]]
+name = "` + initAdminRole + `"
+permissions = ["all"]
+`
+
+const initChars = `# Generated by "scs init". See bin/config_sample/characters.toml for every setting.
+[[list]]
+name = "Ace Attorney"
+characters = ["Phoenix", "Miles", "Maya", "Franziska", "Gumshoe", "Judge"]
+`
+
+const initMusic = `# Generated by "scs init". See bin/config_sample/music.toml for every setting.
+[[category]]
+name = "Ace Attorney"
+songs = ["Ace Attorney/Pursuit/[AA] Pursuit.opus", "Ace Attorney/Objection/[AA] Objection.opus"]
+`
@@ -1,14 +1,43 @@
 package main
 
 import (
+    "fmt"
     "os"
+    "path/filepath"
 
+    "github.com/lambdcalculus/scs/internal/config"
     "github.com/lambdcalculus/scs/internal/server"
     "github.com/lambdcalculus/scs/pkg/logger"
+    "github.com/spf13/pflag"
 )
 
 func main() {
-    log := logger.NewLoggerOutputs(logger.LevelTrace, nil, "stdout", "log/server.log")
+    var configDir, dataDir string
+    pflag.StringVarP(&configDir, "config", "c", os.Getenv("SCS_CONFIG_DIR"), "directory with the config files, languages and scripts (default: \"config\" next to the executable)")
+    pflag.StringVarP(&dataDir, "data", "d", os.Getenv("SCS_DATA_DIR"), "directory the database and logs are kept in (default: the executable's directory)")
+    force := pflag.Bool("force", false, "with init, overwrite existing config files")
+    pflag.Usage = func() {
+        fmt.Fprintf(os.Stderr, "Usage: %v [flags] [init]\n\n", os.Args[0])
+        fmt.Fprintln(os.Stderr, "Runs the server. With \"init\", generates the config files and the first admin user instead.")
+        fmt.Fprintln(os.Stderr, "\nFlags:")
+        pflag.PrintDefaults()
+    }
+    pflag.Parse()
+    config.SetDirs(configDir, dataDir)
+
+    if pflag.Arg(0) == "init" {
+        if err := runInit(os.Stdin, os.Stdout, *force); err != nil {
+            fmt.Fprintf(os.Stderr, "Setup failed: %v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    logPath := "log/server.log"
+    if logDir, err := config.LogDir(); err == nil {
+        logPath = filepath.Join(logDir, "server.log")
+    }
+    log := logger.NewLoggerOutputs(logger.LevelTrace, nil, "stdout", logPath)
     serv, err := server.MakeServer(log)
     if err != nil {
         log.Fatalf("Couldn't make server (%v).", err)
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/lambdcalculus/scs/pkg/logger"
+)
+
+// The lists written by import-ao. Only the settings that were imported are written,
+// so everything else keeps scs's defaults.
+type importedRooms struct {
+	Rooms []importedRoom `toml:"room"`
+}
+
+type importedRoom struct {
+	Name           string   `toml:"name"`
+	Background     string   `toml:"background,omitempty"`
+	LockBg         bool     `toml:"lock_background,omitempty"`
+	AdjacentRooms  []string `toml:"adjacent_rooms"`
+	AllowBlankpost *bool    `toml:"allow_blankpost,omitempty"`
+	AllowIniswap   *bool    `toml:"allow_iniswap,omitempty"`
+	ForceImmediate bool     `toml:"force_immediate,omitempty"`
+	LockMusic      bool     `toml:"lock_music,omitempty"`
+}
+
+type importedChars struct {
+	Lists []importedCharList `toml:"list"`
+}
+
+type importedCharList struct {
+	Name       string   `toml:"name"`
+	Characters []string `toml:"characters"`
+}
+
+type importedMusic struct {
+	Categories []importedCategory `toml:"category"`
+}
+
+type importedCategory struct {
+	Name  string   `toml:"name"`
+	Songs []string `toml:"songs"`
+}
+
+type importedBgs struct {
+	Backgrounds []string `toml:"backgrounds"`
+}
+
+// The name of the character list and of the category for songs before any category.
+const importedListName = "Imported"
+
+// Converts the character, music, background and area lists of an AO server (as used by
+// Akashi and similar servers) into scs configs.
+func handleImportAO(args []string) {
+	in := args[0]
+	out := "."
+	if len(args) > 1 {
+		out = args[1]
+	}
+
+	type conversion struct {
+		from, to string
+		convert  func(path string) (any, error)
+	}
+	conversions := []conversion{
+		{"characters.txt", "characters.toml", importChars},
+		{"music.txt", "music.toml", importMusic},
+		{"backgrounds.txt", "backgrounds.toml", importBgs},
+		{"areas.ini", "room.toml", importAreas},
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		logger.Errorf("import-ao: Couldn't make output directory (%s).", err)
+		os.Exit(1)
+	}
+	var imported int
+	for _, c := range conversions {
+		src := filepath.Join(in, c.from)
+		if _, err := os.Stat(src); err != nil {
+			fmt.Printf("import-ao: Skipping %v, which wasn't found.\n", c.from)
+			continue
+		}
+		dst := filepath.Join(out, c.to)
+		if _, err := os.Stat(dst); err == nil {
+			logger.Errorf("import-ao: %v already exists. Move it away first.", dst)
+			os.Exit(1)
+		}
+		conf, err := c.convert(src)
+		if err != nil {
+			logger.Errorf("import-ao: Couldn't convert %v (%s).", c.from, err)
+			os.Exit(1)
+		}
+		if err := writeTOML(dst, c.from, conf); err != nil {
+			logger.Errorf("import-ao: Couldn't write %v (%s).", dst, err)
+			os.Exit(1)
+		}
+		fmt.Printf("import-ao: Converted %v into %v.\n", c.from, dst)
+		imported++
+	}
+	if imported == 0 {
+		logger.Errorf("import-ao: Found nothing to import in %v.", in)
+		os.Exit(1)
+	}
+}
+
+func writeTOML(path string, from string, conf any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "# Imported from %v by \"serverctl import-ao\".\n\n", from)
+	enc := toml.NewEncoder(f)
+	enc.Indent = ""
+	return enc.Encode(conf)
+}
+
+// Reads the non-empty lines of a file, ignoring comments.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// characters.txt has one character per line.
+func importChars(path string) (any, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return importedChars{Lists: []importedCharList{{Name: importedListName, Characters: lines}}}, nil
+}
+
+// music.txt has one song per line. Lines without a file extension are categories,
+// which the songs after them belong to.
+func importMusic(path string) (any, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf importedMusic
+	for _, l := range lines {
+		if !strings.Contains(l, ".") {
+			conf.Categories = append(conf.Categories, importedCategory{Name: l})
+			continue
+		}
+		if len(conf.Categories) == 0 {
+			conf.Categories = append(conf.Categories, importedCategory{Name: importedListName})
+		}
+		last := &conf.Categories[len(conf.Categories)-1]
+		last.Songs = append(last.Songs, l)
+	}
+	// Categories scs would send without songs are dropped.
+	cats := conf.Categories[:0]
+	for _, c := range conf.Categories {
+		if len(c.Songs) > 0 {
+			cats = append(cats, c)
+		}
+	}
+	conf.Categories = cats
+	return conf, nil
+}
+
+// backgrounds.txt has one background per line.
+func importBgs(path string) (any, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return importedBgs{Backgrounds: lines}, nil
+}
+
+// Matches section headers like "[0:Basement]", with an optional index.
+var areaHeader = regexp.MustCompile(`^\[(?:\d+:)?(.+)\]$`)
+
+// areas.ini has one section per area. Settings scs has no equivalent for are reported
+// and skipped. Every area is made adjacent to every other, as in AO.
+func importAreas(path string) (any, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf importedRooms
+	var skipped []string
+	for _, l := range lines {
+		if m := areaHeader.FindStringSubmatch(l); m != nil {
+			conf.Rooms = append(conf.Rooms, importedRoom{Name: strings.TrimSpace(m[1]), AdjacentRooms: []string{"all"}})
+			continue
+		}
+		key, val, ok := strings.Cut(l, "=")
+		if !ok || len(conf.Rooms) == 0 {
+			return nil, fmt.Errorf("malformed line '%v'", l)
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		r := &conf.Rooms[len(conf.Rooms)-1]
+		b, berr := strconv.ParseBool(val)
+		switch {
+		case key == "background":
+			r.Background = val
+		case key == "bg_locked" && berr == nil:
+			r.LockBg = b
+		case key == "blankposting_allowed" && berr == nil:
+			r.AllowBlankpost = &b
+		case key == "iniswap_allowed" && berr == nil:
+			r.AllowIniswap = &b
+		case key == "force_immediate" && berr == nil:
+			r.ForceImmediate = b
+		case key == "music_locked" && berr == nil:
+			r.LockMusic = b
+		default:
+			if !slices.Contains(skipped, key) {
+				skipped = append(skipped, key)
+			}
+		}
+	}
+	if len(conf.Rooms) == 0 {
+		return nil, fmt.Errorf("no areas found")
+	}
+	for _, key := range skipped {
+		fmt.Printf("import-ao: Skipped the area setting '%v', which has no equivalent.\n", key)
+	}
+	return conf, nil
+}
@@ -6,6 +6,8 @@ import (
 	"net/rpc"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	// using `t`` since we only require the RPC types
 	t "github.com/lambdcalculus/scs/pkg/rpc"
@@ -63,6 +65,24 @@ func init() {
 			"serverctl -p [RPC port] add-auth [username] [password] [role]"},
 		"rm-auth": {handleRmAuth, 1, "removes an user from the auth table",
 			"serverctl -p [RPC port] rm-auth [username]"},
+		"reset-password": {handleResetPassword, 2, "sets a new password for an user in the auth table",
+			"serverctl -p [RPC port] reset-password [username] [new password]"},
+		"setup-totp": {handleSetupTOTP, 1, "makes an user need a one-time code to log in, printing the URI for their authenticator app",
+			"serverctl -p [RPC port] setup-totp [username]"},
+		"disable-totp": {handleDisableTOTP, 1, "stops an user from needing a one-time code to log in",
+			"serverctl -p [RPC port] disable-totp [username]"},
+		"stats": {handleStats, 0, "shows the server's statistics since it started",
+			"serverctl -p [RPC port] stats"},
+		"char-stats": {handleCharStats, 0, "shows how much each character was used, in a room or in every room",
+			"serverctl -p [RPC port] char-stats [room name...]"},
+		"ban-ip": {handleBanIP, 2, "bans a raw IP or range of IPs in CIDR notation",
+			"serverctl -p [RPC port] ban-ip [ip|cidr] [duration] [reason...]"},
+		"unban-ip": {handleUnbanIP, 1, "lifts a ban on a raw IP or range of IPs",
+			"serverctl -p [RPC port] unban-ip [ban id]"},
+		"db-maintain": {handleDBMaintain, 0, "deletes records older than the configured retention and vacuums the database",
+			"serverctl -p [RPC port] db-maintain"},
+		"import-ao": {handleImportAO, 1, "converts an AO server's characters.txt, music.txt, backgrounds.txt and areas.ini into configs (no RPC needed)",
+			"serverctl import-ao [AO config directory] [output directory]"},
 	}
 
 	pflag.IntVarP(&rpcPort, "port", "p", -1, "port used for RPC")
@@ -155,6 +175,131 @@ func handleRmAuth(args []string) {
 	fmt.Printf("rm-auth: User '%v' removed succesfully!\n", args[0])
 }
 
+func handleResetPassword(args []string) {
+	client := dial()
+	rpcArgs := &t.SetPasswordArgs{
+		Username: args[0],
+		Password: args[1],
+	}
+	var reply int
+	if err := client.Call("Server.SetPassword", rpcArgs, &reply); err != nil {
+		logger.Errorf("reset-password: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Printf("reset-password: Password of user '%v' changed succesfully!\n", args[0])
+}
+
+func handleSetupTOTP(args []string) {
+	client := dial()
+	var reply string
+	if err := client.Call("Server.SetupTOTP", &t.SetupTOTPArgs{Username: args[0]}, &reply); err != nil {
+		logger.Errorf("setup-totp: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Printf("setup-totp: TOTP set up for user '%v'. Add this URI to their authenticator app:\n%v\n", args[0], reply)
+}
+
+func handleDisableTOTP(args []string) {
+	client := dial()
+	var reply string
+	if err := client.Call("Server.SetupTOTP", &t.SetupTOTPArgs{Username: args[0], Disable: true}, &reply); err != nil {
+		logger.Errorf("disable-totp: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Printf("disable-totp: TOTP disabled for user '%v'.\n", args[0])
+}
+
+func handleBanIP(args []string) {
+	client := dial()
+	rpcArgs := &t.AddIPBanArgs{
+		CIDR:     args[0],
+		Duration: args[1],
+		Reason:   "No reason given.",
+	}
+	if len(args) > 2 {
+		rpcArgs.Reason = strings.Join(args[2:], " ")
+	}
+	var reply int
+	if err := client.Call("Server.AddIPBan", rpcArgs, &reply); err != nil {
+		logger.Errorf("ban-ip: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Printf("ban-ip: Banned '%v' for %v (ban #%v).\n", args[0], args[1], reply)
+}
+
+func handleUnbanIP(args []string) {
+	client := dial()
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		logger.Errorf("unban-ip: '%v' is not a valid ban ID.", args[0])
+		os.Exit(1)
+	}
+	var reply int
+	if err := client.Call("Server.RmIPBan", &t.RmIPBanArgs{BanID: id}, &reply); err != nil {
+		logger.Errorf("unban-ip: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Printf("unban-ip: IP ban #%v lifted succesfully!\n", id)
+}
+
+func handleStats(args []string) {
+	client := dial()
+	var reply t.StatsReply
+	if err := client.Call("Server.Stats", &t.StatsArgs{}, &reply); err != nil {
+		logger.Errorf("stats: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Uptime:        %v\n", reply.Uptime.Round(time.Second))
+	fmt.Printf("Players:       %v/%v (peak: %v)\n", reply.Players, reply.MaxPlayers, reply.PeakPlayers)
+	fmt.Printf("Connections:   %v\n", reply.Connections)
+	fmt.Printf("Joins:         %v\n", reply.Joins)
+	fmt.Printf("IC messages:   %v\n", reply.ICMessages)
+	fmt.Printf("OOC messages:  %v\n", reply.OOCMessages)
+	fmt.Printf("Commands:      %v\n", reply.Commands)
+	fmt.Printf("Song changes:  %v\n", reply.MusicChanges)
+	fmt.Printf("Mod calls:     %v\n", reply.ModCalls)
+	fmt.Printf("Kicks:         %v\n", reply.Kicks)
+	fmt.Printf("Bans:          %v\n", reply.Bans)
+	fmt.Printf("Queued:        %v (largest queue: %v)\n", reply.Queued, reply.LargestQueue)
+	fmt.Printf("Evictions:     %v\n", reply.Evictions)
+	fmt.Printf("Latency:       %v (worst: %v)\n", reply.AvgRTT.Round(time.Millisecond), reply.MaxRTT.Round(time.Millisecond))
+}
+
+func handleCharStats(args []string) {
+	client := dial()
+	var reply t.CharStatsReply
+	if err := client.Call("Server.CharStats", &t.CharStatsArgs{Room: strings.Join(args, " ")}, &reply); err != nil {
+		logger.Errorf("char-stats: Failed (%s).", err)
+		os.Exit(1)
+	}
+	if len(reply.Chars) == 0 {
+		fmt.Println("char-stats: No characters have been used yet.")
+		return
+	}
+	fmt.Printf("%-30v %8v %12v\n", "Character", "Picks", "Time")
+	for _, c := range reply.Chars {
+		fmt.Printf("%-30v %8v %12v\n", c.Name, c.Picks, c.Time)
+	}
+}
+
+func handleDBMaintain(args []string) {
+	client := dial()
+	var reply t.MaintainDBReply
+	if err := client.Call("Server.MaintainDB", &t.MaintainDBArgs{}, &reply); err != nil {
+		logger.Errorf("db-maintain: Failed (%s).", err)
+		os.Exit(1)
+	}
+	fmt.Println("Pruned:")
+	fmt.Printf("  Mutes:          %v\n", reply.Mutes)
+	fmt.Printf("  Bans:           %v\n", reply.Bans)
+	fmt.Printf("  IP bans:        %v\n", reply.IPBans)
+	fmt.Printf("  Role grants:    %v\n", reply.RoleGrants)
+	fmt.Printf("  Mod calls:      %v\n", reply.ModCalls)
+	fmt.Printf("  Warnings:       %v\n", reply.Warnings)
+	fmt.Printf("  Login failures: %v\n", reply.LoginFailures)
+	fmt.Printf("Done in %v.\n", reply.Took.Round(time.Millisecond))
+}
+
 func dial() *rpc.Client {
 	if rpcPort <= 0 {
 		logger.Fatalf("Port must be specified.")
@@ -8,6 +8,8 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.22.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require golang.org/x/net v0.21.0 // indirect
@@ -0,0 +1,292 @@
+// Package `aotest` implements a scripted AO client, for exercising a running server's
+// protocol handlers the way a real client would: it connects over TCP or WebSocket,
+// performs the handshake, joins, and sends IC, OOC and command messages, while
+// recording every packet the server sends back.
+package aotest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// How long to wait for an expected packet when no timeout is passed.
+const DefaultTimeout = 5 * time.Second
+
+// A scripted AO client. Its methods can be called from multiple goroutines, though
+// a script usually runs on a single one.
+type Client struct {
+	tcp *net.TCPConn
+	ws  *websocket.Conn
+
+	in       chan packets.PacketAO
+	received []packets.PacketAO // everything received, in order
+	readErr  error
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+
+	// Set by [Client.Join].
+	Chars []string
+	Music []string
+}
+
+// Connects to a server's legacy TCP port, e.g. "localhost:8081".
+func DialTCP(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("aotest: Couldn't connect via TCP (%w).", err)
+	}
+	c := newClient()
+	c.tcp = conn.(*net.TCPConn)
+	go c.readTCP()
+	return c, nil
+}
+
+// Connects to a server's WebSocket port, e.g. "ws://localhost:8080".
+func DialWS(url string) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("aotest: Couldn't connect via WS (%w).", err)
+	}
+	c := newClient()
+	c.ws = conn
+	go c.readWS()
+	return c, nil
+}
+
+func newClient() *Client {
+	return &Client{in: make(chan packets.PacketAO, 256)}
+}
+
+// Closes the connection.
+func (c *Client) Close() error {
+	if c.ws != nil {
+		return c.ws.Close()
+	}
+	return c.tcp.Close()
+}
+
+func (c *Client) readTCP() {
+	defer close(c.in)
+	scanner := bufio.NewScanner(c.tcp)
+	scanner.Split(splitPackets)
+	for scanner.Scan() {
+		c.receive(scanner.Bytes())
+	}
+	c.setReadErr(scanner.Err())
+}
+
+func (c *Client) readWS() {
+	defer close(c.in)
+	for {
+		_, b, err := c.ws.ReadMessage()
+		if err != nil {
+			c.setReadErr(err)
+			return
+		}
+		// A message may carry more than one packet.
+		for _, raw := range bytes.SplitAfter(b, []byte("%")) {
+			if len(bytes.TrimSpace(raw)) > 0 {
+				c.receive(raw)
+			}
+		}
+	}
+}
+
+// Splits a TCP stream into packets, which end with '%'.
+func splitPackets(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, '%'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func (c *Client) receive(raw []byte) {
+	p := packets.MakeAOPacket(raw)
+	p.Decode()
+	c.mu.Lock()
+	c.received = append(c.received, p)
+	c.mu.Unlock()
+	c.in <- p
+}
+
+func (c *Client) setReadErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readErr = err
+}
+
+// Returns every packet received so far, in order, including the ones skipped while
+// waiting for others.
+func (c *Client) Received() []packets.PacketAO {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]packets.PacketAO(nil), c.received...)
+}
+
+// Sends a packet to the server.
+func (c *Client) Send(header string, contents ...string) error {
+	msg := []byte(packets.PacketAO{Header: header, Contents: contents}.Encoded())
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	var err error
+	if c.ws != nil {
+		err = c.ws.WriteMessage(websocket.TextMessage, msg)
+	} else {
+		_, err = c.tcp.Write(msg)
+	}
+	if err != nil {
+		return fmt.Errorf("aotest: Couldn't send '%v' (%w).", header, err)
+	}
+	return nil
+}
+
+// Waits for the next packet with the header, skipping any others. A timeout of 0
+// means [DefaultTimeout].
+func (c *Client) Expect(header string, timeout time.Duration) (packets.PacketAO, error) {
+	return c.ExpectFunc(header, nil, timeout)
+}
+
+// Like [Client.Expect], but also skips the packets with the header that `match` returns
+// false for. A nil `match` accepts any of them.
+func (c *Client) ExpectFunc(header string, match func(packets.PacketAO) bool, timeout time.Duration) (packets.PacketAO, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.After(timeout)
+	for {
+		select {
+		case p, ok := <-c.in:
+			if !ok {
+				c.mu.Lock()
+				err := c.readErr
+				c.mu.Unlock()
+				return packets.PacketAO{}, fmt.Errorf("aotest: Connection closed while waiting for '%v' (%v).", header, err)
+			}
+			if p.Header == header && (match == nil || match(p)) {
+				return p, nil
+			}
+		case <-deadline:
+			return packets.PacketAO{}, fmt.Errorf("aotest: Timed out waiting for '%v'.", header)
+		}
+	}
+}
+
+// Sends a packet and waits for the reply with the passed header.
+func (c *Client) Request(reply string, header string, contents ...string) (packets.PacketAO, error) {
+	if err := c.Send(header, contents...); err != nil {
+		return packets.PacketAO{}, err
+	}
+	return c.Expect(reply, 0)
+}
+
+// Performs the whole handshake, as AO2 does, until the server says the client joined.
+// The character and music lists are kept in the client.
+func (c *Client) Join(hdid string, software string, version string) error {
+	if c.tcp != nil {
+		// Only sent to TCP clients, which have to wait for it.
+		if _, err := c.Expect("decryptor", 0); err != nil {
+			return err
+		}
+	}
+	if _, err := c.Request("ID", "HI", hdid); err != nil {
+		return err
+	}
+	if _, err := c.Request("PN", "ID", software, version); err != nil {
+		return err
+	}
+	if _, err := c.Request("SI", "askchaa"); err != nil {
+		return err
+	}
+	sc, err := c.Request("SC", "RC")
+	if err != nil {
+		return err
+	}
+	c.Chars = sc.Contents
+	sm, err := c.Request("SM", "RM")
+	if err != nil {
+		return err
+	}
+	c.Music = sm.Contents
+	_, err = c.Request("DONE", "RD")
+	return err
+}
+
+// Picks the character with the passed CID, waiting for the server to confirm it.
+func (c *Client) PickChar(cid int) error {
+	_, err := c.Request("PV", "CC", "0", strconv.Itoa(cid), "")
+	return err
+}
+
+// Sends an OOC message.
+func (c *Client) OOC(username string, msg string) error {
+	return c.Send("CT", username, msg)
+}
+
+// Sends a command through OOC, waiting for the server's reply, which is returned.
+func (c *Client) Command(username string, cmd string) (string, error) {
+	if err := c.OOC(username, "/"+cmd); err != nil {
+		return "", err
+	}
+	p, err := c.Expect("CT", 0)
+	if err != nil {
+		return "", err
+	}
+	if len(p.Contents) < 2 {
+		return "", fmt.Errorf("aotest: Malformed reply to '/%v'.", cmd)
+	}
+	return p.Contents[1], nil
+}
+
+// An IC message, with the fields a script usually cares about. The rest are sent
+// with AO2's defaults.
+type IC struct {
+	CID      int
+	Char     string
+	Emote    string
+	Message  string
+	Side     string
+	Showname string
+}
+
+// Sends an IC message.
+func (c *Client) IC(m IC) error {
+	return c.Send("MS",
+		"chat",              // desk mod
+		"-",                 // preanim
+		m.Char,              // character
+		m.Emote,             // emote
+		m.Message,           // message
+		m.Side,              // side
+		"0",                 // sfx
+		"0",                 // emote modifier
+		strconv.Itoa(m.CID), // CID
+		"0",                 // sfx delay
+		"0",                 // shout
+		"0",                 // evidence
+		"0",                 // flip
+		"0",                 // realization
+		"0",                 // text color
+		m.Showname,          // showname
+		"-1",                // pair CID
+		"0&0",               // self offset
+		"0",                 // immediate
+		"0",                 // sfx looping
+		"0",                 // screenshake
+		"-",                 // shake frames
+		"-",                 // realization frames
+		"-",                 // sfx frames
+		"0",                 // additive
+		"",                  // effect
+	)
+}
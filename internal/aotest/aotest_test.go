@@ -0,0 +1,155 @@
+package aotest
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Dials the server over WebSocket or TCP and joins it.
+func join(t *testing.T, s *Server, ws bool) *Client {
+	t.Helper()
+	var c *Client
+	if ws {
+		c = s.DialWS(t)
+	} else {
+		c = s.DialTCP(t)
+	}
+	if err := c.Join("hdid", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Joins and picks the character with the CID, waiting for the announcement that the
+// client joined, so it isn't mistaken for a reply later.
+func play(t *testing.T, s *Server, ws bool, cid int) *Client {
+	t.Helper()
+	c := join(t, s, ws)
+	if err := c.PickChar(cid); err != nil {
+		t.Fatal(err)
+	}
+	expectOOC(t, c, "SCS", "has joined the server!")
+	return c
+}
+
+// Waits for an OOC message from `name` containing `text`.
+func expectOOC(t *testing.T, c *Client, name string, text string) packets.PacketAO {
+	t.Helper()
+	p, err := c.ExpectFunc("CT", func(p packets.PacketAO) bool {
+		return len(p.Contents) >= 2 && p.Contents[0] == name && strings.Contains(p.Contents[1], text)
+	}, 0)
+	if err != nil {
+		t.Fatalf("%v (wanted %q from %q)", err, text, name)
+	}
+	return p
+}
+
+func TestHandshake(t *testing.T) {
+	s := StartServer(t, nil)
+	for _, tc := range []struct {
+		desc string
+		ws   bool
+	}{
+		{"TCP", false},
+		{"WS", true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := join(t, s, tc.ws)
+			if want := []string{"Phoenix", "Miles", "Maya"}; !slices.Equal(c.Chars, want) {
+				t.Errorf("got characters %q; want %q", c.Chars, want)
+			}
+			for _, want := range []string{"Lobby", "Courtroom", "Ace Attorney", "Pursuit.opus", "Objection.opus"} {
+				if !slices.Contains(c.Music, want) {
+					t.Errorf("music list %q is missing %q", c.Music, want)
+				}
+			}
+			if err := c.PickChar(1); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestIC(t *testing.T) {
+	s := StartServer(t, nil)
+	a := play(t, s, false, 0)
+	b := play(t, s, true, 1)
+
+	if err := a.IC(IC{CID: 0, Char: "Phoenix", Emote: "normal", Message: "Objection!", Side: "def"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*Client{a, b} {
+		p, err := c.Expect("MS", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Contents) < 9 {
+			t.Fatalf("malformed MS: %q", p.Contents)
+		}
+		if p.Contents[2] != "Phoenix" || p.Contents[4] != "Objection!" || p.Contents[8] != "0" {
+			t.Errorf("got MS %q; want Phoenix (CID 0) saying \"Objection!\"", p.Contents)
+		}
+	}
+
+	// Spectators can't speak.
+	spec := join(t, s, false)
+	if err := spec.IC(IC{CID: -1, Char: "Phoenix", Emote: "normal", Message: "Hold it!", Side: "def"}); err != nil {
+		t.Fatal(err)
+	}
+	expectOOC(t, spec, "SCS", "Spectators cannot speak.")
+}
+
+func TestOOC(t *testing.T) {
+	s := StartServer(t, nil)
+	a := play(t, s, false, 0)
+	b := play(t, s, true, 1)
+
+	if err := a.OOC("alice", "hello there"); err != nil {
+		t.Fatal(err)
+	}
+	expectOOC(t, b, "alice", "hello there")
+
+	// Usernames are unique across the server.
+	if err := b.OOC("alice", "me too"); err != nil {
+		t.Fatal(err)
+	}
+	expectOOC(t, b, "SCS", "Username 'alice' is already in use")
+
+	if err := a.OOC("alice", "   "); err != nil {
+		t.Fatal(err)
+	}
+	expectOOC(t, a, "SCS", "Cannot send blank OOC message.")
+}
+
+func TestCommands(t *testing.T) {
+	s := StartServer(t, nil)
+	c := join(t, s, false)
+	for _, tc := range []struct {
+		cmd  string
+		want string // a prefix of the reply
+	}{
+		{"ping", "Pong!"},
+		{"nonsense", "'/nonsense' is an unknown command."},
+		{"kick 1 bye", "You do not have the required"},
+	} {
+		reply, err := c.Command("bob", tc.cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(reply, tc.want) {
+			t.Errorf("/%v replied %q; want a reply starting with %q", tc.cmd, reply, tc.want)
+		}
+	}
+
+	// Replies with user-controlled text aren't used as format strings.
+	reply, err := c.Command("100%d", "look")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, `"100%d"`) {
+		t.Errorf("/look replied %q; want it to list \"100%%d\"", reply)
+	}
+}
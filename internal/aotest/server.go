@@ -0,0 +1,250 @@
+package aotest
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/db"
+	"github.com/lambdcalculus/scs/internal/server"
+	"github.com/lambdcalculus/scs/pkg/logger"
+)
+
+// The config files test servers are made with. Tests can replace any of them, or add
+// others (e.g. "backgrounds.toml"), through [StartServer]. The ports are always picked
+// by [StartServer].
+var DefaultConfigs = map[string]string{
+	"config.toml": `
+name = "Test"
+description = "A server for tests."
+server_username = "SCS"
+max_players = 10
+allow_ao = true
+allow_sc = true
+motd = ""
+log_level = "debug"
+
+[welcome]
+enabled = false
+`,
+	"room.toml": `
+[[room]]
+name = "Lobby"
+description = "The lobby."
+background = "gs4"
+adjacent_rooms = ["Courtroom"]
+
+[[room]]
+name = "Courtroom"
+description = "A courtroom."
+background = "gs4"
+adjacent_rooms = ["Lobby"]
+`,
+	"roles.toml": `
+[[role]]
+name = "Moderator"
+permissions = ["kick", "ban", "mute", "see_ipids"]
+
+[[role]]
+name = "Admin"
+permissions = ["all"]
+`,
+	"characters.toml": `
+[[list]]
+name = "Ace Attorney"
+characters = ["Phoenix", "Miles", "Maya"]
+`,
+	"music.toml": `
+[[category]]
+name = "Ace Attorney"
+songs = ["Pursuit.opus", "Objection.opus"]
+`,
+}
+
+// A server running in the test's process, with its configuration and data in the
+// test's temporary directory. It's stopped when the test ends.
+type Server struct {
+	SCS *server.SCServer
+	WS  string // the WebSocket URL, e.g. "ws://localhost:8080"
+	TCP string // the legacy TCP address, e.g. "localhost:8081"
+	Dir string // where the configs and data are
+
+	log  *syncBuffer
+	done chan struct{} // closed when the running server stops
+}
+
+// Starts a server with the [DefaultConfigs], replaced or added to by `configs` (by file
+// name). The config and data directories and the ports are set for the whole process,
+// so tests using it can't run in parallel. If the test fails, the server's log is
+// printed.
+func StartServer(t testing.TB, configs map[string]string) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "config")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("aotest: Couldn't make config directory (%v).", err)
+	}
+	files := make(map[string]string, len(DefaultConfigs))
+	for name, contents := range DefaultConfigs {
+		files[name] = contents
+	}
+	for name, contents := range configs {
+		files[name] = contents
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("aotest: Couldn't write %v (%v).", name, err)
+		}
+	}
+	config.SetDirs(configDir, dir)
+	t.Cleanup(func() { config.SetDirs("", "") })
+
+	t.Setenv("SCS_PORT_RPC", "0")
+
+	s := &Server{
+		Dir: dir,
+		log: &syncBuffer{},
+	}
+	t.Cleanup(func() {
+		s.stop()
+		if t.Failed() {
+			t.Logf("Server log:\n%s", s.log)
+		}
+	})
+	s.start(t)
+	return s
+}
+
+// Makes and runs the server on new ports, waiting until it's listening.
+func (s *Server) start(t testing.TB) {
+	t.Helper()
+	wsPort, tcpPort := freePort(t), freePort(t)
+	t.Setenv("SCS_PORT_WS", strconv.Itoa(wsPort))
+	t.Setenv("SCS_PORT_TCP", strconv.Itoa(tcpPort))
+	s.WS = fmt.Sprintf("ws://localhost:%v", wsPort)
+	s.TCP = fmt.Sprintf("localhost:%v", tcpPort)
+
+	var err error
+	s.SCS, err = server.MakeServer(logger.NewLogger(nil, logger.LevelDebug, s.log))
+	if err != nil {
+		t.Fatalf("aotest: Couldn't make server (%v).\n%s", err, s.log)
+	}
+	done := make(chan struct{})
+	s.done = done
+	go func() {
+		s.SCS.Run()
+		close(done)
+	}()
+	waitListening(t, s.TCP)
+	waitListening(t, strings.TrimPrefix(s.WS, "ws://"))
+}
+
+// Stops the server, if it's running, and waits for it to finish.
+func (s *Server) stop() {
+	if s.done == nil {
+		return
+	}
+	// Run may have already returned, in which case nothing receives the stop.
+	go s.SCS.Stop()
+	<-s.done
+	s.done = nil
+}
+
+// Stops the server and starts a new one with the same configuration and data, as if it
+// had been restarted. Clients of the old server are disconnected. A stopped server
+// doesn't close its listeners, so the new one gets new ports, and WS and TCP change.
+func (s *Server) Restart(t testing.TB) {
+	t.Helper()
+	s.stop()
+	s.start(t)
+}
+
+// Adds a user that can log in with the password to the role, through its own connection
+// to the server's database.
+func (s *Server) AddUser(t testing.TB, username string, password string, role string) {
+	t.Helper()
+	d, err := db.Init(filepath.Join(s.Dir, "database.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.AddAuth(username, password, role); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Connects to the server's TCP port, closing the connection when the test ends.
+func (s *Server) DialTCP(t testing.TB) *Client {
+	t.Helper()
+	c, err := DialTCP(s.TCP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Connects to the server's WebSocket port, closing the connection when the test ends.
+func (s *Server) DialWS(t testing.TB) *Client {
+	t.Helper()
+	c, err := DialWS(s.WS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// Returns a port nothing is listening on.
+func freePort(t testing.TB) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("aotest: Couldn't find a free port (%v).", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// Waits until something is listening on the address.
+func waitListening(t testing.TB, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(DefaultTimeout)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("aotest: The server isn't listening on %v (%v).", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// A buffer the server's log is written to, from many goroutines.
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/lambdcalculus/scs/internal/perms"
@@ -37,9 +40,22 @@ const (
 	MutedOOC
 	MutedMusic
 	MutedJudge
+	// IC and OOC messages are silently dropped, but still shown to the client itself.
+	MutedShadow
 	// TODO: add gimp/parrot
 )
 
+// WebSocket close codes sent when disconnecting clients. Codes from 4000 to 4999 are
+// for private use, so we use those for our own reasons.
+const (
+	CloseNormal   = websocket.CloseNormalClosure
+	CloseShutdown = websocket.CloseGoingAway
+	CloseFull     = websocket.CloseTryAgainLater
+	ClosePolicy   = websocket.ClosePolicyViolation
+	CloseKicked   = 4000
+	CloseBanned   = 4001
+)
+
 // Represents a client's connection and attributes.
 type Client struct {
 	mu sync.Mutex
@@ -50,10 +66,14 @@ type Client struct {
 	tcpScanner *bufio.Scanner
 	addr       string
 	clientType ClientType
+	software   string // e.g. "AO2"
+	version    Version
+	knownVer   bool
 
 	// identification data
 	ident    string // the famed "HDID"
 	ipid     string
+	oldIPID  string // the IPID under the legacy hashing scheme
 	uid      int
 	cid      int
 	charname string // character name, i.e. the files the client is using
@@ -62,16 +82,57 @@ type Client struct {
 	// state data
 	showname   string
 	username   string // OOC name
+	language   string // language code for server messages, "" for the server's default
+	session    string // token an SC client can resume its session with after reconnecting
+	gates      Gate   // steps left before the client can play
 	charPicked bool   // a client is technically joined before picking a character, but to announce its entrance properly we need an extra variable. ugh.
 	room       *room.Room
 	side       string
 	mute       MuteState
+	muteEnds   map[MuteState]time.Time // when timed mutes end, by kind
 	autopass   bool // TODO: implement
 	lastMsg    string
+	lastCall   time.Time // last mod call
+	lastMove   time.Time // last room change
+	lastCase   time.Time // last case announcement
+
+	repeatKey    string // what the last IC message repeated, see [Client.CountRepeat]
+	repeats      int
+	spamWarnings int
+	history      []SentMessage // recent messages, see [Client.RecordMessage]
+
+	pendingLogin string                    // username waiting for a password, see [Client.PendingLogin]
+	login        string                    // username the client is logged in as, if any
+	roles        [numRoleSlots]*perms.Role // see [Client.AddRole]
 
 	// pair data
 	pair PairData
 
+	// which case announcements the client wants
+	casePrefs CasePrefs
+
+	// write queue
+	out       chan string
+	closing   chan struct{} // closed when the client is disconnecting
+	flushed   chan struct{} // closed when the writer is done
+	done      chan struct{} // closed when the connection is closed
+	closeOnce sync.Once
+	discOnce  sync.Once
+	evicted   atomic.Bool
+	removed   atomic.Bool // see [Client.MarkRemoved]
+
+	// work for the client's read loop, see [Client.Post]
+	tasks chan func()
+
+	// latency measurements, see [Client.RTT]
+	pingSent  atomic.Int64 // Unix nanoseconds
+	rtt       atomic.Int64 // nanoseconds
+	keepalive atomic.Int64 // Unix nanoseconds
+
+	// sent in the close frame to WebSocket clients
+	closeCode   int
+	closeReason string
+
 	// logger
 	logger *logger.Logger
 }
@@ -84,6 +145,23 @@ type PairData struct {
 	LastFlip   string
 }
 
+// The roles a client wants to be alerted about when a case is announced (AO's SETCASE).
+type CasePrefs struct {
+	Cases []string // case names, only informative
+	CM    bool
+	Def   bool
+	Pro   bool
+	Judge bool
+	Jury  bool
+	Steno bool
+}
+
+// Checks whether the preferences match any of the roles a case needs.
+func (p CasePrefs) Wants(need CasePrefs) bool {
+	return (p.Def && need.Def) || (p.Pro && need.Pro) || (p.Judge && need.Judge) ||
+		(p.Jury && need.Jury) || (p.Steno && need.Steno)
+}
+
 // Makes a new client over a TCP connection. The client will log to the specified logger.
 func NewTCPClient(conn net.Conn, log *logger.Logger) *Client {
 	ipid := hashIP(conn.RemoteAddr())
@@ -92,9 +170,11 @@ func NewTCPClient(conn net.Conn, log *logger.Logger) *Client {
 		addr:       conn.RemoteAddr().String(),
 		clientType: AOClient,
 		ipid:       ipid,
+		oldIPID:    legacyHashIP(conn.RemoteAddr()),
 		uid:        uid.Unjoined,
 		cid:        room.SpectatorCID,
 		pair:       PairData{WantedCID: -1},
+		tasks:      make(chan func(), 1),
 		logger:     log,
 	}
 
@@ -104,6 +184,7 @@ func NewTCPClient(conn net.Conn, log *logger.Logger) *Client {
 	split := splitAt('%')
 	scanner.Split(split)
 	client.tcpScanner = scanner
+	client.startWriter()
 
 	return client
 }
@@ -115,15 +196,25 @@ func NewWSClient(conn *websocket.Conn, log *logger.Logger) *Client {
     conn.SetReadLimit(64 << 10)
 
 	ipid := hashIP(conn.RemoteAddr())
-	return &Client{
-		wsConn: conn,
-		addr:   conn.RemoteAddr().String(),
-		ipid:   ipid,
-		uid:    uid.Unjoined,
-		cid:    room.SpectatorCID,
-		pair:   PairData{WantedCID: -1},
-		logger: log,
+	client := &Client{
+		wsConn:  conn,
+		addr:    conn.RemoteAddr().String(),
+		ipid:    ipid,
+		oldIPID: legacyHashIP(conn.RemoteAddr()),
+		uid:     uid.Unjoined,
+		cid:     room.SpectatorCID,
+		pair:    PairData{WantedCID: -1},
+		tasks:   make(chan func(), 1),
+		logger:  log,
 	}
+	// Pongs count as signs of life, even if the client has nothing to say.
+	conn.SetPongHandler(func(string) error {
+		client.extendDeadline()
+		client.recordPong()
+		return nil
+	})
+	client.startWriter()
+	return client
 }
 
 // Returns whether the client is connected via WebSocket.
@@ -133,6 +224,7 @@ func (c *Client) IsWS() bool {
 
 // Reads a WebSocket message.
 func (c *Client) ReadWS() ([]byte, error) {
+	c.extendDeadline()
 	_, b, err := c.wsConn.ReadMessage()
 	return b, err
 }
@@ -141,6 +233,7 @@ func (c *Client) ReadWS() ([]byte, error) {
 
 // Waits for the next message from the client and interprets it as an AO packet.
 func (c *Client) ReadAO() (*packets.PacketAO, error) {
+	c.extendDeadline()
 	if c.IsWS() {
 		_, b, err := c.wsConn.ReadMessage()
 		if err != nil {
@@ -161,6 +254,7 @@ func (c *Client) ReadAO() (*packets.PacketAO, error) {
 // Waits for the next message from the client and interprets it as a SpriteChat packet.
 func (c *Client) ReadSC() (*packets.PacketSC, error) {
 	var p packets.PacketSC
+	c.extendDeadline()
 	err := c.wsConn.ReadJSON(&p)
 	if err != nil {
 		return nil, err
@@ -177,10 +271,15 @@ func (c *Client) WriteAO(header string, contents ...string) {
 	c.WriteAOPacket(p)
 }
 
-// Writes an AO packet to the client.
+// Writes an AO packet to the client. The packet's contents are not modified, so the same
+// packet can be written to many clients.
 func (c *Client) WriteAOPacket(pkt packets.PacketAO) {
-	pkt.Encode()
-	c.writef("%s#%s#%%", pkt.Header, strings.Join(pkt.Contents, "#"))
+	c.write(pkt.Encoded())
+}
+
+// Writes an already encoded AO packet to the client.
+func (c *Client) WriteAORaw(raw string) {
+	c.write(raw)
 }
 
 // Creates and writes a SC packet to the client.
@@ -189,12 +288,12 @@ func (c *Client) WriteSC(header string, data interface{}) {
 		"header": header,
 		"data":   data,
 	}
-	if err := c.wsConn.WriteJSON(mesg); err != nil {
-		c.logger.Tracef("Couldn't write JSON to %v (IPID: %v) (%v).", c.addr, c.ipid, err)
+	b, err := json.Marshal(mesg)
+	if err != nil {
+		c.logger.Tracef("Couldn't encode JSON for %v (IPID: %v) (%v).", c.addr, c.ipid, err)
 		return
 	}
-	b, _ := json.MarshalIndent(mesg, "", "  ") // cannot fail if we got here
-	c.logger.Tracef("Sent to %v (IPID: %v) via WS: %s.\n", c.addr, c.ipid, b)
+	c.write(string(b))
 }
 
 // Writes a SC packet to the client.
@@ -202,14 +301,87 @@ func (c *Client) WriteSCPacket(pkt packets.PacketSC) {
 	c.WriteSC(pkt.Header, pkt.Data)
 }
 
-// Disconnects the client.
+// Sets the close code and reason sent to the client when it's disconnected, if it's
+// connected via WebSocket. By default, the code is [CloseNormal] with no reason.
+func (c *Client) SetCloseReason(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeCode = code
+	c.closeReason = reason
+}
+
+// Marks the client as removed from the server, returning whether it already was. The
+// server's cleanup of a client can be reached from several goroutines at once (e.g. a
+// kick racing the connection closing), and only the first one to mark it runs it.
+func (c *Client) MarkRemoved() bool {
+	return c.removed.Swap(true)
+}
+
+// Hands a function to the client's read loop, which runs it in between the client's
+// packets. For work on behalf of the client that is triggered from elsewhere, but must
+// be ordered with its packets (e.g. resuming its handshake). Does nothing if the client
+// is disconnecting.
+func (c *Client) Post(f func()) {
+	select {
+	case c.tasks <- f:
+	case <-c.closing:
+	}
+}
+
+// Returns the functions handed to the client's read loop with [Client.Post].
+func (c *Client) Tasks() <-chan func() {
+	return c.tasks
+}
+
+// Disconnects the client, after flushing its write queue (for a short while at most).
+// WebSocket clients are sent a close frame with the code set by [Client.SetCloseReason].
+// Nothing more is queued once it's called, but the flushing and closing happen in the
+// background, so a slow client doesn't hold up the caller. [Client.Done] tells when
+// they're over.
 func (c *Client) Disconnect() {
+	c.discOnce.Do(func() {
+		c.closeOnce.Do(func() { close(c.closing) })
+		go c.disconnect()
+	})
+}
+
+// Returns a channel that's closed once the client's connection is closed, after
+// [Client.Disconnect].
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) disconnect() {
+	defer close(c.done)
+	select {
+	case <-c.flushed:
+	case <-time.After(flushTimeout):
+	}
+	if c.wsConn != nil && !c.evicted.Load() {
+		c.mu.Lock()
+		code, reason := c.closeCode, c.closeReason
+		c.mu.Unlock()
+		if code == 0 {
+			code = CloseNormal
+		}
+		// Control frames can't have more than 125 bytes of payload, two of which are the code.
+		if len(reason) > 123 {
+			reason = strings.ToValidUTF8(reason[:123], "")
+		}
+		mesg := websocket.FormatCloseMessage(code, reason)
+		if err := c.wsConn.WriteControl(websocket.CloseMessage, mesg, time.Now().Add(time.Second)); err != nil {
+			c.logger.Debugf("Couldn't send close frame to %v (IPID: %v) (%v).", c.addr, c.ipid, err)
+		}
+	}
+	c.closeConn()
+}
+
+func (c *Client) closeConn() {
 	if c.tcpConn != nil {
 		c.logger.Debugf("%v (IPID: %v) disconnected (TCP).", c.addr, c.ipid)
 		c.tcpConn.Close()
 	}
 	if c.wsConn != nil {
-		// TODO: deal with close types
 		c.logger.Debugf("%v (IPID: %v) disconnected (WS).", c.addr, c.ipid)
 		c.wsConn.Close()
 	}
@@ -232,15 +404,15 @@ func (c *Client) SendOOCMessage(name string, msg string, server bool) {
 	}
 }
 
-// Attempts a character change to the passed CID.
-func (c *Client) ChangeChar(cid int) {
-	if !c.Room().ChangeChar(c.uid, cid) {
+// Attempts a character change to the passed CID. Returns whether it succeeded.
+func (c *Client) ChangeChar(cid int) (ok bool) {
+	if !c.Room().ChangeChar(c.uid, cid, c.EffectivePerms()) {
 		c.Room().LogEvent(room.EventFail, "%s failed to change characters to %s (%v).", c.LongString(),
 			c.Room().GetNameByCID(cid), cid)
-		return
+		return false
 	}
 	if cid == c.CID() {
-		return
+		return true
 	}
 
     charname := c.Room().GetNameByCID(cid)
@@ -258,6 +430,40 @@ func (c *Client) ChangeChar(cid int) {
 	case SCClient:
 		// TODO
 	}
+	return true
+}
+
+// Updates the client's character after its CID was changed by the room, e.g. because
+// the room's character list changed, and tells the client about it.
+func (c *Client) RefreshChar(cid int) {
+	c.SetCID(cid)
+	c.SetCharname(c.Room().GetNameByCID(cid))
+	switch c.clientType {
+	case AOClient:
+		if cid == room.SpectatorCID {
+			// AO shows the character select screen when it receives DONE.
+			c.WriteAO("DONE")
+		} else {
+			c.WriteAO("PV", "OBSOLETE", "CID", strconv.Itoa(cid))
+		}
+	case SCClient:
+		// TODO
+	}
+}
+
+// Sends the client back to the character select screen, as a spectator.
+func (c *Client) CharSelect() (ok bool) {
+	if !c.ChangeChar(room.SpectatorCID) {
+		return false
+	}
+	switch c.clientType {
+	case AOClient:
+		// AO shows the character select screen when it receives DONE.
+		c.WriteAO("DONE")
+	case SCClient:
+		// TODO
+	}
+	return true
 }
 
 // Sends the client a pop-up.
@@ -272,57 +478,27 @@ func (c *Client) Notify(msg string) {
 
 // Sends ARUPs to the client according to the input.
 func (c *Client) SendRoomUpdateAO(up packets.AreaUpdate) {
-	var players []string
-	var statuses []string
-	var cms []string
-	var locks []string
-
 	// We update this client's room, and all the adjacent ones.
-	vis := c.Room().Visible()
-
-	// Only allocate as necessary.
-	if up&packets.UpdatePlayer != 0 {
-		players = make([]string, len(vis))
-	}
-	if up&packets.UpdateStatus != 0 {
-		statuses = make([]string, len(vis))
-	}
-	if up&packets.UpdateManager != 0 {
-		cms = make([]string, len(vis))
-	}
-	if up&packets.UpdateLock != 0 {
-		locks = make([]string, len(vis))
+	for _, p := range room.AreaUpdateAO(c.Room().Visible(), up) {
+		c.WriteAOPacket(p)
 	}
+}
 
-	for i, r := range vis {
-		// Branch prediction will optimize this for us, I hope.
-		if up&packets.UpdatePlayer != 0 {
-			players[i] = strconv.Itoa(r.PlayerCount())
-		}
-		if up&packets.UpdateStatus != 0 {
-			statuses[i] = r.Status()
-		}
-		if up&packets.UpdateManager != 0 {
-			// TODO: CMs
-			cms[i] = "FREE"
-		}
-		if up&packets.UpdateLock != 0 {
-			locks[i] = r.LockString()
-		}
-	}
-	// TODO: spritechat
-	if up&packets.UpdatePlayer != 0 {
-		c.WriteAO("ARUP#0", players...)
-	}
-	if up&packets.UpdateStatus != 0 {
-		c.WriteAO("ARUP#1", statuses...)
+// Sends the SpriteChat equivalent of ARUPs to the client according to the input. Only the
+// fields in `up` are sent, and only for the passed rooms that the client can see. If no rooms
+// are passed, all rooms visible to the client are sent.
+func (c *Client) SendRoomUpdateSC(up packets.AreaUpdate, rooms ...*room.Room) {
+	if c.Room() == nil {
+		return
 	}
-	if up&packets.UpdateManager != 0 {
-		c.WriteAO("ARUP#2", cms...)
+	vis := c.Room().Visible()
+	if len(rooms) > 0 {
+		vis = room.Intersect(vis, rooms)
 	}
-	if up&packets.UpdateLock != 0 {
-		c.WriteAO("ARUP#3", locks...)
+	if len(vis) == 0 {
+		return
 	}
+	c.WriteSC("ROOMUPDATE", room.AreaUpdateSC(vis, up))
 }
 
 // Notifies a client that it has been kicked, along with the reason.
@@ -336,6 +512,16 @@ func (c *Client) NotifyKick(reason string) {
 	}
 }
 
+// Notifies the client that it has been banned.
+func (c *Client) NotifyBan(reason string) {
+	switch c.clientType {
+	case AOClient:
+		c.WriteAO("KB", reason)
+	case SCClient:
+		// TODO
+	}
+}
+
 // Adds the guard button on the client (AO-only?).
 func (c *Client) AddGuard() {
 	switch c.clientType {
@@ -346,6 +532,38 @@ func (c *Client) AddGuard() {
 	}
 }
 
+// Removes the guard button from the client, e.g. after logging out (AO-only?).
+func (c *Client) RemoveGuard() {
+	switch c.clientType {
+	case AOClient:
+		c.WriteAO("AUTH", "-1")
+	case SCClient:
+		// no-op?
+	}
+}
+
+// Tells the client its login attempt failed, so its login dialog can say so. Only AO
+// clients have a login dialog; SC clients just get the reply to their login.
+func (c *Client) NotifyLoginFailed() {
+	switch c.clientType {
+	case AOClient:
+		c.WriteAO("AUTH", "0")
+	}
+}
+
+// Returns the username the client entered in the login prompt, waiting for a password.
+func (c *Client) PendingLogin() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pendingLogin
+}
+
+func (c *Client) SetPendingLogin(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pendingLogin = username
+}
+
 // Sends the client a mod call packet.
 func (c *Client) ModCall(msg string) {
 	switch c.clientType {
@@ -360,7 +578,7 @@ func (c *Client) ModCall(msg string) {
 func (c *Client) UpdateCharList() {
 	switch c.Type() {
 	case AOClient:
-		c.WriteAO("SC", c.Room().Chars()...)
+		c.WriteAORaw(c.Room().CharListAO())
 		c.WriteAO("CharsCheck", c.Room().TakenList()...)
 	case SCClient:
 		// TODO
@@ -371,7 +589,7 @@ func (c *Client) UpdateCharList() {
 func (c *Client) UpdateMusicList() {
 	switch c.Type() {
 	case AOClient:
-		c.WriteAO("FM", c.Room().MusicList()...)
+		c.WriteAORaw(c.Room().MusicListAO())
 	case SCClient:
 		// TODO
 	}
@@ -381,7 +599,7 @@ func (c *Client) UpdateMusicList() {
 func (c *Client) UpdateRoomList() {
 	switch c.Type() {
 	case AOClient:
-		c.WriteAO("FA", c.Room().VisibleNames()...)
+		c.WriteAORaw(c.Room().RoomListAO())
 	case SCClient:
 		// TODO
 	}
@@ -397,6 +615,16 @@ func (c *Client) UpdateBackground() {
 	}
 }
 
+// Moves the client to its current side, as set with [Client.SetSide].
+func (c *Client) UpdateSide() {
+	switch c.Type() {
+	case AOClient:
+		c.WriteAO("SP", c.Side())
+	case SCClient:
+		// TODO
+	}
+}
+
 // Updates the side list in the client's dropdown.
 func (c *Client) UpdateSides() {
 	switch c.Type() {
@@ -429,7 +657,7 @@ func (c *Client) UpdateSong() {
 			c.Room().Name(),                 // Showname. We're using the room's name.
 			"1",                             // Loop
 			"0",                             // Channel 0 (default for BGM).
-			strconv.Itoa(int(packets.EffectFadeIn|packets.EffectFadeOut))) // Fade in and fade out.
+			strconv.Itoa(int(packets.EffectDefault))) // Fade in and fade out.
 	case SCClient:
 		// TODO
 	}
@@ -445,7 +673,7 @@ func (c *Client) UpdateAmbiance() {
 			c.Room().Name(),                 // Showname. We're using the room's name.
 			"1",                             // Loop
 			"1",                             // Channel 1 (default for Ambiance).
-			strconv.Itoa(int(packets.EffectFadeIn|packets.EffectFadeOut))) // Fade in and fade out.
+			strconv.Itoa(int(packets.EffectDefault))) // Fade in and fade out.
 	case SCClient:
 		// TODO
 	}
@@ -504,9 +732,18 @@ func (c *Client) Iniswapping() bool {
 	return c.Charname() != c.Room().GetNameByCID(c.CID())
 }
 
-// Returns whether the client satisfies the passed permission mask.
+// Returns whether the client satisfies the passed permission mask, taking into account
+// the permission overrides of the room it is in.
 func (c *Client) HasPerms(p perms.Mask) bool {
-	return c.Perms()&p == p
+	return c.EffectivePerms()&p == p
+}
+
+// Returns the client's permissions with the overrides of the room it is in applied.
+func (c *Client) EffectivePerms() perms.Mask {
+	if c.Room() == nil {
+		return c.Perms()
+	}
+	return c.Room().ApplyPerms(c.Perms())
 }
 
 func (c *Client) Addr() string {
@@ -534,6 +771,13 @@ func (c *Client) IPID() string {
 	return c.ipid
 }
 
+// Returns the IPID the client would have under the legacy (unsalted) hashing scheme.
+func (c *Client) LegacyIPID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.oldIPID
+}
+
 func (c *Client) UID() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -582,6 +826,19 @@ func (c *Client) SetPerms(p perms.Mask) {
 	c.perms = p
 }
 
+// Returns the username the client is logged in as, or an empty string if it isn't.
+func (c *Client) Login() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.login
+}
+
+func (c *Client) SetLogin(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.login = username
+}
+
 func (c *Client) Room() *room.Room {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -618,6 +875,40 @@ func (c *Client) SetShowname(name string) {
 	c.showname = name
 }
 
+// Returns the language the client wants server messages in, or "" for the server's default.
+func (c *Client) Language() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.language
+}
+
+func (c *Client) SetLanguage(lang string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.language = lang
+}
+
+// Returns the client's session token, or "" if it has none.
+func (c *Client) Session() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+func (c *Client) SetSession(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = token
+}
+
+// Returns the close code set with [Client.SetCloseReason], or 0 if none was set,
+// e.g. because the client dropped the connection itself.
+func (c *Client) CloseCode() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeCode
+}
+
 func (c *Client) Username() string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -664,6 +955,7 @@ func (c *Client) SetMute(m MuteState) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.mute = m
+	c.forgetMuteEnds()
 }
 
 func (c *Client) AddMute(m MuteState) {
@@ -676,6 +968,7 @@ func (c *Client) RemoveMute(m MuteState) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.mute &= ^m
+	c.forgetMuteEnds()
 }
 
 func (c *Client) LastMsg() string {
@@ -690,6 +983,89 @@ func (c *Client) SetLastMsg(msg string) {
 	c.lastMsg = msg
 }
 
+// Counts how many IC messages in a row had the passed key (e.g. the preanim played),
+// including this one. An empty key never counts as a repeat.
+func (c *Client) CountRepeat(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key == "" || key != c.repeatKey {
+		c.repeatKey, c.repeats = key, 0
+	}
+	c.repeats++
+	return c.repeats
+}
+
+// A message sent by the client, kept for spam detection.
+type SentMessage struct {
+	Text string
+	Time time.Time
+	OOC  bool
+}
+
+// Records a message sent by the client, then returns the messages it sent within the
+// window, including this one, from oldest to newest.
+func (c *Client) RecordMessage(msg SentMessage, window time.Duration) []SentMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := 0
+	for i < len(c.history) && msg.Time.Sub(c.history[i].Time) >= window {
+		i++
+	}
+	c.history = append(c.history[i:], msg)
+	return slices.Clone(c.history)
+}
+
+// Adds a warning for spamming, returning how many the client has.
+func (c *Client) AddSpamWarning() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spamWarnings++
+	return c.spamWarnings
+}
+
+// Forgets the client's spam warnings.
+func (c *Client) ClearSpamWarnings() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spamWarnings = 0
+}
+
+func (c *Client) LastModCall() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastCall
+}
+
+func (c *Client) SetLastModCall(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCall = t
+}
+
+func (c *Client) LastCaseAlert() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastCase
+}
+
+func (c *Client) SetLastCaseAlert(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCase = t
+}
+
+func (c *Client) LastMove() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastMove
+}
+
+func (c *Client) SetLastMove(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastMove = t
+}
+
 func (c *Client) PairData() PairData {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -702,32 +1078,15 @@ func (c *Client) SetPairData(pd PairData) {
 	c.pair = pd
 }
 
-func (c *Client) write(mesg string) {
+func (c *Client) CasePrefs() CasePrefs {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.wsConn == nil {
-		if _, err := fmt.Fprint(c.tcpConn, mesg); err != nil {
-			c.logger.Debugf("Failed to write message to %v (IPID: %v) via TCP (%v). Message: %s.", c.addr, c.ipid, err, mesg)
-			return
-		}
-		c.logger.Tracef("Sent message to %v (IPID: %v) via TCP: %s", c.addr, c.ipid, mesg)
-		return
-	}
-
-	w, err := c.wsConn.NextWriter(websocket.TextMessage)
-	if err != nil {
-		c.logger.Debugf("Failed to write message to %v (IPID: %v) via WS (%v). Message: %s.", c.addr, c.ipid, err, mesg)
-		return
-	}
-	defer w.Close()
-
-	if _, err := fmt.Fprint(w, mesg); err != nil {
-		c.logger.Debugf("Failed to write message to %v (IPID: %v) via WS (%v). Message: %s.", c.addr, c.ipid, err, mesg)
-		return
-	}
-	c.logger.Tracef("Sent message to %v (IPID: %v) via WS: %s", c.addr, c.ipid, mesg)
+	return c.casePrefs
 }
 
-func (c *Client) writef(format string, args ...any) {
-	c.write(fmt.Sprintf(format, args...))
+func (c *Client) SetCasePrefs(p CasePrefs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.casePrefs = p
 }
+
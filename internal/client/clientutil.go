@@ -6,14 +6,54 @@ import (
 	"io"
 	"net"
 
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
 )
 
+// The salt and length used when hashing IPs into IPIDs. Set through [SetIPIDHashing].
+var (
+	ipidSalt   []byte
+	ipidLength = 6
+)
+
+// Sets the server-specific salt and the length of the IPIDs given to clients.
+// Should be called once, before any clients are made. If the salt is empty,
+// the legacy (unsalted) hash is used.
+func SetIPIDHashing(salt []byte, length int) {
+	ipidSalt = salt
+	ipidLength = length
+}
+
+// Returns the IPID of the IP, as it would be for a client connecting from it.
+func IPIDOf(ip net.IP) string {
+	return hashIP(&net.TCPAddr{IP: ip})
+}
+
 // Gives the "IPID" hash for the address. The purpose of this is so
 // clients' IPs aren't leaked to moderators. It intends to be a unique identifier
 // for each IP.
+// The IP is hashed with HMAC-SHA256 keyed with the server's salt, so the same IP
+// has different IPIDs in different servers.
 func hashIP(addr net.Addr) string {
+	if len(ipidSalt) == 0 {
+		return legacyHashIP(addr)
+	}
+	// We only accept TCP connections, so this is safe.
+	ip := addr.(*net.TCPAddr).IP.String()
+
+	h := hmac.New(sha256.New, ipidSalt)
+	io.WriteString(h, ip)
+	enc := base64.RawStdEncoding.EncodeToString(h.Sum(nil))
+	// Each base64 character is 6 bits, so e.g. the 8 characters `ipid_length` defaults
+	// to in the config give us 48 bits.
+	return enc[len(enc)-min(max(ipidLength, 1), len(enc)):]
+}
+
+// Gives the IPID the address had before IPIDs were salted. Used to migrate
+// bans and such made with the old IPIDs.
+func legacyHashIP(addr net.Addr) string {
 	// We only accept TCP connections, so this is safe.
 	ip := addr.(*net.TCPAddr).IP.String()
 
@@ -0,0 +1,31 @@
+package client
+
+// Steps a client has to take after joining before it can pick a character and play.
+type Gate int
+
+const (
+	GatePassword Gate = 1 << iota // entering the server's join password
+	GateRules                     // agreeing to the rules
+	GateRaid                      // logging in or entering the raid password, in raid mode
+)
+
+// Returns the steps the client still has to take.
+func (c *Client) Gates() Gate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gates
+}
+
+func (c *Client) SetGates(g Gate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gates = g
+}
+
+// Marks the step as taken. Returns the steps that are left.
+func (c *Client) PassGate(g Gate) Gate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gates &^= g
+	return c.gates
+}
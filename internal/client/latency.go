@@ -0,0 +1,34 @@
+package client
+
+import "time"
+
+// Measures the round-trip time of the last ping, when its pong arrives.
+func (c *Client) recordPong() {
+	sent := c.pingSent.Load()
+	if sent == 0 {
+		return
+	}
+	c.rtt.Store(time.Now().UnixNano() - sent)
+}
+
+// Returns the round-trip time measured with the last WebSocket ping. Legacy TCP
+// clients can't be pinged, so for them (and for WebSocket clients that haven't
+// answered a ping yet) `ok` is false.
+func (c *Client) RTT() (rtt time.Duration, ok bool) {
+	n := c.rtt.Load()
+	return time.Duration(n), n > 0
+}
+
+// Records that the client sent a keepalive (in AO, the CH packet).
+func (c *Client) MarkKeepalive() {
+	c.keepalive.Store(time.Now().UnixNano())
+}
+
+// Returns when the client last sent a keepalive, or the zero time if it never did.
+func (c *Client) LastKeepalive() time.Time {
+	n := c.keepalive.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,60 @@
+package client
+
+import "time"
+
+// Mutes the client until `end`, or until it's unmuted if `end` is zero.
+func (c *Client) MuteUntil(m MuteState, end time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mute |= m
+	if c.muteEnds == nil {
+		c.muteEnds = make(map[MuteState]time.Time)
+	}
+	for bit := MutedIC; bit <= MutedShadow; bit <<= 1 {
+		switch {
+		case m&bit == 0:
+		case end.IsZero():
+			delete(c.muteEnds, bit)
+		default:
+			c.muteEnds[bit] = end
+		}
+	}
+}
+
+// Lifts the client's timed mutes that have ended by `now`. Returns the lifted mutes.
+func (c *Client) ExpireMutes(now time.Time) MuteState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lifted MuteState
+	for bit, end := range c.muteEnds {
+		if !now.Before(end) {
+			lifted |= bit
+			delete(c.muteEnds, bit)
+		}
+	}
+	c.mute &^= lifted
+	return lifted
+}
+
+// Returns when each of the client's current mutes ends, by kind. Mutes that last until
+// the client is unmuted have a zero time.
+func (c *Client) MuteEnds() map[MuteState]time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ends := make(map[MuteState]time.Time)
+	for bit := MutedIC; bit <= MutedShadow; bit <<= 1 {
+		if c.mute&bit != 0 {
+			ends[bit] = c.muteEnds[bit]
+		}
+	}
+	return ends
+}
+
+// Forgets the ends of the mutes that were lifted. Should be called with the lock held.
+func (c *Client) forgetMuteEnds() {
+	for bit := range c.muteEnds {
+		if c.mute&bit == 0 {
+			delete(c.muteEnds, bit)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// How long a single write may take before it's considered failed.
+	writeTimeout = 10 * time.Second
+	// How long a disconnect waits for the queued messages to be flushed.
+	flushTimeout = 2 * time.Second
+)
+
+// How many outgoing messages can be queued for a client. Set through [SetWriteQueueSize].
+var writeQueueSize = 256
+
+// Sets the size of the clients' write queues. A client whose queue overflows is evicted.
+// Should be called once, before any clients are made.
+func SetWriteQueueSize(size int) {
+	writeQueueSize = max(size, 1)
+}
+
+// Makes the client's write queue and starts its writer.
+func (c *Client) startWriter() {
+	c.out = make(chan string, writeQueueSize)
+	c.closing = make(chan struct{})
+	c.flushed = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.writeLoop()
+}
+
+// Writes queued messages to the connection until the client is closed. WebSocket
+// clients are also pinged from here, so the pings don't race with other writes.
+func (c *Client) writeLoop() {
+	defer close(c.flushed)
+	var ping <-chan time.Time
+	if c.wsConn != nil && pingInterval > 0 {
+		t := time.NewTicker(pingInterval)
+		defer t.Stop()
+		ping = t.C
+	}
+	for {
+		select {
+		case mesg := <-c.out:
+			c.send(mesg)
+		case <-ping:
+			c.pingSent.Store(time.Now().UnixNano())
+			if err := c.wsConn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				c.logger.Debugf("Failed to ping %v (IPID: %v) (%v).", c.addr, c.ipid, err)
+			}
+		case <-c.closing:
+			// Flush whatever is left before the connection is closed.
+			for {
+				select {
+				case mesg := <-c.out:
+					c.send(mesg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Queues a message to be written to the client. If the queue is full, the client
+// isn't keeping up, so it's evicted rather than blocking whoever is writing to it.
+func (c *Client) write(mesg string) {
+	select {
+	case <-c.closing:
+		return
+	default:
+	}
+	select {
+	case c.out <- mesg:
+	default:
+		c.evict()
+	}
+}
+
+func (c *Client) writef(format string, args ...any) {
+	c.write(fmt.Sprintf(format, args...))
+}
+
+// Writes a message to the connection. Only called by the writer.
+func (c *Client) send(mesg string) {
+	if c.wsConn == nil {
+		c.tcpConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprint(c.tcpConn, mesg); err != nil {
+			c.logger.Debugf("Failed to write message to %v (IPID: %v) via TCP (%v). Message: %s.", c.addr, c.ipid, err, mesg)
+			return
+		}
+		c.logger.Tracef("Sent message to %v (IPID: %v) via TCP: %s", c.addr, c.ipid, mesg)
+		return
+	}
+
+	c.wsConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.wsConn.WriteMessage(websocket.TextMessage, []byte(mesg)); err != nil {
+		c.logger.Debugf("Failed to write message to %v (IPID: %v) via WS (%v). Message: %s.", c.addr, c.ipid, err, mesg)
+		return
+	}
+	c.logger.Tracef("Sent message to %v (IPID: %v) via WS: %s", c.addr, c.ipid, mesg)
+}
+
+// Evicts the client for not keeping up with its queue. The connection is closed
+// right away, which makes the read loop end and the server clean the client up.
+func (c *Client) evict() {
+	if c.evicted.Swap(true) {
+		return
+	}
+	c.logger.Infof("Evicting %v (IPID: %v): write queue is full (%v messages).", c.addr, c.ipid, writeQueueSize)
+	c.closeOnce.Do(func() { close(c.closing) })
+	c.closeConn()
+}
+
+// Returns how many messages are waiting to be written to the client.
+func (c *Client) QueueLen() int {
+	return len(c.out)
+}
+
+// Returns whether the client was evicted for overflowing its write queue.
+func (c *Client) Evicted() bool {
+	return c.evicted.Load()
+}
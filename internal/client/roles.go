@@ -0,0 +1,75 @@
+package client
+
+import "github.com/lambdcalculus/scs/internal/perms"
+
+// The places a client's roles can come from. A client holds at most one role from
+// each, and its permissions are the union of all of them.
+type RoleSlot int
+
+const (
+	RoleBase    RoleSlot = iota // granted to the client's IPID, e.g. with /promote
+	RoleAuth                    // from logging in
+	RoleManager                 // from managing a room
+	numRoleSlots
+)
+
+func (s RoleSlot) String() string {
+	switch s {
+	case RoleBase:
+		return "granted"
+	case RoleAuth:
+		return "login"
+	case RoleManager:
+		return "room manager"
+	}
+	return "unknown"
+}
+
+// Puts the role in the slot, replacing whatever was there, and recomputes the
+// client's permissions.
+func (c *Client) AddRole(slot RoleSlot, r *perms.Role) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roles[slot] = r
+	c.recomputePerms()
+}
+
+// Empties the slot and recomputes the client's permissions. Returns the role that
+// was in it, or `nil` if there was none.
+func (c *Client) RemoveRole(slot RoleSlot) *perms.Role {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.roles[slot]
+	c.roles[slot] = nil
+	c.recomputePerms()
+	return r
+}
+
+// Returns the role in the slot, or `nil` if there is none.
+func (c *Client) Role(slot RoleSlot) *perms.Role {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.roles[slot]
+}
+
+// Calls `f` for every role the client holds, in slot order.
+func (c *Client) Roles(f func(slot RoleSlot, r *perms.Role)) {
+	c.mu.Lock()
+	roles := c.roles
+	c.mu.Unlock()
+	for slot, r := range roles {
+		if r != nil {
+			f(RoleSlot(slot), r)
+		}
+	}
+}
+
+// Should be called with the lock held.
+func (c *Client) recomputePerms() {
+	c.perms = perms.None
+	for _, r := range c.roles {
+		if r != nil {
+			c.perms |= r.Perms
+		}
+	}
+}
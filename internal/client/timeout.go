@@ -0,0 +1,41 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// How long a client can go without sending anything before it's considered dead,
+// and how often WebSocket clients are pinged. Set through [SetTimeouts].
+var (
+	readTimeout  = 90 * time.Second
+	pingInterval = 30 * time.Second
+)
+
+// Sets the read timeout and the WebSocket ping interval. A zero value disables
+// the respective feature. Should be called once, before any clients are made.
+func SetTimeouts(read time.Duration, ping time.Duration) {
+	readTimeout = read
+	pingInterval = ping
+}
+
+// Pushes the read deadline forward, since the client has shown signs of life.
+func (c *Client) extendDeadline() {
+	if readTimeout <= 0 {
+		return
+	}
+	deadline := time.Now().Add(readTimeout)
+	if c.wsConn != nil {
+		c.wsConn.SetReadDeadline(deadline)
+	} else {
+		c.tcpConn.SetReadDeadline(deadline)
+	}
+}
+
+// Returns whether a read error happened because the client stopped responding.
+func IsTimeout(err error) bool {
+	// The WebSocket library hides the original error, but keeps whether it was a timeout.
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A client version, as in "2.10.1". Missing parts are 0.
+type Version [3]int
+
+// Parses a version in the "major.minor.patch" format. Anything after the numbers,
+// e.g. "-rc1", is ignored.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	parts := strings.SplitN(s, ".", 3)
+	for i, p := range parts {
+		// Keep only the leading digits.
+		end := strings.IndexFunc(p, func(r rune) bool { return r < '0' || r > '9' })
+		if end == 0 {
+			return Version{}, fmt.Errorf("client: Invalid version '%v'.", s)
+		}
+		if end > 0 {
+			p = p[:end]
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("client: Invalid version '%v' (%w).", s, err)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// Returns whether the version is older than `other`.
+func (v Version) Less(other Version) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%v.%v.%v", v[0], v[1], v[2])
+}
+
+// Sets the client software's name and version, as reported by the client.
+// If the version can't be parsed, it is considered unknown.
+func (c *Client) SetSoftware(name string, version string) {
+	v, err := ParseVersion(version)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.software = name
+	c.version = v
+	c.knownVer = err == nil
+}
+
+// Returns the name of the client software, e.g. "AO2" or "webAO".
+func (c *Client) Software() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.software
+}
+
+// Returns the client software's version. If it isn't known, `ok` is `false`.
+func (c *Client) Version() (v Version, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version, c.knownVer
+}
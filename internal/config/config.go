@@ -1,11 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 
-	"github.com/BurntSushi/toml"
 	"github.com/lambdcalculus/scs/pkg/logger"
 )
 
@@ -18,29 +20,242 @@ type Server struct {
 	PortTCP    int    `toml:"legacy_port"`
 	PortRPC    int    `toml:"rpc_port"`
 	AllowAO    bool   `toml:"allow_ao"`
+	AllowSC    bool   `toml:"allow_sc"`
 	AssetURL   string `toml:"asset_url"`
-	//TODO: AllowAO bool `toml:"allow_ao"`
+	MOTD       string `toml:"motd"`
+	Rules      string `toml:"rules"`
+	Language   string `toml:"language"`
+
+	LandingRoom  string `toml:"landing_room"` // the name of the room clients join in, "" for the first one
+	ModCallRoom  string `toml:"modcall_room"` // the name of a room mod calls are also announced in
+	JoinPassword string `toml:"join_password"`
+	RequireAgree bool   `toml:"require_agree"` // whether the rules must be agreed to before picking a character
+
+	ReservedSlots int      `toml:"reserved_slots"` // slots on top of MaxPlayers, only for staff
+	ReservedIPIDs []string `toml:"reserved_ipids"`
+	WaitQueue     int      `toml:"wait_queue"` // how many clients can wait for a slot, 0 disables the queue
+
+	TranscriptURL string `toml:"transcript_url"` // if set, transcripts are served through the WS port
 
 	// these seem more appropriate for a different section?
 	MaxMsgSize  int `toml:"max_msg_size"`
 	MaxNameSize int `toml:"max_name_size"`
 
+	ModCallCooldown  int  `toml:"modcall_cooldown"` // in seconds
+	EvasionAlertDays int  `toml:"evasion_alert_days"`
+	IPIDLength       int  `toml:"ipid_length"`
+	WriteQueueSize   int  `toml:"write_queue_size"`
+	BroadcastWorkers int  `toml:"broadcast_workers"` // 0 means one per CPU
+	ReadTimeout      int  `toml:"read_timeout"`      // in seconds
+	PingInterval     int  `toml:"ping_interval"`     // in seconds
+	MaxSearchResults int  `toml:"max_search_results"`
+	AdjacentOnly     bool `toml:"adjacent_only"`
+	MoveCooldown     int  `toml:"move_cooldown"` // in seconds
+	Scripts          bool `toml:"scripts"`
+	LoginMaxFailures int  `toml:"login_max_failures"`
+	LoginLockout     int  `toml:"login_lockout"`   // in minutes
+	ResumeGrace      int  `toml:"resume_grace"`    // in seconds
+	WarnKickAfter    int  `toml:"warn_kick_after"` // 0 means never
+
+	ManagerPerms []string `toml:"manager_permissions"` // having any of these makes a user a room manager
+
 	LevelString string `toml:"log_level"`
+
+	Webhooks  Webhooks  `toml:"webhooks"`
+	Geo       Geo       `toml:"geo"`
+	Clients   Clients   `toml:"clients"`
+	Schedule  []Task    `toml:"schedule"`
+	Welcome   Welcome   `toml:"welcome"`
+	Spam      Spam      `toml:"spam"`
+	Raid      Raid      `toml:"raid"`
+	Sanitize  Sanitize  `toml:"sanitize"`
+	Templates Templates `toml:"templates"`
+	Durations Durations `toml:"durations"`
+	Retention Retention `toml:"retention"`
+	Admin     Admin     `toml:"admin"`
+	API       API       `toml:"api"`
+}
+
+// Settings for the read-only JSON API.
+type API struct {
+	Token string `toml:"token"` // empty disables the API
+}
+
+// Settings for the web admin panel.
+type Admin struct {
+	Enabled  bool `toml:"enabled"`
+	Port     int  `toml:"port"`     // 0 means it's served at /admin/ on the WebSocket port
+	Sessions int  `toml:"sessions"` // how long logins last, in hours
+}
+
+// The text of the notices sent to users who are kicked, banned or muted.
+type Templates struct {
+	Kick   string `toml:"kick"`
+	Ban    string `toml:"ban"`
+	Banned string `toml:"banned"` // sent to banned users when they try to join, once per ban
+	Mute   string `toml:"mute"`
+	Appeal string `toml:"appeal"`
+}
+
+// Named durations for bans and mutes, and the ones used when none is given.
+type Durations struct {
+	Presets     map[string]string `toml:"presets"`      // e.g. "short" = "3d"
+	DefaultBan  string            `toml:"default_ban"`  // "" makes the duration required
+	DefaultMute string            `toml:"default_mute"` // "" mutes until unmuted
+}
+
+// How long records are kept in the database, in days. 0 keeps them forever. Records
+// that expire (bans, mutes and role grants) are kept that long after they end.
+type Retention struct {
+	Mutes         int `toml:"mutes"`
+	Bans          int `toml:"bans"`
+	IPBans        int `toml:"ip_bans"`
+	RoleGrants    int `toml:"role_grants"`
+	ModCalls      int `toml:"modcalls"`
+	Warnings      int `toml:"warnings"`
+	LoginFailures int `toml:"login_failures"`
+}
+
+// Settings for cleaning up messages and names.
+type Sanitize struct {
+	Normalize    bool `toml:"normalize"`
+	MaxCombining int  `toml:"max_combining"` // 0 means no limit
+}
+
+// Settings for the protection against repeated IC messages and preanims.
+type Spam struct {
+	MaxRepeats   int     `toml:"max_repeats"` // 0 disables the protection
+	Warnings     int     `toml:"warnings"`
+	MuteDuration int     `toml:"mute_duration"` // in seconds
+	Window       int     `toml:"window"`        // in seconds
+	MaxSimilar   int     `toml:"max_similar"`   // 0 disables the detection
+	Similarity   float64 `toml:"similarity"`
+	MaxCaps      int     `toml:"max_caps"` // 0 disables the detection
+}
+
+// Settings for raid mode, which temporarily restricts who can join and play.
+type Raid struct {
+	Password       string `toml:"password"`        // lets new players play without logging in, "" for none
+	RejectNew      bool   `toml:"reject_new"`      // whether IPIDs never seen before are turned away
+	CooldownFactor int    `toml:"cooldown_factor"` // how much stricter the cooldowns and spam limits get
+}
+
+// Settings for the messages sent to people joining for the first time.
+type Welcome struct {
+	Enabled  bool     `toml:"enabled"`
+	Messages []string `toml:"messages"`
+}
+
+// A recurring action, run either every so often or daily at a set time.
+type Task struct {
+	Action   string `toml:"action"`
+	Every    string `toml:"every"` // e.g. "30m", "12h", "1d"
+	At       string `toml:"at"`    // e.g. "04:00", in the server's local time
+	Message  string `toml:"message"`
+	Warnings []int  `toml:"warnings"` // in seconds before the action
+}
+
+// Settings for which client software is allowed to join.
+type Clients struct {
+	MinVersion       string   `toml:"min_version"`
+	BlockedSoftware  []string `toml:"blocked_software"`
+	DisabledFeatures []string `toml:"disabled_features"`
+	MaxOffsetX       int      `toml:"max_offset_x"` // in percent of the viewport, 0 means no cap
+	MaxOffsetY       int      `toml:"max_offset_y"`
+}
+
+// Settings for the connection policy based on country and ASN.
+type Geo struct {
+	Enabled          bool     `toml:"enabled"`
+	ASNFile          string   `toml:"asn_file"`
+	APIURL           string   `toml:"api_url"`
+	BlockedCountries []string `toml:"blocked_countries"`
+	BlockedASNs      []int    `toml:"blocked_asns"`
+	BlockProxies     bool     `toml:"block_proxies"`
+	Whitelist        []string `toml:"whitelist"` // IPIDs
+}
+
+// Settings for the optional Discord webhook notifier.
+type Webhooks struct {
+	URL       string `toml:"url"`
+	Username  string `toml:"username"`
+	ModCalls  bool   `toml:"modcalls"`
+	Bans      bool   `toml:"bans"`
+	Errors    bool   `toml:"errors"`
+	RateLimit int    `toml:"rate_limit"` // maximum amount of posts per minute
 }
 
 func ServerDefault() *Server {
 	return &Server{
-		Name:        "Unnamed Server",
-		Username:    "SCS",
-		Desc:        "An unconfigured SpriteChat server.",
-		MaxPlayers:  100,
-		PortWS:      8080,
-		PortTCP:     8081,
-		PortRPC:     8082,
-		AssetURL:    "",
-		MaxMsgSize:  150,
-		MaxNameSize: 20,
-		LevelString: "info",
+		Name:             "Unnamed Server",
+		Username:         "SCS",
+		Desc:             "An unconfigured SpriteChat server.",
+		MaxPlayers:       100,
+		PortWS:           8080,
+		PortTCP:          8081,
+		PortRPC:          8082,
+		AllowAO:          true,
+		AllowSC:          true,
+		AssetURL:         "",
+		Language:         "en",
+		ManagerPerms:     []string{"characters"},
+		ModCallCooldown:  60,
+		EvasionAlertDays: 30,
+		IPIDLength:       8,
+		WriteQueueSize:   256,
+		BroadcastWorkers: 0,
+		ReadTimeout:      90,
+		PingInterval:     30,
+		MaxSearchResults: 10,
+		MoveCooldown:     2,
+		LoginMaxFailures: 5,
+		LoginLockout:     15,
+		ResumeGrace:      60,
+		WarnKickAfter:    3,
+		MaxMsgSize:       150,
+		MaxNameSize:      20,
+		LevelString:      "info",
+		Templates: Templates{
+			Kick:   "{reason}",
+			Ban:    "{reason}",
+			Banned: "{reason}. (until: {until})",
+			Mute:   "You have been {kind} muted for {duration} for {reason}.",
+		},
+		Admin: Admin{
+			Enabled:  false,
+			Port:     0,
+			Sessions: 12,
+		},
+		Sanitize: Sanitize{
+			Normalize:    true,
+			MaxCombining: 3,
+		},
+		Spam: Spam{
+			MaxRepeats:   0,
+			Warnings:     2,
+			MuteDuration: 60,
+			Window:       30,
+			MaxSimilar:   0,
+			Similarity:   0.8,
+			MaxCaps:      0,
+		},
+		Raid: Raid{
+			Password:       "",
+			RejectNew:      true,
+			CooldownFactor: 3,
+		},
+		Webhooks: Webhooks{
+			URL:       "",
+			Username:  "SCS",
+			ModCalls:  true,
+			Bans:      true,
+			Errors:    true,
+			RateLimit: 10,
+		},
+		Clients: Clients{
+			MaxOffsetX: 100,
+			MaxOffsetY: 100,
+		},
 	}
 }
 
@@ -56,21 +271,34 @@ var StringToLevel = map[string]logger.LogLevel{
 type Room struct {
 	Name            string `toml:"name"`
 	DefaultDesc     string `toml:"description"`
+	Type            string `toml:"type"` // "normal" or "lobby"
 	DefaultBg       string `toml:"background"`
 	LockBg          bool   `toml:"lock_background"`
 	DefaultAmbiance string `toml:"ambiance"`
 	LockAmbiance    bool   `toml:"lock_ambiance"`
+	LockMusic       bool   `toml:"lock_music"`
 
 	AdjacentRooms  []string `toml:"adjacent_rooms"`
+	AdjacentOnly   bool     `toml:"adjacent_only"`
 	CharLists      []string `toml:"character_lists"`
 	SongCategories []string `toml:"song_categories"`
 	Sides          []string `toml:"side_list"`
+	DefaultSide    string   `toml:"default_side"` // "" means the first side
 
 	AllowBlankpost bool `toml:"allow_blankpost"`
 	AllowShouting  bool `toml:"allow_shouting"`
 	AllowIniswap   bool `toml:"allow_iniswap"`
 	ForceImmediate bool `toml:"force_immediate"`
 
+	IniswapAllowList []string `toml:"iniswap_allow_list"`
+	BannedColors     []int    `toml:"banned_colors"`
+
+	GrantPerms  []string `toml:"grant_permissions"`
+	RevokePerms []string `toml:"revoke_permissions"`
+
+	MaxSpectators int `toml:"max_spectators"` // 0 means no limit
+	Slowmode      int `toml:"slowmode"`       // in seconds, 0 means off
+
 	// TODO: add buffered logging
 	LogMethods []string `toml:"log_methods"`
 	DebugLog   bool     `toml:"log_debug"`
@@ -79,6 +307,7 @@ type Room struct {
 func RoomDefault() *Room {
 	return &Room{
 		Name:            "Unknown",
+		Type:            "normal",
 		DefaultAmbiance: "~stop.mp3",
 		CharLists:       []string{"all"},
 		SongCategories:  []string{"all"},
@@ -96,22 +325,95 @@ type RoomList struct {
 	Confs []Room `toml:"room"`
 }
 
-// Right now we are using strings for songs and characters, but they could
-// be more complicated structures with more metadata later.
-type (
-	Character string
-	Song      string //TODO: song aliases
-)
+// Right now we are using strings for songs, but they could be more complicated
+// structures with more metadata later.
+type Song string //TODO: song aliases
+
+// A character in a list. In the config, it may be just the character's name, or a
+// table with its name and restrictions.
+type Character struct {
+	Name        string   `toml:"name"`
+	Sides       []string `toml:"sides"`        // the sides it can speak from, empty for any
+	ManagerOnly bool     `toml:"manager_only"` // whether only users with the characters permission can pick it
+}
+
+// Decodes a character either from a string or from a table.
+func (c *Character) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case string:
+		*c = Character{Name: v}
+		return nil
+	case map[string]any:
+		name, ok := v["name"].(string)
+		if !ok || name == "" {
+			return fmt.Errorf("config: Character without a name.")
+		}
+		*c = Character{Name: name}
+		if sides, ok := v["sides"].([]any); ok {
+			for _, s := range sides {
+				side, ok := s.(string)
+				if !ok {
+					return fmt.Errorf("config: Character '%v' has a non-string side.", name)
+				}
+				c.Sides = append(c.Sides, side)
+			}
+		}
+		if mo, ok := v["manager_only"]; ok {
+			if c.ManagerOnly, ok = mo.(bool); !ok {
+				return fmt.Errorf("config: Character '%v' has a non-boolean `manager_only`.", name)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("config: Character must be a string or a table, not %T.", data)
+}
 
 type CharList struct {
-	Name       string   `toml:"name"`
-	Characters []string `toml:"characters"`
+	Name       string      `toml:"name"`
+	Characters []Character `toml:"characters"`
 }
 
 type Characters struct {
 	Lists []CharList `toml:"list"`
 }
 
+// A background that can be used in rooms. In the config, it may be just the background's
+// name, or a table with its name and positions.
+type Background struct {
+	Name      string   `toml:"name"`
+	Positions []string `toml:"positions"` // the sides rooms get with it, empty to keep the room's own
+}
+
+// Decodes a background either from a string or from a table.
+func (b *Background) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case string:
+		*b = Background{Name: v}
+		return nil
+	case map[string]any:
+		name, ok := v["name"].(string)
+		if !ok || name == "" {
+			return fmt.Errorf("config: Background without a name.")
+		}
+		*b = Background{Name: name}
+		if positions, ok := v["positions"].([]any); ok {
+			for _, p := range positions {
+				pos, ok := p.(string)
+				if !ok {
+					return fmt.Errorf("config: Background '%v' has a non-string position.", name)
+				}
+				b.Positions = append(b.Positions, pos)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("config: Background must be a string or a table, not %T.", data)
+}
+
+type Backgrounds struct {
+	List []Background `toml:"backgrounds"`
+}
+
 type SongCategory struct {
 	Name  string `toml:"name"`
 	Songs []Song `toml:"songs"`
@@ -119,29 +421,103 @@ type SongCategory struct {
 
 type Music struct {
 	Categories []SongCategory `toml:"category"`
+	Lengths    map[string]int `toml:"lengths"` // in seconds, by song name
 }
 
 type Role struct {
 	Name        string   `toml:"name"`
-	Permissions []string `toml:"permissions"`
+	Inherits    string   `toml:"inherits"`           // the name of a role whose permissions this one starts with
+	Permissions []string `toml:"permissions"`        // added to the inherited ones
+	Revoke      []string `toml:"revoke_permissions"` // removed from the inherited ones
 }
 
 type Roles struct {
 	Confs []Role `toml:"role"`
 }
 
-// Attempts to read server configuration. Returns default server settings if it fails.
+// A script file, defining custom commands and reactions to events.
+type Script struct {
+	File      string           `toml:"-"`
+	Commands  []ScriptCommand  `toml:"command"`
+	Reactions []ScriptReaction `toml:"reaction"`
+}
+
+type ScriptCommand struct {
+	Name        string   `toml:"name"`
+	Usage       string   `toml:"usage"`
+	Description string   `toml:"description"`
+	MinArgs     int      `toml:"min_args"`
+	Permissions []string `toml:"permissions"`
+	Reply       string   `toml:"reply"`
+	Broadcast   bool     `toml:"broadcast"`
+}
+
+type ScriptReaction struct {
+	Event     string `toml:"event"`
+	Match     string `toml:"match"`
+	Reply     string `toml:"reply"`
+	Broadcast bool   `toml:"broadcast"`
+	Block     bool   `toml:"block"`
+}
+
+// Reads the language files in the "lang" folder, returning their messages by language
+// code (the file's name, e.g. "pt" for "pt.toml" or "pt.yaml"). Messages can be grouped in tables,
+// in which case their keys are joined with dots (e.g. "muted" in [ic] is "ic.muted").
+func ReadLanguages() (map[string]map[string]string, error) {
+	dir, err := LangDir()
+	if err != nil {
+		return nil, fmt.Errorf("config: Couldn't find executable location (%w). Can't read languages.", err)
+	}
+	files, err := globFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("config: Couldn't list language files (%w).", err)
+	}
+	langs := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		var raw map[string]any
+		if err := decodeAny(f, &raw); err != nil {
+			return nil, fmt.Errorf("config: Couldn't read language file %v (%w).", filepath.Base(f), err)
+		}
+		msgs := make(map[string]string)
+		if err := flattenMessages(raw, "", msgs); err != nil {
+			return nil, fmt.Errorf("config: Couldn't read language file %v (%w).", filepath.Base(f), err)
+		}
+		langs[baseName(f)] = msgs
+	}
+	return langs, nil
+}
+
+func flattenMessages(raw map[string]any, prefix string, msgs map[string]string) error {
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			msgs[prefix+k] = v
+		case map[string]any:
+			if err := flattenMessages(v, prefix+k+".", msgs); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("config: Message '%v' is not a string.", prefix+k)
+		}
+	}
+	return nil
+}
+
+// Attempts to read server configuration, then applies the overrides in the environment
+// (see [envVars]). Returns default server settings if it fails.
 func ReadServer() (*Server, error) {
-	execDir, err := ExecDir()
+	configDir, err := Dir()
 	if err != nil {
-		return ServerDefault(), fmt.Errorf("config: Couldn't find executable location (%w). Can't read configs.", err)
+		return ServerDefault(), fmt.Errorf("config: Couldn't find config directory (%w). Can't read configs.", err)
 	}
-	configDir := execDir + "/config"
 
 	srvConfig := ServerDefault()
-	if _, err := toml.DecodeFile(configDir+"/config.toml", srvConfig); err != nil {
+	if err := decodeFile(configDir, "config", srvConfig); err != nil {
 		return srvConfig, fmt.Errorf("config: Couldn't read server config (%w).", err)
 	}
+	if err := applyEnv(srvConfig); err != nil {
+		return srvConfig, err
+	}
 
 	return srvConfig, nil
 }
@@ -151,11 +527,10 @@ func ReadServer() (*Server, error) {
 
 // Attempts to read room settings. Returns nil [RoomList] and an error if it fails.
 func ReadRooms() (*RoomList, error) {
-	execDir, err := ExecDir()
+	configDir, err := Dir()
 	if err != nil {
-		return nil, fmt.Errorf("config: Couldn't find executable location (%w). Can't read configs.", err)
+		return nil, fmt.Errorf("config: Couldn't find config directory (%w). Can't read configs.", err)
 	}
-	configDir := execDir + "/config"
 
 	num, err := countRooms(configDir)
 	if err != nil {
@@ -166,7 +541,7 @@ func ReadRooms() (*RoomList, error) {
 	for i := range list.Confs {
 		list.Confs[i] = *RoomDefault()
 	}
-	if _, err = toml.DecodeFile(configDir+"/room.toml", &list); err != nil {
+	if err = decodeFile(configDir, "room", &list); err != nil {
 		return nil, fmt.Errorf("config: Couldn't read rooms (%w).", err)
 	}
 	return &list, nil
@@ -175,7 +550,7 @@ func ReadRooms() (*RoomList, error) {
 // Counts the amount of rooms in the settings, if they can be found.
 func countRooms(configDir string) (int, error) {
 	var list RoomList
-	if _, err := toml.DecodeFile(configDir+"/room.toml", &list); err != nil {
+	if err := decodeFile(configDir, "room", &list); err != nil {
 		return 0, err
 	}
 	return len(list.Confs), nil
@@ -183,14 +558,13 @@ func countRooms(configDir string) (int, error) {
 
 // Attempts to read character settings. Returns nil [CharList] and an error if it fails.
 func ReadCharacters() (*Characters, error) {
-	execDir, err := ExecDir()
+	configDir, err := Dir()
 	if err != nil {
-		return nil, fmt.Errorf("config: Couldn't find executable location (%w). Can't read configs.", err)
+		return nil, fmt.Errorf("config: Couldn't find config directory (%w). Can't read configs.", err)
 	}
-	configDir := execDir + "/config"
 
 	var list Characters
-	if _, err = toml.DecodeFile(configDir+"/characters.toml", &list); err != nil {
+	if err = decodeFile(configDir, "characters", &list); err != nil {
 		return nil, fmt.Errorf("config: Couldn't read characters (%w).", err)
 	}
 	return &list, nil
@@ -198,33 +572,134 @@ func ReadCharacters() (*Characters, error) {
 
 // Attempts to read music settings. Returns the nil [Music] and an error if it fails.
 func ReadMusic() (*Music, error) {
-	execDir, err := ExecDir()
+	configDir, err := Dir()
 	if err != nil {
-		return nil, fmt.Errorf("config: Couldn't find executable location (%w). Can't read configs.", err)
+		return nil, fmt.Errorf("config: Couldn't find config directory (%w). Can't read configs.", err)
 	}
-	configDir := execDir + "/config"
 
 	var conf Music
-	if _, err = toml.DecodeFile(configDir+"/music.toml", &conf); err != nil {
+	if err = decodeFile(configDir, "music", &conf); err != nil {
 		return nil, fmt.Errorf("config: Couldn't read music (%w).", err)
 	}
 	return &conf, nil
 }
 
+// Attempts to read the background list. The list is optional, so if there is no file,
+// returns nil [Backgrounds] and no error.
+func ReadBackgrounds() (*Backgrounds, error) {
+	configDir, err := Dir()
+	if err != nil {
+		return nil, fmt.Errorf("config: Couldn't find config directory (%w). Can't read configs.", err)
+	}
+
+	var conf Backgrounds
+	if err = decodeFile(configDir, "backgrounds", &conf); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("config: Couldn't read backgrounds (%w).", err)
+	}
+	return &conf, nil
+}
+
 func ReadRoles() (*Roles, error) {
-	execDir, err := ExecDir()
+	configDir, err := Dir()
 	if err != nil {
-		return nil, fmt.Errorf("config: Couldn't find executable location (%w). Can't read configs.", err)
+		return nil, fmt.Errorf("config: Couldn't find config directory (%w). Can't read configs.", err)
 	}
-	configDir := execDir + "/config"
 
 	var list Roles
-	if _, err = toml.DecodeFile(configDir+"/roles.toml", &list); err != nil {
+	if err = decodeFile(configDir, "roles", &list); err != nil {
 		return nil, fmt.Errorf("config: Couldn't read roles (%w).", err)
 	}
 	return &list, nil
 }
 
+// Attempts to read every script in the scripts directory, in alphabetical order.
+// Returns nil and an error if any of them fails.
+func ReadScripts() ([]Script, error) {
+	dir, err := ScriptsDir()
+	if err != nil {
+		return nil, fmt.Errorf("config: Couldn't find executable location (%w). Can't read scripts.", err)
+	}
+	files, err := globFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("config: Couldn't list scripts (%w).", err)
+	}
+	scripts := make([]Script, len(files))
+	for i, f := range files {
+		scripts[i].File = filepath.Base(f)
+		if err := decodeAny(f, &scripts[i]); err != nil {
+			return nil, fmt.Errorf("config: Couldn't read script %v (%w).", scripts[i].File, err)
+		}
+	}
+	return scripts, nil
+}
+
+// Directories set through [SetDirs]. Empty means the default, relative to the executable.
+var customConfigDir, customDataDir string
+
+// Sets the directory the config files are read from, and the directory data (e.g. the
+// database) is kept in. Empty strings keep the defaults: the "config" folder in the
+// executable's directory, and the executable's directory itself.
+// Should be called before any configs are read.
+func SetDirs(config string, data string) {
+	customConfigDir, customDataDir = config, data
+}
+
+// Returns the directory the config files are read from.
+func Dir() (string, error) {
+	if customConfigDir != "" {
+		return customConfigDir, nil
+	}
+	execDir, err := ExecDir()
+	if err != nil {
+		return "", err
+	}
+	return execDir + "/config", nil
+}
+
+// Returns the directory data (e.g. the database) is kept in.
+func DataDir() (string, error) {
+	if customDataDir != "" {
+		return customDataDir, nil
+	}
+	return ExecDir()
+}
+
+// Returns the directory the language files are read from.
+func LangDir() (string, error) {
+	return withConfig("lang")
+}
+
+// Returns the directory the scripts are read from.
+func ScriptsDir() (string, error) {
+	return withConfig("scripts")
+}
+
+// Returns the folder with the passed name that is kept with the config files: inside the
+// config directory if one was set, or else next to the executable.
+func withConfig(name string) (string, error) {
+	if customConfigDir != "" {
+		return filepath.Join(customConfigDir, name), nil
+	}
+	execDir, err := ExecDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(execDir, name), nil
+}
+
+// Returns the directory logs, room logs and transcripts are written to, the "log" folder
+// in the data directory.
+func LogDir() (string, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "log"), nil
+}
+
 // Returns the absolute path to the executable's directory, if it doesn't fail.
 func ExecDir() (string, error) {
 	execPath, err := os.Executable()
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// A format config files can be written in, detected by the file's extension.
+type format struct {
+	ext    string
+	decode func(path string, v any) error
+}
+
+// The supported formats, in order of preference: if a config exists in more than one,
+// the first one is read.
+var formats = []format{
+	{".toml", decodeTOML},
+	{".yaml", decodeYAML},
+	{".yml", decodeYAML},
+}
+
+// Decodes the config file with the passed name (without an extension) in the directory,
+// in whichever format it's written in. If there is no such file, the error wraps
+// [fs.ErrNotExist].
+func decodeFile(dir string, name string, v any) error {
+	path, f, err := findFile(dir, name)
+	if err != nil {
+		return err
+	}
+	return f.decode(path, v)
+}
+
+// Returns the path of the config file with the passed name (without an extension) in the
+// directory, and its format.
+func findFile(dir string, name string) (string, format, error) {
+	for _, f := range formats {
+		path := filepath.Join(dir, name+f.ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, f, nil
+		}
+	}
+	return "", format{}, fmt.Errorf("config: No %v file in %v (%w).", name, dir, fs.ErrNotExist)
+}
+
+// Returns the config files in the directory, in any of the formats, sorted by name.
+// If a name exists in more than one format, only the preferred one is returned.
+func globFiles(dir string) ([]string, error) {
+	var files []string
+	seen := make(map[string]struct{})
+	for _, f := range formats {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+f.ext))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			name := strings.TrimSuffix(m, f.ext)
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			files = append(files, m)
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Decodes a file in any of the formats, according to its extension.
+func decodeAny(path string, v any) error {
+	for _, f := range formats {
+		if strings.EqualFold(filepath.Ext(path), f.ext) {
+			return f.decode(path, v)
+		}
+	}
+	return fmt.Errorf("config: Unknown format for %v.", filepath.Base(path))
+}
+
+// Returns the file's name without its directory and extension.
+func baseName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func decodeTOML(path string, v any) error {
+	_, err := toml.DecodeFile(path, v)
+	return err
+}
+
+// YAML is decoded by converting it to TOML, so the configs need only TOML tags and
+// their custom decoding (e.g. [Character.UnmarshalTOML]) works the same in both.
+func decodeYAML(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(dropNulls(raw)); err != nil {
+		return fmt.Errorf("config: Can't convert %v (%w).", filepath.Base(path), err)
+	}
+	_, err = toml.Decode(buf.String(), v)
+	return err
+}
+
+// TOML has no null, so empty YAML values are treated as if they weren't set.
+func dropNulls(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = dropNulls(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = dropNulls(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Prefix of the environment variables that override server settings.
+const envPrefix = "SCS_"
+
+// An environment variable overriding a setting. `dst` is a *string, *int or *bool.
+type envVar struct {
+	name string
+	dst  any
+}
+
+// Returns the settings that can be overridden through the environment, e.g. SCS_PORT_WS
+// for `ws_port`.
+func envVars(c *Server) []envVar {
+	return []envVar{
+		{"NAME", &c.Name},
+		{"DESCRIPTION", &c.Desc},
+		{"USERNAME", &c.Username},
+		{"MAX_PLAYERS", &c.MaxPlayers},
+		{"PORT_WS", &c.PortWS},
+		{"PORT_TCP", &c.PortTCP},
+		{"PORT_RPC", &c.PortRPC},
+		{"ALLOW_AO", &c.AllowAO},
+		{"ALLOW_SC", &c.AllowSC},
+		{"ASSET_URL", &c.AssetURL},
+		{"LANGUAGE", &c.Language},
+		{"JOIN_PASSWORD", &c.JoinPassword},
+		{"LOG_LEVEL", &c.LevelString},
+		{"API_TOKEN", &c.API.Token},
+	}
+}
+
+// Overrides the settings whose environment variables are set.
+func applyEnv(c *Server) error {
+	for _, v := range envVars(c) {
+		val, ok := os.LookupEnv(envPrefix + v.name)
+		if !ok {
+			continue
+		}
+		var err error
+		switch dst := v.dst.(type) {
+		case *string:
+			*dst = val
+		case *int:
+			*dst, err = strconv.Atoi(val)
+		case *bool:
+			*dst, err = strconv.ParseBool(val)
+		}
+		if err != nil {
+			return fmt.Errorf("config: Bad value for %v%v (%w).", envPrefix, v.name, err)
+		}
+	}
+	return nil
+}
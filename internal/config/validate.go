@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// A problem found in the configuration, with where it was found.
+type Problem struct {
+	File string // e.g. "room.toml" or "room.yaml"
+	Line int    // 0 if unknown
+	Msg  string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%v: %v", p.File, p.Msg)
+	}
+	return fmt.Sprintf("%v:%v: %v", p.File, p.Line, p.Msg)
+}
+
+// Every problem found in the configuration, so they can all be fixed at once.
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	lines := make([]string, len(ps))
+	for i, p := range ps {
+		lines[i] = p.String()
+	}
+	return fmt.Sprintf("config: Found %v problem(s):\n%v", len(ps), strings.Join(lines, "\n"))
+}
+
+// Checks that the configs are consistent with each other: that room names are unique,
+// that the rooms, lists and categories referenced by rooms exist, that the ports are
+// valid, and that some role can use manager-only characters. Returns every problem
+// found as [Problems], or `nil` if there are none.
+func Validate(srv *Server, rooms *RoomList, chars *Characters, music *Music, roles *Roles) error {
+	v := validator{loc: newLocator()}
+	v.checkPorts(srv)
+	v.checkRooms(srv, rooms, chars, music)
+	v.checkManager(srv, chars, roles)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+type validator struct {
+	loc      *locator
+	problems Problems
+}
+
+// Adds a problem found in the config with the passed name (e.g. "room"), at the line
+// where `key` is set to `value`. If `value` is empty, the line where `key` is first set
+// is used instead.
+func (v *validator) addf(name string, key string, value string, format string, a ...any) {
+	v.addNthf(name, key, value, 1, format, a...)
+}
+
+// Like [validator.addf], but at the n-th line where `key` is set to `value`.
+func (v *validator) addNthf(name string, key string, value string, n int, format string, a ...any) {
+	file, line := v.loc.find(name, key, value, n)
+	v.problems = append(v.problems, Problem{
+		File: file,
+		Line: line,
+		Msg:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (v *validator) checkPorts(srv *Server) {
+	ports := []struct {
+		key  string
+		port int
+	}{{"ws_port", srv.PortWS}, {"legacy_port", srv.PortTCP}, {"rpc_port", srv.PortRPC}}
+	used := make(map[int]string)
+	for _, p := range ports {
+		if p.port < 0 || p.port > 65535 {
+			v.addf("config", p.key, "", "`%v` is %v, which isn't a valid port.", p.key, p.port)
+			continue
+		}
+		if p.port == 0 {
+			continue
+		}
+		if other, ok := used[p.port]; ok {
+			v.addf("config", p.key, "", "`%v` and `%v` are both %v.", other, p.key, p.port)
+			continue
+		}
+		used[p.port] = p.key
+	}
+}
+
+func (v *validator) checkRooms(srv *Server, rooms *RoomList, chars *Characters, music *Music) {
+	names := make(map[string]int, len(rooms.Confs)) // how many rooms have each name
+	for _, r := range rooms.Confs {
+		names[r.Name]++
+		if n := names[r.Name]; n > 1 {
+			v.addNthf("room", "name", r.Name, n, "There is more than one room named '%v'.", r.Name)
+		}
+	}
+
+	lists := make(map[string]struct{}, len(chars.Lists))
+	for _, l := range chars.Lists {
+		lists[l.Name] = struct{}{}
+	}
+	cats := make(map[string]struct{}, len(music.Categories))
+	for _, c := range music.Categories {
+		cats[c.Name] = struct{}{}
+	}
+	for _, r := range rooms.Confs {
+		for _, adj := range r.AdjacentRooms {
+			if _, ok := names[adj]; !ok && adj != "all" {
+				v.addf("room", "name", r.Name, "Room '%v' is adjacent to '%v', which doesn't exist.", r.Name, adj)
+			}
+		}
+		for _, l := range r.CharLists {
+			if _, ok := lists[l]; !ok && l != "all" {
+				v.addf("room", "name", r.Name, "Room '%v' uses the character list '%v', which isn't in the character config.", r.Name, l)
+			}
+		}
+		for _, c := range r.SongCategories {
+			if _, ok := cats[c]; !ok && c != "all" {
+				v.addf("room", "name", r.Name, "Room '%v' uses the song category '%v', which isn't in the music config.", r.Name, c)
+			}
+		}
+	}
+
+	if _, ok := names[srv.LandingRoom]; !ok && srv.LandingRoom != "" {
+		v.addf("config", "landing_room", "", "The landing room '%v' doesn't exist.", srv.LandingRoom)
+	}
+	if _, ok := names[srv.ModCallRoom]; !ok && srv.ModCallRoom != "" {
+		v.addf("config", "modcall_room", "", "The mod call room '%v' doesn't exist.", srv.ModCallRoom)
+	}
+}
+
+// Manager-only characters can only be picked by room managers, so some role must have
+// one of the manager permissions.
+func (v *validator) checkManager(srv *Server, chars *Characters, roles *Roles) {
+	var restricted string
+	for _, l := range chars.Lists {
+		for _, c := range l.Characters {
+			if c.ManagerOnly {
+				restricted = c.Name
+				break
+			}
+		}
+		if restricted != "" {
+			break
+		}
+	}
+	if restricted == "" {
+		return
+	}
+	for _, r := range roles.Confs {
+		for _, p := range r.Permissions {
+			if p == "all" || slices.Contains(srv.ManagerPerms, p) {
+				return
+			}
+		}
+	}
+	v.addf("characters", "name", restricted,
+		"'%v' is manager-only, but no role has any of the manager permissions (%v).", restricted, strings.Join(srv.ManagerPerms, ", "))
+}
+
+// Finds the lines where keys are set in the config files, for pointing at problems.
+type locator struct {
+	dir   string
+	files map[string]locatedFile // by name, e.g. "room"
+}
+
+type locatedFile struct {
+	name  string // with the extension
+	lines []string
+}
+
+func newLocator() *locator {
+	l := &locator{files: make(map[string]locatedFile)}
+	l.dir, _ = Dir()
+	return l
+}
+
+// Returns the config file with the passed name (e.g. "room.toml" for "room"), and the
+// line of the n-th place `key` is set to `value` in it (or set at all, if `value` is
+// empty). If it isn't found, the line is 0.
+func (l *locator) find(name string, key string, value string, n int) (file string, line int) {
+	f, ok := l.files[name]
+	if !ok {
+		f.name = name + ".toml"
+		if path, _, err := findFile(l.dir, name); err == nil {
+			f.name = filepath.Base(path)
+			if b, err := os.ReadFile(path); err == nil {
+				f.lines = strings.Split(string(b), "\n")
+			}
+		}
+		l.files[name] = f
+	}
+	// Matches both TOML ("key = value") and YAML ("key: value").
+	pattern := `(^|[\s{,-])` + regexp.QuoteMeta(key) + `\s*[=:]`
+	if value != "" {
+		pattern += `\s*["']?` + regexp.QuoteMeta(value) + `(["']|\s*$|\s*[,}#])`
+	}
+	re := regexp.MustCompile(pattern)
+	for i, line := range f.lines {
+		if re.MatchString(line) {
+			n--
+			if n == 0 {
+				return f.name, i + 1
+			}
+		}
+	}
+	return f.name, 0
+}
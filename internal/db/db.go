@@ -10,7 +10,10 @@ package db
 // The simplest would be just storing everything in JSON.
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -26,11 +29,22 @@ import (
 const version int = 0
 
 // Represents a connection to the database. Used for database operations, goroutine-safe.
+// Queries run concurrently on the connections in the pool, with SQLite's write-ahead log
+// letting reads go on while something is written.
 type Database struct {
-	db *sql.DB
-	mu sync.Mutex
+	db      *sql.DB
+	stmts   map[string]*sql.Stmt // prepared statements, by query
+	stmtsMu sync.Mutex
 }
 
+// The options the database is opened with: write-ahead logging, which is safe with
+// `synchronous` set to NORMAL, and waiting for locks held by other connections instead
+// of failing right away.
+const dsnOptions = "?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000"
+
+// How many connections are kept open for reuse.
+const maxIdleConns = 4
+
 // Represents a ban in the database.
 type Ban struct {
 	BanID     int
@@ -42,14 +56,92 @@ type Ban struct {
 	End       time.Time
 }
 
+// Represents a mute in the database. Mutes that last until lifted have a zero end.
+type Mute struct {
+	MuteID    int
+	IPID      string
+	Kind      string
+	Shadow    bool
+	Reason    string
+	Moderator string
+	Start     time.Time
+	End       time.Time
+}
+
+// Represents a mod call in the database, made while no moderator was online.
+type ModCall struct {
+	CallID int
+	IPID   string
+	Room   string
+	Caller string
+	Reason string
+	Time   time.Time
+}
+
+// Represents a ban on a range of raw IPs in the database.
+type IPBan struct {
+	BanID     int
+	CIDR      string
+	Reason    string
+	Moderator string
+	Start     time.Time
+	End       time.Time
+}
+
+// Represents how much a character was used.
+type CharUsage struct {
+	Name  string
+	Picks int
+	Time  time.Duration
+}
+
+// Represents an IPID seen with some HDID in the database.
+type Alias struct {
+	IPID      string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Represents a temporary role grant in the database.
+type RoleGrant struct {
+	GrantID   int
+	IPID      string
+	Role      string
+	Moderator string
+	Start     time.Time
+	End       time.Time
+}
+
 // Opens a connection to the database, creating it and initializing the tables if necessary.
 func Init(path string) (*Database, error) {
-	db, err := sql.Open("sqlite3", path)
+	db, err := sql.Open("sqlite3", path+dsnOptions)
 	if err != nil {
 		return nil, fmt.Errorf("db: Couldn't connect to database (%w).", err)
 	}
+	db.SetMaxIdleConns(maxIdleConns)
 
-	// TODO: users table?
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS users(
+        ipid       TEXT PRIMARY KEY,
+        first_seen INTEGER NOT NULL,
+        last_seen  INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create users table (%w).", err)
+	}
+
+	// The HDIDs each IPID in `users` was seen with.
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS user_hdids(
+        ipid       TEXT NOT NULL,
+        hdid       TEXT NOT NULL,
+        first_seen INTEGER NOT NULL,
+        last_seen  INTEGER NOT NULL,
+        PRIMARY KEY (ipid, hdid)
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create user HDIDs table (%w).", err)
+	}
 
 	_, err = db.Exec(`
     CREATE TABLE IF NOT EXISTS auth(
@@ -61,6 +153,24 @@ func Init(path string) (*Database, error) {
 		return nil, fmt.Errorf("db: Couldn't create auth table (%w).", err)
 	}
 
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS totp(
+        username TEXT PRIMARY KEY,
+        secret   TEXT NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create totp table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS totp_steps(
+        username TEXT PRIMARY KEY,
+        step     INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create totp_steps table (%w).", err)
+	}
+
 	_, err = db.Exec(`
     CREATE TABLE IF NOT EXISTS bans(
         ban_id    INTEGER PRIMARY KEY,
@@ -77,19 +187,283 @@ func Init(path string) (*Database, error) {
 		return nil, fmt.Errorf("db: Couldn't create bans table (%w).", err)
 	}
 
-	return &Database{db: db}, nil
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS modcalls(
+        call_id INTEGER PRIMARY KEY,
+        ipid    TEXT NOT NULL,
+        room    TEXT NOT NULL,
+        caller  TEXT NOT NULL,
+        reason  TEXT NOT NULL,
+        time    INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create modcalls table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS role_grants(
+        grant_id  INTEGER PRIMARY KEY,
+        ipid      TEXT NOT NULL,
+        role      TEXT NOT NULL,
+        moderator TEXT NOT NULL,
+        start     INTEGER NOT NULL,
+        end       INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create role_grants table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS ip_bans(
+        ban_id    INTEGER PRIMARY KEY,
+        cidr      TEXT NOT NULL,
+        reason    TEXT NOT NULL,
+        moderator TEXT NOT NULL,
+        start     INTEGER NOT NULL,
+        end       INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create ip_bans table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS mutes(
+        mute_id   INTEGER PRIMARY KEY,
+        ipid      TEXT NOT NULL,
+        kind      TEXT NOT NULL,
+        shadow    INTEGER NOT NULL,
+        reason    TEXT NOT NULL,
+        moderator TEXT NOT NULL,
+        start     INTEGER NOT NULL,
+        end       INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create mutes table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS warnings(
+        warn_id   INTEGER PRIMARY KEY,
+        ipid      TEXT NOT NULL,
+        reason    TEXT NOT NULL,
+        moderator TEXT NOT NULL,
+        time      INTEGER NOT NULL,
+        acked     INTEGER NOT NULL DEFAULT 0
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create warnings table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS login_failures(
+        failure_id INTEGER PRIMARY KEY,
+        ipid       TEXT NOT NULL,
+        username   TEXT NOT NULL,
+        time       INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create login_failures table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS char_usage(
+        room    TEXT NOT NULL,
+        name    TEXT NOT NULL,
+        picks   INTEGER NOT NULL DEFAULT 0,
+        seconds INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (room, name)
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create char_usage table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS settings(
+        key   TEXT PRIMARY KEY,
+        value TEXT NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create settings table (%w).", err)
+	}
+
+	_, err = db.Exec(`
+    CREATE TABLE IF NOT EXISTS legacy_ipids(
+        ipid      TEXT PRIMARY KEY,
+        salted_at INTEGER NOT NULL
+    )`)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't create legacy_ipids table (%w).", err)
+	}
+
+	return &Database{db: db, stmts: make(map[string]*sql.Stmt)}, nil
+}
+
+// Records that the IPID was seen now. Returns whether it had never been seen before.
+func (d *Database) SeeIPID(ipid string) (first bool, err error) {
+	// Inserting first keeps this right when the same IPID is seen twice at once.
+	now := time.Now().Unix()
+	res, err := d.exec("INSERT INTO users (ipid, first_seen, last_seen) VALUES (?, ?, ?) ON CONFLICT (ipid) DO NOTHING", ipid, now, now)
+	if err != nil {
+		return false, fmt.Errorf("db: Couldn't insert user (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	if _, err := d.exec("UPDATE users SET last_seen = ? WHERE ipid = ?", now, ipid); err != nil {
+		return false, fmt.Errorf("db: Couldn't update user (%w).", err)
+	}
+	return false, nil
+}
+
+// Records that the IPID was seen now with the HDID.
+func (d *Database) SeeHDID(ipid string, hdid string) error {
+	now := time.Now().Unix()
+	_, err := d.exec(`
+    INSERT INTO user_hdids (ipid, hdid, first_seen, last_seen) VALUES (?, ?, ?, ?)
+    ON CONFLICT (ipid, hdid) DO UPDATE SET last_seen = excluded.last_seen`,
+		ipid, hdid, now, now)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't record HDID (%w).", err)
+	}
+	return nil
+}
+
+// Gets every IPID seen with the HDID, most recently seen first.
+func (d *Database) GetAliases(hdid string) ([]Alias, error) {
+	rows, err := d.query(`
+    SELECT ipid, first_seen, last_seen FROM user_hdids
+    WHERE hdid = ? ORDER BY last_seen DESC`, hdid)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query database (%w).", err)
+	}
+	defer rows.Close()
+
+	var aliases []Alias
+	for rows.Next() {
+		var a Alias
+		var first, last int64
+		if err := rows.Scan(&a.IPID, &first, &last); err != nil {
+			return nil, fmt.Errorf("db: Couldn't scan alias (%w).", err)
+		}
+		a.FirstSeen, a.LastSeen = time.Unix(first, 0), time.Unix(last, 0)
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// Returns whether the IPID has been seen before, without recording it.
+func (d *Database) KnownIPID(ipid string) (bool, error) {
+	var n int
+	err := d.queryRow("SELECT COUNT(*) FROM users WHERE ipid = ?", ipid).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("db: Couldn't query user (%w).", err)
+	}
+	return n > 0, nil
+}
+
+// Gets a setting changed at runtime. If it was never set, `ok` is `false`.
+func (d *Database) Setting(key string) (value string, ok bool, err error) {
+	err = d.queryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("db: Couldn't query setting '%v' (%w).", key, err)
+	}
+	return value, true, nil
+}
+
+// Stores a setting changed at runtime, so it survives restarts.
+func (d *Database) SetSetting(key string, value string) error {
+	if _, err := d.exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+		return fmt.Errorf("db: Couldn't store setting '%v' (%w).", key, err)
+	}
+	return nil
+}
+
+// Forgets a setting changed at runtime, so the configured one is used again.
+func (d *Database) RemoveSetting(key string) error {
+	if _, err := d.exec("DELETE FROM settings WHERE key = ?", key); err != nil {
+		return fmt.Errorf("db: Couldn't remove setting '%v' (%w).", key, err)
+	}
+	return nil
+}
+
+// Returns the server's secret salt for hashing IPIDs. On first run, a new random
+// salt is generated and stored, so IPIDs stay the same across restarts, and the IPIDs
+// already in the bans and role grants are marked as legacy, to be migrated by
+// [Database.MigrateIPID].
+func (d *Database) IPIDSalt() ([]byte, error) {
+	// A new salt is only stored if there isn't one, so whichever was stored first wins.
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("db: Couldn't generate IPID salt (%w).", err)
+	}
+	res, err := d.exec("INSERT OR IGNORE INTO settings (key, value) VALUES ('ipid_salt', ?)", hex.EncodeToString(b))
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't store IPID salt (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		now := time.Now().Unix()
+		_, err := d.exec(`
+    INSERT OR IGNORE INTO legacy_ipids (ipid, salted_at)
+    SELECT ipid, ? FROM bans WHERE ipid IS NOT NULL
+    UNION SELECT ipid, ? FROM role_grants`,
+			now, now)
+		if err != nil {
+			return nil, fmt.Errorf("db: Couldn't mark legacy IPIDs (%w).", err)
+		}
+	}
+	var salt string
+	if err := d.queryRow("SELECT value FROM settings WHERE key = 'ipid_salt'").Scan(&salt); err != nil {
+		return nil, fmt.Errorf("db: Couldn't query IPID salt (%w).", err)
+	}
+	return hex.DecodeString(salt)
+}
+
+// Carries the bans and role grants made under a legacy IPID (from before IPIDs were
+// salted) over to the IPID that replaced it. Each legacy IPID is migrated only once, and
+// only its records from before the salt was made, so records of a salted IPID that
+// happens to be the same string are left alone. If the legacy IPID had no records,
+// nothing is written.
+func (d *Database) MigrateIPID(old string, new string) error {
+	var saltedAt int64
+	err := d.queryRow("SELECT salted_at FROM legacy_ipids WHERE ipid = ?", old).Scan(&saltedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("db: Couldn't query legacy IPID (%w).", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("db: Couldn't migrate IPID (%w).", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE bans SET ipid = ? WHERE ipid = ? AND start <= ?", new, old, saltedAt); err != nil {
+		return fmt.Errorf("db: Couldn't migrate IPID in bans (%w).", err)
+	}
+	if _, err := tx.Exec("UPDATE role_grants SET ipid = ? WHERE ipid = ? AND start <= ?", new, old, saltedAt); err != nil {
+		return fmt.Errorf("db: Couldn't migrate IPID in role grants (%w).", err)
+	}
+	if _, err := tx.Exec("DELETE FROM legacy_ipids WHERE ipid = ?", old); err != nil {
+		return fmt.Errorf("db: Couldn't mark IPID as migrated (%w).", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("db: Couldn't migrate IPID (%w).", err)
+	}
+	return nil
 }
 
 // Adds a new ban to the database.
 func (d *Database) AddBan(ipid string, hdid string, reason string, moderator string, duration time.Duration) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
 	// Get time right away.
 	start := time.Now()
 	end := start.Add(duration)
 
 	if ipid != "" && hdid != "" {
-		_, err := d.db.Exec(`
+		_, err := d.exec(`
         INSERT INTO bans
             (ipid, hdid, reason, moderator, start, end)
         VALUES
@@ -107,7 +481,7 @@ func (d *Database) AddBan(ipid string, hdid string, reason string, moderator str
 	switch {
 	case ipid == "":
 		id = hdid
-		st, err = d.db.Prepare(`
+		st, err = d.stmt(`
         INSERT INTO bans
             (ipid, hdid, reason, moderator, start, end)
         VALUES
@@ -118,7 +492,7 @@ func (d *Database) AddBan(ipid string, hdid string, reason string, moderator str
 
 	case hdid == "":
 		id = ipid
-		st, err = d.db.Prepare(`
+		st, err = d.stmt(`
         INSERT INTO bans
             (ipid, hdid, reason, moderator, start, end)
         VALUES
@@ -138,15 +512,40 @@ func (d *Database) AddBan(ipid string, hdid string, reason string, moderator str
 
 // Gets all bans that correspond to the passed IPID and HDID (including expired ones).
 func (d *Database) GetBans(ipid string, hdid string) ([]Ban, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	rows, err := d.query("SELECT DISTINCT * FROM bans WHERE ipid = ? OR hdid = ?", ipid, hdid)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query database (%w).", err)
+	}
+	defer rows.Close()
+	return scanBans(rows)
+}
 
-	rows, err := d.db.Query("SELECT DISTINCT * FROM bans WHERE ipid = ? OR hdid = ?", ipid, hdid)
+// Gets the bans on the passed HDID that were placed on a different IPID and that were
+// still active at `since`. A match suggests someone is evading a ban with a new IP.
+func (d *Database) GetEvasionBans(ipid string, hdid string, since time.Time) ([]Ban, error) {
+	rows, err := d.query(`
+    SELECT DISTINCT * FROM bans
+    WHERE hdid = ? AND ipid IS NOT NULL AND ipid != ? AND end > ?`,
+		hdid, ipid, since.Unix())
 	if err != nil {
 		return nil, fmt.Errorf("db: Couldn't query database (%w).", err)
 	}
 	defer rows.Close()
+	return scanBans(rows)
+}
 
+// Gets all bans that haven't expired yet.
+func (d *Database) GetActiveBans() ([]Ban, error) {
+	rows, err := d.query("SELECT * FROM bans WHERE end > ?", time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query database (%w).", err)
+	}
+	defer rows.Close()
+	return scanBans(rows)
+}
+
+// Scans all rows from a query on the bans table.
+func scanBans(rows *sql.Rows) ([]Ban, error) {
 	var bans []Ban
 	for rows.Next() {
 		var ban Ban
@@ -187,11 +586,8 @@ func (d *Database) CheckBanned(ipid string, hdid string) (bool, []Ban, error) {
 
 // Nullifies a ban by setting its end time to the current time.
 func (d *Database) NullBan(id int) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
 	now := time.Now().Unix()
-	_, err := d.db.Exec(`
+	_, err := d.exec(`
     UPDATE bans
     SET end = ?
     WHERE ban_id = ?`,
@@ -204,9 +600,6 @@ func (d *Database) NullBan(id int) error {
 
 // Nullifies all bans for the passed IPID and HDID.
 func (d *Database) NullBans(ipid string, hdid string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
 	bans, err := d.GetBans(ipid, hdid)
 	if err != nil {
 		return fmt.Errorf("db: Couldn't get bans (%w).", err)
@@ -219,16 +612,333 @@ func (d *Database) NullBans(ipid string, hdid string) error {
 	return nil
 }
 
+// Records a mod call that no moderator was online to hear.
+func (d *Database) AddModCall(ipid string, room string, caller string, reason string) error {
+	_, err := d.exec(`
+    INSERT INTO modcalls
+        (ipid, room, caller, reason, time)
+    VALUES
+        (?, ?, ?, ?, ?)`,
+		ipid, room, caller, reason, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("db: Couldn't insert mod call (%w).", err)
+	}
+	return nil
+}
+
+// Gets the recorded mod calls, oldest first.
+func (d *Database) GetModCalls() ([]ModCall, error) {
+	rows, err := d.query("SELECT call_id, ipid, room, caller, reason, time FROM modcalls ORDER BY time, call_id")
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query mod calls (%w).", err)
+	}
+	defer rows.Close()
+	var calls []ModCall
+	for rows.Next() {
+		var m ModCall
+		var t int64
+		if err := rows.Scan(&m.CallID, &m.IPID, &m.Room, &m.Caller, &m.Reason, &t); err != nil {
+			return nil, fmt.Errorf("db: Couldn't scan mod call (%w).", err)
+		}
+		m.Time = time.Unix(t, 0)
+		calls = append(calls, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("db: Couldn't read mod calls (%w).", err)
+	}
+	return calls, nil
+}
+
+// Removes every recorded mod call, once a moderator has seen them.
+func (d *Database) ClearModCalls() error {
+	if _, err := d.exec("DELETE FROM modcalls"); err != nil {
+		return fmt.Errorf("db: Couldn't remove mod calls (%w).", err)
+	}
+	return nil
+}
+
+// Records a mute, so it can be reapplied when the user reconnects and moderators can
+// audit it. Shadow mutes are recorded as such. A duration of 0 means the mute lasts until
+// it's lifted, and is recorded with an end of 0 until then.
+func (d *Database) AddMute(ipid string, kind string, shadow bool, reason string, moderator string, duration time.Duration) error {
+	start := time.Now()
+	var end int64
+	if duration > 0 {
+		end = start.Add(duration).Unix()
+	}
+	_, err := d.exec(`
+    INSERT INTO mutes
+        (ipid, kind, shadow, reason, moderator, start, end)
+    VALUES
+        (?, ?, ?, ?, ?, ?, ?)`,
+		ipid, kind, shadow, reason, moderator, start.Unix(), end)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't insert mute (%w).", err)
+	}
+	return nil
+}
+
+// Returns the IPID's mutes that haven't ended or been lifted.
+func (d *Database) ActiveMutes(ipid string) ([]Mute, error) {
+	rows, err := d.query(`
+    SELECT mute_id, ipid, kind, shadow, reason, moderator, start, end FROM mutes
+    WHERE ipid = ? AND (end = 0 OR end > ?)`,
+		ipid, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query mutes (%w).", err)
+	}
+	return scanMutes(rows)
+}
+
+// Returns every mute of the IPID, the latest first.
+func (d *Database) GetMutes(ipid string) ([]Mute, error) {
+	rows, err := d.query(`
+    SELECT mute_id, ipid, kind, shadow, reason, moderator, start, end FROM mutes
+    WHERE ipid = ?
+    ORDER BY start DESC, mute_id DESC`,
+		ipid)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query mutes (%w).", err)
+	}
+	return scanMutes(rows)
+}
+
+func scanMutes(rows *sql.Rows) ([]Mute, error) {
+	defer rows.Close()
+	var mutes []Mute
+	for rows.Next() {
+		var m Mute
+		var start, end int64
+		if err := rows.Scan(&m.MuteID, &m.IPID, &m.Kind, &m.Shadow, &m.Reason, &m.Moderator, &start, &end); err != nil {
+			return nil, fmt.Errorf("db: Couldn't scan mute (%w).", err)
+		}
+		m.Start = time.Unix(start, 0)
+		if end != 0 {
+			m.End = time.Unix(end, 0)
+		}
+		mutes = append(mutes, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("db: Couldn't read mutes (%w).", err)
+	}
+	return mutes, nil
+}
+
+// Ends the IPID's active mutes now, as when they're lifted with /unmute.
+func (d *Database) LiftMutes(ipid string) error {
+	now := time.Now().Unix()
+	_, err := d.exec("UPDATE mutes SET end = ? WHERE ipid = ? AND (end = 0 OR end > ?)", now, ipid, now)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't lift mutes (%w).", err)
+	}
+	return nil
+}
+
+// Records a warning given to an IPID. Returns how many of the IPID's warnings are
+// unacknowledged, including this one.
+func (d *Database) AddWarning(ipid string, reason string, moderator string) (int, error) {
+	_, err := d.exec(`
+    INSERT INTO warnings
+        (ipid, reason, moderator, time)
+    VALUES
+        (?, ?, ?, ?)`,
+		ipid, reason, moderator, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("db: Couldn't insert warning (%w).", err)
+	}
+	return d.UnackedWarnings(ipid)
+}
+
+// Returns how many of the IPID's warnings are unacknowledged.
+func (d *Database) UnackedWarnings(ipid string) (int, error) {
+	var n int
+	err := d.queryRow(`SELECT COUNT(*) FROM warnings WHERE ipid = ? AND acked = 0`, ipid).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("db: Couldn't count warnings (%w).", err)
+	}
+	return n, nil
+}
+
+// Marks all of the IPID's warnings as acknowledged. Returns how many were unacknowledged.
+func (d *Database) AckWarnings(ipid string) (int, error) {
+	res, err := d.exec(`UPDATE warnings SET acked = 1 WHERE ipid = ? AND acked = 0`, ipid)
+	if err != nil {
+		return 0, fmt.Errorf("db: Couldn't acknowledge warnings (%w).", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// Records that a character was picked in a room.
+func (d *Database) AddCharPick(room string, name string) error {
+	_, err := d.exec(`
+    INSERT INTO char_usage (room, name, picks) VALUES (?, ?, 1)
+    ON CONFLICT (room, name) DO UPDATE SET picks = picks + 1`,
+		room, name)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't record character pick (%w).", err)
+	}
+	return nil
+}
+
+// Adds to how long a character was used in a room.
+func (d *Database) AddCharTime(room string, name string, dur time.Duration) error {
+	secs := int64(dur.Seconds())
+	_, err := d.exec(`
+    INSERT INTO char_usage (room, name, seconds) VALUES (?, ?, ?)
+    ON CONFLICT (room, name) DO UPDATE SET seconds = seconds + ?`,
+		room, name, secs, secs)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't record character time (%w).", err)
+	}
+	return nil
+}
+
+// Gets how much each character was used in the room, or in every room if `room` is
+// empty, the most used first.
+func (d *Database) GetCharUsage(room string) ([]CharUsage, error) {
+	rows, err := d.query(`
+    SELECT name, SUM(picks), SUM(seconds) FROM char_usage
+    WHERE ? = '' OR room = ?
+    GROUP BY name
+    ORDER BY SUM(seconds) DESC, SUM(picks) DESC`,
+		room, room)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query database (%w).", err)
+	}
+	defer rows.Close()
+	var usage []CharUsage
+	for rows.Next() {
+		var u CharUsage
+		var secs int64
+		if err := rows.Scan(&u.Name, &u.Picks, &secs); err != nil {
+			return usage, fmt.Errorf("db: Error scanning row (%w).", err)
+		}
+		u.Time = time.Duration(secs) * time.Second
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// Records a failed login, so brute-force attempts can be audited.
+func (d *Database) AddLoginFailure(ipid string, username string) error {
+	_, err := d.exec(`
+    INSERT INTO login_failures
+        (ipid, username, time)
+    VALUES
+        (?, ?, ?)`,
+		ipid, username, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("db: Couldn't insert login failure (%w).", err)
+	}
+	return nil
+}
+
+// Adds a new ban on an IP range, in CIDR notation. Returns the ID of the new ban.
+func (d *Database) AddIPBan(cidr string, reason string, moderator string, duration time.Duration) (int, error) {
+	// Get time right away.
+	start := time.Now()
+	end := start.Add(duration)
+
+	res, err := d.exec(`
+    INSERT INTO ip_bans
+        (cidr, reason, moderator, start, end)
+    VALUES
+        (?, ?, ?, ?, ?)`,
+		cidr, reason, moderator, start.Unix(), end.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("db: Couldn't insert IP ban (%w).", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("db: Couldn't get IP ban ID (%w).", err)
+	}
+	return int(id), nil
+}
+
+// Gets all non-expired IP range bans.
+func (d *Database) GetIPBans() ([]IPBan, error) {
+	rows, err := d.query(`
+    SELECT ban_id, cidr, reason, moderator, start, end FROM ip_bans
+    WHERE end > ?`,
+		time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't query database (%w).", err)
+	}
+	defer rows.Close()
+
+	var bans []IPBan
+	for rows.Next() {
+		var ban IPBan
+		var start int64
+		var end int64
+		if err := rows.Scan(&ban.BanID, &ban.CIDR, &ban.Reason, &ban.Moderator, &start, &end); err != nil {
+			return bans, fmt.Errorf("db: Error scanning row (%w).", err)
+		}
+		ban.Start = time.Unix(start, 0)
+		ban.End = time.Unix(end, 0)
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+// Nullifies an IP range ban by setting its end time to the current time.
+func (d *Database) NullIPBan(id int) error {
+	res, err := d.exec("UPDATE ip_bans SET end = ? WHERE ban_id = ?", time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't null IP ban (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("db: No IP ban with ID %v.", id)
+	}
+	return nil
+}
+
+// Grants a role to an IPID for the passed duration.
+func (d *Database) AddRoleGrant(ipid string, role string, moderator string, duration time.Duration) error {
+	// Get time right away.
+	start := time.Now()
+	end := start.Add(duration)
+
+	_, err := d.exec(`
+    INSERT INTO role_grants
+        (ipid, role, moderator, start, end)
+    VALUES
+        (?, ?, ?, ?, ?)`,
+		ipid, role, moderator, start.Unix(), end.Unix())
+	if err != nil {
+		return fmt.Errorf("db: Couldn't insert role grant (%w).", err)
+	}
+	return nil
+}
+
+// Gets the latest non-expired role grant for the passed IPID. If there is none, `ok` is `false`.
+func (d *Database) ActiveRoleGrant(ipid string) (grant RoleGrant, ok bool, err error) {
+	row := d.queryRow(`
+    SELECT grant_id, ipid, role, moderator, start, end FROM role_grants
+    WHERE ipid = ? AND end > ?
+    ORDER BY start DESC LIMIT 1`,
+		ipid, time.Now().Unix())
+	var start int64
+	var end int64
+	if err := row.Scan(&grant.GrantID, &grant.IPID, &grant.Role, &grant.Moderator, &start, &end); err != nil {
+		if err == sql.ErrNoRows {
+			return RoleGrant{}, false, nil
+		}
+		return RoleGrant{}, false, fmt.Errorf("db: Couldn't query role grants (%w).", err)
+	}
+	grant.Start = time.Unix(start, 0)
+	grant.End = time.Unix(end, 0)
+	return grant, true, nil
+}
+
 // Adds a new user that can authenticate to the passed role.
 func (d *Database) AddAuth(username string, password string, role string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("db: Error hashing password (%w).", err)
 	}
-	_, err = d.db.Exec(`
+	_, err = d.exec(`
     INSERT INTO auth
         (username, password, role)
     VALUES
@@ -241,7 +951,7 @@ func (d *Database) AddAuth(username string, password string, role string) error
 }
 
 // func (d *Database) UserExists(username string) (bool, error) {
-//     r := d.db.QueryRow("SELECT NULL FROM auth WHERE username = ?", username)
+//     r := d.queryRow("SELECT NULL FROM auth WHERE username = ?", username)
 //     if err := r.Scan(); err != nil {
 //         if err != sql.ErrNoRows {
 //             return false, err
@@ -254,10 +964,7 @@ func (d *Database) AddAuth(username string, password string, role string) error
 // Checks whether a given username and password authenticate to a user. Returns whether the authentication
 // was successful and the role the user has been authenticated to, along with an error should a DB error happen.
 func (d *Database) CheckAuth(username string, password string) (ok bool, role string, err error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	row := d.db.QueryRow("SELECT password, role FROM auth WHERE username = ?", username)
+	row := d.queryRow("SELECT password, role FROM auth WHERE username = ?", username)
 	var hash string
 	// var role string
 	if err := row.Scan(&hash, &role); err != nil {
@@ -273,20 +980,121 @@ func (d *Database) CheckAuth(username string, password string) (ok bool, role st
 	return true, role, nil
 }
 
+// Returns the role a user authenticates to. If the user doesn't exist, `ok` is `false`.
+func (d *Database) UserRole(username string) (role string, ok bool, err error) {
+	row := d.queryRow("SELECT role FROM auth WHERE username = ?", username)
+	if err := row.Scan(&role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("db: Couldn't query role (%w).", err)
+	}
+	return role, true, nil
+}
+
 // Removes a user from the auth table.
 func (d *Database) RemoveAuth(username string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	if _, err := d.db.Exec("DELETE FROM auth WHERE username = ?", username); err != nil {
+	res, err := d.exec("DELETE FROM auth WHERE username = ?", username)
+	if err != nil {
 		return fmt.Errorf("db: Couldn't remove user (%w).", err)
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("db: No user named '%v'.", username)
+	}
+	if _, err := d.exec("DELETE FROM totp WHERE username = ?", username); err != nil {
+		return fmt.Errorf("db: Couldn't remove user's TOTP secret (%w).", err)
+	}
+	if _, err := d.exec("DELETE FROM totp_steps WHERE username = ?", username); err != nil {
+		return fmt.Errorf("db: Couldn't remove user's TOTP steps (%w).", err)
+	}
+	return nil
+}
+
+// Sets the TOTP secret of a user, so logging in as them requires a one-time code.
+func (d *Database) SetTOTPSecret(username string, secret string) error {
+	res, err := d.exec(`
+    INSERT OR REPLACE INTO totp (username, secret)
+    SELECT ?, ? WHERE EXISTS (SELECT 1 FROM auth WHERE username = ?)`,
+		username, secret, username)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't set TOTP secret (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("db: No user named '%v'.", username)
+	}
+	return nil
+}
+
+// Removes the TOTP secret of a user, so logging in as them only requires a password.
+func (d *Database) RemoveTOTPSecret(username string) error {
+	res, err := d.exec("DELETE FROM totp WHERE username = ?", username)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't remove TOTP secret (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("db: User '%v' doesn't have TOTP set up.", username)
+	}
+	return nil
+}
+
+// Gets the TOTP secret of a user. If the user doesn't have one, `ok` is `false`.
+func (d *Database) TOTPSecret(username string) (secret string, ok bool, err error) {
+	row := d.queryRow("SELECT secret FROM totp WHERE username = ?", username)
+	if err := row.Scan(&secret); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("db: Couldn't query TOTP secret (%w).", err)
+	}
+	return secret, true, nil
+}
+
+// Records that a user logged in with the one-time code for the time step. Returns `false`
+// if a code for that step or a later one was already used, in which case the code is a
+// replay and must be refused.
+func (d *Database) UseTOTPStep(username string, step int64) (bool, error) {
+	res, err := d.exec(`
+    INSERT INTO totp_steps (username, step) VALUES (?, ?)
+    ON CONFLICT (username) DO UPDATE SET step = excluded.step WHERE excluded.step > totp_steps.step`,
+		username, step)
+	if err != nil {
+		return false, fmt.Errorf("db: Couldn't record TOTP step (%w).", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// Changes a user's password, hashing the new one.
+func (d *Database) UpdatePassword(username string, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("db: Error hashing password (%w).", err)
+	}
+	res, err := d.exec("UPDATE auth SET password = ? WHERE username = ?", string(hash), username)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't update password (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("db: No user named '%v'.", username)
+	}
+	return nil
+}
+
+// Changes the role a user authenticates to.
+func (d *Database) UpdateRole(username string, role string) error {
+	res, err := d.exec("UPDATE auth SET role = ? WHERE username = ?", role, username)
+	if err != nil {
+		return fmt.Errorf("db: Couldn't update role (%w).", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("db: No user named '%v'.", username)
+	}
 	return nil
 }
 
 // Closes the database connection.
 func (d *Database) Close() error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	d.closeStmts()
 	if err := d.db.Close(); err != nil {
 		return fmt.Errorf("db: Error closing database (%w).", err)
 	}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// How long each kind of record is kept. Zero keeps them forever. Records that expire
+// (bans, mutes and role grants) are kept that long after they end, so active ones are
+// never pruned.
+type Retention struct {
+	Mutes         time.Duration
+	Bans          time.Duration
+	IPBans        time.Duration
+	RoleGrants    time.Duration
+	ModCalls      time.Duration
+	Warnings      time.Duration
+	LoginFailures time.Duration
+}
+
+// How many records of each kind were pruned.
+type Pruned struct {
+	Mutes         int64
+	Bans          int64
+	IPBans        int64
+	RoleGrants    int64
+	ModCalls      int64
+	Warnings      int64
+	LoginFailures int64
+}
+
+// Returns how many records were pruned in total.
+func (p Pruned) Total() int64 {
+	return p.Mutes + p.Bans + p.IPBans + p.RoleGrants + p.ModCalls + p.Warnings + p.LoginFailures
+}
+
+// Deletes the records older than the retention allows.
+func (d *Database) Prune(r Retention) (Pruned, error) {
+	var p Pruned
+	prunes := []struct {
+		name  string
+		keep  time.Duration
+		query string
+		count *int64
+	}{
+		{"mutes", r.Mutes, "DELETE FROM mutes WHERE end != 0 AND end < ?", &p.Mutes},
+		{"bans", r.Bans, "DELETE FROM bans WHERE end < ?", &p.Bans},
+		{"IP bans", r.IPBans, "DELETE FROM ip_bans WHERE end < ?", &p.IPBans},
+		{"role grants", r.RoleGrants, "DELETE FROM role_grants WHERE end < ?", &p.RoleGrants},
+		{"mod calls", r.ModCalls, "DELETE FROM modcalls WHERE time < ?", &p.ModCalls},
+		{"warnings", r.Warnings, "DELETE FROM warnings WHERE time < ?", &p.Warnings},
+		{"login failures", r.LoginFailures, "DELETE FROM login_failures WHERE time < ?", &p.LoginFailures},
+	}
+	now := time.Now()
+	for _, pr := range prunes {
+		if pr.keep <= 0 {
+			continue
+		}
+		res, err := d.db.Exec(pr.query, now.Add(-pr.keep).Unix())
+		if err != nil {
+			return p, fmt.Errorf("db: Couldn't prune %v (%w).", pr.name, err)
+		}
+		*pr.count, _ = res.RowsAffected()
+	}
+	return p, nil
+}
+
+// Rebuilds the database file, giving the space left by deleted records back to the system.
+func (d *Database) Vacuum() error {
+	if _, err := d.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("db: Couldn't vacuum database (%w).", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Returns the prepared statement for the query, preparing it the first time it's used.
+// Statements are safe for concurrent use, and are closed with the database.
+func (d *Database) stmt(query string) (*sql.Stmt, error) {
+	d.stmtsMu.Lock()
+	defer d.stmtsMu.Unlock()
+	if st, ok := d.stmts[query]; ok {
+		return st, nil
+	}
+	st, err := d.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("db: Couldn't prepare statement (%w).", err)
+	}
+	d.stmts[query] = st
+	return st, nil
+}
+
+// Like [sql.DB.Exec], with a cached prepared statement.
+func (d *Database) exec(query string, args ...any) (sql.Result, error) {
+	st, err := d.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return st.Exec(args...)
+}
+
+// Like [sql.DB.Query], with a cached prepared statement.
+func (d *Database) query(query string, args ...any) (*sql.Rows, error) {
+	st, err := d.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return st.Query(args...)
+}
+
+// A row returned by [Database.queryRow].
+type row interface {
+	Scan(dest ...any) error
+}
+
+// A row whose statement couldn't be prepared, which returns the error when scanned.
+type errRow struct{ err error }
+
+func (r errRow) Scan(dest ...any) error {
+	return r.err
+}
+
+// Like [sql.DB.QueryRow], with a cached prepared statement.
+func (d *Database) queryRow(query string, args ...any) row {
+	st, err := d.stmt(query)
+	if err != nil {
+		return errRow{err}
+	}
+	return st.QueryRow(args...)
+}
+
+// Closes every cached statement.
+func (d *Database) closeStmts() {
+	d.stmtsMu.Lock()
+	defer d.stmtsMu.Unlock()
+	for query, st := range d.stmts {
+		st.Close()
+		delete(d.stmts, query)
+	}
+}
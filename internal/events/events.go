@@ -0,0 +1,141 @@
+// Package `events` implements a bus for things that happen in the server, so that
+// subsystems such as logging, notifications and statistics can react to them without
+// the code that makes them happen knowing about each one.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// The kind of an Event.
+type Kind int
+
+const (
+	Join    Kind = iota // a client joined the server
+	Leave               // a joined client left the server
+	IC                  // an IC message was sent
+	OOC                 // an OOC message was sent
+	Music               // a song was played
+	ModCall             // a moderator was called
+	Kick                // a client was kicked
+	Ban                 // someone was banned
+)
+
+var kindToString = map[Kind]string{
+	Join:    "join",
+	Leave:   "leave",
+	IC:      "ic",
+	OOC:     "ooc",
+	Music:   "music",
+	ModCall: "modcall",
+	Kick:    "kick",
+	Ban:     "ban",
+}
+
+func (k Kind) String() string {
+	return kindToString[k]
+}
+
+// Something that happened in the server. Which fields are set depends on the kind.
+type Event struct {
+	Kind Kind
+	Time time.Time
+
+	Client *client.Client // the client who caused the event, if any
+	Room   *room.Room     // the room the event happened in, if any
+	Actor  string         // who caused the event, when it wasn't a client (e.g. a moderator through RPC)
+	Target string         // who the event was done to, for moderator actions
+
+	Name     string        // the name used, e.g. the showname for IC messages
+	Text     string        // the message, song or reason
+	Duration time.Duration // for bans
+}
+
+// Handles an event. Handlers are called on the publisher's goroutine, so they
+// shouldn't block.
+type Handler func(Event)
+
+// Decides whether a subscriber gets an event.
+type Filter func(Event) bool
+
+// Identifies a subscription, so it can be cancelled with [Bus.Unsubscribe].
+type Subscription int
+
+type subscriber struct {
+	id     Subscription
+	h      Handler
+	filter Filter        // nil means every event
+	kinds  map[Kind]bool // nil means every kind
+}
+
+// A Bus delivers events to the handlers subscribed to them. Its methods can be called
+// from multiple goroutines.
+type Bus struct {
+	subs   []subscriber
+	nextID Subscription
+	mu     sync.RWMutex
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribes the handler to the passed kinds of events, or to every event if no kinds
+// are passed.
+func (b *Bus) Subscribe(h Handler, kinds ...Kind) Subscription {
+	return b.SubscribeFiltered(h, nil, kinds...)
+}
+
+// Like [Bus.Subscribe], but the handler only gets the events the filter accepts.
+// The filter is called on the publisher's goroutine too.
+func (b *Bus) SubscribeFiltered(h Handler, filter Filter, kinds ...Kind) Subscription {
+	sub := subscriber{h: h, filter: filter}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[Kind]bool, len(kinds))
+		for _, k := range kinds {
+			sub.kinds[k] = true
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	sub.id = b.nextID
+	b.subs = append(b.subs, sub)
+	return sub.id
+}
+
+// Cancels the subscription. Events already being delivered may still reach it.
+func (b *Bus) Unsubscribe(id Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, sub := range b.subs {
+		if sub.id == id {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Delivers the event to its subscribers, in the order they subscribed in. If the
+// event's time isn't set, it's set to now.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, sub := range subs {
+		if sub.kinds != nil && !sub.kinds[e.Kind] {
+			continue
+		}
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		sub.h(e)
+	}
+}
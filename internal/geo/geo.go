@@ -0,0 +1,252 @@
+// Package `geo` implements connection policies based on the country and ASN of an IP,
+// e.g. for blocking VPNs and datacenter ranges.
+package geo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/config"
+)
+
+// How long results from the external API are cached, and how many entries are cached
+// before expired ones are cleaned up.
+const (
+	cacheTTL  = time.Hour
+	maxCached = 4096
+)
+
+// Information about an IP.
+type Info struct {
+	Country string // ISO 3166-1 alpha-2 code, e.g. "BR"
+	ASN     int
+	Proxy   bool // whether the IP is a known proxy, VPN or hosting provider (API only)
+}
+
+// A Lookup finds information about an IP.
+type Lookup interface {
+	Lookup(ip net.IP) (Info, error)
+}
+
+// A Policy decides whether connections are allowed according to the information
+// about their IP. Its methods can be called from multiple goroutines.
+type Policy struct {
+	lookups   []Lookup
+	countries map[string]struct{}
+	asns      map[int]struct{}
+	proxies   bool
+	whitelist map[string]struct{} // IPIDs
+}
+
+// Creates a new Policy according to the configuration. If the policy is disabled,
+// returns `nil`, which is a valid Policy that allows everything.
+func NewPolicy(conf config.Geo) (*Policy, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+	p := &Policy{
+		countries: make(map[string]struct{}),
+		asns:      make(map[int]struct{}),
+		proxies:   conf.BlockProxies,
+		whitelist: make(map[string]struct{}),
+	}
+	for _, c := range conf.BlockedCountries {
+		p.countries[strings.ToUpper(c)] = struct{}{}
+	}
+	for _, a := range conf.BlockedASNs {
+		p.asns[a] = struct{}{}
+	}
+	for _, id := range conf.Whitelist {
+		p.whitelist[id] = struct{}{}
+	}
+	if conf.ASNFile != "" {
+		l, err := loadTable(conf.ASNFile)
+		if err != nil {
+			return nil, err
+		}
+		p.lookups = append(p.lookups, l)
+	}
+	if conf.APIURL != "" {
+		p.lookups = append(p.lookups, newAPILookup(conf.APIURL))
+	}
+	if len(p.lookups) == 0 {
+		return nil, fmt.Errorf("geo: Enabled, but neither an ASN file nor an API URL was configured.")
+	}
+	return p, nil
+}
+
+// Checks whether a connection from the IP (with the passed IPID) is allowed.
+// If not, returns the reason. Lookup failures allow the connection.
+func (p *Policy) Check(ip net.IP, ipid string) (ok bool, reason string, err error) {
+	if p == nil {
+		return true, "", nil
+	}
+	if _, ok := p.whitelist[ipid]; ok {
+		return true, "", nil
+	}
+	var info Info
+	for _, l := range p.lookups {
+		i, lerr := l.Lookup(ip)
+		if lerr != nil {
+			err = lerr
+			continue
+		}
+		// Later lookups fill in what earlier ones didn't know.
+		if info.Country == "" {
+			info.Country = i.Country
+		}
+		if info.ASN == 0 {
+			info.ASN = i.ASN
+		}
+		info.Proxy = info.Proxy || i.Proxy
+	}
+	if _, ok := p.countries[info.Country]; ok && info.Country != "" {
+		return false, fmt.Sprintf("country %v is blocked", info.Country), err
+	}
+	if _, ok := p.asns[info.ASN]; ok && info.ASN != 0 {
+		return false, fmt.Sprintf("AS%v is blocked", info.ASN), err
+	}
+	if p.proxies && info.Proxy {
+		return false, "proxies and VPNs are blocked", err
+	}
+	return true, "", err
+}
+
+// A table of IP ranges, as in the TSV files distributed by iptoasn.com, with the format:
+// `range_start	range_end	AS_number	country_code	AS_description`.
+type table struct {
+	ranges []ipRange // sorted by start
+}
+
+type ipRange struct {
+	start   net.IP // always 16 bytes
+	end     net.IP
+	asn     int
+	country string
+}
+
+func loadTable(path string) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("geo: Couldn't open ASN file (%w).", err)
+	}
+	defer f.Close()
+
+	var t table
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 4 {
+			continue
+		}
+		start := net.ParseIP(fields[0])
+		end := net.ParseIP(fields[1])
+		asn, err := strconv.Atoi(fields[2])
+		if start == nil || end == nil || err != nil {
+			return nil, fmt.Errorf("geo: Malformed ASN file at line %v.", line)
+		}
+		country := fields[3]
+		if country == "None" {
+			country = ""
+		}
+		t.ranges = append(t.ranges, ipRange{start.To16(), end.To16(), asn, country})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("geo: Couldn't read ASN file (%w).", err)
+	}
+	sort.Slice(t.ranges, func(i, j int) bool {
+		return bytes.Compare(t.ranges[i].start, t.ranges[j].start) < 0
+	})
+	return &t, nil
+}
+
+func (t *table) Lookup(ip net.IP) (Info, error) {
+	ip = ip.To16()
+	// Find the last range that starts at or before the IP.
+	i := sort.Search(len(t.ranges), func(i int) bool {
+		return bytes.Compare(t.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, t.ranges[i].end) > 0 {
+		return Info{}, nil
+	}
+	return Info{Country: t.ranges[i].country, ASN: t.ranges[i].asn}, nil
+}
+
+// Looks IPs up in an external JSON API, such as ip-api.com. The URL should contain
+// "{ip}", which is replaced by the IP. The response may have the fields "countryCode",
+// "as" (e.g. "AS15169 Google LLC"), "proxy" and "hosting".
+type apiLookup struct {
+	url   string
+	http  *http.Client
+	cache map[string]cached
+	mu    sync.Mutex
+}
+
+type cached struct {
+	info Info
+	time time.Time
+}
+
+type apiReply struct {
+	CountryCode string `json:"countryCode"`
+	AS          string `json:"as"`
+	Proxy       bool   `json:"proxy"`
+	Hosting     bool   `json:"hosting"`
+}
+
+func newAPILookup(url string) *apiLookup {
+	return &apiLookup{
+		url:   url,
+		http:  &http.Client{Timeout: 3 * time.Second},
+		cache: make(map[string]cached),
+	}
+}
+
+func (a *apiLookup) Lookup(ip net.IP) (Info, error) {
+	key := ip.String()
+	a.mu.Lock()
+	if c, ok := a.cache[key]; ok && time.Since(c.time) < cacheTTL {
+		a.mu.Unlock()
+		return c.info, nil
+	}
+	a.mu.Unlock()
+
+	resp, err := a.http.Get(strings.ReplaceAll(a.url, "{ip}", key))
+	if err != nil {
+		return Info{}, fmt.Errorf("geo: Couldn't query API (%w).", err)
+	}
+	defer resp.Body.Close()
+	var reply apiReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return Info{}, fmt.Errorf("geo: Bad API response (%w).", err)
+	}
+
+	info := Info{Country: reply.CountryCode, Proxy: reply.Proxy || reply.Hosting}
+	if as, _, _ := strings.Cut(reply.AS, " "); strings.HasPrefix(as, "AS") {
+		info.ASN, _ = strconv.Atoi(as[2:])
+	}
+
+	a.mu.Lock()
+	if len(a.cache) >= maxCached {
+		for k, c := range a.cache {
+			if time.Since(c.time) >= cacheTTL {
+				delete(a.cache, k)
+			}
+		}
+	}
+	a.cache[key] = cached{info, time.Now()}
+	a.mu.Unlock()
+	return info, nil
+}
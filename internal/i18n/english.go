@@ -0,0 +1,228 @@
+package i18n
+
+// The built-in English messages. Language files use the same keys.
+var english = map[string]string{
+	"server.full":         "The server is full.",
+	"server.joined":       "%s has joined the server!",
+	"server.disconnected": "%s has disconnected.",
+	"server.queued":       "The server is full. You are number %v in the queue, and will join once a slot frees up.",
+	"server.temp_role":    "You have the temporary role '%v' until %s.",
+	"server.role_expired": "Your temporary role '%v' has expired.",
+	"server.lockdown":     "The server is in lockdown and isn't accepting new players. Try again later.",
+	"server.raid":         "The server is in raid mode and isn't accepting new players. Try again later.",
+
+	"gate.password":    "This server is password-protected. Enter the password with /serverpass [password] to play.",
+	"gate.rules":       "You must agree to the rules before playing. Read them with /rules, then use /agree.",
+	"gate.raid":        "The server is in raid mode. Log in with /login to play.",
+	"gate.raid_pass":   "The server is in raid mode. Log in with /login, or enter the raid password with /serverpass [password], to play.",
+	"gate.wrong":       "Wrong password.",
+	"gate.too_many":    "Too many wrong passwords. Try again later.",
+	"gate.no_password": "This server doesn't have a password, or you have already entered it.",
+	"gate.agreed":      "You have already agreed to the rules.",
+	"gate.done":        "You can now pick a character.",
+
+	"cmd.unknown":         "'/%v' is an unknown command. Use /help to see a list of commands.",
+	"cmd.not_enough_args": "Not enough arguments for /%v.\n Usage of /%v: %v",
+	"cmd.no_perms":        "You do not have the required permisions to use /%v (missing: %v).",
+	"cmd.usage":           "Usage of /%v: %v",
+	"cmd.lobby":           "/%v can't be used in a lobby.",
+	"cmd.bad_uid":         "'%v' is not a valid UID.",
+	"cmd.no_uid":          "No client with UID '%v'.",
+	"cmd.no_room":         "There is no room with the ID or name '%v'.",
+
+	"lang.current": "Your language is '%v'. Available languages: %v.",
+	"lang.unknown": "'%v' is not an available language. Available languages: %v.",
+	"lang.set":     "Your language is now '%v'.",
+
+	"ic.spectator":         "Spectators cannot speak.",
+	"ic.lobby":             "IC chat is disabled in this room. Move to another room to play.",
+	"ic.slowmode":          "Slow mode is on in this room. You can speak again in %v.",
+	"ic.muted":             "You are IC muted!",
+	"ic.not_invited":       "This room is in spectatable mode and you are not on the invite list.",
+	"ic.invalid_deskmod":   "Invalid deskmod.",
+	"ic.no_iniswap":        "Iniswapping is not allowed in this room!",
+	"ic.no_blankpost":      "Blankposting is not allowed in this room!",
+	"ic.duplicate":         "You just sent that message! Watch out for lag.",
+	"ic.no_shouts":         "Shhh! Shouting is not allowed in this room!",
+	"ic.banned_color":      "That text color is not allowed in this room!",
+	"ic.showname_too_long": "Your showname is too long!",
+	"ic.pair_request":      "%v wants to pair with you!",
+	"ic.pair_position":     "You're not in the same position as your pairing partner! Their pos is '%v'.",
+
+	"ooc.muted":             "You are OOC muted!",
+	"ooc.blank":             "Cannot send blank OOC message.",
+	"ooc.no_username":       "Set a username to send OOC messages!",
+	"ooc.username_too_long": "Your username is too long!",
+	"ooc.username_taken":    "Username '%v' is already in use in the server.",
+
+	"msg.too_long": "Your message is too long!",
+
+	"mute.expired":     "Your %v mute has expired.",
+	"mute.none":        "You have no mutes or bans.",
+	"mute.until":       "%v mute, until lifted by a moderator.",
+	"mute.remaining":   "%v mute, %v left.",
+	"mute.ban":         "Ban #%v, %v left. Reason: %s",
+	"mute.past":        "Past %v mute from %v, for %v. Reason: %s",
+	"mute.muted":       "%v muted UID %v until unmuted.",
+	"mute.muted_for":   "%v muted UID %v for %v.",
+	"mute.not_muted":   "UID %v isn't muted.",
+	"mute.unmuted":     "You have been unmuted.",
+	"mute.unmuted_uid": "Unmuted UID %v.",
+
+	"judge.muted":       "You are currently blocked from using judge commands.",
+	"room.spectating":   "You are only allowed to spectate in this area.",
+	"room.manager_only": "That character can only be used by room managers.",
+
+	"modcall.cooldown": "You must wait %v before calling a moderator again.",
+	"modcall.heard":    "A moderator has been notified of your call.",
+	"modcall.offline":  "No moderators are online right now. Your call has been recorded and will be seen once one comes online.",
+
+	"case.no_perms": "You need the \"status\" permission to announce cases.",
+	"case.cooldown": "You must wait %v before announcing another case.",
+	"case.sent":     "Your case was announced to %v user(s).",
+
+	"move.same_room":       "You are already in this room!",
+	"move.cooldown":        "You must wait %v before changing rooms again.",
+	"move.adjacent_only":   "You can only move to adjacent rooms from here.",
+	"move.not_invited":     "You are not invited to this room!",
+	"move.spectators_full": "This room has reached its spectator limit.",
+	"move.needs_approval":  "This room requires approval to pick a character. Changing to Spectator.",
+	"move.char_taken":      "Your character in this room is taken. Changing to Spectator.",
+	"move.char_not_listed": "Your character is not in this room's list. Changing to Spectator.",
+	"move.char_restricted": "Your character can only be used by managers in this room. Changing to Spectator.",
+	"move.moved":           "Moved to [%v] %s. Description: %s",
+	"move.title":           "Title: %s",
+	"move.enters":          "%s enters from [%v] %s.",
+	"move.leaves":          "%s leaves to [%v] %s.",
+	"move.forced":          "A moderator moved you to [%v] %s.",
+
+	"music.from_queue": "Now playing '%v' from the queue.",
+	"music.lobby":      "The music in this room can't be changed.",
+
+	"confirm.ask":  "You are about to %v. Send /confirm within 30 seconds to go ahead.",
+	"confirm.none": "There is nothing to confirm.",
+
+	"user.confirm_add":    "add the user '%v' with the role '%v'",
+	"user.confirm_remove": "remove the user '%v'",
+	"user.confirm_role":   "change the role of the user '%v' to '%v'",
+
+	"alert.alt":         "Possible alt: a client from IPID %v has the same HDID as %s.",
+	"alert.evasion":     "Possible ban evasion: client from IPID %v has an HDID matching ban #%v (IPID: %v, reason: %s).",
+	"alert.muted":       "%s %v muted %s until unmuted. Reason: %s",
+	"alert.muted_for":   "%s %v muted %s for %v. Reason: %s",
+	"alert.auto_muted":  "%s was automatically %v muted for %v for %v.",
+	"alert.raid_on":     "%v turned raid mode on until it's turned off.",
+	"alert.raid_on_for": "%v turned raid mode on for %v.",
+	"alert.raid_off":    "%v turned raid mode off.",
+	"alert.raid_ended":  "Raid mode has ended.",
+
+	"announce.message": "[Announcement] %s",
+
+	"slowmode.on":         "Slow mode is on in this room: one IC message every %v.",
+	"slowmode.off":        "Slow mode is off in this room.",
+	"slowmode.turned_on":  "Slow mode is now on in this room: one IC message every %v.",
+	"slowmode.turned_off": "Slow mode is now off in this room.",
+
+	"aliases.no_hdid": "UID %v hasn't sent an HDID.",
+	"aliases.error":   "Couldn't get the aliases: internal error.",
+	"aliases.none":    "No IPIDs have been seen with the HDID of UID %v.",
+	"aliases.header":  ">>> IPIDs seen with the HDID of UID %v <<<",
+	"aliases.entry":   "%v: first seen %v, last seen %v",
+
+	"ban.no_duration":     "Pass a duration, such as 3d, or one of the presets (%v).",
+	"ban.error":           "Couldn't ban: internal error.",
+	"ban.banned_uid":      "Banned UID %v for %v.",
+	"ban.banned_ipid":     "Banned IPID %v for %v.",
+	"ban.not_connected":   "No connected user has the UID or IPID '%v'. To ban an IPID that isn't connected, use /ban --offline.",
+	"ban.confirm_offline": "ban the IPID %v, which isn't connected, for %v (reason: %s)",
+	"ban.confirm_unknown": "ban the IPID %v, which has never joined, for %v (reason: %s)",
+
+	"bg.current":  "The background of this room is '%v'.",
+	"bg.locked":   "The background of this room is locked.",
+	"bg.unknown":  "'%v' isn't a valid background. See the list with /bglist.",
+	"bg.changed":  "%v changed the background to '%v'.",
+	"bg.no_list":  "This server doesn't have a background list, so any background can be used.",
+	"bg.bad_page": "Pick a page between 1 and %v.",
+	"bg.list":     ">>> Backgrounds (page %v of %v) <<<",
+
+	"charlists.current":   "This room uses the character lists: %v.\nAvailable lists: %v.",
+	"charlists.unknown":   "There is no character list named '%v'.",
+	"charlists.empty":     "Those lists have no characters.",
+	"charlists.changed":   "The character list of this room was changed to: %v.",
+	"charlists.spectator": "Your character isn't in the new list, so you are now a spectator.",
+
+	"charstats.server": "the server",
+	"charstats.error":  "Couldn't get the character statistics.",
+	"charstats.none":   "No characters have been used in %v yet.",
+	"charstats.header": ">>> Most used characters in %v <<<",
+	"charstats.entry":  "%v. %s: %v, picked %v times",
+	"charstats.unused": "%v of the room's %v characters were never used.",
+
+	"clearroom.no_room": "There is no room adjacent to this one with the ID or name '%v'.",
+	"clearroom.reset":   "%v reset the room to its defaults.",
+	"clearroom.stuck":   "Couldn't move %v user(s): [%v] %s has reached its spectator limit.",
+	"clearroom.moved":   "Moved everyone to [%v] %s.",
+
+	"forcemove.already": "UID %v is already in [%v] %s.",
+	"forcemove.full":    "Couldn't move UID %v: [%v] %s has reached its spectator limit.",
+	"forcemove.moved":   "Moved UID %v to [%v] %s.",
+
+	"kickroom.confirm": "kick everyone but staff from [%v] %s",
+	"kickroom.reason":  "Everyone in the room was kicked.",
+	"kickroom.done":    "Kicked %v user(s) from [%v] %s.",
+
+	"lockdown.on":      "The server is in lockdown. Lift it with /lockdown off.",
+	"lockdown.off":     "The server isn't in lockdown.",
+	"lockdown.already": "The server is already in lockdown.",
+	"lockdown.confirm": "lock down the server, locking every room and letting only staff join",
+	"lockdown.started": "The server is in lockdown: every room is locked, and new players can't join.",
+	"lockdown.done":    "Locked down the server. Lift the lockdown with /lockdown off.",
+	"lockdown.lifted":  "The lockdown was lifted, and the rooms have their previous locks again.",
+
+	"raid.off":      "Raid mode is off.",
+	"raid.on":       "Raid mode is on until it's turned off.",
+	"raid.on_until": "Raid mode is on until %v.",
+	"raid.not_on":   "Raid mode isn't on.",
+
+	"side.current":     "Your side is '%v'. This room's sides are: %v.",
+	"side.unknown":     "'%v' isn't one of this room's sides: %v.",
+	"side.restricted":  "Your character can only use the sides: %v.",
+	"side.set":         "Your side is now '%v'.",
+	"side.exists":      "This room already has the side '%v'.",
+	"side.added":       "%v added the side '%v' to this room.",
+	"side.cant_remove": "This room doesn't have the side '%v', or it's the room's only side.",
+	"side.removed":     "%v removed the side '%v' from this room.",
+
+	"title.current":     "The title of this room is: %s",
+	"title.none":        "This room has no title.",
+	"title.clear_error": "Couldn't clear the title: internal error.",
+	"title.cleared":     "%v cleared the room's title.",
+	"title.too_long":    "That title is too long.",
+	"title.error":       "Couldn't change the title: internal error.",
+	"title.changed":     "%v changed the room's title: %s",
+
+	"transcript.empty":    "There are no IC messages to save.",
+	"transcript.error":    "Couldn't save the transcript.",
+	"transcript.saved":    "Saved a transcript of %v messages: %v",
+	"transcript.saved_to": "Saved a transcript of %v messages to %v.",
+
+	"warn.error":       "Couldn't record the warning.",
+	"warn.kick_reason": "%v unacknowledged warnings. Last warning: %s",
+	"warn.kicked":      "Warned UID %v. They had %v unacknowledged warnings, so they were kicked.",
+	"warn.warned":      "You have been warned by a moderator: %s\n\nUse /ack in OOC to acknowledge this warning.",
+	"warn.done":        "Warned UID %v. They have %v unacknowledged warnings.",
+	"warn.ack_error":   "Couldn't acknowledge your warnings.",
+	"warn.ack_none":    "You have no warnings to acknowledge.",
+	"warn.acked":       "Acknowledged %v warnings.",
+	"warn.reminder":    "You have %v unacknowledged warnings from the moderators. Use /ack in OOC to acknowledge them.",
+
+	"watch.prefix":       "[Watch: [%v] %s]",
+	"watch.kicked":       "%s was kicked by %s. Reason: %s",
+	"watch.none":         "You aren't watching any rooms.",
+	"watch.list":         "You are watching: %v.",
+	"watch.already":      "You are already watching [%v] %s.",
+	"watch.started":      "Now watching [%v] %s. Its OOC messages and kicks will be sent to you.",
+	"watch.stopped_all":  "You are no longer watching any rooms.",
+	"watch.not_watching": "You aren't watching [%v] %s.",
+	"watch.stopped":      "No longer watching [%v] %s.",
+}
@@ -0,0 +1,72 @@
+// Package `i18n` translates the messages the server sends to users. Messages are
+// identified by keys (e.g. "ic.muted") and are format strings, as in [fmt.Sprintf].
+package i18n
+
+import (
+	"fmt"
+	"slices"
+)
+
+// The language whose messages are built into the server, and which every other
+// language falls back to.
+const Builtin = "en"
+
+// A Catalog holds the messages of every language. Its methods can be called from
+// multiple goroutines.
+type Catalog struct {
+	langs map[string]map[string]string
+	def   string
+}
+
+// Creates a new Catalog from the messages of each language, by language code, using
+// `def` for users who haven't picked a language. The built-in English messages are
+// always available, but can be overridden.
+func New(langs map[string]map[string]string, def string) (*Catalog, error) {
+	c := &Catalog{langs: make(map[string]map[string]string), def: def}
+	c.langs[Builtin] = english
+	for lang, msgs := range langs {
+		if lang == Builtin {
+			merged := make(map[string]string, len(english)+len(msgs))
+			for k, v := range english {
+				merged[k] = v
+			}
+			for k, v := range msgs {
+				merged[k] = v
+			}
+			msgs = merged
+		}
+		c.langs[lang] = msgs
+	}
+	if !c.Has(def) {
+		return nil, fmt.Errorf("i18n: Default language '%v' has no messages.", def)
+	}
+	return c, nil
+}
+
+// Returns whether the language is available.
+func (c *Catalog) Has(lang string) bool {
+	_, ok := c.langs[lang]
+	return ok
+}
+
+// Returns the available languages, in alphabetical order.
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.langs))
+	for lang := range c.langs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Returns the message with the passed key in the language, formatted with the
+// arguments. An empty language means the default one. Messages missing from the
+// language are taken from the default language, and then from the built-in one.
+func (c *Catalog) Message(lang string, key string, a ...any) string {
+	for _, l := range [...]string{lang, c.def, Builtin} {
+		if msg, ok := c.langs[l][key]; ok {
+			return fmt.Sprintf(msg, a...)
+		}
+	}
+	return key
+}
@@ -3,6 +3,7 @@ package perms
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lambdcalculus/scs/internal/config"
 )
@@ -27,6 +28,14 @@ const (
 	Ban
 	// Permission to bypass locks (e.g. room locks, background locks, etc.).
 	BypassLocks
+	// Permission to add, remove and change the roles of authenticated users.
+	ModifyDatabase
+	// Permission to make server-wide announcements.
+	Announce
+	// Permission to move other users between rooms.
+	ForceMove
+	// Permission to lock down the server and kick everyone from a room, for raids.
+	Lockdown
 
 	// Room stuff.
 
@@ -40,10 +49,32 @@ const (
 	Background
 	// Permission to change the room's ambiance track (does not bypass ambiance lock).
 	Ambiance
+	// Permission to reserve the room's characters and approve who can pick them.
+	Characters
+	// Permission to skip and clear the room's music queue.
+	Music
+	// Permission to start and close polls in the room.
+	Polls
+	// Permission to change the room's toggles (blankposting, shouts and immediate preanims).
+	Settings
+	// Permission to export transcripts of the room's IC chat.
+	Transcripts
+	// Permission to use the judge controls when the room restricts them.
+	Judge
+	// Permission to manage the room's evidence. Reserved: the server doesn't handle
+	// evidence yet, so nothing checks it.
+	Evidence
+	// Permission to play music in the room even when its music is locked (e.g. for DJs).
+	Play
 
 	All Mask = 0xffffffff
 )
 
+// The permissions that only concern the room the user is in. These are the only ones
+// rooms can grant or revoke through their permission overrides.
+const RoomMask Mask = Status | Lock | Description | Background | Ambiance | Characters | Music | Polls | Settings | Transcripts |
+	Judge | Evidence | Play
+
 type Role struct {
 	Name  string
 	Perms Mask
@@ -54,35 +85,143 @@ func (r *Role) Check(p Mask) bool {
 	return r.Perms&p == p
 }
 
-var stringToPerm = map[string]Mask{
-	"hear_modcall": HearModCalls,
-	"see_ipids":    SeeIPIDs,
-	"mute":         Mute,
-	"kick":         Kick,
-	"ban":          Ban,
-	"bypass_locks": BypassLocks,
-	"status":       Status,
-	"description":  Description,
-	"background":   Background,
-	"ambiance":     Ambiance,
-	"all":          All,
+// A permission, with the name it has in the configs and a description for users.
+type Info struct {
+	Name string
+	Mask Mask
+	Desc string
+}
+
+// Every permission, in order of their bits.
+var Table = []Info{
+	{"see_ipids", SeeIPIDs, "see IPIDs"},
+	{"hear_modcall", HearModCalls, "hear mod calls"},
+	{"mute", Mute, "mute users"},
+	{"kick", Kick, "kick and warn users"},
+	{"ban", Ban, "ban users"},
+	{"bypass_locks", BypassLocks, "bypass room, background and other locks"},
+	{"modify_db", ModifyDatabase, "add, remove and change the roles of users who can log in"},
+	{"announce", Announce, "make server-wide announcements"},
+	{"force_move", ForceMove, "move other users between rooms"},
+	{"lockdown", Lockdown, "lock down the server and kick everyone from a room"},
+	{"status", Status, "change the room's status"},
+	{"lock", Lock, "lock the room"},
+	{"description", Description, "change the room's description and title"},
+	{"background", Background, "change the room's background and sides"},
+	{"ambiance", Ambiance, "change the room's ambiance"},
+	{"characters", Characters, "reserve characters, approve who picks them and change the character lists"},
+	{"music", Music, "lock the music, skip songs and clear the queue"},
+	{"polls", Polls, "close polls"},
+	{"settings", Settings, "change the room's toggles"},
+	{"transcripts", Transcripts, "export transcripts"},
+	{"judge", Judge, "use the judge controls when the room restricts them"},
+	{"evidence", Evidence, "manage the room's evidence (reserved, does nothing yet)"},
+	{"play", Play, "play music even when it's locked"},
 }
 
-// Makes a list of roles out of the roles configuration.
-func MakeRoles() ([]Role, error) {
-	confs, err := config.ReadRoles()
-	if err != nil {
-		return nil, fmt.Errorf("perms: Couldn't read roles (%w).", err)
+var stringToPerm = map[string]Mask{"all": All}
+
+var permToString = make(map[Mask]string)
+
+func init() {
+	for _, p := range Table {
+		stringToPerm[p.Name] = p.Mask
+		permToString[p.Mask] = p.Name
 	}
-	roles := make([]Role, len(confs.Confs))
+}
+
+// The permissions that make a user count as a room manager, e.g. for picking
+// manager-only characters. Set through [SetManagerPerms].
+var managerMask = Characters
+
+// Sets the permissions that make a user count as a room manager: having any of them
+// is enough. An empty mask keeps the default, [Characters].
+// Should be called once, before any clients are made.
+func SetManagerPerms(m Mask) {
+	if m != None {
+		managerMask = m
+	}
+}
+
+// Checks whether a user with the passed permissions counts as a room manager.
+func IsManager(p Mask) bool {
+	return p&managerMask != 0
+}
+
+// Returns the names of the permissions in the mask, in order of their bits.
+func (m Mask) Names() []string {
+	var names []string
+	for i := 0; i < 32; i++ {
+		if name, ok := permToString[1<<i]; ok && m&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Returns the permission names in the mask as a comma-separated list.
+// A full mask is shown as "all", and an empty one as "none".
+func (m Mask) String() string {
+	switch m {
+	case All:
+		return "all"
+	case None:
+		return "none"
+	}
+	return strings.Join(m.Names(), ", ")
+}
+
+// Makes a permission mask out of a list of permission names. Unknown names are ignored.
+func FromNames(names []string) Mask {
+	perms := None
+	for _, s := range names {
+		perms |= stringToPerm[s]
+	}
+	return perms
+}
+
+// Makes a list of roles out of the roles configuration. A role that inherits from
+// another starts with its permissions, then has its own added and revoked ones applied.
+// Fails if a role inherits from one that doesn't exist, or if inheritance is circular.
+func MakeRoles(confs *config.Roles) ([]Role, error) {
+	byName := make(map[string]int, len(confs.Confs))
 	for i, conf := range confs.Confs {
-		perms := None
-		for _, s := range conf.Permissions {
-			perms |= stringToPerm[s]
+		byName[strings.ToLower(conf.Name)] = i
+	}
+	roles := make([]Role, len(confs.Confs))
+	resolved := make([]bool, len(confs.Confs))
+	var resolve func(i int, chain []string) error
+	resolve = func(i int, chain []string) error {
+		if resolved[i] {
+			return nil
+		}
+		conf := confs.Confs[i]
+		for _, name := range chain {
+			if strings.EqualFold(name, conf.Name) {
+				return fmt.Errorf("perms: Roles inherit from each other in a cycle (%v).", strings.Join(append(chain, conf.Name), " -> "))
+			}
+		}
+		var perms Mask
+		if conf.Inherits != "" {
+			parent, ok := byName[strings.ToLower(conf.Inherits)]
+			if !ok {
+				return fmt.Errorf("perms: Role '%v' inherits from '%v', which doesn't exist.", conf.Name, conf.Inherits)
+			}
+			if err := resolve(parent, append(chain, conf.Name)); err != nil {
+				return err
+			}
+			perms = roles[parent].Perms
 		}
 		roles[i] = Role{
 			Name:  conf.Name,
-			Perms: perms,
+			Perms: (perms | FromNames(conf.Permissions)) &^ FromNames(conf.Revoke),
+		}
+		resolved[i] = true
+		return nil
+	}
+	for i := range confs.Confs {
+		if err := resolve(i, nil); err != nil {
+			return nil, err
 		}
 	}
 	return roles, nil
@@ -0,0 +1,23 @@
+package room
+
+import "slices"
+
+// Sets the room's background. If `sides` isn't empty, the room's sides become the
+// background's positions. Otherwise, they go back to the room's configured ones.
+func (r *Room) SetBackground(bg string, sides []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bg = bg
+	if len(sides) > 0 {
+		r.sides = slices.Clone(sides)
+	} else {
+		r.sides = slices.Clone(r.defSides)
+	}
+}
+
+// Returns whether the background is locked, so only users who bypass locks can change it.
+func (r *Room) BackgroundLocked() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lockBg
+}
@@ -0,0 +1,75 @@
+package room
+
+import (
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Holds the room's list packets, already encoded, so they don't have to be rebuilt every
+// time a client joins or switches rooms. Built on first use, and reset by [Room.InvalidateCache].
+type listCache struct {
+	chars string // SC
+	music string // FM
+	rooms string // FA
+	both  string // SM (rooms and music)
+	gen   int    // incremented on invalidation, so stale results aren't stored
+}
+
+// Returns the encoded SC packet with the room's character list.
+func (r *Room) CharListAO() string {
+	return r.cached(&r.cache.chars, func() packets.PacketAO {
+		return packets.PacketAO{Header: "SC", Contents: r.Chars()}
+	})
+}
+
+// Returns the encoded FM packet with the room's music list.
+func (r *Room) MusicListAO() string {
+	return r.cached(&r.cache.music, func() packets.PacketAO {
+		return packets.PacketAO{Header: "FM", Contents: r.MusicList()}
+	})
+}
+
+// Returns the encoded FA packet with the list of rooms visible from this room.
+func (r *Room) RoomListAO() string {
+	return r.cached(&r.cache.rooms, func() packets.PacketAO {
+		return packets.PacketAO{Header: "FA", Contents: r.VisibleNames()}
+	})
+}
+
+// Returns the encoded SM packet, which AO uses for both the visible rooms and the music list.
+func (r *Room) SMListAO() string {
+	return r.cached(&r.cache.both, func() packets.PacketAO {
+		vis := r.VisibleNames()
+		music := r.MusicList()
+		list := make([]string, 0, len(vis)+len(music))
+		list = append(list, vis...)
+		list = append(list, music...)
+		return packets.PacketAO{Header: "SM", Contents: list}
+	})
+}
+
+// Clears the cached list packets. Must be called whenever the room's characters, music
+// or adjacent rooms change.
+func (r *Room) InvalidateCache() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = listCache{gen: r.cache.gen + 1}
+}
+
+// Returns the cached packet at `p`, building it with `build` if it isn't cached.
+// The packet is built without holding the lock, since building it may lock other rooms.
+func (r *Room) cached(p *string, build func() packets.PacketAO) string {
+	r.mu.Lock()
+	s, gen := *p, r.cache.gen
+	r.mu.Unlock()
+	if s != "" {
+		return s
+	}
+
+	s = build().Encoded()
+	r.mu.Lock()
+	if r.cache.gen == gen {
+		*p = s
+	}
+	r.mu.Unlock()
+	return s
+}
@@ -0,0 +1,90 @@
+package room
+
+import (
+	"slices"
+
+	"github.com/lambdcalculus/scs/internal/config"
+)
+
+// Makes the characters of the lists with the passed names, in the config's order.
+func makeChars(conf *config.Characters, names []string) []*char {
+	var chars []*char
+	for _, l := range findCharLists(conf, names) {
+		for _, c := range l.Characters {
+			chars = append(chars, &char{name: c.Name, sides: c.Sides, managerOnly: c.ManagerOnly})
+		}
+	}
+	return chars
+}
+
+// Returns the names of the character lists the room is using.
+func (r *Room) CharLists() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.charLists)
+}
+
+// Returns the names of the character lists the room was configured with.
+func (r *Room) DefaultCharLists() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.defCharLists)
+}
+
+// Replaces the room's characters with the ones in the lists with the passed names.
+// Users keep their characters if they are in the new lists, and become spectators
+// otherwise. Reservations of kept characters move along with them, and the rest are
+// dropped. Returns the new CID of each user whose CID changed, by UID, or `ok` as
+// `false` if the lists have no characters.
+func (r *Room) SetCharLists(conf *config.Characters, names []string) (moved map[int]int, ok bool) {
+	chars := makeChars(conf, names)
+	if len(chars) == 0 {
+		return nil, false
+	}
+	newCID := make(map[string]int, len(chars))
+	for cid, c := range chars {
+		if _, dup := newCID[c.name]; !dup {
+			newCID[c.name] = cid
+		}
+	}
+	remap := func(old int) int {
+		if cid, ok := newCID[r.chars[old].name]; ok {
+			return cid
+		}
+		return SpectatorCID
+	}
+
+	r.mu.Lock()
+	moved = make(map[int]int)
+	for _, u := range r.users {
+		if u.charID == SpectatorCID {
+			continue
+		}
+		cid := remap(u.charID)
+		if cid != SpectatorCID && chars[cid].taken {
+			// A duplicate name in the old lists; only one of them keeps it.
+			cid = SpectatorCID
+		}
+		if cid != SpectatorCID {
+			chars[cid].taken = true
+		}
+		if cid != u.charID {
+			moved[u.userID] = cid
+			u.charID = cid
+		}
+	}
+	reserved := make(map[int]int)
+	for old, uid := range r.reserved {
+		if cid := remap(old); cid != SpectatorCID {
+			reserved[cid] = uid
+		}
+	}
+	r.reserved = reserved
+	r.chars = chars
+	r.charLists = slices.Clone(names)
+	r.lastSpeaker = SpectatorCID
+	r.mu.Unlock()
+
+	r.InvalidateCache()
+	return moved, true
+}
@@ -0,0 +1,40 @@
+package room
+
+import "time"
+
+// How many IC messages a room remembers.
+const maxHistory = 1000
+
+// An IC message in the room's history.
+type HistoryEntry struct {
+	Time time.Time
+	Char string // the character (folder) used
+	Name string // the name shown, i.e. the showname or the character's name
+	Text string
+}
+
+// Adds an IC message to the room's history, forgetting the oldest one if the history is full.
+func (r *Room) AddHistory(e HistoryEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.history) >= maxHistory {
+		r.history = r.history[1:]
+	}
+	r.history = append(r.history, e)
+}
+
+// Returns the IC messages sent since the passed time, oldest first.
+func (r *Room) History(since time.Time) []HistoryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := 0
+	for i < len(r.history) && r.history[i].Time.Before(since) {
+		i++
+	}
+	return append([]HistoryEntry(nil), r.history[i:]...)
+}
+
+// Returns a version of the room's name that can be used in file names.
+func (r *Room) Slug() string {
+	return slugify(r.Name())
+}
@@ -0,0 +1,106 @@
+package room
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A poll running in a room.
+type poll struct {
+	id       int
+	question string
+	options  []string
+	votes    map[int]int // option index, by UID
+}
+
+// The outcome of a closed poll.
+type PollResults struct {
+	ID       int
+	Question string
+	Options  []string
+	Counts   []int // votes for each option, in order
+}
+
+// Formats the results as a multi-line message.
+func (p PollResults) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Results of poll #%v: %v", p.ID, p.Question)
+	total := 0
+	for _, n := range p.Counts {
+		total += n
+	}
+	for i, opt := range p.Options {
+		fmt.Fprintf(&b, "\n%v. %v: %v vote(s)", i+1, opt, p.Counts[i])
+		if total > 0 {
+			fmt.Fprintf(&b, " (%v%%)", p.Counts[i]*100/total)
+		}
+	}
+	return b.String()
+}
+
+// Starts a poll in the room. Returns its ID, or `false` if a poll is already running.
+func (r *Room) StartPoll(question string, options []string) (id int, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.poll != nil {
+		return 0, false
+	}
+	r.pollCount++
+	r.poll = &poll{
+		id:       r.pollCount,
+		question: question,
+		options:  options,
+		votes:    make(map[int]int),
+	}
+	return r.pollCount, true
+}
+
+// Returns the running poll's question and options. If there is none, `ok` is `false`.
+func (r *Room) Poll() (id int, question string, options []string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.poll == nil {
+		return 0, "", nil, false
+	}
+	return r.poll.id, r.poll.question, r.poll.options, true
+}
+
+// Votes for the option (starting at 0) in the running poll as the UID. Each UID can
+// only vote once. Returns a reason if the vote isn't counted.
+func (r *Room) Vote(uid int, option int) (ok bool, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.poll == nil:
+		return false, "There is no poll running in this room."
+	case option < 0 || option >= len(r.poll.options):
+		return false, fmt.Sprintf("There is no option %v.", option+1)
+	}
+	if _, voted := r.poll.votes[uid]; voted {
+		return false, "You have already voted."
+	}
+	r.poll.votes[uid] = option
+	return true, ""
+}
+
+// Closes the poll with the passed ID, if it is still running, and returns its results.
+// An ID of 0 closes whichever poll is running.
+func (r *Room) ClosePoll(id int) (results PollResults, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.poll == nil || (id != 0 && r.poll.id != id) {
+		return PollResults{}, false
+	}
+	p := r.poll
+	r.poll = nil
+	results = PollResults{
+		ID:       p.id,
+		Question: p.question,
+		Options:  p.options,
+		Counts:   make([]int, len(p.options)),
+	}
+	for _, opt := range p.votes {
+		results.Counts[opt]++
+	}
+	return results, true
+}
@@ -0,0 +1,46 @@
+package room
+
+// How many songs can be waiting in a room's music queue.
+const maxQueue = 50
+
+// Adds a song to the end of the room's music queue. Returns its position in the queue
+// (starting at 1), or `false` if the queue is full.
+func (r *Room) Enqueue(song string) (pos int, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.queue) >= maxQueue {
+		return 0, false
+	}
+	r.queue = append(r.queue, song)
+	return len(r.queue), true
+}
+
+// Pops the next song from the room's music queue. If the queue is empty, `ok` is `false`.
+func (r *Room) Dequeue() (song string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.queue) == 0 {
+		return "", false
+	}
+	song = r.queue[0]
+	r.queue = r.queue[1:]
+	return song, true
+}
+
+// Returns a copy of the room's music queue, in order.
+func (r *Room) Queue() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	q := make([]string, len(r.queue))
+	copy(q, r.queue)
+	return q
+}
+
+// Empties the room's music queue, returning how many songs were removed.
+func (r *Room) ClearQueue() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.queue)
+	r.queue = nil
+	return n
+}
@@ -0,0 +1,87 @@
+package room
+
+// Reserves the character with the passed CID for the user with the passed UID, so only
+// they can pick it. Returns `false` if the CID is out of bounds.
+func (r *Room) Reserve(cid int, uid int) (ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cid < 0 || cid >= len(r.chars) {
+		return false
+	}
+	r.reserved[cid] = uid
+	return true
+}
+
+// Removes the reservation of the character with the passed CID. Returns `false`
+// if it wasn't reserved.
+func (r *Room) Unreserve(cid int) (ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reserved[cid]; !ok {
+		return false
+	}
+	delete(r.reserved, cid)
+	return true
+}
+
+// Returns the UID the character with the passed CID is reserved for. If it isn't
+// reserved, `ok` is `false`.
+func (r *Room) ReservedFor(cid int) (uid int, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uid, ok = r.reserved[cid]
+	return uid, ok
+}
+
+// Sets whether users need to be approved before picking a character in the room.
+func (r *Room) SetApproval(on bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approval = on
+}
+
+// Returns whether users need to be approved before picking a character in the room.
+func (r *Room) Approval() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.approval
+}
+
+// Approves the user with the passed UID to pick characters in the room.
+func (r *Room) Approve(uid int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approved[uid] = struct{}{}
+}
+
+// Returns whether the user with the passed UID needs to be approved before picking
+// a character.
+func (r *Room) NeedsApproval(uid int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.approved[uid]
+	return r.approval && !ok
+}
+
+// Forgets the reservations, approval and slow mode timing of the user with the passed
+// UID, e.g. because they disconnected and the UID may be given to someone else.
+func (r *Room) ForgetUID(uid int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for cid, u := range r.reserved {
+		if u == uid {
+			delete(r.reserved, cid)
+		}
+	}
+	delete(r.approved, uid)
+	delete(r.lastIC, uid)
+}
+
+// Checks whether the user can pick the character, regardless of whether it's taken.
+func (r *Room) canPick(cid int, uid int) bool {
+	if u, ok := r.reserved[cid]; ok && u != uid {
+		return false
+	}
+	_, ok := r.approved[uid]
+	return !r.approval || ok
+}
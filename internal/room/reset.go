@@ -0,0 +1,43 @@
+package room
+
+import (
+	"slices"
+	"time"
+
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Puts the room back the way it was configured: its description, background, sides,
+// ambiance, locks, status, settings and HP bars. The music stops and the queue is
+// cleared, and the title, password, invites, reservations and approvals are dropped.
+// The users in the room and its character lists are left alone.
+func (r *Room) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conf := r.conf
+	r.desc = conf.DefaultDesc
+	r.title = ""
+	r.bg = conf.DefaultBg
+	r.lockBg = conf.LockBg
+	r.sides = slices.Clone(r.defSides)
+	r.ambiance = conf.DefaultAmbiance
+	r.lockAmb = conf.LockAmbiance
+	r.song = packets.SongStop
+	r.queue = nil
+	r.lockMus = conf.LockMusic || r.kind == KindLobby
+	r.status = StatusIdle
+	r.lock = LockFree
+	r.password = ""
+	r.invited = make(map[int]struct{})
+	r.reserved = make(map[int]int)
+	r.approval = false
+	r.approved = make(map[int]struct{})
+	r.slowmode = time.Duration(conf.Slowmode) * time.Second
+	r.lastIC = make(map[int]time.Time)
+	r.blankposting = conf.AllowBlankpost
+	r.iniswapping = conf.AllowIniswap
+	r.shouting = conf.AllowShouting
+	r.immediate = conf.ForceImmediate
+	r.defBar = packets.BarMax
+	r.proBar = packets.BarMax
+}
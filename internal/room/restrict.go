@@ -0,0 +1,35 @@
+package room
+
+import (
+	"slices"
+
+	"github.com/lambdcalculus/scs/internal/perms"
+)
+
+// Checks whether a user with the passed (effective) permissions may use the character
+// with the passed CID, according to its restrictions in the config. Spectating is
+// always allowed.
+func (r *Room) CanUse(cid int, p perms.Mask) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.canUse(cid, p)
+}
+
+// Should be called with the lock held.
+func (r *Room) canUse(cid int, p perms.Mask) bool {
+	if cid < 0 || cid >= len(r.chars) {
+		return true
+	}
+	return !r.chars[cid].managerOnly || perms.IsManager(p)
+}
+
+// Returns the sides the character with the passed CID may speak from, or `nil` if it
+// may use any of the room's sides.
+func (r *Room) CharSides(cid int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cid < 0 || cid >= len(r.chars) {
+		return nil
+	}
+	return slices.Clone(r.chars[cid].sides)
+}
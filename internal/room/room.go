@@ -4,10 +4,16 @@ package room
 // TODO: improve logging
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/perms"
 	"github.com/lambdcalculus/scs/pkg/logger"
 	"github.com/lambdcalculus/scs/pkg/packets"
 )
@@ -16,6 +22,9 @@ import (
 // The spectator CID is -1.
 const SpectatorCID = -1
 
+// The highest IC text color index in AO (0 is white, 1 green, 2 red, and so on).
+const MaxTextColor = 11
+
 // The "status" of a Room, as in AO.
 type Status int
 
@@ -57,6 +66,22 @@ var lockToString = map[LockState]string{
 	LockLocked: "LOCKED",
 }
 
+// The type of a Room, which restricts what can be done in it.
+type Kind int
+
+const (
+	// A regular room.
+	KindNormal Kind = iota
+
+	// A hub where users can only chat in OOC and move. IC is disabled and the music is locked.
+	KindLobby
+)
+
+var stringToKind = map[string]Kind{
+	"normal": KindNormal,
+	"lobby":  KindLobby,
+}
+
 // Used internally to represent an invalid user.
 const invalidUID = 0
 
@@ -66,15 +91,32 @@ type Room struct {
 	id       int
 	name     string
 	desc     string
+	title    string // the current topic, set by managers
+	kind     Kind
+	conf     config.Room // what the room was configured with, for resets
 	adjacent []*Room
+	adjOnly  bool // whether users can only leave to adjacent rooms
 	chars    []*char
 	music    []MusicCategory
 	sides    []string
+	defSides []string // the ones from the config, used when the background has no positions
+	defSide  string
+
+	charLists    []string // the names of the lists the characters are from
+	defCharLists []string // the ones from the config
 
 	blankposting bool
 	iniswapping  bool
+	iniswapList  map[string]struct{} // lowercase folder names that can always be iniswapped to
 	shouting     bool
 	immediate    bool
+	bannedColors map[int]struct{} // IC text colors that can't be used
+
+	// Permission overrides for users in this room.
+	grant  perms.Mask
+	revoke perms.Mask
+
+	maxSpectators int // 0 means no limit
 
 	// TODO: evidence? i kinda hate evidence
 	// TODO: CMs (and permissions in general)
@@ -82,10 +124,12 @@ type Room struct {
 	defBar   packets.BarHP
 	proBar   packets.BarHP
 	song     string
+	queue    []string // songs to play next, in order
 	bg       string
 	lockBg   bool
 	ambiance string
 	lockAmb  bool
+	lockMus  bool
 	status   Status
 	lock     LockState
 
@@ -96,6 +140,26 @@ type Room struct {
 	// A list of invited UIDs. Used to decide who can speak when the room spectatable,
 	// or who can enter when it is locked.
 	invited map[int]struct{} // Another set!
+	// Lets users in without being invited. Empty if the room has no password.
+	password string
+
+	// Characters reserved for specific UIDs, by CID.
+	reserved map[int]int
+	// Whether users need to be approved before picking a character, and the approved UIDs.
+	approval bool
+	approved map[int]struct{}
+	// The minimum delay between IC messages from each user, and when each UID last spoke IC.
+	slowmode time.Duration
+	lastIC   map[int]time.Time
+
+	// The running poll, if any, and how many polls the room has had.
+	poll      *poll
+	pollCount int
+
+	// The most recent IC messages, oldest first.
+	history []HistoryEntry
+
+	cache listCache
 
 	logger *logger.Logger
 	mu     sync.Mutex
@@ -104,6 +168,9 @@ type Room struct {
 type char struct {
 	name  string
 	taken bool
+
+	sides       []string // the sides it can speak from, empty for any
+	managerOnly bool
 }
 
 type MusicCategory config.SongCategory
@@ -127,6 +194,7 @@ const (
 	EventIC
 	EventJudge
 	EventMod
+	EventPoll
 	EventDebug
 	EventFail
 )
@@ -142,31 +210,32 @@ var eventToString = map[Event]string{
 	EventIC:        "IC   ",
 	EventJudge:     "JUD  ",
 	EventMod:       "MOD  ",
+	EventPoll:      "POLL ",
 	EventDebug:     "DEBUG",
 	EventFail:      "FAIL ",
 }
 
-// MakeRooms creates a list of rooms according to the room configuration.
-func MakeRooms(charsConf *config.Characters, musicConf *config.Music) ([]*Room, error) {
-	// TODO: warn about non-existant lists/adjancecies?
-	roomConf, err := config.ReadRooms()
-	if err != nil {
-		return nil, fmt.Errorf("room: Couldn't read room config (%w).", err)
-	}
+// MakeRooms creates a list of rooms according to the room configuration. Missing lists
+// and adjacencies are ignored, so the configuration should be checked with
+// [config.Validate] first.
+func MakeRooms(roomConf *config.RoomList, charsConf *config.Characters, musicConf *config.Music) ([]*Room, error) {
 	if len(roomConf.Confs) == 0 {
 		return nil, fmt.Errorf("room: Empty room list.")
 	}
+	for _, conf := range roomConf.Confs {
+		if _, ok := stringToKind[conf.Type]; !ok {
+			return nil, fmt.Errorf("room: Room '%v' has unknown type '%v'.", conf.Name, conf.Type)
+		}
+	}
+	logDir, err := config.LogDir()
+	if err != nil {
+		return nil, fmt.Errorf("room: Couldn't find log directory (%w).", err)
+	}
 
 	var rooms []*Room
 	for i, conf := range roomConf.Confs {
 		// Read characters.
-		var chars []*char
-		charLists := findCharLists(charsConf, conf.CharLists)
-		for _, l := range charLists {
-			for _, c := range l.Characters {
-				chars = append(chars, &char{c, false})
-			}
-		}
+		chars := makeChars(charsConf, conf.CharLists)
 		// Read music.
 		var music []MusicCategory
 		musicCats := findMusicCategories(musicConf, conf.SongCategories)
@@ -181,7 +250,7 @@ func MakeRooms(charsConf *config.Characters, musicConf *config.Music) ([]*Room,
 				logOuts = append(logOuts, "stdout")
 			case "file":
 				// TODO: check for log file name collision?
-				logOuts = append(logOuts, fmt.Sprintf("log/room/%v.log", slugify(conf.Name)))
+				logOuts = append(logOuts, filepath.Join(logDir, "room", slugify(conf.Name)+".log"))
 			}
 		}
 
@@ -193,16 +262,30 @@ func MakeRooms(charsConf *config.Characters, musicConf *config.Music) ([]*Room,
 		rooms = append(rooms, &Room{
 			id:           i,
 			name:         conf.Name,
+			conf:         conf,
 			desc:         conf.DefaultDesc,
+			kind:         stringToKind[conf.Type],
 			chars:        chars,
+			charLists:    conf.CharLists,
+			defCharLists: conf.CharLists,
 			music:        music,
 			sides:        conf.Sides,
+			defSides:     conf.Sides,
+			defSide:      conf.DefaultSide,
 			blankposting: conf.AllowBlankpost,
 			iniswapping:  conf.AllowIniswap,
+			iniswapList:  makeIniswapList(conf.IniswapAllowList),
 			shouting:     conf.AllowShouting,
 			immediate:    conf.ForceImmediate,
+			bannedColors: makeColorSet(conf.BannedColors),
+			grant:        perms.FromNames(conf.GrantPerms) & perms.RoomMask,
+			revoke:       perms.FromNames(conf.RevokePerms) & perms.RoomMask,
+			maxSpectators: conf.MaxSpectators,
 			bg:           conf.DefaultBg,
 			lockBg:       conf.LockBg,
+			lockAmb:      conf.LockAmbiance,
+			lockMus:      conf.LockMusic || stringToKind[conf.Type] == KindLobby,
+			adjOnly:      conf.AdjacentOnly,
             defBar:       packets.BarMax,
             proBar:       packets.BarMax,
 			song:         packets.SongStop, // the canonical "stop" song for AO
@@ -210,6 +293,10 @@ func MakeRooms(charsConf *config.Characters, musicConf *config.Music) ([]*Room,
 			status:       StatusIdle,
 			lock:         LockFree,
 			invited:      make(map[int]struct{}),
+			reserved:     make(map[int]int),
+			approved:     make(map[int]struct{}),
+			slowmode:     time.Duration(conf.Slowmode) * time.Second,
+			lastIC:       make(map[int]time.Time),
 			// TODO: log to files
 			logger: logger.NewLoggerOutputs(lvl, roomFormatter(i, conf.Name), logOuts...),
 		})
@@ -239,12 +326,17 @@ func (r *Room) LogEventDebug(event Event, format string, a ...any) {
 }
 
 // Attempts to enter a new user into the room. If unable, returns `false`.
-// A CID of -1 (spectator) will bypass the check for available CIDs, and will always
-// succeed.
+// A CID of -1 (spectator) will bypass the check for available CIDs, and will only
+// fail if the room has reached its spectator limit.
 // This doesn't check for locks or anything like that, that needs to be done externally.
 func (r *Room) Enter(cid int, uid int) (ok bool) {
 	r.mu.Lock()
 	if cid == SpectatorCID {
+		if r.spectatorsFull() {
+			r.mu.Unlock()
+			r.LogEvent(EventFail, "UID %v tried joining as a spectator, but the spectator limit was reached.", uid)
+			return false
+		}
 		goto enter
 	}
 	if cid >= len(r.chars) || cid < 0 {
@@ -256,6 +348,11 @@ func (r *Room) Enter(cid int, uid int) (ok bool) {
 		r.LogEvent(EventFail, "UID %v tried joining as %v (CID: %v), but this character is taken.",
 			uid, r.GetNameByCID(cid), cid)
 		return false
+	} else if !r.canPick(cid, uid) {
+		r.mu.Unlock()
+		r.LogEvent(EventFail, "UID %v tried joining as %v (CID: %v), but this character is reserved or they aren't approved.",
+			uid, r.GetNameByCID(cid), cid)
+		return false
 	}
 	r.chars[cid].taken = true
 
@@ -311,8 +408,9 @@ func (r *Room) GetCIDByName(name string) (cid int, ok bool) {
 	return SpectatorCID, false
 }
 
-// Attempts a char change.
-func (r *Room) ChangeChar(uid int, to int) (ok bool) {
+// Attempts a char change. The permissions are the user's effective permissions, for
+// characters that only managers may use.
+func (r *Room) ChangeChar(uid int, to int, p perms.Mask) (ok bool) {
 	r.mu.Lock()
 
 	usr := r.getUser(uid)
@@ -323,6 +421,12 @@ func (r *Room) ChangeChar(uid int, to int) (ok bool) {
 	}
 
 	if to == SpectatorCID {
+		if r.spectatorsFull() {
+			r.mu.Unlock()
+			r.LogEvent(EventFail, "%v (CID: %v, UID: %v) tried changing to spectator, but the spectator limit was reached.",
+				r.GetNameByCID(from), from, uid)
+			return false
+		}
 		goto change
 	}
 
@@ -336,6 +440,16 @@ func (r *Room) ChangeChar(uid int, to int) (ok bool) {
 		r.LogEvent(EventFail, "%v (CID: %v, UID: %v) tried changing to %v (CID %v), but this character is taken.",
 			r.GetNameByCID(from), from, uid, r.GetNameByCID(to), to)
 		return false
+	} else if !r.canPick(to, uid) {
+		r.mu.Unlock()
+		r.LogEvent(EventFail, "%v (CID: %v, UID: %v) tried changing to %v (CID %v), but this character is reserved or they aren't approved.",
+			r.GetNameByCID(from), from, uid, r.GetNameByCID(to), to)
+		return false
+	} else if !r.canUse(to, p) {
+		r.mu.Unlock()
+		r.LogEvent(EventFail, "%v (CID: %v, UID: %v) tried changing to %v (CID %v), but this character is restricted to managers.",
+			r.GetNameByCID(from), from, uid, r.GetNameByCID(to), to)
+		return false
 	}
 	r.chars[to].taken = true
 
@@ -369,6 +483,27 @@ func (r *Room) Desc() string {
 	return r.desc
 }
 
+// Sets the description of the room.
+func (r *Room) SetDesc(desc string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.desc = desc
+}
+
+// Returns the title of the room, i.e. its current topic, or "" if it has none.
+func (r *Room) Title() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.title
+}
+
+// Sets the title of the room. "" clears it.
+func (r *Room) SetTitle(title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.title = title
+}
+
 // Returns the background of the room.
 func (r *Room) Background() string {
 	r.mu.Lock()
@@ -416,6 +551,32 @@ func (r *Room) Song() string {
 	return r.song
 }
 
+// Returns the room's type.
+func (r *Room) Kind() Kind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.kind
+}
+
+// Returns whether the room is a lobby.
+func (r *Room) IsLobby() bool {
+	return r.Kind() == KindLobby
+}
+
+// Returns whether the music is locked, i.e. only managers and invited users can change it.
+func (r *Room) MusicLocked() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lockMus
+}
+
+// Sets whether the music is locked.
+func (r *Room) SetMusicLock(lock bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lockMus = lock
+}
+
 // Sets the current song in the room.
 func (r *Room) SetSong(s string) {
 	r.mu.Lock()
@@ -444,6 +605,13 @@ func (r *Room) AllowBlankpost() bool {
 	return r.blankposting
 }
 
+// Sets whether blankposts are allowed.
+func (r *Room) SetBlankpost(allow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blankposting = allow
+}
+
 // Returns whether iniswapping is allowed.
 func (r *Room) AllowIniswapping() bool {
 	r.mu.Lock()
@@ -451,6 +619,22 @@ func (r *Room) AllowIniswapping() bool {
 	return r.iniswapping
 }
 
+// Sets whether iniswapping is allowed.
+func (r *Room) SetIniswapping(allow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.iniswapping = allow
+}
+
+// Returns whether a character can iniswap to the passed folder, either because
+// iniswapping is allowed or because the folder is in the room's allow-list.
+func (r *Room) CanIniswapTo(folder string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.iniswapList[strings.ToLower(folder)]
+	return r.iniswapping || ok
+}
+
 // Returns whether shouts are allowed.
 func (r *Room) AllowShouting() bool {
 	r.mu.Lock()
@@ -458,6 +642,13 @@ func (r *Room) AllowShouting() bool {
 	return r.shouting
 }
 
+// Sets whether shouts are allowed.
+func (r *Room) SetShouting(allow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shouting = allow
+}
+
 // Returns whether preanims are played immediately.
 func (r *Room) ForceImmediate() bool {
 	r.mu.Lock()
@@ -465,6 +656,56 @@ func (r *Room) ForceImmediate() bool {
 	return r.immediate
 }
 
+// Sets whether preanims are played immediately.
+func (r *Room) SetForceImmediate(force bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.immediate = force
+}
+
+// Returns whether the IC text color can be used in the room.
+func (r *Room) ColorAllowed(color int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, banned := r.bannedColors[color]
+	return !banned
+}
+
+// Sets whether the IC text color can be used in the room.
+func (r *Room) SetColorAllowed(color int, allow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if allow {
+		delete(r.bannedColors, color)
+	} else {
+		r.bannedColors[color] = struct{}{}
+	}
+}
+
+// Returns the IC text colors that can't be used in the room, in ascending order.
+func (r *Room) BannedColors() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	colors := make([]int, 0, len(r.bannedColors))
+	for c := range r.bannedColors {
+		colors = append(colors, c)
+	}
+	slices.Sort(colors)
+	return colors
+}
+
+// Applies the room's permission overrides to the passed mask. Only room permissions can be
+// granted or revoked, and revocations don't apply to masks with [perms.BypassLocks].
+func (r *Room) ApplyPerms(p perms.Mask) perms.Mask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p |= r.grant
+	if p&perms.BypassLocks == 0 {
+		p &^= r.revoke
+	}
+	return p
+}
+
 // Returns the name of the track for the room's ambiance.
 func (r *Room) Ambiance() string {
 	r.mu.Lock()
@@ -488,6 +729,25 @@ func (r *Room) Adjacent() []*Room {
 	return rooms
 }
 
+// Returns whether the passed room is adjacent to this one.
+func (r *Room) IsAdjacent(other *Room) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range r.adjacent {
+		if a == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether users can only leave this room to adjacent rooms.
+func (r *Room) AdjacentOnly() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.adjOnly
+}
+
 // Returns the list of visible rooms (adjacent rooms, and the room itself).
 func (r *Room) Visible() []*Room {
 	adj := r.Adjacent()
@@ -516,13 +776,27 @@ func (r *Room) UIDs() []int {
 	return uids
 }
 
-// Returns the number of players in the room.
+// Returns the number of players in the room, including spectators.
 func (r *Room) PlayerCount() int {
 	r.mu.Lock()
 	r.mu.Unlock()
 	return len(r.users)
 }
 
+// Returns the number of spectators in the room.
+func (r *Room) SpectatorCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spectators()
+}
+
+// Returns the maximum amount of spectators in the room. 0 means there is no limit.
+func (r *Room) MaxSpectators() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.maxSpectators
+}
+
 // Returns the names of the characters in the room.
 func (r *Room) Chars() []string {
 	r.mu.Lock()
@@ -596,6 +870,11 @@ func (r *Room) Sides() []string {
 	return sides
 }
 
+// Rotates the room's log files. See [logger.Logger.Rotate].
+func (r *Room) RotateLog() error {
+	return r.logger.Rotate()
+}
+
 // Returns the room's status.
 func (r *Room) Status() string {
 	r.mu.Lock()
@@ -627,10 +906,31 @@ func (r *Room) LockString() string {
 // Sets the room's lock state.
 func (r *Room) SetLockState(s LockState) {
 	r.mu.Lock()
-	defer r.mu.Lock()
+	defer r.mu.Unlock()
 	r.lock = s
 }
 
+// Sets the room's password. An empty password removes it.
+func (r *Room) SetPassword(pw string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.password = pw
+}
+
+// Returns whether the room has a password.
+func (r *Room) HasPassword() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.password != ""
+}
+
+// Checks the passed password against the room's. Always fails if the room has no password.
+func (r *Room) CheckPassword(pw string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.password != "" && subtle.ConstantTimeCompare([]byte(pw), []byte(r.password)) == 1
+}
+
 // Returns a list of invited UIDs.
 func (r *Room) Invited() []int {
 	r.mu.Lock()
@@ -687,6 +987,20 @@ func (r *Room) Taken() []bool {
 	return taken
 }
 
+// Returns the CIDs of the characters that aren't taken.
+func (r *Room) FreeCIDs() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var free []int
+	for cid, c := range r.chars {
+		if _, reserved := r.reserved[cid]; !c.taken && !reserved {
+			free = append(free, cid)
+		}
+	}
+	return free
+}
+
 // Returns a list of taken CIDs as strings (for the CharsCheck AO packet).
 // Cursed, yes.
 func (r *Room) TakenList() []string {
@@ -694,8 +1008,9 @@ func (r *Room) TakenList() []string {
 	defer r.mu.Unlock()
 
 	var takenList []string
-	for _, c := range r.chars {
-		if c.taken {
+	for cid, c := range r.chars {
+		// Reserved characters are shown as taken, since the list is the same for everyone.
+		if _, reserved := r.reserved[cid]; c.taken || reserved {
 			takenList = append(takenList, "-1")
 		} else {
 			takenList = append(takenList, "0")
@@ -728,3 +1043,33 @@ func (r *Room) removeUser(uid int) {
 		}
 	}
 }
+
+func (r *Room) spectators() int {
+	n := 0
+	for _, u := range r.users {
+		if u.charID == SpectatorCID {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Room) spectatorsFull() bool {
+	return r.maxSpectators > 0 && r.spectators() >= r.maxSpectators
+}
+
+func makeColorSet(colors []int) map[int]struct{} {
+	set := make(map[int]struct{}, len(colors))
+	for _, c := range colors {
+		set[c] = struct{}{}
+	}
+	return set
+}
+
+func makeIniswapList(folders []string) map[string]struct{} {
+	list := make(map[string]struct{}, len(folders))
+	for _, f := range folders {
+		list[strings.ToLower(f)] = struct{}{}
+	}
+	return list
+}
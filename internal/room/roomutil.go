@@ -10,74 +10,63 @@ import (
 )
 
 // Returns the charlists in the configuration that correspond to the list of names in linear time.
-// If `"all"` is in the list, return all of the charlists.
-// TODO: this makes it so you can't "order" the character lists. Change?
+// If `"all"` is in the list, return all of the charlists. The charlists are always in the
+// configuration's order, so the character list sent to clients doesn't depend on the
+// order of the names.
+// The returned pointers point into `conf`, so they are indexed rather than taken from
+// the range variable, which older Go versions reuse between iterations.
 func findCharLists(conf *config.Characters, names []string) []*config.CharList {
-	set := make(map[string]struct{})
-	for _, n := range names {
-		set[n] = struct{}{}
-	}
+	set := makeNameSet(names)
+	_, all := set["all"]
 
 	var lists []*config.CharList
-	if _, ok := set["all"]; ok {
-		for _, l := range conf.Lists {
-			lists = append(lists, &l)
-		}
-		return lists
-	}
-	for _, l := range conf.Lists {
-		if _, ok := set[l.Name]; ok {
-			lists = append(lists, &l)
+	for i := range conf.Lists {
+		if _, ok := set[conf.Lists[i].Name]; all || ok {
+			lists = append(lists, &conf.Lists[i])
 		}
 	}
 	return lists
 }
 
 // Returns the music categories in the configuration that correspond to the list of names in linear time.
-// If `"all"` is in the list, return all of the categories.
+// If `"all"` is in the list, return all of the categories. As with [findCharLists], the
+// categories are in the configuration's order.
 func findMusicCategories(conf *config.Music, names []string) []*config.SongCategory {
-	set := make(map[string]struct{})
-	for _, n := range names {
-		set[n] = struct{}{}
-	}
+	set := makeNameSet(names)
+	_, all := set["all"]
 
 	var cats []*config.SongCategory
-	if _, ok := set["all"]; ok {
-		for _, cat := range conf.Categories {
-			cats = append(cats, &cat)
-		}
-		return cats
-	}
-	for _, cat := range conf.Categories {
-		if _, ok := set[cat.Name]; ok {
-			cats = append(cats, &cat)
+	for i := range conf.Categories {
+		if _, ok := set[conf.Categories[i].Name]; all || ok {
+			cats = append(cats, &conf.Categories[i])
 		}
 	}
 	return cats
 }
 
-// Returns the rooms in the passed list that correspond to the list of names passed.
+// Returns the rooms in the passed list that correspond to the list of names passed,
+// in the list's order.
 func findRooms(list []*Room, names []string) []*Room {
-	set := make(map[string]struct{})
-	for _, n := range names {
-		set[n] = struct{}{}
-	}
+	set := makeNameSet(names)
+	_, all := set["all"]
 
 	var rooms []*Room
-	if _, ok := set["all"]; ok {
-		for _, r := range list {
-			rooms = append(rooms, r)
-		}
-		return list
-	}
 	for _, r := range list {
-		if _, ok := set[r.Name()]; ok {
+		if _, ok := set[r.Name()]; all || ok {
 			rooms = append(rooms, r)
 		}
 	}
 	return rooms
 }
 
+func makeNameSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		set[n] = struct{}{}
+	}
+	return set
+}
+
 // Returns a [logger.FormatFunc] that matches the given name and id.
 func roomFormatter(id int, name string) logger.FormatFunc {
 	return func(msg string, lvl logger.LogLevel) string {
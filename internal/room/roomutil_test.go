@@ -0,0 +1,77 @@
+package room
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lambdcalculus/scs/internal/config"
+)
+
+// The cases shared by the find functions, over the names "A", "B" and "C", in that order.
+var findCases = []struct {
+	desc  string
+	names []string
+	want  []string
+}{
+	{"none", nil, nil},
+	{"one", []string{"B"}, []string{"B"}},
+	{"config order", []string{"C", "A"}, []string{"A", "C"}},
+	{"all", []string{"all"}, []string{"A", "B", "C"}},
+	{"all with others", []string{"B", "all"}, []string{"A", "B", "C"}},
+	{"unknown", []string{"D", "B"}, []string{"B"}},
+	{"duplicates", []string{"A", "A", "C"}, []string{"A", "C"}},
+}
+
+func TestFindCharLists(t *testing.T) {
+	conf := &config.Characters{Lists: []config.CharList{{Name: "A"}, {Name: "B"}, {Name: "C"}}}
+	for _, tc := range findCases {
+		var got []string
+		for _, l := range findCharLists(conf, tc.names) {
+			got = append(got, l.Name)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%v: findCharLists(%q) = %q; want %q", tc.desc, tc.names, got, tc.want)
+		}
+	}
+
+	// The lists returned point into the config, not to copies.
+	lists := findCharLists(conf, []string{"all"})
+	for i, l := range lists {
+		if l != &conf.Lists[i] {
+			t.Errorf("list %v doesn't point into the config", l.Name)
+		}
+	}
+}
+
+func TestFindMusicCategories(t *testing.T) {
+	conf := &config.Music{Categories: []config.SongCategory{{Name: "A"}, {Name: "B"}, {Name: "C"}}}
+	for _, tc := range findCases {
+		var got []string
+		for _, c := range findMusicCategories(conf, tc.names) {
+			got = append(got, c.Name)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%v: findMusicCategories(%q) = %q; want %q", tc.desc, tc.names, got, tc.want)
+		}
+	}
+
+	cats := findMusicCategories(conf, []string{"all"})
+	for i, c := range cats {
+		if c != &conf.Categories[i] {
+			t.Errorf("category %v doesn't point into the config", c.Name)
+		}
+	}
+}
+
+func TestFindRooms(t *testing.T) {
+	list := []*Room{{name: "A"}, {name: "B"}, {name: "C"}}
+	for _, tc := range findCases {
+		var got []string
+		for _, r := range findRooms(list, tc.names) {
+			got = append(got, r.name)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%v: findRooms(%q) = %q; want %q", tc.desc, tc.names, got, tc.want)
+		}
+	}
+}
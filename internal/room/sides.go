@@ -0,0 +1,51 @@
+package room
+
+import "slices"
+
+// The side used when a room has neither a default side nor any sides, as in AO.
+const fallbackSide = "wit"
+
+// Returns the side users are put in when they don't pick a valid one: the room's
+// default side if it's available, or else the first of its sides.
+func (r *Room) DefaultSide() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.defSide != "" && (len(r.sides) == 0 || slices.Contains(r.sides, r.defSide)) {
+		return r.defSide
+	}
+	if len(r.sides) > 0 {
+		return r.sides[0]
+	}
+	return fallbackSide
+}
+
+// Checks whether the side is one of the room's sides.
+func (r *Room) HasSide(side string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Contains(r.sides, side)
+}
+
+// Adds a side to the room. Returns `false` if the room already has it.
+func (r *Room) AddSide(side string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.Contains(r.sides, side) {
+		return false
+	}
+	r.sides = append(r.sides, side)
+	return true
+}
+
+// Removes a side from the room. Returns `false` if the room doesn't have it, or if
+// it's the room's last side.
+func (r *Room) RemoveSide(side string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.Index(r.sides, side)
+	if i < 0 || len(r.sides) == 1 {
+		return false
+	}
+	r.sides = slices.Delete(r.sides, i, i+1)
+	return true
+}
@@ -0,0 +1,38 @@
+package room
+
+import "time"
+
+// Sets the minimum delay between IC messages from each user in the room. 0 turns
+// slow mode off.
+func (r *Room) SetSlowmode(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slowmode = d
+}
+
+// Returns the minimum delay between IC messages from each user in the room, or 0 if
+// slow mode is off.
+func (r *Room) Slowmode() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.slowmode
+}
+
+// Returns how long the user with the passed UID has to wait before speaking IC in
+// the room again, or 0 if they can speak now.
+func (r *Room) SlowmodeWait(uid int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.lastIC[uid]
+	if r.slowmode <= 0 || !ok {
+		return 0
+	}
+	return max(r.slowmode-time.Since(last), 0)
+}
+
+// Records that the user with the passed UID just spoke IC in the room.
+func (r *Room) MarkIC(uid int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastIC[uid] = time.Now()
+}
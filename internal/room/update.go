@@ -0,0 +1,82 @@
+package room
+
+import (
+	"strconv"
+
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Makes the ARUP packets for the passed list of rooms, with the fields in `up`.
+// The list should be the visible rooms of a client, in order.
+func AreaUpdateAO(rooms []*Room, up packets.AreaUpdate) []packets.PacketAO {
+	var pkts []packets.PacketAO
+	if up&packets.UpdatePlayer != 0 {
+		players := make([]string, len(rooms))
+		for i, r := range rooms {
+			players[i] = strconv.Itoa(r.PlayerCount())
+		}
+		pkts = append(pkts, packets.PacketAO{Header: "ARUP", Contents: append([]string{"0"}, players...)})
+	}
+	if up&packets.UpdateStatus != 0 {
+		statuses := make([]string, len(rooms))
+		for i, r := range rooms {
+			statuses[i] = r.Status()
+		}
+		pkts = append(pkts, packets.PacketAO{Header: "ARUP", Contents: append([]string{"1"}, statuses...)})
+	}
+	if up&packets.UpdateManager != 0 {
+		cms := make([]string, len(rooms))
+		for i := range rooms {
+			// TODO: CMs
+			cms[i] = "FREE"
+		}
+		pkts = append(pkts, packets.PacketAO{Header: "ARUP", Contents: append([]string{"2"}, cms...)})
+	}
+	if up&packets.UpdateLock != 0 {
+		locks := make([]string, len(rooms))
+		for i, r := range rooms {
+			locks[i] = r.LockString()
+		}
+		pkts = append(pkts, packets.PacketAO{Header: "ARUP", Contents: append([]string{"3"}, locks...)})
+	}
+	return pkts
+}
+
+// Makes the room states for SpriteChat's ROOMUPDATE packet for the passed rooms,
+// with the fields in `up`.
+func AreaUpdateSC(rooms []*Room, up packets.AreaUpdate) packets.DataRoomUpdate {
+	states := make(packets.DataRoomUpdate, len(rooms))
+	for i, r := range rooms {
+		states[i].ID = r.ID()
+		if up&packets.UpdatePlayer != 0 {
+			count, spec := r.PlayerCount(), r.SpectatorCount()
+			states[i].Players = &count
+			states[i].Spectators = &spec
+		}
+		if up&packets.UpdateStatus != 0 {
+			states[i].Status = r.Status()
+		}
+		if up&packets.UpdateManager != 0 {
+			// TODO: CMs
+			states[i].Manager = "FREE"
+		}
+		if up&packets.UpdateLock != 0 {
+			states[i].Lock = r.LockString()
+		}
+	}
+	return states
+}
+
+// Returns the rooms in `rooms` that are also in `from`, keeping the order of `rooms`.
+func Intersect(rooms []*Room, from []*Room) []*Room {
+	var out []*Room
+	for _, r := range rooms {
+		for _, f := range from {
+			if r == f {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
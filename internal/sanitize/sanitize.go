@@ -0,0 +1,82 @@
+// Package `sanitize` cleans up user-provided text, such as messages and names, by
+// removing control and invisible characters, normalizing it and limiting the abuse of
+// combining characters ("zalgo" text).
+package sanitize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A Sanitizer cleans up text according to the server's configuration. Its methods can
+// be called from multiple goroutines.
+type Sanitizer struct {
+	normalize    bool
+	maxCombining int // 0 means no limit
+}
+
+// Creates a new Sanitizer according to the configuration.
+func New(conf config.Sanitize) *Sanitizer {
+	return &Sanitizer{
+		normalize:    conf.Normalize,
+		maxCombining: max(conf.MaxCombining, 0),
+	}
+}
+
+// Cleans up a message. Line breaks are kept.
+func (s *Sanitizer) Text(text string) string {
+	return s.clean(text, true)
+}
+
+// Cleans up a name, such as a showname or an OOC username. Line breaks are removed.
+func (s *Sanitizer) Name(name string) string {
+	return s.clean(name, false)
+}
+
+func (s *Sanitizer) clean(text string, newlines bool) string {
+	if s.normalize {
+		// Normalizing first composes what can be composed, so the combining characters
+		// left over are the ones that really are stacked.
+		text = norm.NFC.String(text)
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	combining := 0
+	for _, r := range text {
+		if r == '\n' && newlines {
+			b.WriteRune(r)
+			combining = 0
+			continue
+		}
+		if invisible(r) {
+			continue
+		}
+		if unicode.In(r, unicode.Mn, unicode.Me) {
+			combining++
+			if s.maxCombining > 0 && combining > s.maxCombining {
+				continue
+			}
+		} else {
+			combining = 0
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Returns whether the rune is a control character or an invisible formatting character
+// (e.g. zero-width spaces and bidirectional overrides). Zero-width joiners and
+// non-joiners are kept, as emoji and some scripts need them.
+func invisible(r rune) bool {
+	switch r {
+	case '\u200c', '\u200d':
+		return false
+	case utf8.RuneError: // invalid UTF-8
+		return true
+	}
+	return unicode.In(r, unicode.Cc, unicode.Cf)
+}
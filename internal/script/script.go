@@ -0,0 +1,284 @@
+// Package `script` runs the custom commands and event reactions that hosts define in
+// the scripts directory.
+//
+// Replies are written as Go templates (see [text/template]), which stand in for an
+// embedded language such as Lua or Starlark: they need nothing outside the standard
+// library, and what they can do is small enough to check when they're loaded. Templates
+// can only see the [Context] they are run with and the functions in [funcs], so scripts
+// can't touch anything outside of the message they produce. They also can't loop
+// except over the command's arguments, nor call other templates, so they always finish
+// quickly, on the loop of the client that triggered them (see [checkBounded]).
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/perms"
+)
+
+// The longest reply a script can produce.
+const maxOutput = 2000
+
+// How deeply ranges can be nested in a template.
+const maxRangeDepth = 2
+
+// The events reactions can be set on.
+const (
+	EventIC      = "ic"      // a valid IC message; the context's Text is the message
+	EventModCall = "modcall" // a mod call; the context's Text is the reason
+)
+
+// What a script can see about the client that triggered it.
+type Context struct {
+	User  string // OOC username
+	Name  string // showname, or character name if there is none
+	UID   int
+	CID   int
+	Room  string
+	Args  []string // command arguments
+	Text  string   // event text, for reactions
+	Perms perms.Mask
+}
+
+// The functions templates can call.
+var funcs = template.FuncMap{
+	// arg returns the i-th argument, or def if there aren't enough.
+	"arg": func(args []string, i int, def string) string {
+		if i < 0 || i >= len(args) {
+			return def
+		}
+		return args[i]
+	},
+	// atoi parses an integer, returning 0 if it isn't one.
+	"atoi": func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	},
+	// rand returns a random integer in [1, n].
+	"rand": func(n int) int {
+		if n < 1 {
+			return 0
+		}
+		return rand.Intn(n) + 1
+	},
+	// choice returns one of its arguments at random.
+	"choice": func(opts ...string) string {
+		if len(opts) == 0 {
+			return ""
+		}
+		return opts[rand.Intn(len(opts))]
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// now returns the current UTC time with the passed Go layout.
+	"now": func(layout string) string {
+		return time.Now().UTC().Format(layout)
+	},
+}
+
+// The loaded scripts.
+type Engine struct {
+	Commands  []*Command
+	Reactions []*Reaction
+}
+
+// A custom OOC command.
+type Command struct {
+	Name      string
+	Usage     string
+	Desc      string
+	MinArgs   int
+	Perms     perms.Mask
+	Broadcast bool // whether the reply is sent to the whole room instead of only the user
+	tmpl      *template.Template
+}
+
+// A reaction to an event.
+type Reaction struct {
+	Event     string
+	Broadcast bool // whether the reply is sent to the whole room instead of only the user
+	Block     bool // whether the event is stopped from going through
+	match     *regexp.Regexp
+	tmpl      *template.Template
+}
+
+// Compiles the scripts. Fails on the first script with a mistake.
+func Load(scripts []config.Script) (*Engine, error) {
+	e := &Engine{}
+	for _, s := range scripts {
+		for _, conf := range s.Commands {
+			if conf.Name == "" {
+				return nil, fmt.Errorf("script: Command without a name in %v.", s.File)
+			}
+			tmpl, err := parseReply(conf.Name, conf.Reply)
+			if err != nil {
+				return nil, fmt.Errorf("script: Bad reply for /%v in %v (%w).", conf.Name, s.File, err)
+			}
+			usage := conf.Usage
+			if usage == "" {
+				usage = "/" + conf.Name
+			}
+			e.Commands = append(e.Commands, &Command{
+				Name:      conf.Name,
+				Usage:     usage,
+				Desc:      conf.Description,
+				MinArgs:   conf.MinArgs,
+				Perms:     perms.FromNames(conf.Permissions),
+				Broadcast: conf.Broadcast,
+				tmpl:      tmpl,
+			})
+		}
+		for i, conf := range s.Reactions {
+			if conf.Event != EventIC && conf.Event != EventModCall {
+				return nil, fmt.Errorf("script: Unknown event '%v' for reaction #%v in %v.", conf.Event, i+1, s.File)
+			}
+			match, err := regexp.Compile(conf.Match)
+			if err != nil {
+				return nil, fmt.Errorf("script: Bad match for reaction #%v in %v (%w).", i+1, s.File, err)
+			}
+			tmpl, err := parseReply(conf.Event, conf.Reply)
+			if err != nil {
+				return nil, fmt.Errorf("script: Bad reply for reaction #%v in %v (%w).", i+1, s.File, err)
+			}
+			e.Reactions = append(e.Reactions, &Reaction{
+				Event:     conf.Event,
+				Broadcast: conf.Broadcast,
+				Block:     conf.Block,
+				match:     match,
+				tmpl:      tmpl,
+			})
+		}
+	}
+	return e, nil
+}
+
+// Parses a reply, checking that it's bounded.
+func parseReply(name string, reply string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBounded(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Checks that running the template always ends quickly. Execution can't be interrupted,
+// so instead, templates can only range over the arguments, nested at most
+// [maxRangeDepth] times, and can't call templates, which could recurse. Everything else
+// templates can do runs in bounded time.
+func checkBounded(tmpl *template.Template) error {
+	if len(tmpl.Templates()) > 1 {
+		return errors.New("replies can't define templates")
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return checkNode(tmpl.Tree, tmpl.Tree.Root, 0)
+}
+
+func checkNode(tree *parse.Tree, node parse.Node, depth int) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNode(tree, child, depth); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(tree, &n.BranchNode, depth)
+	case *parse.WithNode:
+		return checkBranch(tree, &n.BranchNode, depth)
+	case *parse.RangeNode:
+		loc, _ := tree.ErrorContext(n)
+		if !rangesOverArgs(n.Pipe) {
+			return fmt.Errorf("%v: replies can only range over .Args", loc)
+		}
+		if depth >= maxRangeDepth {
+			return fmt.Errorf("%v: ranges can't be nested more than %v times", loc, maxRangeDepth)
+		}
+		// The else branch only runs when there's nothing to range over.
+		if err := checkNode(tree, n.List, depth+1); err != nil {
+			return err
+		}
+		return checkNode(tree, n.ElseList, depth)
+	case *parse.TemplateNode:
+		loc, _ := tree.ErrorContext(n)
+		return fmt.Errorf("%v: replies can't call templates", loc)
+	}
+	return nil
+}
+
+func checkBranch(tree *parse.Tree, b *parse.BranchNode, depth int) error {
+	if err := checkNode(tree, b.List, depth); err != nil {
+		return err
+	}
+	return checkNode(tree, b.ElseList, depth)
+}
+
+// Checks whether the pipeline is just `.Args` or `$.Args`, possibly declaring variables.
+// Only the context has an Args field, so either is at most as long as the arguments.
+func rangesOverArgs(pipe *parse.PipeNode) bool {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return len(arg.Ident) == 1 && arg.Ident[0] == "Args"
+	case *parse.VariableNode:
+		return len(arg.Ident) == 2 && arg.Ident[0] == "$" && arg.Ident[1] == "Args"
+	}
+	return false
+}
+
+// Runs the command, returning its reply.
+func (c *Command) Run(ctx Context) (string, error) {
+	return run(c.tmpl, ctx)
+}
+
+// Checks whether the reaction is set on the event and its text matches.
+func (r *Reaction) Matches(event string, text string) bool {
+	return r.Event == event && r.match.MatchString(text)
+}
+
+// Runs the reaction, returning its reply.
+func (r *Reaction) Run(ctx Context) (string, error) {
+	return run(r.tmpl, ctx)
+}
+
+var errTooLong = errors.New("reply too long")
+
+// Stops writes past maxOutput, so a script can't build an enormous reply.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxOutput {
+		return 0, errTooLong
+	}
+	return b.Buffer.Write(p)
+}
+
+func run(tmpl *template.Template, ctx Context) (string, error) {
+	var b limitedBuffer
+	if err := tmpl.Execute(&b, ctx); err != nil {
+		return "", fmt.Errorf("script: Couldn't run %v (%w).", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
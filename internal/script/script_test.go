@@ -0,0 +1,115 @@
+package script
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/perms"
+)
+
+func command(name string, reply string) config.Script {
+	return config.Script{File: "test.toml", Commands: []config.ScriptCommand{{Name: name, Reply: reply}}}
+}
+
+func TestLoad(t *testing.T) {
+	e, err := Load([]config.Script{{
+		File: "test.toml",
+		Commands: []config.ScriptCommand{{
+			Name:        "roll",
+			Description: "Rolls a die.",
+			MinArgs:     1,
+			Permissions: []string{"kick"},
+			Broadcast:   true,
+			Reply:       "{{rand 6}}",
+		}},
+		Reactions: []config.ScriptReaction{{Event: EventIC, Match: "(?i)objection", Block: true, Reply: "No."}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Commands) != 1 || len(e.Reactions) != 1 {
+		t.Fatalf("got %v commands and %v reactions; want 1 and 1", len(e.Commands), len(e.Reactions))
+	}
+	cmd := e.Commands[0]
+	if cmd.Name != "roll" || cmd.Usage != "/roll" || cmd.MinArgs != 1 || cmd.Perms != perms.Kick || !cmd.Broadcast {
+		t.Errorf("got command %+v", *cmd)
+	}
+	r := e.Reactions[0]
+	if !r.Matches(EventIC, "OBJECTION!") || r.Matches(EventModCall, "objection") || r.Matches(EventIC, "hold it") {
+		t.Errorf("reaction matches the wrong events")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		script config.Script
+		want   string // part of the error
+	}{
+		{"no name", command("", "hi"), "without a name"},
+		{"bad template", command("x", "{{.User"), "Bad reply for /x"},
+		{"unknown event", config.Script{Reactions: []config.ScriptReaction{{Event: "ooc"}}}, "Unknown event"},
+		{"bad match", config.Script{Reactions: []config.ScriptReaction{{Event: EventIC, Match: "("}}}, "Bad match"},
+		{"range over a number", command("x", "{{range 1000000000}}{{end}}"), "only range over .Args"},
+		{"range over a function", command("x", `{{range atoi (arg .Args 0 "1")}}{{end}}`), "only range over .Args"},
+		{"range over a variable", command("x", "{{$a := .Args}}{{range $a}}{{end}}"), "only range over .Args"},
+		{"nested too deep", command("x", "{{range .Args}}{{range .Args}}{{range .Args}}{{end}}{{end}}{{end}}"), "nested"},
+		{"nested in else", command("x", "{{range .Args}}{{else}}{{range .Args}}{{range .Args}}{{end}}{{end}}{{end}}"), ""},
+		{"define", command("x", `{{define "a"}}{{template "a"}}{{end}}`), "define templates"},
+		{"block", command("x", `{{block "a" .}}{{end}}`), "define templates"},
+		{"template call", command("x", `{{template "x"}}`), "call templates"},
+	} {
+		_, err := Load([]config.Script{tc.script})
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%v: got error %v; want none", tc.desc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: got error %v; want one containing %q", tc.desc, err, tc.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		reply string
+		ctx   Context
+		want  string
+	}{
+		{"fields", "{{.User}} as {{.Name}} ({{.UID}}) in {{.Room}}", Context{User: "bob", Name: "Phoenix", UID: 3, Room: "Lobby"}, "bob as Phoenix (3) in Lobby"},
+		{"arg", `{{arg .Args 1 "none"}} {{arg .Args 5 "none"}}`, Context{Args: []string{"a", "b"}}, "b none"},
+		{"join and upper", `{{upper (join .Args "-")}}`, Context{Args: []string{"a", "b"}}, "A-B"},
+		{"range", "{{range .Args}}[{{.}}]{{end}}", Context{Args: []string{"a", "b"}}, "[a][b]"},
+		{"nested range", "{{range .Args}}{{range $.Args}}x{{end}}{{end}}", Context{Args: []string{"a", "b"}}, "xxxx"},
+		{"trimmed", "  hi \n", Context{}, "hi"},
+	} {
+		e, err := Load([]config.Script{command("x", tc.reply)})
+		if err != nil {
+			t.Errorf("%v: %v", tc.desc, err)
+			continue
+		}
+		got, err := e.Commands[0].Run(tc.ctx)
+		if err != nil || got != tc.want {
+			t.Errorf("%v: got %q, %v; want %q", tc.desc, got, err, tc.want)
+		}
+	}
+}
+
+func TestRunTooLong(t *testing.T) {
+	args := make([]string, 60)
+	for i := range args {
+		args[i] = strings.Repeat("a", 10)
+	}
+	e, err := Load([]config.Script{command("x", "{{range .Args}}{{range $.Args}}{{.}}{{end}}{{end}}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Commands[0].Run(Context{Args: args}); !errors.Is(err, errTooLong) {
+		t.Fatalf("got error %v; want errTooLong", err)
+	}
+}
@@ -0,0 +1,513 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/db"
+	"github.com/lambdcalculus/scs/internal/perms"
+)
+
+const (
+	// The name of the cookie holding the admin panel session.
+	adminCookie = "scs_admin"
+	// How much of the end of the server log the admin panel shows, in bytes.
+	adminLogTail = 16 << 10
+)
+
+// The sessions of users logged into the admin panel. Its methods can be called from
+// multiple goroutines.
+type adminSessions struct {
+	ttl      time.Duration
+	sessions map[string]*adminSession
+	mu       sync.Mutex
+}
+
+type adminSession struct {
+	username string
+	perms    perms.Mask // of the user's current role, see [SCServer.adminSession]
+	expires  time.Time
+}
+
+func newAdminSessions(ttl time.Duration) *adminSessions {
+	return &adminSessions{ttl: ttl, sessions: make(map[string]*adminSession)}
+}
+
+// Starts a session, returning its token.
+func (s *adminSessions) start(username string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, sess := range s.sessions {
+		if time.Now().After(sess.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = &adminSession{username: username, expires: time.Now().Add(s.ttl)}
+	return token, nil
+}
+
+// Returns the session with the token, if it exists and hasn't expired.
+func (s *adminSessions) get(token string) (adminSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok || time.Now().After(sess.expires) {
+		delete(s.sessions, token)
+		return adminSession{}, false
+	}
+	return *sess, true
+}
+
+func (s *adminSessions) end(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Registers the admin panel's handlers under the prefix (e.g. "/admin/").
+func (srv *SCServer) handleAdmin(mux *http.ServeMux, prefix string) {
+	mux.HandleFunc(prefix, srv.adminPage(prefix))
+	mux.HandleFunc(prefix+"login", srv.adminLogin(prefix))
+	mux.HandleFunc(prefix+"logout", srv.adminAction(prefix, perms.None, srv.adminLogout))
+	mux.HandleFunc(prefix+"kick", srv.adminAction(prefix, perms.Kick, srv.adminKick))
+	mux.HandleFunc(prefix+"ban", srv.adminAction(prefix, perms.Ban, srv.adminBan))
+	mux.HandleFunc(prefix+"unban", srv.adminAction(prefix, perms.Ban, srv.adminUnban))
+}
+
+// Serves the admin panel on its own port.
+func (srv *SCServer) listenAdmin() {
+	mux := http.NewServeMux()
+	srv.handleAdmin(mux, "/")
+	adminServer := &http.Server{
+		Addr:           fmt.Sprintf(":%v", srv.config.Admin.Port),
+		Handler:        mux,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	srv.logger.Infof("Serving the admin panel on port %v.", srv.config.Admin.Port)
+	srv.reportError("Stopped serving the admin panel: %v.", adminServer.ListenAndServe())
+}
+
+// Returns the session of the request, if it has a valid one. The user's role is looked
+// up on every request, so removing a user or changing their role takes effect right away:
+// their sessions end once they can't use the panel anymore.
+func (srv *SCServer) adminSession(r *http.Request) (adminSession, bool) {
+	cookie, err := r.Cookie(adminCookie)
+	if err != nil {
+		return adminSession{}, false
+	}
+	sess, ok := srv.admin.get(cookie.Value)
+	if !ok {
+		return adminSession{}, false
+	}
+	role, ok, err := srv.db.UserRole(sess.username)
+	if err != nil {
+		srv.logger.Warnf("admin: Couldn't check the role of '%v' (%v).", sess.username, err)
+		return adminSession{}, false
+	}
+	rl := srv.getRole(role)
+	if !ok || rl == nil || rl.Perms&perms.SeeIPIDs == 0 {
+		srv.admin.end(cookie.Value)
+		srv.logger.Infof("admin: Ended the session of '%v', who can't use the admin panel anymore.", sess.username)
+		return adminSession{}, false
+	}
+	sess.perms = rl.Perms
+	return sess, true
+}
+
+// Returns the host of the request's remote address, which logins are throttled by.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (srv *SCServer) adminLogin(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		host := remoteHost(r)
+		ipid := client.IPIDOf(net.ParseIP(host))
+		fail := func(msg string) {
+			srv.renderAdmin(w, adminData{Prefix: prefix, Error: msg})
+		}
+		if wait := srv.logins.wait(ipid); wait > 0 {
+			fail(fmt.Sprintf("Too many failed logins. Try again in %v.", wait.Round(time.Second)))
+			return
+		}
+
+		username := r.PostFormValue("username")
+		ok, role, err := srv.db.CheckAuth(username, r.PostFormValue("password"))
+		if err != nil {
+			srv.logger.Warnf("Error in authentication (%v).", err)
+			fail("Couldn't authenticate: internal error.")
+			return
+		}
+		if secret, needsCode, err := srv.db.TOTPSecret(username); err != nil {
+			srv.logger.Warnf("Error in authentication (%v).", err)
+			fail("Couldn't authenticate: internal error.")
+			return
+		} else if ok && needsCode && !srv.checkCode(username, secret, r.PostFormValue("code")) {
+			ok = false
+		}
+		rl := srv.getRole(role)
+		if ok && (rl == nil || rl.Perms&perms.SeeIPIDs == 0) {
+			srv.logger.Infof("admin: '%v' logged in from %v, but their role can't use the admin panel.", username, host)
+			fail("Your role can't use the admin panel.")
+			return
+		}
+		if !ok {
+			if err := srv.db.AddLoginFailure(ipid, username); err != nil {
+				srv.logger.Warnf("Couldn't record failed login (%v).", err)
+			}
+			srv.logins.fail(ipid)
+			srv.logger.Infof("admin: Failed login as '%v' from %v.", username, host)
+			fail("Incorrect password or code, or user doesn't exist.")
+			return
+		}
+		srv.logins.succeed(ipid)
+
+		token, err := srv.admin.start(username)
+		if err != nil {
+			srv.logger.Warnf("admin: Couldn't start session (%v).", err)
+			fail("Couldn't authenticate: internal error.")
+			return
+		}
+		srv.logger.Infof("admin: '%v' logged in from %v.", username, host)
+		http.SetCookie(w, &http.Cookie{
+			Name:     adminCookie,
+			Value:    token,
+			Path:     prefix,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, prefix, http.StatusSeeOther)
+	}
+}
+
+// Wraps an action of the admin panel, which must be POSTed by a user logged in with
+// the required permissions. The action returns the message to show, or an error.
+func (srv *SCServer) adminAction(prefix string, required perms.Mask,
+	action func(sess adminSession, r *http.Request) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		sess, ok := srv.adminSession(r)
+		if !ok {
+			http.Redirect(w, r, prefix, http.StatusSeeOther)
+			return
+		}
+		if sess.perms&required != required {
+			data := srv.adminData(prefix, sess)
+			data.Error = fmt.Sprintf("You don't have the required permissions (missing: %v).", required&^sess.perms)
+			srv.renderAdmin(w, data)
+			return
+		}
+		msg, err := action(sess, r)
+		if r.URL.Path == prefix+"logout" {
+			http.Redirect(w, r, prefix, http.StatusSeeOther)
+			return
+		}
+		data := srv.adminData(prefix, sess)
+		if err != nil {
+			data.Error = err.Error()
+		}
+		data.Message = msg
+		srv.renderAdmin(w, data)
+	}
+}
+
+func (srv *SCServer) adminLogout(sess adminSession, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(adminCookie); err == nil {
+		srv.admin.end(cookie.Value)
+	}
+	return "", nil
+}
+
+func (srv *SCServer) adminKick(sess adminSession, r *http.Request) (string, error) {
+	id, err := strconv.Atoi(r.PostFormValue("uid"))
+	if err != nil {
+		return "", fmt.Errorf("Invalid UID.")
+	}
+	c := srv.getByUID(id)
+	if c == nil {
+		return "", fmt.Errorf("No client with UID %v.", id)
+	}
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" {
+		reason = "No reason given."
+	}
+	moderator := sess.username + " (admin panel)"
+	srv.logger.Infof("admin: %v kicked %s. Reason: %s", moderator, c.LongString(), reason)
+	srv.kickClient(c, reason, moderator)
+	return fmt.Sprintf("Kicked UID %v.", id), nil
+}
+
+func (srv *SCServer) adminBan(sess adminSession, r *http.Request) (string, error) {
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" {
+		reason = "No reason given."
+	}
+	moderator := sess.username + " (admin panel)"
+	target := strings.TrimSpace(r.PostFormValue("target"))
+	duration := r.PostFormValue("duration")
+	if r.PostFormValue("kind") == "ip" {
+		if sess.perms&perms.All != perms.All {
+			return "", fmt.Errorf("Only admins can ban IP ranges.")
+		}
+		id, err := srv.banIPRange(target, duration, reason, moderator)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Added IP ban #%v.", id), nil
+	}
+	if target == "" {
+		return "", fmt.Errorf("No IPID given.")
+	}
+	if offline, known := srv.offlineIPID(target); offline && r.PostFormValue("offline") == "" {
+		why := "isn't connected"
+		if !known {
+			why = "has never joined"
+		}
+		return "", fmt.Errorf("The IPID %v %v. Tick \"Offline\" to ban it anyway.", target, why)
+	}
+	if err := srv.banIPID(target, duration, reason, moderator); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Banned IPID %v.", target), nil
+}
+
+func (srv *SCServer) adminUnban(sess adminSession, r *http.Request) (string, error) {
+	id, err := strconv.Atoi(r.PostFormValue("id"))
+	if err != nil {
+		return "", fmt.Errorf("Invalid ban ID.")
+	}
+	if r.PostFormValue("kind") == "ip" {
+		if sess.perms&perms.All != perms.All {
+			return "", fmt.Errorf("Only admins can lift IP range bans.")
+		}
+		if err := srv.liftIPBan(id); err != nil {
+			return "", err
+		}
+	} else if err := srv.db.NullBan(id); err != nil {
+		return "", err
+	}
+	srv.logger.Infof("admin: %v lifted ban #%v.", sess.username, id)
+	return fmt.Sprintf("Lifted ban #%v.", id), nil
+}
+
+func (srv *SCServer) adminPage(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != prefix {
+			http.NotFound(w, r)
+			return
+		}
+		sess, ok := srv.adminSession(r)
+		if !ok {
+			srv.renderAdmin(w, adminData{Prefix: prefix})
+			return
+		}
+		srv.renderAdmin(w, srv.adminData(prefix, sess))
+	}
+}
+
+// What the admin panel's template shows.
+type adminData struct {
+	Prefix   string
+	Username string // empty if not logged in
+	Message  string
+	Error    string
+
+	Uptime  time.Duration
+	Players int
+	Max     int
+	Rooms   []adminRoom
+	Clients []adminClient
+	Bans    []db.Ban
+	IPBans  []db.IPBan
+	Log     string
+}
+
+type adminRoom struct {
+	ID      int
+	Name    string
+	Players int
+	Status  string
+	Lock    string
+}
+
+type adminClient struct {
+	UID      int
+	Room     string
+	Char     string
+	Showname string
+	Username string
+	IPID     string
+	Software string
+}
+
+func (srv *SCServer) adminData(prefix string, sess adminSession) adminData {
+	data := adminData{
+		Prefix:   prefix,
+		Username: sess.username,
+		Uptime:   time.Since(srv.start).Round(time.Second),
+		Players:  srv.clients.SizeJoined(),
+		Max:      srv.config.MaxPlayers,
+	}
+	for _, r := range srv.rooms {
+		data.Rooms = append(data.Rooms, adminRoom{r.ID(), r.Name(), r.PlayerCount(), r.Status(), r.LockString()})
+	}
+	for c := range srv.clients.ClientsJoined() {
+		ac := adminClient{
+			UID:      c.UID(),
+			Char:     c.Charname(),
+			Showname: c.Showname(),
+			Username: c.Username(),
+			IPID:     c.IPID(),
+			Software: c.Software(),
+		}
+		if r := c.Room(); r != nil {
+			ac.Room = fmt.Sprintf("[%v] %v", r.ID(), r.Name())
+		}
+		data.Clients = append(data.Clients, ac)
+	}
+	var err error
+	if data.Bans, err = srv.db.GetActiveBans(); err != nil {
+		srv.logger.Warnf("admin: Couldn't get bans (%v).", err)
+	}
+	if data.IPBans, err = srv.db.GetIPBans(); err != nil {
+		srv.logger.Warnf("admin: Couldn't get IP bans (%v).", err)
+	}
+	if files := srv.logger.Files(); len(files) > 0 {
+		data.Log = tailFile(files[0], adminLogTail)
+	}
+	return data
+}
+
+// Returns up to the last `n` bytes of the file, starting at a line, or "" if it can't be read.
+func tailFile(path string, n int64) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	start := max(info.Size()-n, 0)
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return ""
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return ""
+	}
+	s := string(b)
+	if start > 0 {
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	return s
+}
+
+func (srv *SCServer) renderAdmin(w http.ResponseWriter, data adminData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	if err := adminTemplate.Execute(w, data); err != nil {
+		srv.logger.Debugf("admin: Error rendering page (%v).", err)
+	}
+}
+
+var adminTemplate = template.Must(template.New("admin").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Admin panel</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; }
+.error { color: #b00; }
+.message { color: #070; }
+pre { background: #eee; padding: 0.5em; max-height: 30em; overflow: auto; }
+form.inline { display: inline; }
+</style>
+</head>
+<body>
+<h1>Admin panel</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Message}}<p class="message">{{.Message}}</p>{{end}}
+{{if not .Username}}
+<form method="post" action="{{.Prefix}}login">
+<p><label>Username <input name="username" autocomplete="username"></label></p>
+<p><label>Password <input name="password" type="password" autocomplete="current-password"></label></p>
+<p><label>Code (if enabled) <input name="code" autocomplete="one-time-code"></label></p>
+<p><button>Log in</button></p>
+</form>
+{{else}}
+<form method="post" action="{{.Prefix}}logout"><p>Logged in as {{.Username}}. <button>Log out</button></p></form>
+<p>Uptime: {{.Uptime}}. Players: {{.Players}}/{{.Max}}.</p>
+
+<h2>Rooms</h2>
+<table>
+<tr><th>ID</th><th>Name</th><th>Players</th><th>Status</th><th>Lock</th></tr>
+{{range .Rooms}}<tr><td>{{.ID}}</td><td>{{.Name}}</td><td>{{.Players}}</td><td>{{.Status}}</td><td>{{.Lock}}</td></tr>
+{{end}}</table>
+
+<h2>Players</h2>
+<table>
+<tr><th>UID</th><th>Room</th><th>Character</th><th>Showname</th><th>OOC name</th><th>IPID</th><th>Client</th><th>Actions</th></tr>
+{{range .Clients}}<tr><td>{{.UID}}</td><td>{{.Room}}</td><td>{{.Char}}</td><td>{{.Showname}}</td><td>{{.Username}}</td><td>{{.IPID}}</td><td>{{.Software}}</td>
+<td><form class="inline" method="post" action="{{$.Prefix}}kick"><input type="hidden" name="uid" value="{{.UID}}"><input name="reason" placeholder="Reason"><button>Kick</button></form>
+<form class="inline" method="post" action="{{$.Prefix}}ban"><input type="hidden" name="kind" value="ipid"><input type="hidden" name="target" value="{{.IPID}}"><input name="duration" placeholder="Duration (e.g. 3d)" size="12"><input name="reason" placeholder="Reason"><button>Ban</button></form></td></tr>
+{{end}}</table>
+
+<h2>Bans</h2>
+<form method="post" action="{{.Prefix}}ban">
+<select name="kind"><option value="ipid">IPID</option><option value="ip">IP range</option></select>
+<input name="target" placeholder="IPID, IP or CIDR"><input name="duration" placeholder="Duration (e.g. 3d)"><input name="reason" placeholder="Reason">
+<label title="Needed to ban an IPID that isn't connected"><input type="checkbox" name="offline" value="1">Offline</label><button>Ban</button>
+</form>
+<table>
+<tr><th>ID</th><th>IPID</th><th>HDID</th><th>Reason</th><th>Moderator</th><th>Until</th><th></th></tr>
+{{range .Bans}}<tr><td>{{.BanID}}</td><td>{{.IPID}}</td><td>{{.HDID}}</td><td>{{.Reason}}</td><td>{{.Moderator}}</td><td>{{.End.UTC.Format "2006-01-02 15:04"}}</td>
+<td><form class="inline" method="post" action="{{$.Prefix}}unban"><input type="hidden" name="id" value="{{.BanID}}"><button>Unban</button></form></td></tr>
+{{end}}</table>
+<table>
+<tr><th>ID</th><th>IP range</th><th>Reason</th><th>Moderator</th><th>Until</th><th></th></tr>
+{{range .IPBans}}<tr><td>{{.BanID}}</td><td>{{.CIDR}}</td><td>{{.Reason}}</td><td>{{.Moderator}}</td><td>{{.End.UTC.Format "2006-01-02 15:04"}}</td>
+<td><form class="inline" method="post" action="{{$.Prefix}}unban"><input type="hidden" name="kind" value="ip"><input type="hidden" name="id" value="{{.BanID}}"><button>Unban</button></form></td></tr>
+{{end}}</table>
+
+<h2>Server log</h2>
+<pre>{{.Log}}</pre>
+{{end}}
+</body>
+</html>
+`))
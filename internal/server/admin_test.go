@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lambdcalculus/scs/internal/aotest"
+	"github.com/lambdcalculus/scs/internal/db"
+)
+
+// Logs into the admin panel, returning an HTTP client holding the session.
+func adminLogin(t *testing.T, base string, username string, password string) *http.Client {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	hc := &http.Client{Jar: jar}
+	resp, err := hc.PostForm(base+"login", url.Values{"username": {username}, "password": {password}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return hc
+}
+
+// Returns whether the admin panel shows the user as logged in.
+func adminLoggedIn(t *testing.T, hc *http.Client, base string, username string) bool {
+	t.Helper()
+	resp, err := hc.Get(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Contains(string(body), "Logged in as "+username+".")
+}
+
+func TestAdminSessionFollowsRole(t *testing.T) {
+	s := aotest.StartServer(t, map[string]string{
+		"config.toml": aotest.DefaultConfigs["config.toml"] + "\n[admin]\nenabled = true\n",
+		"roles.toml": aotest.DefaultConfigs["roles.toml"] + `
+[[role]]
+name = "Helper"
+permissions = ["kick"]
+`,
+	})
+	s.AddUser(t, "demoted", "hunter2", "Moderator")
+	s.AddUser(t, "removed", "hunter3", "Moderator")
+	base := strings.Replace(s.WS, "ws://", "http://", 1) + "/admin/"
+
+	demoted := adminLogin(t, base, "demoted", "hunter2")
+	removed := adminLogin(t, base, "removed", "hunter3")
+	if !adminLoggedIn(t, demoted, base, "demoted") || !adminLoggedIn(t, removed, base, "removed") {
+		t.Fatal("couldn't log into the admin panel")
+	}
+
+	d, err := db.Init(filepath.Join(s.Dir, "database.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.UpdateRole("demoted", "Helper"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RemoveAuth("removed"); err != nil {
+		t.Fatal(err)
+	}
+	if adminLoggedIn(t, demoted, base, "demoted") {
+		t.Error("a user demoted to a role that can't use the panel is still logged in")
+	}
+	if adminLoggedIn(t, removed, base, "removed") {
+		t.Error("a removed user is still logged in")
+	}
+}
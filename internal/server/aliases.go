@@ -0,0 +1,52 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+)
+
+// Records the client's HDID, and alerts moderators if a connected client with a different
+// IPID has the same one, which likely means it's the same person behind a VPN.
+func (srv *SCServer) checkHDID(c *client.Client) {
+	hdid := c.Ident()
+	if hdid == "" {
+		return
+	}
+	if err := srv.db.SeeHDID(c.IPID(), hdid); err != nil {
+		srv.logger.Warnf("server: Error recording HDID (%s).", err)
+	}
+	for cl := range srv.clients.ClientsJoined() {
+		if cl != c && cl.Ident() == hdid && cl.IPID() != c.IPID() {
+			srv.alertMods("alert.alt", c.IPID(), cl.LongString())
+		}
+	}
+}
+
+func (srv *SCServer) cmdAliases(c *client.Client, args []string) (string, bool) {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return srv.tr(c, "cmd.bad_uid", args[0]), false
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return srv.tr(c, "cmd.no_uid", id), false
+	}
+	if target.Ident() == "" {
+		return srv.tr(c, "aliases.no_hdid", id), false
+	}
+	aliases, err := srv.db.GetAliases(target.Ident())
+	if err != nil {
+		srv.logger.Warnf("Couldn't get aliases (%v).", err)
+		return srv.tr(c, "aliases.error"), false
+	}
+	if len(aliases) == 0 {
+		return srv.tr(c, "aliases.none", id), false
+	}
+	msg := "\n" + srv.tr(c, "aliases.header", id)
+	for _, a := range aliases {
+		msg += "\n" + srv.tr(c, "aliases.entry", a.IPID, a.FirstSeen.Format(time.DateTime), a.LastSeen.Format(time.DateTime))
+	}
+	return msg, false
+}
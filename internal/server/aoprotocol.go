@@ -2,13 +2,17 @@ package server
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
 	"github.com/lambdcalculus/scs/internal/perms"
 	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/internal/uid"
 	"github.com/lambdcalculus/scs/pkg/logger"
 	"github.com/lambdcalculus/scs/pkg/packets"
 )
@@ -39,16 +43,14 @@ var handlerMapAO = map[string]handlerAO{
 	"HP":      {(*SCServer).handleBar, 2, 2, true},
 	"RT":      {(*SCServer).handleJudge, 1, 2, true},
 	"ZZ":      {(*SCServer).handleModCall, 1, 1, true},
+	"SETCASE": {(*SCServer).handleSetCase, 7, 7, true},
+	"CASEA":   {(*SCServer).handleCaseAlert, 6, 6, true},
 
 	// These will be repurposed for a better inventory system.
 	// LE (evidence list)
 	// PE (add evidence)
 	// DE (remove evidence)
 	// EE (edit evidence)
-
-	// Who even uses this? I'll probably not implement it.
-	// SETCASE (case preferences)
-	// CASEA (case alert)
 }
 
 func (srv *SCServer) handlePacketAO(c *client.Client, pkt packets.PacketAO) {
@@ -69,89 +71,254 @@ func (srv *SCServer) handlePacketAO(c *client.Client, pkt packets.PacketAO) {
 func (srv *SCServer) handleHI(c *client.Client, contents []string) {
 	c.SetIdent(contents[0])
 	c.WriteAO("ID", "scs", "0")
+}
+
+type featureAO struct {
+	name  string
+	since client.Version // the version of AO2 that introduced the feature
+	needs string         // a packet that must be handled for the feature to work, if any
+}
+
+// The FL features. Features whose packets aren't handled are never advertised.
+var featuresAO = []featureAO{
+	{"yellowtext", client.Version{2, 1, 0}, ""},
+	{"flipping", client.Version{2, 1, 0}, ""},
+	{"customobjections", client.Version{2, 1, 0}, ""},
+	{"fastloading", client.Version{2, 1, 0}, ""},
+	{"noencryption", client.Version{2, 1, 0}, ""},
+	{"deskmod", client.Version{2, 3, 0}, ""},
+	{"evidence", client.Version{2, 3, 0}, "PE"},
+	{"cccc_ic_support", client.Version{2, 6, 0}, ""},
+	{"arup", client.Version{2, 6, 0}, ""},
+	{"casing_alerts", client.Version{2, 6, 0}, "CASEA"},
+	{"modcall_reason", client.Version{2, 6, 0}, "ZZ"},
+	{"looping_sfx", client.Version{2, 8, 0}, ""},
+	{"additive", client.Version{2, 8, 0}, ""},
+	{"effects", client.Version{2, 8, 0}, ""},
+	{"y_offset", client.Version{2, 9, 0}, ""},
+	{"expanded_desk_mods", client.Version{2, 9, 0}, ""},
+	{"auth_packet", client.Version{2, 9, 1}, ""},
+}
+
+// Returns the features to advertise, leaving out the disabled ones and the ones whose
+// packets aren't handled. Fails if a disabled feature doesn't exist.
+func enabledFeaturesAO(disabled []string) ([]featureAO, error) {
+	off := make(map[string]struct{}, len(disabled))
+	for _, name := range disabled {
+		if !slices.ContainsFunc(featuresAO, func(f featureAO) bool { return f.name == name }) {
+			return nil, fmt.Errorf("server: Can't disable unknown feature '%v'.", name)
+		}
+		off[name] = struct{}{}
+	}
+	var features []featureAO
+	for _, f := range featuresAO {
+		if _, ok := off[f.name]; ok {
+			continue
+		}
+		if _, ok := handlerMapAO[f.needs]; f.needs != "" && !ok {
+			continue
+		}
+		features = append(features, f)
+	}
+	return features, nil
+}
+
+func (srv *SCServer) handleID(c *client.Client, contents []string) {
+	c.SetSoftware(contents[0], contents[1])
+	if ok, reason := srv.checkSoftware(c); !ok {
+		srv.logger.Infof("Refused %v (IPID: %v): %s", c.Addr(), c.IPID(), reason)
+		c.Notify(reason)
+		c.SetCloseReason(client.ClosePolicy, reason)
+		srv.removeClient(c)
+		return
+	}
+
 	c.WriteAO("PN", strconv.Itoa(srv.clients.SizeJoined()), strconv.Itoa(srv.config.MaxPlayers))
 
-	c.WriteAO("FL",
-		"yellowtext", "flipping", "customobjections", "fastloading", "noencryption", // 2.1.0 features
-		"deskmod",                                                        /*"evidence",*/ // 2.3 - 2.5 features
-		"cccc_ic_support", "arup" /*"casing_alerts",*/, "modcall_reason", // 2.6 features
-		"looping_sfx", "additive", "effects", // 2.8 features
-		"y_offset", "expanded_desk_mods", // 2.9 features
-		"auth_packet", // 2.9.1 feature
-	)
+	// Only AO2 uses these version numbers, so other clients get every feature.
+	v, known := c.Version()
+	var features []string
+	for _, f := range srv.features {
+		if !known || c.Software() != "AO2" || !v.Less(f.since) {
+			features = append(features, f.name)
+		}
+	}
+	c.WriteAO("FL", features...)
 
 	if srv.config.AssetURL != "" {
 		c.WriteAO("ASS", srv.config.AssetURL)
 	}
 }
 
-func (srv *SCServer) handleID(c *client.Client, contents []string) {
-	// no-op
+// Clamps an offset ("x" or "x&y") to the configured caps. Returns `false` if it's malformed.
+func (srv *SCServer) clampOffset(s string) (string, bool) {
+	caps := []int{srv.config.Clients.MaxOffsetX, srv.config.Clients.MaxOffsetY}
+	offsets := strings.Split(s, "&")
+	for i, off := range offsets {
+		n, err := strconv.Atoi(off)
+		if err != nil {
+			return "", false
+		}
+		if i < len(caps) && caps[i] > 0 {
+			offsets[i] = strconv.Itoa(max(min(n, caps[i]), -caps[i]))
+		}
+	}
+	return strings.Join(offsets, "&"), true
+}
+
+// Checks whether the client's software is allowed to join. If not, returns the reason,
+// to be sent to the client.
+func (srv *SCServer) checkSoftware(c *client.Client) (ok bool, reason string) {
+	for _, s := range srv.config.Clients.BlockedSoftware {
+		if strings.EqualFold(s, c.Software()) {
+			return false, fmt.Sprintf("%v is not allowed in this server.", c.Software())
+		}
+	}
+	if srv.config.Clients.MinVersion == "" {
+		return true, ""
+	}
+	// Already validated when the server was made.
+	minVer, _ := client.ParseVersion(srv.config.Clients.MinVersion)
+	if v, known := c.Version(); known && v.Less(minVer) {
+		return false, fmt.Sprintf("Your client is outdated (version %v). This server requires version %v or newer.", v, minVer)
+	}
+	return true, ""
 }
 
 func (srv *SCServer) handleAskCounts(c *client.Client, contents []string) {
+	if c.LegacyIPID() != c.IPID() {
+		if err := srv.db.MigrateIPID(c.LegacyIPID(), c.IPID()); err != nil {
+			srv.logger.Warnf("server: Error migrating legacy IPID (%s).", err)
+		}
+	}
 	banned, bans, err := srv.db.CheckBanned(c.IPID(), c.Ident())
 	if err != nil {
 		srv.logger.Warnf("server: Error checking ban (%s).", err)
 	}
+	srv.checkEvasion(c)
+	srv.checkHDID(c)
 	if banned {
 		var sb strings.Builder
 		for _, ban := range bans {
-			sb.WriteString(fmt.Sprintf("%s. (until: %s)\n", ban.Reason, ban.End.UTC().Format(time.UnixDate)))
+			sb.WriteString(srv.fillNotice(srv.config.Templates.Banned,
+				notice{reason: ban.Reason, moderator: ban.Moderator, until: ban.End, duration: ban.End.Sub(ban.Start)}))
+			sb.WriteString("\n")
 		}
 
 		c.WriteAO("BD", sb.String())
+		c.SetCloseReason(client.CloseBanned, sb.String())
+		srv.removeClient(c)
 		return
 	}
 
-	charCount := strconv.Itoa(srv.rooms[0].CharsLen())
-	musicCount := strconv.Itoa(srv.rooms[0].MusicLen())
+	charCount := strconv.Itoa(srv.landing.CharsLen())
+	musicCount := strconv.Itoa(srv.landing.MusicLen())
 
-	if srv.clients.SizeJoined() >= srv.config.MaxPlayers {
-		c.Notify("The server is full.")
+	// TODO: implement evidence
+	if srv.serverFull(c) {
+		if srv.enqueue(c, func() { c.WriteAO("SI", charCount, "0", musicCount) }) {
+			return
+		}
+		c.Notify(srv.tr(c, "server.full"))
 		srv.logger.Infof("A client (IPID: %v) couldn't join because the server is full.", c.IPID())
+		c.SetCloseReason(client.CloseFull, "The server is full.")
 		srv.removeClient(c)
 		return
 	}
-	// TODO: implement evidence
 	c.WriteAO("SI", charCount, "0", musicCount)
 }
 
+// Alerts moderators if the client's HDID matches recent bans on other IPIDs.
+func (srv *SCServer) checkEvasion(c *client.Client) {
+	if srv.config.EvasionAlertDays <= 0 || c.Ident() == "" {
+		return
+	}
+	since := time.Now().Add(-time.Duration(srv.config.EvasionAlertDays) * 24 * time.Hour)
+	bans, err := srv.db.GetEvasionBans(c.IPID(), c.Ident(), since)
+	if err != nil {
+		srv.logger.Warnf("server: Error checking ban evasion (%s).", err)
+		return
+	}
+	for _, ban := range bans {
+		srv.alertMods("alert.evasion", c.IPID(), ban.BanID, ban.IPID, ban.Reason)
+	}
+}
+
 func (srv *SCServer) handleRequestChars(c *client.Client, contents []string) {
-	c.WriteAO("SC", srv.rooms[0].Chars()...)
-	c.WriteAO("CharsCheck", srv.rooms[0].TakenList()...)
+	c.WriteAORaw(srv.landing.CharListAO())
+	c.WriteAO("CharsCheck", srv.landing.TakenList()...)
 }
 
 func (srv *SCServer) handleRequestMusic(c *client.Client, contents []string) {
-	// TODO: Maybe better have everything pre-prepared. But I doubt this is too slow to matter.
-
 	// AO uses this for both areas and songs.
-	vis := srv.rooms[0].VisibleNames()
-	music := srv.rooms[0].MusicList()
-
-	list := make([]string, 0, len(vis)+len(music))
-	list = append(list, vis...)
-	list = append(list, music...)
-	c.WriteAO("SM", list...)
+	c.WriteAORaw(srv.landing.SMListAO())
 }
 
 func (srv *SCServer) handleDone(c *client.Client, contents []string) {
 	// Client has committed to joining.
-	uid := srv.uidHeap.Take()
-	srv.rooms[0].Enter(room.SpectatorCID, uid)
-	c.SetUID(uid)
+	if c.UID() != uid.Unjoined {
+		return
+	}
+	if !srv.hookJoin(c) {
+		srv.logger.Infof("A client (IPID: %v) was stopped from joining by a hook.", c.IPID())
+		c.SetCloseReason(client.ClosePolicy, "You can't join this server.")
+		srv.removeClient(c)
+		return
+	}
+	if srv.lockedOut(c) {
+		c.Notify(srv.tr(c, "server.lockdown"))
+		c.SetCloseReason(client.ClosePolicy, "The server is in lockdown.")
+		srv.removeClient(c)
+		return
+	}
+	if srv.raidRejects(c) {
+		srv.logger.Infof("A client (IPID: %v) was turned away by raid mode.", c.IPID())
+		c.Notify(srv.tr(c, "server.raid"))
+		c.SetCloseReason(client.ClosePolicy, "The server is in raid mode.")
+		srv.removeClient(c)
+		return
+	}
+	if srv.serverFull(c) {
+		// The server may have filled up since the player count was checked.
+		srv.logger.Infof("A client (IPID: %v) couldn't join because the server is full.", c.IPID())
+		c.Notify(srv.tr(c, "server.full"))
+		c.SetCloseReason(client.CloseFull, "The server is full.")
+		srv.removeClient(c)
+		return
+	}
+	id, err := srv.uidHeap.Take()
+	if err != nil {
+		// Can happen if several clients pass the player count check at once.
+		srv.logger.Infof("A client (IPID: %v) couldn't join because the server is full.", c.IPID())
+		c.Notify(srv.tr(c, "server.full"))
+		c.SetCloseReason(client.CloseFull, "The server is full.")
+		srv.removeClient(c)
+		return
+	}
+	if !srv.landing.Enter(room.SpectatorCID, id) {
+		srv.uidHeap.Free(id)
+		srv.logger.Infof("A client (IPID: %v) couldn't join because the landing room is full of spectators.", c.IPID())
+		c.Notify(srv.tr(c, "server.full"))
+		c.SetCloseReason(client.CloseFull, "The server is full.")
+		srv.removeClient(c)
+		return
+	}
+	c.SetUID(id)
+	srv.waits.forget(c)
 	c.SetCID(room.SpectatorCID)
 	c.SetCharname("Spectator")
-	c.SetRoom(srv.rooms[0])
+	c.SetRoom(srv.landing)
 	c.WriteAO("DONE")
-	logger.Debugf("A client has joined with UID %v.", uid)
+	logger.Debugf("A client has joined with UID %v.", id)
+	srv.events.Publish(events.Event{Kind: events.Join, Client: c, Room: srv.landing})
 
 	c.UpdateBackground()
 	c.UpdateSides()
 	c.UpdateBars()
 	c.UpdateSong()
 	c.UpdateAmbiance()
-	srv.sendRoomUpdateAllAO(packets.UpdateAll)
+	srv.sendRoomUpdateAll(packets.UpdateAll, srv.landing)
+	srv.afterJoin(c)
 }
 
 func (srv *SCServer) handleChangeChars(c *client.Client, contents []string) {
@@ -159,32 +326,39 @@ func (srv *SCServer) handleChangeChars(c *client.Client, contents []string) {
 	if err != nil {
 		return
 	}
-	c.ChangeChar(cid)
-	if !c.CharPicked() {
-		srv.sendServerMessageToRoom(srv.rooms[0], fmt.Sprintf("%s has joined the server!", c.ShortString()))
-		srv.rooms[0].LogEvent(room.EventEnter, "%s joined the server.", c.LongString())
-		c.SetCharPicked(true)
+	if cid != room.SpectatorCID && !srv.canPickChar(c) {
+		return
 	}
+	if !c.Room().CanUse(cid, c.EffectivePerms()) {
+		srv.tell(c, "room.manager_only")
+		return
+	}
+	c.ChangeChar(cid)
+	srv.trackChar(c)
+	srv.announcePicked(c)
 	// TODO: announce change of chars in room?
 	// TODO: SpriteChat version
-	srv.writeToRoomAO(c.Room(), "CharsCheck", c.Room().TakenList()...)
+	srv.sendCharsCheck(c.Room())
 }
 
 func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 	// Welcome to He11. It is time to validate an IC message.
 	if c.CID() == room.SpectatorCID {
 		c.Room().LogEvent(room.EventFail, "%s tried speaking IC as a Spectator.", c.LongString())
-		srv.sendServerMessage(c, "Spectators cannot speak.")
+		srv.tell(c, "ic.spectator")
+		return
+	}
+	if !srv.roomAllowsIC(c) {
 		return
 	}
 	if c.MuteState()&client.MutedIC != 0 {
 		c.Room().LogEvent(room.EventFail, "%s tried to speak IC, but was muted.", c.LongString())
-		srv.sendServerMessage(c, "You are IC muted!")
+		srv.tell(c, "ic.muted")
 		return
 	}
 	if c.Room().LockState() == room.LockSpec && !c.Room().IsInvited(c.UID()) {
 		c.Room().LogEvent(room.EventFail, "%s tried to speak IC but was not invited.", c.LongString())
-		srv.sendServerMessage(c, "This room is in spectatable mode and you are not on the invite list.")
+		srv.tell(c, "ic.not_invited")
 		return
 	}
 	var valid bool = false
@@ -218,16 +392,14 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 		resp[0] = "1"
 	}
 	if mod, err := strconv.Atoi(resp[0]); err != nil || mod < 0 || mod > 5 {
-		reason = "Invalid deskmod."
-		srv.sendServerMessage(c, reason)
+		reason = srv.tell(c, "ic.invalid_deskmod")
 		return
 	}
 
 	// char name (i.e. the actual file)
 	iniswapping := (c.Room().GetNameByCID(c.CID()) != resp[2])
-	if !c.Room().AllowIniswapping() && iniswapping {
-		reason = "Iniswapping is not allowed in this room!"
-		srv.sendServerMessage(c, reason)
+	if iniswapping && !c.Room().CanIniswapTo(resp[2]) {
+		reason = srv.tell(c, "ic.no_iniswap")
 		return
 	}
 
@@ -235,36 +407,27 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 	// TODO: narrator/first-person mode.
 
 	// message
-	resp[4] = strings.TrimSpace(resp[4])
-	if len(resp[4]) > srv.config.MaxMsgSize {
-		reason = "Your message is too long!"
-		srv.sendServerMessage(c, reason)
+	resp[4] = strings.TrimSpace(srv.sanitize.Text(resp[4]))
+	if utf8.RuneCountInString(resp[4]) > srv.config.MaxMsgSize {
+		reason = srv.tell(c, "msg.too_long")
 		return
 	}
 	if !c.Room().AllowBlankpost() && resp[4] == "" {
-		reason = "Blankposting is not allowed in this room!"
-		srv.sendServerMessage(c, reason)
+		reason = srv.tell(c, "ic.no_blankpost")
 		return
 	}
 	if c.Room().LastSpeaker() == c.CID() && c.LastMsg() == resp[4] && c.LastMsg() != "" {
-		reason = "You just sent that message! Watch out for lag."
-		srv.sendServerMessage(c, reason)
+		reason = srv.tell(c, "ic.duplicate")
 		return
 	}
 
 	// pos/side
-	validPos := false
-	for _, side := range c.Room().Sides() {
-		if resp[5] == side {
-			validPos = true
-		}
+	if !c.Room().HasSide(resp[5]) {
+		resp[5] = c.Room().DefaultSide()
 	}
-	if !validPos {
-		if len(c.Room().Sides()) > 0 {
-			resp[5] = c.Room().Sides()[0]
-		} else {
-			resp[5] = "wit" // TODO: un-hardcode
-		}
+	// The character may be restricted to some of the sides.
+	if sides := c.Room().CharSides(c.CID()); len(sides) > 0 && !slices.Contains(sides, resp[5]) {
+		resp[5] = sides[0]
 	}
 
 	// sfx (resp[6])
@@ -289,8 +452,7 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 	// old clients dont support the '4&custom' modifier
 	// but fuck them
 	if !c.Room().AllowShouting() && resp[10] != "0" {
-		reason = "Shhh! Shouting is not allowed in this room!"
-		srv.sendServerMessage(c, reason)
+		reason = srv.tell(c, "ic.no_shouts")
 		return
 	}
 	if mod, err := strconv.Atoi(strings.Split(resp[10], "&")[0]); err != nil || mod < 0 || mod > 4 {
@@ -314,17 +476,19 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 	}
 
 	// text color
-	if c, err := strconv.Atoi(resp[14]); err != nil || c < 0 || c > 11 {
+	if color, err := strconv.Atoi(resp[14]); err != nil || color < 0 || color > room.MaxTextColor {
 		reason = "Invalid text color."
 		return
+	} else if !c.Room().ColorAllowed(color) {
+		reason = srv.tell(c, "ic.banned_color")
+		return
 	}
 
 	// 2.6+ extensions, from here on
 	// showname
-	resp[15] = strings.TrimSpace(resp[15])
-	if len(resp[15]) > srv.config.MaxNameSize {
-		reason = "Your showname is too long!"
-		srv.sendServerMessage(c, reason)
+	resp[15] = strings.TrimSpace(srv.sanitize.Name(resp[15]))
+	if utf8.RuneCountInString(resp[15]) > srv.config.MaxNameSize {
+		reason = srv.tell(c, "ic.showname_too_long")
 		return
 	}
 
@@ -339,13 +503,12 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 	// self offset
 	// older clients don't support two-dimensional offsets
 	// but fuck them
-	offsets := strings.Split(resp[19], "&")
-	for _, off := range offsets {
-		if _, err := strconv.Atoi(off); err != nil {
-			reason = "Invalid self-offset."
-			return
-		}
+	offset, ok := srv.clampOffset(resp[19])
+	if !ok {
+		reason = "Invalid self-offset."
+		return
 	}
+	resp[19] = offset
 
 	// non-interrupting preanim ("immediate")
 	if resp[22] == "" {
@@ -400,6 +563,22 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 	/* END OF VALIDATION */
 	valid = true
 
+	if !srv.checkSlowmode(c) {
+		return
+	}
+	if !srv.checkRepeats(c, resp[2], resp[1], resp[7], resp[4]) {
+		return
+	}
+	if resp[4] != "" && !srv.checkFlood(c, resp[4], false) {
+		return
+	}
+
+	hooked := ICMessage{Text: resp[4], Showname: resp[15]}
+	if !srv.hookIC(c, &hooked) {
+		return
+	}
+	resp[4], resp[15] = hooked.Text, hooked.Showname
+
 	c.SetCharname(resp[2])
 	c.SetLastMsg(resp[4])
 	c.SetSide(resp[5])
@@ -433,12 +612,10 @@ func (srv *SCServer) handleIC(c *client.Client, contents []string) {
 			resp[21] = pd.LastFlip
 			goto paired
 		} else if pd.WantedCID != c.CID() {
-			srv.sendServerMessage(other, "%v wants to pair with you!", c.ShortString())
+			srv.tell(other, "ic.pair_request", c.ShortString())
 		} else if c.Side() != other.Side() {
-			srv.sendServerMessage(other,
-				fmt.Sprintf("You're not in the same position as your pairing partner! Their pos is '%v'.", c.Side()))
-			srv.sendServerMessage(c,
-				fmt.Sprintf("You're not in the same position as your pairing partner! Their pos is '%v'.", other.Side()))
+			srv.tell(other, "ic.pair_position", c.Side())
+			srv.tell(c, "ic.pair_position", other.Side())
 		}
 	}
 nopair:
@@ -449,19 +626,26 @@ nopair:
 	resp[21] = "0"   // other_flip
 paired:
 
+	if c.MuteState()&client.MutedShadow != 0 {
+		// Only the sender sees the message, so it looks like it went through.
+		c.Room().LogEvent(room.EventMod, "%s is shadow muted. Dropped IC message: %s", c.LongString(), resp[4])
+		c.WriteAO("MS", resp...)
+		return
+	}
 	c.Room().SetLastSpeaker(c.CID())
 	name := c.Charname()
 	if c.Showname() != "" {
 		name = c.Showname()
 	}
-	c.Room().LogEvent(room.EventIC, "%s: %s | (from %s)", name, resp[4], c.LongString())
+	srv.events.Publish(events.Event{Kind: events.IC, Client: c, Room: c.Room(), Name: name, Text: resp[4]})
+	c.Room().MarkIC(c.UID())
 	srv.writeToRoomAO(c.Room(), "MS", resp...)
 }
 
 func (srv *SCServer) handleOOC(c *client.Client, contents []string) {
 	if c.MuteState()&client.MutedOOC != 0 {
 		c.Room().LogEvent(room.EventFail, "%s tried to speak in OOC, but was muted.", c.LongString())
-		srv.sendServerMessage(c, "You are OOC muted!")
+		srv.tell(c, "ooc.muted")
 		return
 	}
 	name := contents[0]
@@ -472,39 +656,34 @@ func (srv *SCServer) handleOOC(c *client.Client, contents []string) {
 	defer func() {
 		if !valid {
 			c.Room().LogEvent(room.EventFail, "%s sent an invalid OOC message (%s): %#v",
-				c.LongString(), reason, contents)
+				c.LongString(), reason, []string{name, loggedOOC(msg)})
 		}
 	}()
 
-	outMsg := strings.TrimSpace(msg)
+	outMsg := strings.TrimSpace(srv.sanitize.Text(msg))
 	if outMsg == "" {
-		reason = "Cannot send blank OOC message."
-		srv.sendServerMessage(c, reason)
+		reason = srv.tell(c, "ooc.blank")
 		return
 	}
-	if len(outMsg) > srv.config.MaxMsgSize {
-		reason = "Your message is too long!"
-		srv.sendServerMessage(c, reason)
+	if utf8.RuneCountInString(outMsg) > srv.config.MaxMsgSize {
+		reason = srv.tell(c, "msg.too_long")
 		return
 	}
 
-	outName := strings.TrimSpace(name)
+	outName := strings.TrimSpace(srv.sanitize.Name(name))
 	if outName == "" {
-		reason = "Set a username to send OOC messages!"
-		srv.sendServerMessage(c, reason)
+		reason = srv.tell(c, "ooc.no_username")
 		return
 	}
-	if len(outName) > srv.config.MaxNameSize {
-		reason = "Your username is too long!"
-		srv.sendServerMessage(c, reason)
+	if utf8.RuneCountInString(outName) > srv.config.MaxNameSize {
+		reason = srv.tell(c, "ooc.username_too_long")
 		return
 	}
 	// TODO: make username check room-based?
 	// this would require making changes to moveClient.
 	for cl := range srv.clients.Clients() {
 		if cl.Username() == outName && cl != c {
-			reason = fmt.Sprintf("Username '%v' is already in use in the server.", name)
-			srv.sendServerMessage(c, reason)
+			reason = srv.tell(c, "ooc.username_taken", name)
 			return
 		}
 	}
@@ -526,8 +705,16 @@ func (srv *SCServer) handleOOC(c *client.Client, contents []string) {
 		return
 	}
 
+	if !srv.canSpeak(c) || !srv.checkFlood(c, outMsg, true) {
+		return
+	}
+	if c.MuteState()&client.MutedShadow != 0 {
+		c.Room().LogEvent(room.EventMod, "%s is shadow muted. Dropped OOC message: %s", c.LongString(), outMsg)
+		c.SendOOCMessage(outName, outMsg, false)
+		return
+	}
 	srv.sendOOCMessageToRoom(c.Room(), outName, outMsg, false)
-	c.Room().LogEvent(room.EventOOC, "%s: %s | (from %s)", outName, outMsg, c.LongString())
+	srv.events.Publish(events.Event{Kind: events.OOC, Client: c, Room: c.Room(), Name: outName, Text: outMsg})
 }
 
 func (srv *SCServer) handleMusicArea(c *client.Client, contents []string) {
@@ -548,14 +735,12 @@ func (srv *SCServer) handleMusicArea(c *client.Client, contents []string) {
 }
 
 func (srv *SCServer) handleMusic(c *client.Client, contents []string) {
-	if c.MuteState()&client.MutedMusic != 0 {
-		c.Room().LogEvent(room.EventFail, "%s tried to play song '%s', but was muted.", c.LongString(), contents[0])
-		srv.sendServerMessage(c, "You are muted from playing music.")
+	if !srv.canSpeak(c) {
 		return
 	}
-	if (c.Room().LockState() == room.LockSpec) && !c.Room().IsInvited(c.UID()) {
-		c.Room().LogEvent(room.EventFail, "%s tried to play song '%s', but was not invited.", c.LongString(), contents[0])
-		srv.sendServerMessage(c, "You are only allowed to spectate in this area.")
+	if ok, reason := srv.canPlayMusic(c); !ok {
+		c.Room().LogEvent(room.EventFail, "%s tried to play song '%s', but couldn't (%s)", c.LongString(), contents[0], reason)
+		srv.sendServerMessage(c, "%s", reason)
 		return
 	}
 
@@ -566,24 +751,25 @@ func (srv *SCServer) handleMusic(c *client.Client, contents []string) {
 
 	var showname string
 	if len(contents) >= 3 {
-		showname = strings.TrimSpace(contents[2])
+		showname = strings.TrimSpace(srv.sanitize.Name(contents[2]))
+		if utf8.RuneCountInString(showname) > srv.config.MaxNameSize {
+			srv.tell(c, "ic.showname_too_long")
+			return
+		}
 		c.SetShowname(showname)
 	}
 	if showname == "" {
 		showname = c.Room().GetNameByCID(c.CID())
 	}
 
-	effects := "0"
+	effects := packets.EffectDefault
 	if len(contents) >= 4 {
-		effects = contents[3]
-	}
-	c.Room().SetSong(song)
-	srv.writeToRoomAO(c.Room(), "MC", song, contents[1], showname, "1", "0", effects)
-	if song == packets.SongStop {
-		c.Room().LogEvent(room.EventMusic, "%s stopped the music.", c.LongString())
-	} else {
-		c.Room().LogEvent(room.EventMusic, "%s played %s.", c.LongString(), song)
+		if e, err := strconv.Atoi(contents[3]); err == nil {
+			effects = packets.SongEffect(e) & packets.EffectAll
+		}
 	}
+	srv.playSong(c.Room(), song, c.CID(), showname, effects)
+	srv.events.Publish(events.Event{Kind: events.Music, Client: c, Room: c.Room(), Name: showname, Text: song})
 	return
 }
 
@@ -599,12 +785,12 @@ func (srv *SCServer) handleArea(c *client.Client, contents []string) {
 func (srv *SCServer) handleBar(c *client.Client, contents []string) {
 	if c.MuteState()&client.MutedJudge != 0 {
 		c.Room().LogEvent(room.EventFail, "%s tried changing HP bars but was blocked from judge commands.", c.LongString())
-		srv.sendServerMessage(c, "You are currently blocked from using judge commands.")
+		srv.tell(c, "judge.muted")
 		return
 	}
 	if (c.Room().LockState() == room.LockSpec) && !c.Room().IsInvited(c.UID()) {
 		c.Room().LogEvent(room.EventFail, "%s tried changing HP bars but was not invited.", c.LongString())
-		srv.sendServerMessage(c, "You are only allowed to spectate in this area.")
+		srv.tell(c, "room.spectating")
 		return
 	}
 
@@ -614,24 +800,15 @@ func (srv *SCServer) handleBar(c *client.Client, contents []string) {
 		return
 	}
 	val, err := strconv.Atoi(contents[1])
-	if err != nil || val < 0 || val > 10 {
-		c.Room().LogEvent(room.EventJudge, "%s tried sending an invalid HP packet (invalid hp value): %#v.", c.LongString(), contents)
+	if err != nil || val < int(packets.BarMin) || val > int(packets.BarMax) {
+		c.Room().LogEvent(room.EventFail, "%s tried sending an invalid HP packet (invalid hp value): %#v.", c.LongString(), contents)
+		return
 	}
 
 	// validated
 
-	c.Room().SetBar(packets.BarSelect(bar), packets.BarHP(val))
-	for _, cl := range srv.getClientsInRoom(c.Room()) {
-		cl.UpdateBars()
-	}
-
-	var barStr string
-	if packets.BarSelect(bar) == packets.BarPro {
-		barStr = "prosecution"
-	} else {
-		barStr = "defense"
-	}
-	c.Room().LogEvent(room.EventJudge, "%s set the %s bar to %v.", c.LongString(), barStr, val)
+	srv.setBar(c.Room(), packets.BarSelect(bar), packets.BarHP(val))
+	c.Room().LogEvent(room.EventJudge, "%s set the %s bar to %v.", c.LongString(), barName(packets.BarSelect(bar)), val)
 
 }
 
@@ -640,29 +817,128 @@ func (srv *SCServer) handleJudge(c *client.Client, contents []string) {
 	// but if this causes problems, then only allow judge stuff in this pos.
 	if c.MuteState()&client.MutedJudge != 0 {
 		c.Room().LogEvent(room.EventFail, "%s tried using a splash animation but was blocked from judge commands.", c.LongString())
-		srv.sendServerMessage(c, "You are currently blocked from using judge commands.")
+		srv.tell(c, "judge.muted")
 		return
 	}
 	if (c.Room().LockState() == room.LockSpec) && !c.Room().IsInvited(c.UID()) {
 		c.Room().LogEvent(room.EventFail, "%s tried using a splash animation but was not invited.", c.LongString())
-		srv.sendServerMessage(c, "You are only allowed to spectate in this area.")
+		srv.tell(c, "room.spectating")
 		return
 	}
 	srv.writeToRoomAO(c.Room(), "RT", contents...)
+	c.Room().LogEvent(room.EventJudge, "%s played the splash %q.", c.LongString(), contents)
 }
 
 func (srv *SCServer) handleModCall(c *client.Client, contents []string) {
-	c.Room().LogEvent(room.EventMod, "Mod called by %s. Reason: %s", c.LongString(), contents[0])
-	msg := fmt.Sprintf("Mod called in [%v] %s by %s. \nReason: %s",
-		c.Room().ID(), c.Room().Name(), c.LongString(), contents[0])
-	srv.logger.Infof(msg)
-	for c := range srv.clients.ClientsJoined() {
-		if c.Perms()&perms.HearModCalls != 0 {
-			c.ModCall(msg)
+	cooldown := srv.cooldown(srv.config.ModCallCooldown)
+	if wait := time.Until(c.LastModCall().Add(cooldown)); wait > 0 {
+		c.Room().LogEvent(room.EventFail, "%s tried calling a mod, but was on cooldown.", c.LongString())
+		srv.tell(c, "modcall.cooldown", wait.Round(time.Second))
+		return
+	}
+	if !srv.hookModcall(c, &contents[0]) {
+		return
+	}
+	c.SetLastModCall(time.Now())
+	srv.events.Publish(events.Event{Kind: events.ModCall, Client: c, Room: c.Room(), Text: contents[0]})
+
+	roomStr := fmt.Sprintf("[%v] %s", c.Room().ID(), c.Room().Name())
+	call := srv.modcalls.add(roomStr, c.LongString(), contents[0])
+	format := "Mod call #%v in %s by %s. \nReason: %s\nUse /ack %v to handle it."
+	msg := fmt.Sprintf(format, call.id, roomStr, c.LongString(), contents[0], call.id)
+	// Without the IPID, for whoever can't see them.
+	public := fmt.Sprintf(format, call.id, roomStr, c.String(), contents[0], call.id)
+	srv.logger.Info(msg)
+	heard := false
+	for cl := range srv.clients.ClientsJoined() {
+		if cl.Perms()&perms.HearModCalls != 0 {
+			if cl.Perms()&perms.SeeIPIDs != 0 {
+				cl.ModCall(msg)
+			} else {
+				cl.ModCall(public)
+			}
+			heard = true
+		}
+	}
+	if r := srv.modcallRoom; r != nil {
+		// Those who can hear mod calls already got it.
+		for _, cl := range srv.getClientsInRoom(r) {
+			if cl.Perms()&perms.HearModCalls == 0 {
+				srv.sendServerMessage(cl, "%s", public)
+			}
 		}
+		r.LogEvent(room.EventMod, "Mod call #%v in %s by %s. Reason: %s", call.id, roomStr, c.LongString(), contents[0])
+	}
+	if heard {
+		srv.tell(c, "modcall.heard")
+		return
+	}
+
+	// Nobody heard the call, so we keep it around for when someone comes online.
+	if err := srv.db.AddModCall(c.IPID(), roomStr, c.LongString(), contents[0]); err != nil {
+		srv.logger.Warnf("server: Couldn't record offline mod call (%v).", err)
 	}
+	srv.tell(c, "modcall.offline")
 }
 
 func (srv *SCServer) handleCheck(c *client.Client, contents []string) {
+	c.MarkKeepalive()
 	c.WriteAO("CHECK")
 }
+
+func (srv *SCServer) handleSetCase(c *client.Client, contents []string) {
+	var cases []string
+	for _, s := range strings.Split(contents[0], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cases = append(cases, s)
+		}
+	}
+	c.SetCasePrefs(client.CasePrefs{
+		Cases: cases,
+		CM:    contents[1] == "1",
+		Def:   contents[2] == "1",
+		Pro:   contents[3] == "1",
+		Judge: contents[4] == "1",
+		Jury:  contents[5] == "1",
+		Steno: contents[6] == "1",
+	})
+	c.Room().LogEvent(room.EventDebug, "%s set their case preferences: %#v.", c.LongString(), contents)
+}
+
+func (srv *SCServer) handleCaseAlert(c *client.Client, contents []string) {
+	if !c.HasPerms(perms.Status) {
+		c.Room().LogEvent(room.EventFail, "%s tried announcing a case without permission.", c.LongString())
+		srv.tell(c, "case.no_perms")
+		return
+	}
+	cooldown := srv.cooldown(srv.config.ModCallCooldown)
+	if wait := time.Until(c.LastCaseAlert().Add(cooldown)); wait > 0 {
+		srv.tell(c, "case.cooldown", wait.Round(time.Second))
+		return
+	}
+	title := strings.TrimSpace(contents[0])
+	if title == "" || utf8.RuneCountInString(title) > srv.config.MaxMsgSize {
+		c.Room().LogEvent(room.EventFail, "%s tried announcing a case with an invalid title: %#v.", c.LongString(), contents)
+		return
+	}
+	need := client.CasePrefs{
+		Def:   contents[1] == "1",
+		Pro:   contents[2] == "1",
+		Judge: contents[3] == "1",
+		Jury:  contents[4] == "1",
+		Steno: contents[5] == "1",
+	}
+	c.SetLastCaseAlert(time.Now())
+
+	msg := fmt.Sprintf("=== Case Announcement ===\n%v in %v needs players for %v.", c.ShortString(), c.Room().Name(), title)
+	sent := 0
+	for cl := range srv.clients.ClientsJoined() {
+		if cl == c || cl.Type() != client.AOClient || !cl.CasePrefs().Wants(need) {
+			continue
+		}
+		cl.WriteAO("CASEA", msg, contents[1], contents[2], contents[3], contents[4], contents[5])
+		sent++
+	}
+	c.Room().LogEvent(room.EventMod, "%s announced the case '%v' (%v alerted).", c.LongString(), title, sent)
+	srv.tell(c, "case.sent", sent)
+}
@@ -0,0 +1,165 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lambdcalculus/scs/internal/aotest"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// The default limits, in characters.
+const (
+	maxMsgSize  = 150
+	maxNameSize = 20
+)
+
+// Waits for the packet with the header that `match` accepts, failing the test if it
+// doesn't come.
+func expect(t *testing.T, c *aotest.Client, header string, match func(packets.PacketAO) bool) packets.PacketAO {
+	t.Helper()
+	p, err := c.ExpectFunc(header, match, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Matches OOC messages from the server with the text.
+func serverSays(text string) func(packets.PacketAO) bool {
+	return func(p packets.PacketAO) bool {
+		return len(p.Contents) >= 2 && p.Contents[0] == "SCS" && p.Contents[1] == text
+	}
+}
+
+func TestICLengthLimits(t *testing.T) {
+	s := aotest.StartServer(t, nil)
+	c := s.DialTCP(t)
+	if err := c.Join("hdid", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PickChar(0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		desc     string
+		msg      string
+		showname string
+		want     string // the echoed message, or "" if it's rejected
+		reject   string // what the server says if it's rejected
+	}{
+		{"two-byte at limit", strings.Repeat("\u00e9", maxMsgSize), "", strings.Repeat("\u00e9", maxMsgSize), ""},
+		{"two-byte over limit", strings.Repeat("\u00e9", maxMsgSize+1), "", "", "Your message is too long!"},
+		{"four-byte at limit", strings.Repeat("👍", maxMsgSize), "", strings.Repeat("👍", maxMsgSize), ""},
+		{"four-byte over limit", strings.Repeat("👍", maxMsgSize+1), "", "", "Your message is too long!"},
+		// These compose into a single character each, so they're counted after normalizing.
+		{"composable at limit", strings.Repeat("o\u0301", maxMsgSize), "", strings.Repeat("\u00f3", maxMsgSize), ""},
+		// These don't, so the mark counts as a character of its own.
+		{"combining at limit", strings.Repeat("a\u0332", maxMsgSize/2), "", strings.Repeat("a\u0332", maxMsgSize/2), ""},
+		{"combining over limit", strings.Repeat("a\u0332", maxMsgSize/2) + "b\u0332", "", "", "Your message is too long!"},
+		{"showname at limit", "Hold it!", strings.Repeat("\u00f1", maxNameSize), "Hold it!", ""},
+		{"showname over limit", "Take that!", strings.Repeat("\u00f1", maxNameSize+1), "", "Your showname is too long!"},
+	} {
+		if err := c.IC(aotest.IC{CID: 0, Char: "Phoenix", Emote: "normal", Message: tc.msg, Side: "def", Showname: tc.showname}); err != nil {
+			t.Fatal(err)
+		}
+		if tc.want == "" {
+			expect(t, c, "CT", serverSays(tc.reject))
+			continue
+		}
+		p := expect(t, c, "MS", nil)
+		if got := p.Contents[4]; got != tc.want {
+			t.Errorf("case %v (%v): echoed %q; want %q", i, tc.desc, got, tc.want)
+		}
+		if got := p.Contents[15]; got != tc.showname {
+			t.Errorf("case %v (%v): echoed showname %q; want %q", i, tc.desc, got, tc.showname)
+		}
+	}
+}
+
+func TestOOCLengthLimits(t *testing.T) {
+	s := aotest.StartServer(t, nil)
+	c := s.DialWS(t)
+	if err := c.Join("hdid", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range []struct {
+		desc   string
+		name   string
+		msg    string
+		want   string // the echoed name, if it's accepted
+		reject string // what the server says if it's rejected, "" if it's accepted
+	}{
+		{"three-byte at limit", "bob", strings.Repeat("日", maxMsgSize), "bob", ""},
+		{"three-byte over limit", "bob", strings.Repeat("日", maxMsgSize+1), "", "Your message is too long!"},
+		{"combining at limit", "bob", strings.Repeat("a\u0332", maxMsgSize/2), "bob", ""},
+		{"combining over limit", "bob", strings.Repeat("a\u0332", maxMsgSize/2+1), "", "Your message is too long!"},
+		{"name at limit", strings.Repeat("\u00fc", maxNameSize), "hi", strings.Repeat("\u00fc", maxNameSize), ""},
+		{"name over limit", strings.Repeat("\u00fc", maxNameSize+1), "hi", "", "Your username is too long!"},
+		// Each of these composes into a single character, so the name fits once normalized.
+		{"composable name at limit", strings.Repeat("u\u0308", maxNameSize), "hello", strings.Repeat("\u00fc", maxNameSize), ""},
+	} {
+		if err := c.OOC(tc.name, tc.msg); err != nil {
+			t.Fatal(err)
+		}
+		if tc.reject != "" {
+			expect(t, c, "CT", serverSays(tc.reject))
+			continue
+		}
+		p := expect(t, c, "CT", func(p packets.PacketAO) bool {
+			return len(p.Contents) >= 2 && p.Contents[0] != "SCS"
+		})
+		if p.Contents[0] != tc.want || p.Contents[1] != tc.msg {
+			t.Errorf("case %v (%v): echoed %q: %q; want %q: %q", i, tc.desc, p.Contents[0], p.Contents[1], tc.want, tc.msg)
+		}
+	}
+}
+
+func TestModCallHidesIPID(t *testing.T) {
+	s := aotest.StartServer(t, map[string]string{
+		"config.toml": "modcall_room = \"Lobby\"\n" + aotest.DefaultConfigs["config.toml"],
+		"roles.toml": aotest.DefaultConfigs["roles.toml"] + `
+[[role]]
+name = "Listener"
+permissions = ["hear_modcall"]
+`,
+	})
+	s.AddUser(t, "listener", "hunter2", "Listener")
+	join := func() *aotest.Client {
+		c := s.DialTCP(t)
+		if err := c.Join("hdid", "AO2", "2.10.0"); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	listener, bystander, caller := join(), join(), join()
+	if _, err := listener.Command("listener", "login listener hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := caller.Send("ZZ", "help"); err != nil {
+		t.Fatal(err)
+	}
+	call := expect(t, listener, "ZZ", nil)
+	if strings.Contains(call.Contents[0], "IPID") {
+		t.Errorf("a listener without see_ipids got the caller's IPID: %q", call.Contents[0])
+	}
+	announced := expect(t, bystander, "CT", func(p packets.PacketAO) bool {
+		return strings.HasPrefix(p.Contents[1], "Mod call #1")
+	})
+	if strings.Contains(announced.Contents[1], "IPID") {
+		t.Errorf("the mod call room got the caller's IPID: %q", announced.Contents[1])
+	}
+
+	// The listener is in the mod call room too, but doesn't get the call twice.
+	if _, err := listener.Command("listener", "ping"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range listener.Received() {
+		if p.Header == "CT" && strings.HasPrefix(p.Contents[1], "Mod call #1") {
+			t.Errorf("the listener got the mod call in OOC too")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Registers the read-only JSON API's handlers under /api/.
+func (srv *SCServer) handleAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/rooms", srv.apiEndpoint(srv.apiRooms))
+	mux.HandleFunc("/api/players", srv.apiEndpoint(srv.apiPlayers))
+	mux.HandleFunc("/api/stats", srv.apiEndpoint(srv.apiStats))
+}
+
+// Wraps an API endpoint, checking the method and the token, and writing the
+// returned value as JSON. The token may be passed either as a bearer token in
+// the Authorization header or in the `token` query parameter.
+func (srv *SCServer) apiEndpoint(get func() any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(srv.config.API.Token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(get()); err != nil {
+			srv.logger.Debugf("HTTP: (%v) Error writing response to %s (%v).", r.URL.Path, r.RemoteAddr, err)
+		}
+	}
+}
+
+// A room, as shown by '/api/rooms'.
+type apiRoom struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Desc    string `json:"description"`
+	Players int    `json:"players"`
+	Status  string `json:"status"`
+	Lock    string `json:"lock"`
+	Song    string `json:"song"`
+}
+
+// A player, as shown by '/api/players'. IPIDs and other private information are left out.
+type apiPlayer struct {
+	UID      int    `json:"uid"`
+	Room     int    `json:"room"` // the room's ID
+	Char     string `json:"character"`
+	Showname string `json:"showname"`
+	Username string `json:"username"`
+}
+
+// The reply to '/api/stats'.
+type apiStats struct {
+	Uptime      int64 `json:"uptime"` // in seconds
+	Players     int   `json:"players"`
+	MaxPlayers  int   `json:"max_players"`
+	PeakPlayers int64 `json:"peak_players"`
+	Joins       int64 `json:"joins"`
+	ICMessages  int64 `json:"ic_messages"`
+	OOCMessages int64 `json:"ooc_messages"`
+	Songs       int64 `json:"songs"`
+}
+
+func (srv *SCServer) apiRooms() any {
+	rooms := make([]apiRoom, 0, len(srv.rooms))
+	for _, r := range srv.rooms {
+		rooms = append(rooms, apiRoom{
+			ID:      r.ID(),
+			Name:    r.Name(),
+			Desc:    r.Desc(),
+			Players: r.PlayerCount(),
+			Status:  r.Status(),
+			Lock:    r.LockString(),
+			Song:    r.Song(),
+		})
+	}
+	return rooms
+}
+
+func (srv *SCServer) apiPlayers() any {
+	players := []apiPlayer{}
+	for c := range srv.clients.ClientsJoined() {
+		p := apiPlayer{
+			UID:      c.UID(),
+			Room:     -1,
+			Char:     c.Charname(),
+			Showname: c.Showname(),
+			Username: c.Username(),
+		}
+		if r := c.Room(); r != nil {
+			p.Room = r.ID()
+		}
+		players = append(players, p)
+	}
+	return players
+}
+
+func (srv *SCServer) apiStats() any {
+	st := srv.stats.Snapshot()
+	return apiStats{
+		Uptime:      int64(time.Since(srv.start).Seconds()),
+		Players:     srv.clients.SizeJoined(),
+		MaxPlayers:  srv.config.MaxPlayers,
+		PeakPlayers: st.PeakPlayers,
+		Joins:       st.Joins,
+		ICMessages:  st.ICMessages,
+		OOCMessages: st.OOCMessages,
+		Songs:       st.MusicChanges,
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// How many backgrounds /bglist shows per page.
+const bgListPage = 30
+
+// Finds a background in the list by name, ignoring case. If the server has no
+// background list, any name is accepted, without positions.
+func (srv *SCServer) findBackground(name string) (bg config.Background, ok bool) {
+	if srv.backgrounds == nil {
+		return config.Background{Name: name}, true
+	}
+	for _, b := range srv.backgrounds {
+		if strings.EqualFold(b.Name, name) {
+			return b, true
+		}
+	}
+	return config.Background{}, false
+}
+
+// Changes the room's background and updates everyone in it.
+func (srv *SCServer) setBackground(r *room.Room, bg config.Background) {
+	r.SetBackground(bg.Name, bg.Positions)
+	for _, c := range srv.getClientsInRoom(r) {
+		c.UpdateBackground()
+	}
+	srv.updateSides(r)
+}
+
+func (srv *SCServer) cmdBg(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		return srv.tr(c, "bg.current", r.Background()), false
+	}
+	if !c.HasPerms(perms.Background) {
+		return srv.tr(c, "cmd.no_perms", "bg", perms.Background&^c.EffectivePerms()), false
+	}
+	if r.BackgroundLocked() && !c.HasPerms(perms.BypassLocks) {
+		return srv.tr(c, "bg.locked"), false
+	}
+	name := strings.Join(args, " ")
+	bg, ok := srv.findBackground(name)
+	if !ok {
+		return srv.tr(c, "bg.unknown", name), false
+	}
+	srv.setBackground(r, bg)
+	r.LogEvent(room.EventMod, "%s changed the background to '%s'.", c.LongString(), bg.Name)
+	srv.tellRoom(r, "bg.changed", c.ShortString(), bg.Name)
+	return "", false
+}
+
+func (srv *SCServer) cmdBgList(c *client.Client, args []string) (string, bool) {
+	if srv.backgrounds == nil {
+		return srv.tr(c, "bg.no_list"), false
+	}
+	pages := max((len(srv.backgrounds)+bgListPage-1)/bgListPage, 1)
+	page := 1
+	if len(args) > 0 {
+		p, err := strconv.Atoi(args[0])
+		if err != nil || p < 1 || p > pages {
+			return srv.tr(c, "bg.bad_page", pages), false
+		}
+		page = p
+	}
+	list := srv.backgrounds[(page-1)*bgListPage : min(page*bgListPage, len(srv.backgrounds))]
+	names := make([]string, len(list))
+	for i, b := range list {
+		names[i] = b.Name
+	}
+	return "\n" + srv.tr(c, "bg.list", page, pages) + "\n" + strings.Join(names, "\n"), false
+}
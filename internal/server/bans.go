@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Parses a duration for a ban or mute, which is either one of the presets (ignoring case)
+// or an explicit duration, as accepted by [parseDuration].
+func resolveDuration(presets map[string]string, s string) (time.Duration, error) {
+	for name, d := range presets {
+		if strings.EqualFold(name, s) {
+			return parseDuration(d)
+		}
+	}
+	return parseDuration(s)
+}
+
+// Like [resolveDuration], with the configured presets.
+func (srv *SCServer) banDuration(s string) (time.Duration, error) {
+	return resolveDuration(srv.config.Durations.Presets, s)
+}
+
+// Returns the names of the configured presets, sorted, for showing to moderators.
+func (srv *SCServer) durationPresets() string {
+	if len(srv.config.Durations.Presets) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(srv.config.Durations.Presets))
+	for name := range srv.config.Durations.Presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// Bans an IPID for the passed duration (e.g. "3d", or a preset), disconnecting the
+// clients with it.
+func (srv *SCServer) banIPID(ipid string, duration string, reason string, moderator string) error {
+	dur, err := srv.banDuration(duration)
+	if err != nil {
+		return fmt.Errorf("server: '%v' is not a valid duration.", duration)
+	}
+	if err := srv.db.AddBan(ipid, "", reason, moderator, dur); err != nil {
+		return err
+	}
+	srv.logger.Infof("%v banned IPID %v for %v. Reason: %s", moderator, ipid, dur, reason)
+	srv.events.Publish(events.Event{Kind: events.Ban, Actor: moderator, Target: "IPID " + ipid, Text: reason, Duration: dur})
+	for _, c := range srv.getByIPID(ipid) {
+		srv.kickBanned(c, reason, moderator, dur)
+	}
+	return nil
+}
+
+// Returns whether no client with the IPID is connected and, if so, whether it has ever
+// joined. Banning an IPID that isn't connected needs confirming, since it may be a
+// mistyped UID or IPID.
+func (srv *SCServer) offlineIPID(ipid string) (offline bool, known bool) {
+	if len(srv.getByIPID(ipid)) > 0 {
+		return false, true
+	}
+	if known, err := srv.db.KnownIPID(ipid); err == nil && !known {
+		return true, false
+	}
+	return true, true
+}
+
+// Bans the client's IPID and HDID for the passed duration, disconnecting every client
+// with either of them.
+func (srv *SCServer) banClient(target *client.Client, dur time.Duration, reason string, moderator string) error {
+	ipid, hdid := target.IPID(), target.Ident()
+	if err := srv.db.AddBan(ipid, hdid, reason, moderator, dur); err != nil {
+		return err
+	}
+	srv.logger.Infof("%v banned %s for %v. Reason: %s", moderator, target.LongString(), dur, reason)
+	target.Room().LogEvent(room.EventMod, "%v banned %s for %v. Reason: %s", moderator, target.LongString(), dur, reason)
+	srv.events.Publish(events.Event{Kind: events.Ban, Client: target, Room: target.Room(), Actor: moderator,
+		Target: "IPID " + ipid, Text: reason, Duration: dur})
+	for c := range srv.clients.Clients() {
+		if c.IPID() == ipid || (hdid != "" && c.Ident() == hdid) {
+			srv.kickBanned(c, reason, moderator, dur)
+		}
+	}
+	return nil
+}
+
+func (srv *SCServer) cmdBan(c *client.Client, args []string) (string, bool) {
+	offline := false
+	if args[0] == "--offline" {
+		offline = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return "", true
+	}
+	// A UID of someone who isn't connected is taken as an IPID, so a mistyped UID is
+	// refused like a mistyped IPID.
+	var target *client.Client
+	if id, err := strconv.Atoi(args[0]); err == nil {
+		target = srv.getByUID(id)
+	}
+	ipid := args[0]
+	args = args[1:]
+
+	duration := srv.config.Durations.DefaultBan
+	if len(args) > 0 {
+		if _, err := srv.banDuration(args[0]); err == nil {
+			duration = args[0]
+			args = args[1:]
+		}
+	}
+	if duration == "" {
+		return srv.tr(c, "ban.no_duration", srv.durationPresets()), false
+	}
+	// The default was checked when the server was made.
+	dur, _ := srv.banDuration(duration)
+	reason := strings.Join(args, " ")
+	if reason == "" {
+		reason = "No reason given."
+	}
+
+	isOffline, known := srv.offlineIPID(ipid)
+	switch {
+	case target != nil:
+		if err := srv.banClient(target, dur, reason, c.String()); err != nil {
+			srv.logger.Warnf("Couldn't ban (%v).", err)
+			return srv.tr(c, "ban.error"), false
+		}
+		return srv.tr(c, "ban.banned_uid", target.UID(), dur), false
+	case !isOffline:
+		if err := srv.banIPID(ipid, duration, reason, c.String()); err != nil {
+			srv.logger.Warnf("Couldn't ban (%v).", err)
+			return srv.tr(c, "ban.error"), false
+		}
+		return srv.tr(c, "ban.banned_ipid", ipid, dur), false
+	case !offline:
+		return srv.tr(c, "ban.not_connected", ipid), false
+	}
+
+	key := "ban.confirm_offline"
+	if !known {
+		key = "ban.confirm_unknown"
+	}
+	return srv.confirm(c, key, []any{ipid, dur, reason}, func() string {
+		if err := srv.banIPID(ipid, duration, reason, c.String()); err != nil {
+			srv.logger.Warnf("Couldn't ban (%v).", err)
+			return srv.tr(c, "ban.error")
+		}
+		c.Room().LogEvent(room.EventMod, "%s banned the offline IPID %v for %v. Reason: %s", c.LongString(), ipid, dur, reason)
+		return srv.tr(c, "ban.banned_ipid", ipid, dur)
+	}), false
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/lambdcalculus/scs/internal/client"
+)
+
+// Broadcasts to fewer clients than this are done on the caller's goroutine, since
+// splitting them up wouldn't be worth it.
+const minBroadcastChunk = 16
+
+// Fans broadcasts out over a fixed amount of workers, so writing to a large room
+// isn't serialised through a single goroutine.
+type broadcaster struct {
+	workers int
+	jobs    chan func()
+}
+
+// Starts a broadcaster with the passed amount of workers. If it isn't positive,
+// one worker per CPU is used.
+func newBroadcaster(workers int) *broadcaster {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	b := &broadcaster{
+		workers: workers,
+		jobs:    make(chan func(), workers),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range b.jobs {
+				job()
+			}
+		}()
+	}
+	return b
+}
+
+// Calls `send` for every client, splitting the clients between the workers.
+// Returns once all of them are done, so consecutive broadcasts arrive in order.
+func (b *broadcaster) fanOut(clients []*client.Client, send func(*client.Client)) {
+	if len(clients) < 2*minBroadcastChunk || b.workers == 1 {
+		for _, c := range clients {
+			send(c)
+		}
+		return
+	}
+
+	size := max((len(clients)+b.workers-1)/b.workers, minBroadcastChunk)
+	var wg sync.WaitGroup
+	for len(clients) > 0 {
+		chunk := clients[:min(size, len(clients))]
+		clients = clients[len(chunk):]
+		wg.Add(1)
+		b.jobs <- func() {
+			defer wg.Done()
+			for _, c := range chunk {
+				send(c)
+			}
+		}
+	}
+	wg.Wait()
+}
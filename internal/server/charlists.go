@@ -0,0 +1,75 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+func (srv *SCServer) cmdCharLists(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		available := make([]string, len(srv.charsConf.Lists))
+		for i, l := range srv.charsConf.Lists {
+			available[i] = l.Name
+		}
+		return srv.tr(c, "charlists.current", strings.Join(r.CharLists(), ", "), strings.Join(available, ", ")), false
+	}
+	if !c.HasPerms(perms.Characters) {
+		return srv.tr(c, "cmd.no_perms", "charlists", perms.Characters&^c.EffectivePerms()), false
+	}
+
+	var names []string
+	if len(args) == 1 && args[0] == "reset" {
+		names = r.DefaultCharLists()
+	} else {
+		for _, n := range strings.Split(strings.Join(args, " "), ",") {
+			n = strings.TrimSpace(n)
+			if n == "" {
+				continue
+			}
+			if !srv.charListExists(n) {
+				return srv.tr(c, "charlists.unknown", n), false
+			}
+			names = append(names, n)
+		}
+	}
+	moved, ok := r.SetCharLists(srv.charsConf, names)
+	if !ok {
+		return srv.tr(c, "charlists.empty"), false
+	}
+	srv.swapChars(r, moved)
+	srv.tellRoom(r, "charlists.changed", strings.Join(names, ", "))
+	r.LogEvent(room.EventMod, "%s changed the character lists to: %v.", c.LongString(), strings.Join(names, ", "))
+	return "", false
+}
+
+// Checks whether a character list with the passed name is configured. "all" always is.
+func (srv *SCServer) charListExists(name string) bool {
+	if name == "all" {
+		return true
+	}
+	for _, l := range srv.charsConf.Lists {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Updates the occupants of a room whose character list was just changed: everyone
+// gets the new list, and those whose CID changed get their new character.
+func (srv *SCServer) swapChars(r *room.Room, moved map[int]int) {
+	for _, c := range srv.getClientsInRoom(r) {
+		c.UpdateCharList()
+		if cid, ok := moved[c.UID()]; ok {
+			c.RefreshChar(cid)
+			if cid == room.SpectatorCID {
+				srv.tell(c, "charlists.spectator")
+			}
+		}
+		srv.trackChar(c)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// How many characters /charstats lists.
+const charStatsShown = 10
+
+// The character each client is currently using, so the time spent with it can be
+// recorded once it changes.
+type charTracker struct {
+	current map[*client.Client]charSession
+	mu      sync.Mutex
+}
+
+type charSession struct {
+	room  string
+	char  string
+	since time.Time
+}
+
+func newCharTracker() *charTracker {
+	return &charTracker{current: make(map[*client.Client]charSession)}
+}
+
+// Records a change of the client's character or room. Should be called after any
+// change, including becoming a spectator. Moving to another room with the same
+// character doesn't count as a new pick.
+func (srv *SCServer) trackChar(c *client.Client) {
+	var now charSession
+	if r := c.Room(); r != nil && c.CID() != room.SpectatorCID {
+		now = charSession{room: r.Name(), char: c.Charname(), since: time.Now()}
+	}
+
+	srv.chars.mu.Lock()
+	old, had := srv.chars.current[c]
+	if had && old.room == now.room && old.char == now.char {
+		srv.chars.mu.Unlock()
+		return
+	}
+	delete(srv.chars.current, c)
+	if now.char != "" {
+		srv.chars.current[c] = now
+	}
+	srv.chars.mu.Unlock()
+
+	if had {
+		if err := srv.db.AddCharTime(old.room, old.char, time.Since(old.since)); err != nil {
+			srv.logger.Warnf("server: Error recording character usage (%s).", err)
+		}
+	}
+	if now.char != "" && (!had || old.char != now.char) {
+		if err := srv.db.AddCharPick(now.room, now.char); err != nil {
+			srv.logger.Warnf("server: Error recording character usage (%s).", err)
+		}
+	}
+}
+
+// Records the time spent with the client's character, as it's leaving.
+func (srv *SCServer) untrackChar(c *client.Client) {
+	srv.chars.mu.Lock()
+	old, had := srv.chars.current[c]
+	delete(srv.chars.current, c)
+	srv.chars.mu.Unlock()
+	if !had {
+		return
+	}
+	if err := srv.db.AddCharTime(old.room, old.char, time.Since(old.since)); err != nil {
+		srv.logger.Warnf("server: Error recording character usage (%s).", err)
+	}
+}
+
+func (srv *SCServer) cmdCharStats(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) > 0 {
+		query := strings.Join(args, " ")
+		if query == "all" {
+			r = nil
+		} else if r = srv.findRoom(query); r == nil {
+			return srv.tr(c, "cmd.no_room", query), false
+		}
+	}
+	name := ""
+	where := srv.tr(c, "charstats.server")
+	if r != nil {
+		name = r.Name()
+		where = fmt.Sprintf("[%v] %s", r.ID(), r.Name())
+	}
+	usage, err := srv.db.GetCharUsage(name)
+	if err != nil {
+		srv.logger.Warnf("server: Error getting character usage (%s).", err)
+		return srv.tr(c, "charstats.error"), false
+	}
+	if len(usage) == 0 {
+		return srv.tr(c, "charstats.none", where), false
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n" + srv.tr(c, "charstats.header", where))
+	for i, u := range usage[:min(len(usage), charStatsShown)] {
+		sb.WriteString("\n" + srv.tr(c, "charstats.entry", i+1, u.Name, u.Time, u.Picks))
+	}
+	if r != nil {
+		used := make(map[string]bool, len(usage))
+		for _, u := range usage {
+			used[u.Name] = true
+		}
+		unused := 0
+		for _, char := range r.Chars() {
+			if !used[char] {
+				unused++
+			}
+		}
+		sb.WriteString("\n" + srv.tr(c, "charstats.unused", unused, r.CharsLen()))
+	}
+	return sb.String(), false
+}
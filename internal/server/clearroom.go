@@ -0,0 +1,71 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// /clearroom changes everything these permissions cover, so it requires all of them.
+const clearRoomPerms = perms.Status | perms.Lock | perms.Description | perms.Background |
+	perms.Ambiance | perms.Characters | perms.Music | perms.Settings
+
+// Resets the room to its configured defaults for /clearroom, and updates everyone in it.
+func (srv *SCServer) clearRoom(r *room.Room) {
+	r.Reset()
+	srv.scheduleQueue(r, packets.SongStop)
+	if err := srv.db.RemoveSetting(titleSettingPrefix + r.Name()); err != nil {
+		srv.logger.Warnf("Couldn't remove the title of [%v] %v (%s).", r.ID(), r.Name(), err)
+	}
+	if def := r.DefaultCharLists(); !slices.Equal(r.CharLists(), def) {
+		if moved, ok := r.SetCharLists(srv.charsConf, def); ok {
+			srv.swapChars(r, moved)
+		}
+	}
+	for _, c := range srv.getClientsInRoom(r) {
+		if c.Role(client.RoleManager) != nil {
+			srv.removeRole(c, client.RoleManager)
+		}
+		c.UpdateBackground()
+		c.UpdateBars()
+		c.UpdateSong()
+		c.UpdateAmbiance()
+	}
+	srv.updateSides(r)
+	srv.sendRoomUpdateAll(packets.UpdateStatus|packets.UpdateManager|packets.UpdateLock, r)
+}
+
+func (srv *SCServer) cmdClearRoom(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	var dst *room.Room
+	if len(args) > 0 {
+		query := strings.Join(args, " ")
+		dst = srv.findRoom(query)
+		if dst == nil || dst == r || !r.IsAdjacent(dst) {
+			return srv.tr(c, "clearroom.no_room", query), false
+		}
+	}
+
+	var stuck int
+	if dst != nil {
+		for _, other := range srv.getClientsInRoom(r) {
+			if other != c && !srv.forceMove(other, dst, c) {
+				stuck++
+			}
+		}
+	}
+	srv.clearRoom(r)
+	r.LogEvent(room.EventMod, "%s reset the room to its defaults.", c.LongString())
+	srv.tellRoom(r, "clearroom.reset", c.ShortString())
+	switch {
+	case dst == nil:
+		return "", false
+	case stuck > 0:
+		return srv.tr(c, "clearroom.stuck", stuck, dst.ID(), dst.Name()), false
+	}
+	return srv.tr(c, "clearroom.moved", dst.ID(), dst.Name()), false
+}
@@ -2,12 +2,19 @@ package server
 
 import (
 	"fmt"
+	"math/rand"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
 	"github.com/lambdcalculus/scs/internal/perms"
 	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/internal/totp"
+	"github.com/lambdcalculus/scs/pkg/packets"
 )
 
 // A cmdFunc attempts to execute a command with the passed args. It returns whether
@@ -28,15 +35,308 @@ var cmdMap map[string]cmdHandler
 func init() {
 	cmdMap = map[string]cmdHandler{
 		"help": {(*SCServer).cmdHelp, 0, perms.None,
-			"/help [command: optional]",
-			"Shows detailed usage of a command, or the list of commands if no command is passed."},
-		"login": {(*SCServer).cmdLogin, 2, perms.None,
-			"/login [username] [password]",
-			"Attempts to authenticate with the passed username and password."},
+			"/help [command|permissions: optional]",
+			"Shows detailed usage of a command, or the list of commands if no command is passed.\n" +
+				"\"/help permissions\" describes each permission commands may require."},
+		"lang": {(*SCServer).cmdLang, 0, perms.None,
+			"/lang [language]",
+			"Shows the available languages, or changes the language of the server's messages to you."},
+		"login": {(*SCServer).cmdLogin, 1, perms.None,
+			"/login [username] [password] [code: if needed]",
+			"Attempts to authenticate with the passed username and password.\n" +
+				"The username and password can also be sent separately (\"/login [username]\", then \"/login [password]\"), " +
+				"which lets AO's login dialog be used for the password.\n" +
+				"Users with two-factor authentication also need to send the 6-digit code from their authenticator app after the password."},
+		"motd": {(*SCServer).cmdMOTD, 0, perms.None,
+			"/motd",
+			"Shows the message of the day."},
+		"rules": {(*SCServer).cmdRules, 0, perms.None,
+			"/rules",
+			"Shows the server's rules."},
+		"agree": {(*SCServer).cmdAgree, 0, perms.None,
+			"/agree",
+			"Agrees to the server's rules, if the server requires it before picking a character."},
+		"serverpass": {(*SCServer).cmdServerPass, 1, perms.None,
+			"/serverpass [password]",
+			"Enters the server's password, if it has one, or the raid password in raid mode. Until then, you can't pick " +
+				"a character, chat in OOC or play music."},
+		"setmotd": {(*SCServer).cmdSetMOTD, 1, perms.All,
+			"/setmotd [message|reset]",
+			"Changes the message of the day, shown to everyone who joins. The change is kept across restarts.\n" +
+				"\"/setmotd reset\" goes back to the MOTD in the server's configuration."},
+		"announce": {(*SCServer).cmdAnnounce, 1, perms.Announce,
+			"/announce [message]",
+			"Sends a message to everyone in the server.\n" +
+				"Example usage: /announce The server restarts in 10 minutes."},
+		"poll": {(*SCServer).cmdPoll, 0, perms.None,
+			"/poll [duration: optional] [question] | [option] | [option]...",
+			"Starts a poll in this room, which everyone in it can /vote in. Without arguments, shows the running poll.\n" +
+				"If a duration is passed, the poll closes by itself after it. Starting polls needs the \"polls\" permission.\n" +
+				"Example usage: /poll 2m Is the defendant guilty? | Guilty | Not guilty"},
+		"vote": {(*SCServer).cmdVote, 1, perms.None,
+			"/vote [option number]",
+			"Votes in the poll running in this room. You can only vote once."},
+		"closepoll": {(*SCServer).cmdClosePoll, 0, perms.Polls,
+			"/closepoll",
+			"Closes the poll running in this room and shows its results to everyone in it."},
+		"verdict": {(*SCServer).cmdVerdict, 1, perms.None,
+			"/verdict [guilty|notguilty|text]",
+			"Announces the verdict. \"guilty\" and \"notguilty\" also play the judge's ruling splash.\n" +
+				"Example usage: /verdict The defendant is sentenced to community service."},
+		"penalty": {(*SCServer).cmdPenalty, 1, perms.None,
+			"/penalty [def|pro] [+n|-n|n: optional]",
+			"Shows or changes a side's penalty bar. \"+n\" and \"-n\" add and take away health, while \"n\" sets it.\n" +
+				"Example usage: /penalty def -2"},
+		"logout": {(*SCServer).cmdLogout, 0, perms.None,
+			"/logout",
+			"Logs out, dropping the role you logged in with and the permissions that came with it."},
+		"passwd": {(*SCServer).cmdPasswd, 2, perms.None,
+			"/passwd [old password] [new password]",
+			"Changes the password of the user you are logged in as."},
+		"whoami": {(*SCServer).cmdWhoami, 0, perms.None,
+			"/whoami",
+			"Shows who you are logged in as, your role and your permissions."},
+		"mysanctions": {(*SCServer).cmdMySanctions, 0, perms.None,
+			"/mysanctions",
+			"Lists your active mutes and bans, with how long they have left, and your past mutes."},
+		"ack": {(*SCServer).cmdAck, 0, perms.None,
+			"/ack [id: optional]",
+			"Without an ID, acknowledges the warnings you have received from moderators.\n" +
+				"With an ID, claims a pending mod call, letting the other moderators know it is being handled. This requires the hear_modcall permission."},
+		"warn": {(*SCServer).cmdWarn, 2, perms.Kick,
+			"/warn <uid> [reason]",
+			"Warns an user by UID with a pop-up, which they must acknowledge with /ack. " +
+				"Users with too many unacknowledged warnings are kicked (see warn_kick_after in config.toml).\n" +
+				"Example usage: /warn 4 please stop spamming"},
 		"kick": {(*SCServer).cmdKick, 2, perms.Kick,
 			"/kick <cid|uid|ipid> [id] [reason: optional]",
 			"Kicks an user by CID, UID or IPID with an optional reason. Note that kicking by IPID kicks all instances of that IPID - to kick a specific client, kick by UID or CID.\n" +
 				"Example usage: /kick uid 1 dumb and stupid\""},
+		"mute": {(*SCServer).cmdMute, 1, perms.Mute,
+			"/mute [--shadow] <uid> [ic|ooc|music|judge|all: optional] [duration: optional] [reason: optional]",
+			"Mutes an user by UID, from everything unless a kind of mute is passed, until unmuted or for the passed duration. " +
+				"The duration may be one of the server's presets, and defaults to the server's default mute duration, if any.\n" +
+				"With --shadow, the user isn't told, and their IC and OOC messages are only shown to themselves.\n" +
+				"Example usage: /mute 4 ooc 30m spamming\n" +
+				"Example usage: /mute --shadow 4 1d"},
+		"ban": {(*SCServer).cmdBan, 1, perms.Ban,
+			"/ban [--offline] <uid|ipid> [duration: optional] [reason: optional]",
+			"Bans an user's IPID and HDID by UID, or an IPID, disconnecting them. The duration may be one of the server's presets, " +
+				"and defaults to the server's default ban duration, if any. Banning an IPID that isn't connected " +
+				"requires --offline, and must be confirmed with /confirm.\n" +
+				"Example usage: /ban 4 2w raiding\n" +
+				"Example usage: /ban --offline 1a2b3c4d standard ban evasion"},
+		"unmute": {(*SCServer).cmdUnmute, 1, perms.Mute,
+			"/unmute <uid>",
+			"Lifts every mute, including shadow mutes, from an user by UID."},
+		"forcemove": {(*SCServer).cmdForceMove, 2, perms.ForceMove,
+			"/forcemove [uid] [room id|name]",
+			"Moves a user to a room, ignoring locks, adjacency and move cooldowns. The user is told they were moved.\n" +
+				"Example usage: /forcemove 4 Courtroom 1"},
+		"summon": {(*SCServer).cmdSummon, 1, perms.ForceMove,
+			"/summon [uid]",
+			"Brings a user to your room, ignoring locks, adjacency and move cooldowns. The user is told they were moved.\n" +
+				"Example usage: /summon 4"},
+		"lockdown": {(*SCServer).cmdLockdown, 0, perms.Lockdown,
+			"/lockdown [on|off: optional]",
+			"Shows whether the server is in lockdown, or starts or lifts it, for responding to raids. While it's on, " +
+				"every room is locked and only staff can join. Lifting it gives the rooms back their previous locks. " +
+				"Starting it must be confirmed with /confirm.\n" +
+				"Example usage: /lockdown on"},
+		"raidmode": {(*SCServer).cmdRaidMode, 0, perms.Lockdown,
+			"/raidmode [on|off|duration: optional]",
+			"Shows whether raid mode is on, or turns it on (until turned off, or for a duration) or off. In raid mode, " +
+				"new players must log in or enter the raid password before playing, IPIDs that never joined before " +
+				"can be turned away, and the cooldowns and spam limits are stricter. Staff are told when it changes.\n" +
+				"Example usage: /raidmode 30m"},
+		"kickroom": {(*SCServer).cmdKickRoom, 0, perms.Lockdown | perms.Kick,
+			"/kickroom [room id|name: optional]",
+			"Kicks everyone but staff from a room, or from this room if none is passed. Must be confirmed with /confirm.\n" +
+				"Example usage: /kickroom Courtroom 1"},
+		"aliases": {(*SCServer).cmdAliases, 1, perms.SeeIPIDs,
+			"/aliases [uid]",
+			"Lists every IPID that has been seen with the same HDID as a user, most recent first.\n" +
+				"Example usage: /aliases 4"},
+		"watch": {(*SCServer).cmdWatch, 0, perms.HearModCalls,
+			"/watch [room: optional]",
+			"Sends you a room's OOC messages and kicks while you are in another room, or lists the rooms you are watching if no room is passed.\n" +
+				"Example usage: /watch Courtroom 1"},
+		"unwatch": {(*SCServer).cmdUnwatch, 0, perms.HearModCalls,
+			"/unwatch [room|all]",
+			"Stops watching a room, or every room if none is passed."},
+		"perms": {(*SCServer).cmdPerms, 0, perms.None,
+			"/perms",
+			"Shows your current permissions."},
+		"roles": {(*SCServer).cmdRoles, 0, perms.All,
+			"/roles",
+			"Lists the configured roles and their permissions."},
+		"adduser": {(*SCServer).cmdAddUser, 3, perms.ModifyDatabase,
+			"/adduser [username] [password] [role]",
+			"Adds an user that can /login to the passed role. Needs to be confirmed with /confirm.\n" +
+				"Example usage: /adduser phoenix hunter2 Room Manager"},
+		"rmuser": {(*SCServer).cmdRmUser, 1, perms.ModifyDatabase,
+			"/rmuser [username]",
+			"Removes an user, logging out anyone logged in as them. Needs to be confirmed with /confirm."},
+		"setrole": {(*SCServer).cmdSetRole, 2, perms.ModifyDatabase,
+			"/setrole [username] [role]",
+			"Changes the role an user logs in to, updating anyone logged in as them. Needs to be confirmed with /confirm.\n" +
+				"Example usage: /setrole phoenix Super"},
+		"confirm": {(*SCServer).cmdConfirm, 0, perms.None,
+			"/confirm",
+			"Goes ahead with the last action you were asked to confirm."},
+		"promote": {(*SCServer).cmdPromote, 3, perms.All,
+			"/promote [uid] [role] [duration]",
+			"Grants a role to an user for a limited time. The grant is tied to the user's IPID, so it survives reconnects.\n" +
+				"Durations are written like 30m, 12h, 3d or 2w.\n" +
+				"Example usage: /promote 4 Room Manager 3d"},
+		"ipban": {(*SCServer).cmdIPBan, 2, perms.All,
+			"/ipban [ip|cidr] [duration] [reason: optional]",
+			"Bans a single raw IP or a range of IPs in CIDR notation. Checked when connections are accepted, " +
+				"before IPs are hashed into IPIDs. Clients in the range are disconnected.\n" +
+				"Example usage: /ipban 203.0.113.0/24 2w rotating IPs"},
+		"ping": {(*SCServer).cmdPing, 0, perms.None,
+			"/ping",
+			"Shows your latency to the server and how backed up your connection is."},
+		"stats": {(*SCServer).cmdStats, 0, perms.None,
+			"/stats",
+			"Shows statistics about the server since it started. Moderators see more detailed statistics."},
+		"charstats": {(*SCServer).cmdCharStats, 0, perms.None,
+			"/charstats [room|all: optional]",
+			"Shows which characters were used the most in a room (your current one by default) or in the whole server, " +
+				"and how many of the room's characters were never used.\n" +
+				"Example usage: /charstats Courtroom 1"},
+		"charlists": {(*SCServer).cmdCharLists, 0, perms.None,
+			"/charlists [lists|reset: optional]",
+			"Shows the character lists this room uses and the available ones, or switches the room to other lists, " +
+				"separated by commas. Switching requires the characters permission. " +
+				"Users whose characters aren't in the new lists become spectators. \"reset\" goes back to the room's configured lists.\n" +
+				"Example usage: /charlists Ace Attorney, Danganronpa"},
+		"charselect": {(*SCServer).cmdCharSelect, 0, perms.None,
+			"/charselect",
+			"Returns you to the character select screen, freeing your character."},
+		"switch": {(*SCServer).cmdSwitch, 1, perms.None,
+			"/switch [character]",
+			"Changes to the character with the passed name, if it isn't taken.\n" +
+				"Example usage: /switch Phoenix Wright"},
+		"randomchar": {(*SCServer).cmdRandomChar, 0, perms.None,
+			"/randomchar",
+			"Changes to a random character that isn't taken."},
+		"reserve": {(*SCServer).cmdReserve, 2, perms.Characters,
+			"/reserve [cid] [uid]",
+			"Reserves a character in this room for an user, so only they can pick it. Reserved characters are shown as taken.\n" +
+				"Example usage: /reserve 3 12"},
+		"unreserve": {(*SCServer).cmdUnreserve, 1, perms.Characters,
+			"/unreserve [cid]",
+			"Removes the reservation of a character in this room."},
+		"approval": {(*SCServer).cmdApproval, 1, perms.Characters,
+			"/approval <on|off>",
+			"Sets whether users need to be approved with /approve before picking a character in this room. " +
+				"Users without approval can only spectate."},
+		"approve": {(*SCServer).cmdApprove, 1, perms.Characters,
+			"/approve [uid]",
+			"Approves an user to pick characters in this room."},
+		"iniswap": {(*SCServer).cmdIniswap, 1, perms.Characters,
+			"/iniswap <on|off>",
+			"Sets whether iniswapping is allowed in this room. Folders in the room's allow-list can always be iniswapped to."},
+		"colors": {(*SCServer).cmdColors, 0, perms.None,
+			"/colors [ban|allow <color...>]",
+			"Lists the IC text colors that can't be used in this room. Banning or allowing colors (given by their number, from 0 to 11) requires the 'settings' permission."},
+		"transcript": {(*SCServer).cmdTranscript, 0, perms.Transcripts,
+			"/transcript [duration: optional] [html: optional]",
+			"Saves the room's recent IC messages (by default, all that the server remembers) to a file, as text or, with 'html', as a web page.\n" +
+				"Example usage: /transcript 2h html"},
+		"blankpost": {(*SCServer).cmdBlankpost, 1, perms.Settings,
+			"/blankpost <on|off>",
+			"Sets whether blank IC messages are allowed in this room."},
+		"shouts": {(*SCServer).cmdShouts, 1, perms.Settings,
+			"/shouts <on|off>",
+			"Sets whether shouts (e.g. objections) are allowed in this room."},
+		"immediate": {(*SCServer).cmdImmediate, 1, perms.Settings,
+			"/immediate <on|off>",
+			"Sets whether preanimations are forced to play at the same time as the text in this room."},
+		"slowmode": {(*SCServer).cmdSlowmode, 0, perms.None,
+			"/slowmode [seconds|off]",
+			"Shows this room's slow mode, or sets the minimum delay between IC messages from each user in this room. " +
+				"Changing it requires the settings permission, and users with it are exempt.\n" +
+				"Example usage: /slowmode 5"},
+		"play": {(*SCServer).cmdPlay, 1, perms.None,
+			"/play [song]",
+			"Plays the song in this room's music list that best matches the search. If more than one song matches, they are listed.\n" +
+				"Example usage: /play objection"},
+		"findsong": {(*SCServer).cmdFindSong, 1, perms.None,
+			"/findsong [song]",
+			"Searches for a song in every room's music list, showing which rooms have it.\n" +
+				"Example usage: /findsong pursuit"},
+		"queue": {(*SCServer).cmdQueue, 0, perms.None,
+			"/queue [song: optional]",
+			"Adds a song to this room's music queue, or shows the queue if no song is passed. Queued songs play in order " +
+				"when the current song would loop. Songs are searched as in /play.\n" +
+				"Example usage: /queue DANGANRONPA V3.opus"},
+		"musiclock": {(*SCServer).cmdMusicLock, 1, perms.Music,
+			"/musiclock <on|off>",
+			"Sets whether only users with the music permission, or invited users, can change the music in this room."},
+		"skip": {(*SCServer).cmdSkip, 0, perms.Music,
+			"/skip",
+			"Plays the next song in this room's music queue."},
+		"clearqueue": {(*SCServer).cmdClearQueue, 0, perms.Music,
+			"/clearqueue",
+			"Removes every song from this room's music queue."},
+		"area": {(*SCServer).cmdArea, 0, perms.None,
+			"/area [id|name: optional]",
+			"Moves you to a room you can see, by ID or name. Lists the rooms you can see if none is passed.\n" +
+				"Example usage: /area 2"},
+		"join": {(*SCServer).cmdJoin, 2, perms.None,
+			"/join [id|name] [password]",
+			"Enters a locked room using its password, without needing an invite. Too many wrong passwords will " +
+				"stop you from trying for a while.\n" +
+				"Example usage: /join 3 hunter2"},
+		"lock": {(*SCServer).cmdLock, 1, perms.Lock,
+			"/lock <free|spectatable|locked|password> [password: optional]",
+			"Sets this room's lock. \"/lock password [password]\" locks the room and lets anyone who knows " +
+				"the password in with /join. \"/lock password\" removes the password, and \"/lock free\" unlocks " +
+				"the room and removes the password."},
+		"clearroom": {(*SCServer).cmdClearRoom, 0, clearRoomPerms,
+			"/clearroom [adjacent room: optional]",
+			"Resets this room to its configured defaults, for cleaning up after an event: the background, sides, " +
+				"ambiance, description, settings and character lists go back to the configured ones, the music stops, " +
+				"the status becomes idle, the room is unlocked, and its title, invites, reservations and managers are cleared. " +
+				"If an adjacent room is passed, everyone else here is moved to it first.\n" +
+				"Example usage: /clearroom Lobby"},
+		"bg": {(*SCServer).cmdBg, 0, perms.None,
+			"/bg [background: optional]",
+			"Shows this room's background, or changes it. Changing it requires the background permission, " +
+				"and bypassing locks if the background is locked. If the server has a background list, only backgrounds in it can be used.\n" +
+				"Example usage: /bg gs4"},
+		"bglist": {(*SCServer).cmdBgList, 0, perms.None,
+			"/bglist [page: optional]",
+			"Lists the backgrounds that can be used in this server.\n" +
+				"Example usage: /bglist 2"},
+		"pos": {(*SCServer).cmdPos, 0, perms.None,
+			"/pos [side: optional]",
+			"Shows your side and this room's sides, or moves you to another side.\n" +
+				"Example usage: /pos def"},
+		"addside": {(*SCServer).cmdAddSide, 1, perms.Background,
+			"/addside [side]",
+			"Adds a side to this room. Clients look for the background's images named after the side.\n" +
+				"Example usage: /addside jur"},
+		"rmside": {(*SCServer).cmdRmSide, 1, perms.Background,
+			"/rmside [side]",
+			"Removes a side from this room. Users in it are moved to the default side.\n" +
+				"Example usage: /rmside hlp"},
+		"desc": {(*SCServer).cmdDesc, 1, perms.Description,
+			"/desc [description]",
+			"Sets this room's description."},
+		"title": {(*SCServer).cmdTitle, 0, perms.None,
+			"/title [title|clear: optional]",
+			"Shows this room's title (its current topic), or sets it. Setting it requires the description permission. " +
+				"The title is shown to everyone entering the room, and is kept across restarts.\n" +
+				"Example usage: /title Case 3: The Stolen Turnabout"},
+		"look": {(*SCServer).cmdLook, 0, perms.None,
+			"/look",
+			"Shows this room's description and who is in it."},
+		"arealist": {(*SCServer).cmdAreaList, 0, perms.None,
+			"/arealist",
+			"Lists the rooms you can see, with their IDs, player counts, statuses and locks."},
 		"get": {(*SCServer).cmdGet, 1, perms.None,
 			"/get <room|rooms|allrooms>",
 			"Gets a list of users in a room or set of rooms. Use:\n" +
@@ -46,27 +346,90 @@ func init() {
 	}
 }
 
+// Hides the passwords in the arguments of the commands that take them, so they're never
+// written to the logs.
+var secretArgs = map[string]func(args []string) []string{
+	"login":      redactFrom(0), // with a pending login, the first argument is the password
+	"passwd":     redactFrom(0),
+	"serverpass": redactFrom(0),
+	"adduser":    redactFrom(1),
+	"join": func(args []string) []string {
+		return redactFrom(len(args) - 1)(args)
+	},
+	"lock": func(args []string) []string {
+		if len(args) > 0 && args[0] == "password" {
+			return redactFrom(1)(args)
+		}
+		return args
+	},
+}
+
+// Returns a redactor that hides the arguments from position `i` on.
+func redactFrom(i int) func(args []string) []string {
+	return func(args []string) []string {
+		if i < 0 || i >= len(args) {
+			return args
+		}
+		return append(slices.Clip(args[:i]), "[redacted]")
+	}
+}
+
+// Returns the command's arguments as they should be logged.
+func loggedArgs(name string, args []string) []string {
+	if redact, ok := secretArgs[name]; ok {
+		return redact(args)
+	}
+	return args
+}
+
+// Returns an OOC message as it should be logged, hiding any passwords if it's a command.
+func loggedOOC(msg string) string {
+	trimmed := strings.TrimSpace(msg)
+	if !strings.HasPrefix(trimmed, "/") {
+		return msg
+	}
+	split := strings.Split(trimmed[1:], " ")
+	return "/" + strings.Join(append(split[:1], loggedArgs(split[0], split[1:])...), " ")
+}
+
+// Returns the built-in or scripted command with the name.
+func (srv *SCServer) command(name string) (cmdHandler, bool) {
+	if cmd, ok := cmdMap[name]; ok {
+		return cmd, true
+	}
+	cmd, ok := srv.scripts[name]
+	return cmd, ok
+}
+
 func (srv *SCServer) handleCommand(c *client.Client, name string, args []string) {
-	cmd, ok := cmdMap[name]
+	logged := loggedArgs(name, args)
+	cmd, ok := srv.command(name)
 	if !ok {
-		srv.sendServerMessage(c, fmt.Sprintf("'/%v' is an unknown command. Use /help to see a list of commands.", name))
+		srv.tell(c, "cmd.unknown", name)
 		c.Room().LogEvent(room.EventFail, "%s tried running unknown command '/%s' with arguments %#v",
-			c.LongString(), name, args)
+			c.LongString(), name, logged)
 		return
 	}
 	if len(args) < cmd.minArgs {
-		srv.sendServerMessage(c, fmt.Sprintf("Not enough arguments for /%v.\n Usage of /%v: %v", name, name, cmd.usage))
+		srv.tell(c, "cmd.not_enough_args", name, name, cmd.usage)
 		c.Room().LogEvent(room.EventFail, "%s tried running command '/%s' with too few arguments %#v.",
-			c.LongString(), name, args)
+			c.LongString(), name, logged)
 		return
 	}
 	if !c.HasPerms(cmd.reqPerms) {
-		srv.sendServerMessage(c, fmt.Sprintf("You do not have the required permisions to use /%v.", name))
+		srv.tell(c, "cmd.no_perms", name, cmd.reqPerms&^c.EffectivePerms())
 		c.Room().LogEvent(room.EventFail, "%s tried running command '/%s' with arguments %#v but did not have permission.",
-			c.LongString(), name, args)
+			c.LongString(), name, logged)
+		return
+	}
+	if !srv.roomAllowsCommand(c, name) {
 		return
 	}
-	c.Room().LogEvent(room.EventCommand, "%s ran command '/%s' with arguments %#v.", c.LongString(), name, args)
+	if !srv.hookCommand(c, name, &args) {
+		return
+	}
+	c.Room().LogEvent(room.EventCommand, "%s ran command '/%s' with arguments %#v.", c.LongString(), name, loggedArgs(name, args))
+	srv.stats.AddCommand()
 	msg, usage := cmd.cmdFunc(srv, c, args)
 	var reply string
 	if msg != "" {
@@ -76,10 +439,10 @@ func (srv *SCServer) handleCommand(c *client.Client, name string, args []string)
 		if reply != "" {
 			reply += "\n"
 		}
-		reply += fmt.Sprintf("Usage of /%v: %v", name, cmd.usage)
+		reply += srv.tr(c, "cmd.usage", name, cmd.usage)
 	}
 	if reply != "" {
-		srv.sendServerMessage(c, reply)
+		srv.sendServerMessage(c, "%s", reply)
 	}
 }
 
@@ -90,36 +453,383 @@ func (srv *SCServer) cmdHelp(c *client.Client, args []string) (string, bool) {
 		for cmd := range cmdMap {
 			msg += "/" + cmd + ", "
 		}
+		for cmd := range srv.scripts {
+			msg += "/" + cmd + ", "
+		}
 		return msg[:len(msg)-2], false
 	}
-	cmd, ok := cmdMap[args[0]]
+	if args[0] == "permissions" {
+		msg := "Permissions:"
+		for _, p := range perms.Table {
+			msg += fmt.Sprintf("\n%v: %v", p.Name, p.Desc)
+		}
+		return msg, false
+	}
+	cmd, ok := srv.command(args[0])
 	if !ok {
 		return fmt.Sprintf("'%v' is not a valid command.", args[0]), false
 	}
-	return fmt.Sprintf("Usage of /%v: %v\n%v", args[0], cmd.usage, cmd.detailed), false
+	msg := fmt.Sprintf("Usage of /%v: %v\n%v", args[0], cmd.usage, cmd.detailed)
+	if cmd.reqPerms != perms.None {
+		msg += fmt.Sprintf("\nRequires: %v. See /help permissions.", cmd.reqPerms)
+	}
+	return msg, false
+}
+
+// Checks a user's one-time code. Each code is accepted only once, and only if it's newer
+// than the last one accepted, so a code that was seen can't be replayed.
+func (srv *SCServer) checkCode(username string, secret string, code string) bool {
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	fresh, err := srv.db.UseTOTPStep(username, step)
+	if err != nil {
+		srv.logger.Warnf("Error in authentication (%v).", err)
+		return false
+	}
+	return fresh
 }
 
 func (srv *SCServer) cmdLogin(c *client.Client, args []string) (string, bool) {
-	ok, role, err := srv.db.CheckAuth(args[0], args[1])
+	var username string
+	var rest []string // the password, and possibly the one-time code
+	switch {
+	case c.PendingLogin() != "":
+		username, rest = c.PendingLogin(), args
+	case len(args) >= 2:
+		username, rest = args[0], args[1:]
+	default:
+		c.SetPendingLogin(args[0])
+		return fmt.Sprintf("Logging in as '%v'. Now send your password with /login [password].", args[0]), false
+	}
+	c.SetPendingLogin("")
+
+	if wait := srv.logins.wait(c.IPID()); wait > 0 {
+		c.NotifyLoginFailed()
+		return fmt.Sprintf("Too many failed logins. Try again in %v.", wait.Round(time.Second)), false
+	}
+	secret, needsCode, err := srv.db.TOTPSecret(username)
 	if err != nil {
 		srv.logger.Warnf("Error in authentication (%v).", err)
+		c.NotifyLoginFailed()
 		return "Couldn't authenticate: internal error.", false
 	}
+	var code string
+	if needsCode && len(rest) >= 2 {
+		code, rest = rest[len(rest)-1], rest[:len(rest)-1]
+	}
+	ok, role, err := srv.db.CheckAuth(username, strings.Join(rest, " "))
+	if err != nil {
+		srv.logger.Warnf("Error in authentication (%v).", err)
+		c.NotifyLoginFailed()
+		return "Couldn't authenticate: internal error.", false
+	}
+	if ok && needsCode && !srv.checkCode(username, secret, code) {
+		// Whether the password was right isn't revealed.
+		ok = false
+	}
 	if !ok {
-		return "Incorrect password, or user doesn't exist.", false
+		if err := srv.db.AddLoginFailure(c.IPID(), username); err != nil {
+			srv.logger.Warnf("Couldn't record failed login (%v).", err)
+		}
+		c.NotifyLoginFailed()
+		if srv.logins.fail(c.IPID()) {
+			srv.logger.Warnf("Locked out %s from logging in after repeated failures (last as '%v').", c.LongString(), username)
+			return fmt.Sprintf("Incorrect password or code, or user doesn't exist. Too many failed logins: try again in %v.",
+				time.Duration(srv.config.LoginLockout)*time.Minute), false
+		}
+		srv.logger.Infof("Failed login as '%v' by %s.", username, c.LongString())
+		return "Incorrect password or code, or user doesn't exist.", false
 	}
-	for _, r := range srv.roles {
-		if r.Name == role {
-			c.SetPerms(r.Perms)
-			if r.Perms&perms.HearModCalls != 0 {
-				c.AddGuard()
+	srv.logins.succeed(c.IPID())
+	r := srv.getRole(role)
+	if r == nil {
+		return fmt.Sprintf("Was able to authenticate, but role '%v' doesn't exist.", role), false
+	}
+	srv.addRole(c, client.RoleAuth, r)
+	c.SetLogin(username)
+	srv.logger.Infof("%s logged in as '%v' (role '%v').", c.LongString(), username, r.Name)
+	if c.Gates()&client.GateRaid != 0 {
+		srv.passGate(c, client.GateRaid)
+	}
+	// TODO: say permissions?
+	msg := fmt.Sprintf("Successfully authenticated as user '%v' and role '%v'.", username, role)
+	if r.Perms&perms.HearModCalls != 0 {
+		if pending := srv.modcalls.pending(); len(pending) > 0 {
+			msg += "\nPending mod calls:"
+			for _, call := range pending {
+				msg += "\n" + call.String()
 			}
-			// TODO: say permissions?
-			return fmt.Sprintf("Successfully authenticated as user '%v' and role '%v'.", args[0], role), false
 		}
+		// The calls made while no moderator was online have now been seen.
+		if err := srv.db.ClearModCalls(); err != nil {
+			srv.logger.Warnf("server: Couldn't clear recorded mod calls (%v).", err)
+		}
+	}
+	return msg, false
+}
+
+func (srv *SCServer) cmdMOTD(c *client.Client, args []string) (string, bool) {
+	if motd := srv.motd.get(); motd != "" {
+		return motd, false
+	}
+	return "There is no message of the day.", false
+}
+
+func (srv *SCServer) cmdRules(c *client.Client, args []string) (string, bool) {
+	if srv.config.Rules != "" {
+		return srv.config.Rules, false
+	}
+	return "This server has no rules set.", false
+}
+
+func (srv *SCServer) cmdSetMOTD(c *client.Client, args []string) (string, bool) {
+	if len(args) == 1 && args[0] == "reset" {
+		if err := srv.db.RemoveSetting(motdSetting); err != nil {
+			srv.logger.Warnf("%v", err)
+			return "Couldn't reset the MOTD: internal error.", false
+		}
+		srv.motd.set(srv.config.MOTD)
+		srv.logger.Infof("%s reset the MOTD.", c.LongString())
+		return "The MOTD was reset to the configured one.", false
+	}
+	text := strings.Join(args, " ")
+	if err := srv.db.SetSetting(motdSetting, text); err != nil {
+		srv.logger.Warnf("%v", err)
+		return "Couldn't change the MOTD: internal error.", false
+	}
+	srv.motd.set(text)
+	srv.logger.Infof("%s changed the MOTD to: %s", c.LongString(), text)
+	return "Changed the MOTD.", false
+}
+
+func (srv *SCServer) cmdAnnounce(c *client.Client, args []string) (string, bool) {
+	text := strings.Join(args, " ")
+	for cl := range srv.clients.ClientsJoined() {
+		srv.tell(cl, "announce.message", text)
+	}
+	srv.logger.Infof("%s made an announcement: %s", c.LongString(), text)
+	return "", false
+}
+
+func (srv *SCServer) cmdPoll(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		id, question, options, ok := r.Poll()
+		if !ok {
+			return "There is no poll running in this room.", false
+		}
+		msg := fmt.Sprintf("Poll #%v: %v", id, question)
+		for i, opt := range options {
+			msg += fmt.Sprintf("\n%v. %v", i+1, opt)
+		}
+		return msg + "\nUse /vote [option number] to vote.", false
+	}
+	if !c.HasPerms(perms.Polls) {
+		return "You do not have the required permissions to start polls (missing: polls).", false
+	}
+
+	var dur time.Duration
+	if d, err := parseDuration(args[0]); err == nil && d > 0 {
+		dur, args = d, args[1:]
+	}
+	parts := strings.Split(strings.Join(args, " "), "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	question, options := parts[0], parts[1:]
+	if question == "" || len(options) < 2 || slices.Contains(options, "") {
+		return "A poll needs a question and at least two options.", true
+	}
+
+	id, ok := r.StartPoll(question, options)
+	if !ok {
+		return "There is already a poll running in this room. Close it with /closepoll first.", false
+	}
+	msg := fmt.Sprintf("%v started poll #%v: %v", c.ShortString(), id, question)
+	for i, opt := range options {
+		msg += fmt.Sprintf("\n%v. %v", i+1, opt)
+	}
+	msg += "\nUse /vote [option number] to vote."
+	if dur > 0 {
+		msg += fmt.Sprintf(" The poll closes in %v.", dur)
+		time.AfterFunc(dur, func() { srv.closePoll(r, id) })
+	}
+	srv.sendServerMessageToRoom(r, "%s", msg)
+	r.LogEvent(room.EventPoll, "%s started poll #%v: %v %q.", c.LongString(), id, question, options)
+	return "", false
+}
+
+func (srv *SCServer) cmdVote(c *client.Client, args []string) (string, bool) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid option number.", args[0]), true
+	}
+	if ok, reason := c.Room().Vote(c.UID(), n-1); !ok {
+		return reason, false
+	}
+	c.Room().LogEvent(room.EventPoll, "%s voted for option %v.", c.LongString(), n)
+	return fmt.Sprintf("Voted for option %v.", n), false
+}
+
+func (srv *SCServer) cmdClosePoll(c *client.Client, args []string) (string, bool) {
+	if !srv.closePoll(c.Room(), 0) {
+		return "There is no poll running in this room.", false
+	}
+	return "", false
+}
+
+// Closes the poll with the passed ID in the room (or whichever poll is running, if
+// the ID is 0) and shows its results to everyone in it. Returns `false` if the poll
+// isn't running anymore.
+func (srv *SCServer) closePoll(r *room.Room, id int) bool {
+	results, ok := r.ClosePoll(id)
+	if !ok {
+		return false
+	}
+	srv.sendServerMessageToRoom(r, "%s", results.String())
+	r.LogEvent(room.EventPoll, "Poll #%v closed. %v", results.ID, results.String())
+	return true
+}
+
+func (srv *SCServer) cmdVerdict(c *client.Client, args []string) (string, bool) {
+	if ok, reason := srv.canJudge(c); !ok {
+		return reason, false
+	}
+	r := c.Room()
+	verdict := strings.Join(args, " ")
+	switch strings.ToLower(verdict) {
+	case "guilty":
+		srv.writeToRoomAO(r, "RT", splashRuling, "1")
+		verdict = "Guilty"
+	case "notguilty", "not guilty":
+		srv.writeToRoomAO(r, "RT", splashRuling, "0")
+		verdict = "Not guilty"
+	}
+	srv.sendServerMessageToRoom(r, "%v announced the verdict: %v", c.ShortString(), verdict)
+	r.LogEvent(room.EventJudge, "%s announced the verdict: %v", c.LongString(), verdict)
+	return "", false
+}
+
+func (srv *SCServer) cmdPenalty(c *client.Client, args []string) (string, bool) {
+	var bar packets.BarSelect
+	switch args[0] {
+	case "def":
+		bar = packets.BarDef
+	case "pro":
+		bar = packets.BarPro
+	default:
+		return "", true
+	}
+	r := c.Room()
+	current := r.Bar(bar)
+	if len(args) == 1 {
+		return fmt.Sprintf("The %v's penalty bar is at %v/%v.", barName(bar), current, packets.BarMax), false
+	}
+	if ok, reason := srv.canJudge(c); !ok {
+		return reason, false
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid amount.", args[1]), true
+	}
+	val := packets.BarHP(n)
+	if args[1][0] == '+' || args[1][0] == '-' {
+		val += current
+	}
+	val = min(max(val, packets.BarMin), packets.BarMax)
+	srv.setBar(r, bar, val)
+	srv.sendServerMessageToRoom(r, "%v set the %v's penalty bar to %v/%v.", c.ShortString(), barName(bar), val, packets.BarMax)
+	r.LogEvent(room.EventJudge, "%s set the %s bar to %v.", c.LongString(), barName(bar), val)
+	return "", false
+}
+
+func (srv *SCServer) cmdLogout(c *client.Client, args []string) (string, bool) {
+	username := c.Login()
+	if username == "" {
+		return "You are not logged in.", false
+	}
+	r := srv.removeRole(c, client.RoleAuth)
+	c.SetLogin("")
+	srv.logger.Infof("%s logged out (user '%v', role '%v').", c.LongString(), username, r.Name)
+	msg := fmt.Sprintf("Logged out. You no longer have the role '%v'.", r.Name)
+	if c.Perms() != perms.None {
+		msg += " Your other roles still apply; see /whoami."
+	}
+	return msg, false
+}
+
+func (srv *SCServer) cmdPasswd(c *client.Client, args []string) (string, bool) {
+	username := c.Login()
+	if username == "" {
+		return "You are not logged in.", false
+	}
+	if len(args) > 2 {
+		return "Passwords can't have spaces.", false
+	}
+	// Checking the old password is as good as a login attempt, so it's throttled the same way.
+	if wait := srv.logins.wait(c.IPID()); wait > 0 {
+		return fmt.Sprintf("Too many failed attempts. Try again in %v.", wait.Round(time.Second)), false
+	}
+	ok, _, err := srv.db.CheckAuth(username, args[0])
+	if err != nil {
+		srv.logger.Warnf("Error in authentication (%v).", err)
+		return "Couldn't change password: internal error.", false
+	}
+	if !ok {
+		if err := srv.db.AddLoginFailure(c.IPID(), username); err != nil {
+			srv.logger.Warnf("Couldn't record failed login (%v).", err)
+		}
+		srv.logins.fail(c.IPID())
+		return "Incorrect password.", false
+	}
+	if err := srv.db.UpdatePassword(username, args[1]); err != nil {
+		srv.logger.Warnf("Couldn't change password (%v).", err)
+		return "Couldn't change password: internal error.", false
+	}
+	srv.logger.Infof("%s changed the password of the user '%v'.", c.LongString(), username)
+	return "Password changed.", false
+}
+
+func (srv *SCServer) cmdWhoami(c *client.Client, args []string) (string, bool) {
+	msg := "You are not logged in."
+	if username := c.Login(); username != "" {
+		msg = fmt.Sprintf("You are logged in as '%v'.", username)
 	}
-	return fmt.Sprintf("Was able to authenticate, but role '%v' doesn't exist.", role), false
+	msg += fmt.Sprintf("\nYou are %s, in %v.", c.ShortString(), c.Room().Name())
+	c.Roles(func(slot client.RoleSlot, r *perms.Role) {
+		msg += fmt.Sprintf("\nRole '%v' (%v): %v.", r.Name, slot, r.Perms)
+	})
+	p, _ := srv.cmdPerms(c, nil)
+	return msg + "\n" + p, false
 }
+
+func (srv *SCServer) cmdAck(c *client.Client, args []string) (string, bool) {
+	if len(args) == 0 {
+		return srv.ackWarnings(c), false
+	}
+	if !c.HasPerms(perms.HearModCalls) {
+		return srv.tr(c, "cmd.no_perms", "ack", perms.HearModCalls&^c.EffectivePerms()), false
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid mod call ID.", args[0]), true
+	}
+	call, ok := srv.modcalls.ack(id)
+	if !ok {
+		return fmt.Sprintf("Mod call #%v is not pending. It may have already been handled.", id), false
+	}
+	srv.logger.Infof("Mod call #%v was acknowledged by %s.", id, c.LongString())
+	c.Room().LogEvent(room.EventMod, "%s acknowledged mod call #%v.", c.LongString(), id)
+	for cl := range srv.clients.ClientsJoined() {
+		if cl != c && cl.Perms()&perms.HearModCalls != 0 {
+			srv.sendServerMessage(cl, "Mod call #%v (%s) is being handled by %s.", id, call.room, c.ShortString())
+		}
+	}
+	return fmt.Sprintf("You are now handling mod call #%v: %s", id, call), false
+}
+
 func (srv *SCServer) cmdKick(c *client.Client, args []string) (string, bool) {
 	var reason string
 	if len(args) < 3 {
@@ -136,7 +846,7 @@ func (srv *SCServer) cmdKick(c *client.Client, args []string) (string, bool) {
 			return fmt.Sprintf("No client with IPID '%v'.", ipid), false
 		}
 		for _, cl := range toKick {
-			srv.kickClient(cl, reason)
+			srv.kickClient(cl, reason, c.String())
 		}
 		return fmt.Sprintf("Successfully kicked client with IPID %v.", ipid), false
 
@@ -148,7 +858,7 @@ func (srv *SCServer) cmdKick(c *client.Client, args []string) (string, bool) {
 		}
 		for _, cl := range srv.getClientsInRoom(c.Room()) {
 			if cl.CID() == cid {
-				srv.kickClient(cl, reason)
+				srv.kickClient(cl, reason, c.String())
 				return fmt.Sprintf("Successfully kicked client with CID %v.", cid), false
 			}
 		}
@@ -163,7 +873,7 @@ func (srv *SCServer) cmdKick(c *client.Client, args []string) (string, bool) {
 		if toKick == nil {
 			return fmt.Sprintf("No client with UID '%v'.", uid), false
 		}
-		srv.kickClient(toKick, reason)
+		srv.kickClient(toKick, reason, c.String())
 		return fmt.Sprintf("Successfully kicked client with UID %v.", uid), false
 
 	default:
@@ -175,7 +885,7 @@ func (srv *SCServer) cmdGet(c *client.Client, args []string) (string, bool) {
 	switch args[0] {
 	// TODO: permissions and stuff
 	case "room":
-		msg := fmt.Sprintf("\n>>> [%v] %v: <<<", c.Room().ID(), c.Room().Name())
+		msg := "\n" + roomHeader(c.Room())
 		for _, cl := range srv.getClientsInRoom(c.Room()) {
 			msg += "\n"
 			if c.HasPerms(perms.SeeIPIDs) {
@@ -190,7 +900,7 @@ func (srv *SCServer) cmdGet(c *client.Client, args []string) (string, bool) {
 		var msg string
 		for _, r := range c.Room().Visible() {
 			var submsg string
-			submsg += fmt.Sprintf("\n>>> [%v] %v: <<<", r.ID(), r.Name())
+			submsg += "\n" + roomHeader(r)
 			for _, cl := range srv.getClientsInRoom(r) {
 				submsg += "\n"
 				if c.HasPerms(perms.SeeIPIDs) {
@@ -207,7 +917,7 @@ func (srv *SCServer) cmdGet(c *client.Client, args []string) (string, bool) {
 		var msg string
 		for _, r := range srv.rooms {
 			var submsg string
-			submsg += fmt.Sprintf("\n>>> [%v] %v: <<<", r.ID(), r.Name())
+			submsg += "\n" + roomHeader(r)
 			for _, cl := range srv.getClientsInRoom(r) {
 				submsg += "\n"
 				if c.HasPerms(perms.SeeIPIDs) {
@@ -223,3 +933,712 @@ func (srv *SCServer) cmdGet(c *client.Client, args []string) (string, bool) {
 		return "", true
 	}
 }
+
+func (srv *SCServer) cmdCharSelect(c *client.Client, args []string) (string, bool) {
+	if !c.CharSelect() {
+		return "Couldn't return to the character select screen.", false
+	}
+	srv.trackChar(c)
+	srv.sendCharsCheck(c.Room())
+	return "", false
+}
+
+func (srv *SCServer) cmdSwitch(c *client.Client, args []string) (string, bool) {
+	name := strings.Join(args, " ")
+	cid, ok := c.Room().GetCIDByName(name)
+	if !ok {
+		// Be lenient with capitalization.
+		for i, char := range c.Room().Chars() {
+			if strings.EqualFold(char, name) {
+				cid, ok = i, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return fmt.Sprintf("There is no character named '%v' in this room.", name), false
+	}
+	if cid == c.CID() {
+		return "You are already that character.", false
+	}
+	if !srv.canPickChar(c) {
+		return "", false
+	}
+	if !c.Room().CanUse(cid, c.EffectivePerms()) {
+		return srv.tr(c, "room.manager_only"), false
+	}
+	if !c.ChangeChar(cid) {
+		return fmt.Sprintf("%v is taken.", c.Room().GetNameByCID(cid)), false
+	}
+	srv.trackChar(c)
+	srv.announcePicked(c)
+	srv.sendCharsCheck(c.Room())
+	return fmt.Sprintf("Switched to %v.", c.Charname()), false
+}
+
+func (srv *SCServer) cmdRandomChar(c *client.Client, args []string) (string, bool) {
+	if !srv.canPickChar(c) {
+		return "", false
+	}
+	free := slices.DeleteFunc(c.Room().FreeCIDs(), func(cid int) bool {
+		return !c.Room().CanUse(cid, c.EffectivePerms())
+	})
+	if len(free) == 0 {
+		return "Every character in this room is taken.", false
+	}
+	if !c.ChangeChar(free[rand.Intn(len(free))]) {
+		// Someone took it in the meantime.
+		return "Couldn't change characters. Try again.", false
+	}
+	srv.trackChar(c)
+	srv.announcePicked(c)
+	srv.sendCharsCheck(c.Room())
+	return fmt.Sprintf("Switched to %v.", c.Charname()), false
+}
+
+func (srv *SCServer) cmdReserve(c *client.Client, args []string) (string, bool) {
+	cid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid CID.", args[0]), false
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid UID.", args[1]), false
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return fmt.Sprintf("No client with UID %v.", id), false
+	}
+	if !c.Room().Reserve(cid, id) {
+		return fmt.Sprintf("There is no character with CID %v in this room.", cid), false
+	}
+	name := c.Room().GetNameByCID(cid)
+	c.Room().LogEvent(room.EventMod, "%s reserved %v (CID: %v) for %s.", c.LongString(), name, cid, target.LongString())
+	srv.sendServerMessage(target, "%v has been reserved for you in [%v] %v.", name, c.Room().ID(), c.Room().Name())
+	srv.sendCharsCheck(c.Room())
+	return fmt.Sprintf("Reserved %v for UID %v.", name, id), false
+}
+
+func (srv *SCServer) cmdUnreserve(c *client.Client, args []string) (string, bool) {
+	cid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid CID.", args[0]), false
+	}
+	if !c.Room().Unreserve(cid) {
+		return fmt.Sprintf("CID %v isn't reserved.", cid), false
+	}
+	c.Room().LogEvent(room.EventMod, "%s removed the reservation of CID %v.", c.LongString(), cid)
+	srv.sendCharsCheck(c.Room())
+	return fmt.Sprintf("Removed the reservation of %v.", c.Room().GetNameByCID(cid)), false
+}
+
+func (srv *SCServer) cmdApproval(c *client.Client, args []string) (string, bool) {
+	var on bool
+	switch args[0] {
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		return "", true
+	}
+	c.Room().SetApproval(on)
+	if on {
+		// Whoever turns it on shouldn't lock themselves out.
+		c.Room().Approve(c.UID())
+		srv.sendServerMessageToRoom(c.Room(), "Picking characters in this room now requires approval.")
+	} else {
+		srv.sendServerMessageToRoom(c.Room(), "Picking characters in this room no longer requires approval.")
+	}
+	c.Room().LogEvent(room.EventMod, "%s turned approval %v.", c.LongString(), args[0])
+	return "", false
+}
+
+func (srv *SCServer) cmdApprove(c *client.Client, args []string) (string, bool) {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid UID.", args[0]), false
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return fmt.Sprintf("No client with UID %v.", id), false
+	}
+	c.Room().Approve(id)
+	c.Room().LogEvent(room.EventMod, "%s approved %s.", c.LongString(), target.LongString())
+	srv.sendServerMessage(target, "You have been approved to pick characters in [%v] %v.", c.Room().ID(), c.Room().Name())
+	return fmt.Sprintf("Approved UID %v.", id), false
+}
+
+func (srv *SCServer) cmdIniswap(c *client.Client, args []string) (string, bool) {
+	return srv.toggleRoom(c, args[0], "iniswapping", c.Room().SetIniswapping,
+		"Iniswapping is now allowed in this room.", "Iniswapping is no longer allowed in this room.")
+}
+
+func (srv *SCServer) cmdBlankpost(c *client.Client, args []string) (string, bool) {
+	return srv.toggleRoom(c, args[0], "blankposting", c.Room().SetBlankpost,
+		"Blankposting is now allowed in this room.", "Blankposting is no longer allowed in this room.")
+}
+
+func (srv *SCServer) cmdShouts(c *client.Client, args []string) (string, bool) {
+	return srv.toggleRoom(c, args[0], "shouts", c.Room().SetShouting,
+		"Shouts are now allowed in this room.", "Shouts are no longer allowed in this room.")
+}
+
+func (srv *SCServer) cmdImmediate(c *client.Client, args []string) (string, bool) {
+	return srv.toggleRoom(c, args[0], "immediate preanims", c.Room().SetForceImmediate,
+		"Preanimations now play at the same time as the text in this room.",
+		"Preanimations no longer play at the same time as the text in this room.")
+}
+
+func (srv *SCServer) cmdSlowmode(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		if d := r.Slowmode(); d > 0 {
+			return srv.tr(c, "slowmode.on", d), false
+		}
+		return srv.tr(c, "slowmode.off"), false
+	}
+	if !c.HasPerms(perms.Settings) {
+		return srv.tr(c, "cmd.no_perms", "slowmode", perms.Settings&^c.EffectivePerms()), false
+	}
+	var d time.Duration
+	if args[0] != "off" {
+		secs, err := strconv.Atoi(args[0])
+		if err != nil || secs < 0 {
+			return "", true
+		}
+		d = time.Duration(secs) * time.Second
+	}
+	r.SetSlowmode(d)
+	if d == 0 {
+		srv.tellRoom(r, "slowmode.turned_off")
+	} else {
+		srv.tellRoom(r, "slowmode.turned_on", d)
+	}
+	r.LogEvent(room.EventMod, "%s set slow mode to %v.", c.LongString(), d)
+	return "", false
+}
+
+func (srv *SCServer) cmdColors(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		banned := r.BannedColors()
+		if len(banned) == 0 {
+			return "All text colors are allowed in this room.", false
+		}
+		names := make([]string, len(banned))
+		for i, color := range banned {
+			names[i] = strconv.Itoa(color)
+		}
+		return fmt.Sprintf("Text colors not allowed in this room: %v.", strings.Join(names, ", ")), false
+	}
+	if len(args) < 2 || (args[0] != "ban" && args[0] != "allow") {
+		return "", true
+	}
+	if !c.HasPerms(perms.Settings) {
+		return "You do not have the required permissions to change the text colors (missing: settings).", false
+	}
+
+	colors := make([]int, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		color, err := strconv.Atoi(arg)
+		if err != nil || color < 0 || color > room.MaxTextColor {
+			return fmt.Sprintf("'%v' is not a valid text color (must be from 0 to %v).", arg, room.MaxTextColor), false
+		}
+		colors = append(colors, color)
+	}
+	allow := args[0] == "allow"
+	for _, color := range colors {
+		r.SetColorAllowed(color, allow)
+	}
+	list := strings.Join(args[1:], ", ")
+	if allow {
+		srv.sendServerMessageToRoom(r, "Text colors %v are now allowed in this room.", list)
+		r.LogEvent(room.EventMod, "%s allowed text colors %v.", c.LongString(), list)
+	} else {
+		srv.sendServerMessageToRoom(r, "Text colors %v are no longer allowed in this room.", list)
+		r.LogEvent(room.EventMod, "%s banned text colors %v.", c.LongString(), list)
+	}
+	return "", false
+}
+
+// Turns one of the room's toggles on or off according to `arg` ("on" or "off"), letting
+// the room know with the matching message.
+func (srv *SCServer) toggleRoom(c *client.Client, arg string, name string, set func(bool), onMsg string, offMsg string) (string, bool) {
+	switch arg {
+	case "on":
+		set(true)
+		srv.sendServerMessageToRoom(c.Room(), "%s", onMsg)
+	case "off":
+		set(false)
+		srv.sendServerMessageToRoom(c.Room(), "%s", offMsg)
+	default:
+		return "", true
+	}
+	c.Room().LogEvent(room.EventMod, "%s turned %v %v.", c.LongString(), name, arg)
+	return "", false
+}
+
+func (srv *SCServer) cmdPlay(c *client.Client, args []string) (string, bool) {
+	if ok, reason := srv.canPlayMusic(c); !ok {
+		return reason, false
+	}
+	query := strings.Join(args, " ")
+	matches := searchSongs(c.Room().MusicList(), query)
+	switch len(matches) {
+	case 0:
+		return fmt.Sprintf("No songs in this room match '%v'.", query), false
+	case 1:
+		showname := c.Showname()
+		if showname == "" {
+			showname = c.Charname()
+		}
+		srv.playSong(c.Room(), matches[0], c.CID(), showname, packets.EffectDefault)
+		srv.events.Publish(events.Event{Kind: events.Music, Client: c, Room: c.Room(), Name: showname, Text: matches[0]})
+		return "", false
+	default:
+		return srv.listMatches(query, matches), false
+	}
+}
+
+func (srv *SCServer) cmdFindSong(c *client.Client, args []string) (string, bool) {
+	query := strings.Join(args, " ")
+	var all []string
+	rooms := make(map[string][]string)
+	for _, r := range srv.rooms {
+		for _, song := range searchSongs(r.MusicList(), query) {
+			if _, ok := rooms[song]; !ok {
+				all = append(all, song)
+			}
+			rooms[song] = append(rooms[song], fmt.Sprintf("[%v] %v", r.ID(), r.Name()))
+		}
+	}
+	if len(all) == 0 {
+		return fmt.Sprintf("No songs match '%v'.", query), false
+	}
+	msg := fmt.Sprintf("\n>>> Songs matching '%v' <<<", query)
+	for i, song := range all {
+		if i == srv.config.MaxSearchResults {
+			msg += fmt.Sprintf("\n...and %v more.", len(all)-i)
+			break
+		}
+		msg += fmt.Sprintf("\n%v (in %v)", song, strings.Join(rooms[song], ", "))
+	}
+	return msg, false
+}
+
+// Formats an ambiguous search's matches, up to the configured maximum.
+func (srv *SCServer) listMatches(query string, matches []string) string {
+	msg := fmt.Sprintf("\n'%v' matches %v songs. Be more specific:", query, len(matches))
+	for i, song := range matches {
+		if i == srv.config.MaxSearchResults {
+			msg += fmt.Sprintf("\n...and %v more.", len(matches)-i)
+			break
+		}
+		msg += "\n" + song
+	}
+	return msg
+}
+
+func (srv *SCServer) cmdQueue(c *client.Client, args []string) (string, bool) {
+	if len(args) == 0 {
+		queue := c.Room().Queue()
+		if len(queue) == 0 {
+			return "The music queue is empty.", false
+		}
+		msg := "\n>>> Music queue <<<"
+		for i, song := range queue {
+			msg += fmt.Sprintf("\n%v. %v", i+1, song)
+		}
+		return msg, false
+	}
+	if ok, reason := srv.canPlayMusic(c); !ok {
+		return reason, false
+	}
+
+	query := strings.Join(args, " ")
+	matches := searchSongs(c.Room().MusicList(), query)
+	if len(matches) == 0 {
+		return fmt.Sprintf("No songs in this room match '%v'.", query), false
+	}
+	if len(matches) > 1 {
+		return srv.listMatches(query, matches), false
+	}
+	song := matches[0]
+	pos, ok := c.Room().Enqueue(song)
+	if !ok {
+		return "The music queue is full.", false
+	}
+	c.Room().LogEvent(room.EventMusic, "%s queued %s.", c.LongString(), song)
+	if c.Room().Song() == packets.SongStop && srv.playNext(c.Room()) {
+		// Nothing was playing, so there's no reason to wait.
+		return "", false
+	}
+	srv.sendServerMessageToRoom(c.Room(), "%v queued '%v' (position %v).", c.ShortString(), song, pos)
+	return "", false
+}
+
+func (srv *SCServer) cmdMusicLock(c *client.Client, args []string) (string, bool) {
+	switch args[0] {
+	case "on":
+		c.Room().SetMusicLock(true)
+		srv.sendServerMessageToRoom(c.Room(), "The music in this room is now locked.")
+	case "off":
+		c.Room().SetMusicLock(false)
+		srv.sendServerMessageToRoom(c.Room(), "The music in this room is no longer locked.")
+	default:
+		return "", true
+	}
+	c.Room().LogEvent(room.EventMod, "%s turned the music lock %v.", c.LongString(), args[0])
+	return "", false
+}
+
+func (srv *SCServer) cmdSkip(c *client.Client, args []string) (string, bool) {
+	if !srv.playNext(c.Room()) {
+		return "The music queue is empty.", false
+	}
+	c.Room().LogEvent(room.EventMusic, "%s skipped the song.", c.LongString())
+	return "", false
+}
+
+func (srv *SCServer) cmdClearQueue(c *client.Client, args []string) (string, bool) {
+	n := c.Room().ClearQueue()
+	c.Room().LogEvent(room.EventMusic, "%s cleared the music queue (%v songs).", c.LongString(), n)
+	srv.sendServerMessageToRoom(c.Room(), "%v cleared the music queue.", c.ShortString())
+	return "", false
+}
+
+func (srv *SCServer) cmdArea(c *client.Client, args []string) (string, bool) {
+	if len(args) == 0 {
+		return srv.cmdAreaList(c, args)
+	}
+	query := strings.Join(args, " ")
+	dst := findVisibleRoom(c, query)
+	if dst == nil {
+		return fmt.Sprintf("You can't see a room with the ID or name '%v'.", query), false
+	}
+	srv.moveClient(c, dst)
+	return "", false
+}
+
+func (srv *SCServer) cmdJoin(c *client.Client, args []string) (string, bool) {
+	query, pw := strings.Join(args[:len(args)-1], " "), args[len(args)-1]
+	if !srv.joins.allow(c.IPID()) {
+		return "Too many wrong passwords. Try again later.", false
+	}
+	dst := findVisibleRoom(c, query)
+	if dst == nil {
+		return fmt.Sprintf("You can't see a room with the ID or name '%v'.", query), false
+	}
+	if !dst.HasPassword() {
+		return "That room doesn't have a password.", false
+	}
+	if !dst.CheckPassword(pw) {
+		srv.joins.fail(c.IPID())
+		dst.LogEvent(room.EventFail, "%s tried to enter with a wrong password.", c.LongString())
+		return "Wrong password.", false
+	}
+	dst.Invite(c.UID())
+	srv.moveClient(c, dst)
+	return "", false
+}
+
+func (srv *SCServer) cmdLock(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	switch args[0] {
+	case "free":
+		r.SetLockState(room.LockFree)
+		r.SetPassword("")
+	case "spectatable":
+		r.SetLockState(room.LockSpec)
+	case "locked":
+		r.SetLockState(room.LockLocked)
+	case "password":
+		if len(args) < 2 {
+			r.SetPassword("")
+			r.LogEvent(room.EventMod, "%s removed the room's password.", c.LongString())
+			return "Removed the password. The lock is unchanged.", false
+		}
+		r.SetLockState(room.LockLocked)
+		r.SetPassword(args[1])
+	default:
+		return "", true
+	}
+	// Whoever locks the room shouldn't lock themselves out.
+	r.Invite(c.UID())
+	r.LogEvent(room.EventMod, "%s set the lock to %v.", c.LongString(), args[0])
+	srv.sendServerMessageToRoom(r, "%v set the room's lock to %v.", c.ShortString(), r.LockString())
+	srv.sendRoomUpdateAll(packets.UpdateLock, r)
+	return "", false
+}
+
+func (srv *SCServer) cmdDesc(c *client.Client, args []string) (string, bool) {
+	desc := strings.Join(args, " ")
+	if utf8.RuneCountInString(desc) > srv.config.MaxMsgSize {
+		return "That description is too long.", false
+	}
+	c.Room().SetDesc(desc)
+	c.Room().LogEvent(room.EventMod, "%s changed the description to '%s'.", c.LongString(), desc)
+	srv.sendServerMessageToRoom(c.Room(), "%v changed the room's description: %v", c.ShortString(), desc)
+	return "", false
+}
+
+func (srv *SCServer) cmdLook(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	desc := r.Desc()
+	if desc == "" {
+		desc = "No description."
+	}
+	msg := fmt.Sprintf("\n>>> [%v] %v <<<\n%v", r.ID(), r.Name(), desc)
+	if title := r.Title(); title != "" {
+		msg += "\n" + srv.tr(c, "move.title", title)
+	}
+	msg += "\nPeople here:"
+	for _, cl := range srv.getClientsInRoom(r) {
+		msg += "\n" + cl.String()
+	}
+	return msg, false
+}
+
+// Looks for a room visible from the client's room by ID or name. Returns `nil` if not found.
+func findVisibleRoom(c *client.Client, query string) *room.Room {
+	id, err := strconv.Atoi(query)
+	for _, r := range c.Room().Visible() {
+		if (err == nil && r.ID() == id) || strings.EqualFold(r.Name(), query) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (srv *SCServer) cmdAreaList(c *client.Client, args []string) (string, bool) {
+	msg := "\n>>> Rooms <<<"
+	for _, r := range c.Room().Visible() {
+		msg += fmt.Sprintf("\n[%v] %v: %v players, %v, %v", r.ID(), r.Name(), r.PlayerCount(), r.Status(), r.LockString())
+		if r == c.Room() {
+			msg += " (you are here)"
+		}
+	}
+	return msg, false
+}
+
+// Returns the header for a room in /get's output, with its player and spectator counts.
+func roomHeader(r *room.Room) string {
+	spec := fmt.Sprint(r.SpectatorCount())
+	if limit := r.MaxSpectators(); limit > 0 {
+		spec += fmt.Sprintf("/%v", limit)
+	}
+	header := fmt.Sprintf(">>> [%v] %v (%v players, %v spectators): <<<",
+		r.ID(), r.Name(), r.PlayerCount()-r.SpectatorCount(), spec)
+	if title := r.Title(); title != "" {
+		header += "\nTitle: " + title
+	}
+	return header
+}
+
+func (srv *SCServer) cmdPerms(c *client.Client, args []string) (string, bool) {
+	p := c.EffectivePerms()
+	if p == perms.None {
+		return "You have no special permissions.", false
+	}
+	msg := fmt.Sprintf("Your permissions: %v.", p)
+	if p != c.Perms() {
+		msg += fmt.Sprintf("\n(Your role's permissions are %v, but this room changes them.)", c.Perms())
+	}
+	return msg, false
+}
+
+func (srv *SCServer) cmdRoles(c *client.Client, args []string) (string, bool) {
+	if len(srv.roles) == 0 {
+		return "There are no configured roles.", false
+	}
+	msg := "Configured roles:"
+	for _, r := range srv.roles {
+		msg += fmt.Sprintf("\n%v: %v", r.Name, r.Perms)
+	}
+	return msg, false
+}
+
+func (srv *SCServer) cmdPromote(c *client.Client, args []string) (string, bool) {
+	uid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid UID.", args[0]), true
+	}
+	target := srv.getByUID(uid)
+	if target == nil {
+		return fmt.Sprintf("No client with UID '%v'.", uid), false
+	}
+	// Role names may have spaces, so the role is everything between the UID and the duration.
+	name := strings.Join(args[1:len(args)-1], " ")
+	r := srv.getRole(name)
+	if r == nil {
+		return fmt.Sprintf("Role '%v' doesn't exist.", name), false
+	}
+	dur, err := parseDuration(args[len(args)-1])
+	if err != nil {
+		return fmt.Sprintf("'%v' is not a valid duration.", args[len(args)-1]), true
+	}
+
+	if err := srv.db.AddRoleGrant(target.IPID(), r.Name, c.String(), dur); err != nil {
+		srv.logger.Warnf("Couldn't add role grant (%v).", err)
+		return "Couldn't promote: internal error.", false
+	}
+	srv.grantRole(target, r, time.Now().Add(dur))
+	srv.sendServerMessage(target, "You have been given the role '%v' for %v.", r.Name, dur)
+	srv.logger.Infof("%s promoted %s to '%v' for %v.", c.LongString(), target.LongString(), r.Name, dur)
+	return fmt.Sprintf("Promoted %s to '%v' for %v.", target.ShortString(), r.Name, dur), false
+}
+
+func (srv *SCServer) cmdAddUser(c *client.Client, args []string) (string, bool) {
+	username, password := args[0], args[1]
+	name := strings.Join(args[2:], " ")
+	r := srv.getRole(name)
+	if r == nil {
+		return fmt.Sprintf("Role '%v' doesn't exist.", name), false
+	}
+	return srv.confirm(c, "user.confirm_add", []any{username, r.Name}, func() string {
+		if err := srv.db.AddAuth(username, password, r.Name); err != nil {
+			srv.logger.Warnf("Couldn't add user (%v).", err)
+			return "Couldn't add user. Does the user already exist?"
+		}
+		srv.logger.Infof("%s added the user '%v' with the role '%v'.", c.LongString(), username, r.Name)
+		c.Room().LogEvent(room.EventMod, "%s added the user '%v' with the role '%v'.", c.LongString(), username, r.Name)
+		return fmt.Sprintf("Added the user '%v' with the role '%v'.", username, r.Name)
+	}), false
+}
+
+func (srv *SCServer) cmdRmUser(c *client.Client, args []string) (string, bool) {
+	username := args[0]
+	return srv.confirm(c, "user.confirm_remove", []any{username}, func() string {
+		if err := srv.db.RemoveAuth(username); err != nil {
+			srv.logger.Warnf("Couldn't remove user (%v).", err)
+			return fmt.Sprintf("Couldn't remove the user '%v'. Does the user exist?", username)
+		}
+		for cl := range srv.clients.ClientsJoined() {
+			if cl.Login() == username {
+				srv.removeRole(cl, client.RoleAuth)
+				cl.SetLogin("")
+				srv.sendServerMessage(cl, "Your user was removed, so you have been logged out.")
+			}
+		}
+		srv.logger.Infof("%s removed the user '%v'.", c.LongString(), username)
+		c.Room().LogEvent(room.EventMod, "%s removed the user '%v'.", c.LongString(), username)
+		return fmt.Sprintf("Removed the user '%v'.", username)
+	}), false
+}
+
+func (srv *SCServer) cmdSetRole(c *client.Client, args []string) (string, bool) {
+	username := args[0]
+	name := strings.Join(args[1:], " ")
+	r := srv.getRole(name)
+	if r == nil {
+		return fmt.Sprintf("Role '%v' doesn't exist.", name), false
+	}
+	return srv.confirm(c, "user.confirm_role", []any{username, r.Name}, func() string {
+		if err := srv.db.UpdateRole(username, r.Name); err != nil {
+			srv.logger.Warnf("Couldn't change role (%v).", err)
+			return fmt.Sprintf("Couldn't change the role of the user '%v'. Does the user exist?", username)
+		}
+		for cl := range srv.clients.ClientsJoined() {
+			if cl.Login() == username {
+				srv.addRole(cl, client.RoleAuth, r)
+				srv.sendServerMessage(cl, "Your role was changed to '%v'.", r.Name)
+			}
+		}
+		srv.logger.Infof("%s changed the role of the user '%v' to '%v'.", c.LongString(), username, r.Name)
+		c.Room().LogEvent(room.EventMod, "%s changed the role of the user '%v' to '%v'.", c.LongString(), username, r.Name)
+		return fmt.Sprintf("Changed the role of the user '%v' to '%v'.", username, r.Name)
+	}), false
+}
+
+func (srv *SCServer) cmdConfirm(c *client.Client, args []string) (string, bool) {
+	action, ok := srv.confirms.take(c)
+	if !ok {
+		return srv.tr(c, "confirm.none"), false
+	}
+	return action.do(), false
+}
+
+// Parses a duration such as "30m" or "12h". On top of the units accepted by
+// [time.ParseDuration], it also accepts whole days ("3d") and weeks ("2w").
+func parseDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(s, "d"), strings.HasSuffix(s, "w"):
+		var n int
+		n, err = strconv.Atoi(s[:len(s)-1])
+		d = time.Duration(n) * 24 * time.Hour
+		if s[len(s)-1] == 'w' {
+			d *= 7
+		}
+	default:
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
+
+func (srv *SCServer) cmdIPBan(c *client.Client, args []string) (string, bool) {
+	reason := "No reason given."
+	if len(args) > 2 {
+		reason = strings.Join(args[2:], " ")
+	}
+	id, err := srv.banIPRange(args[0], args[1], reason, c.String())
+	if err != nil {
+		return fmt.Sprintf("Couldn't ban IP range (%v).", err), false
+	}
+	return fmt.Sprintf("Successfully added IP ban #%v.", id), false
+}
+
+func (srv *SCServer) cmdPing(c *client.Client, args []string) (string, bool) {
+	var msg string
+	if rtt, ok := c.RTT(); ok {
+		msg = fmt.Sprintf("Pong! Your latency is %v.", rtt.Round(time.Millisecond))
+	} else if c.IsWS() {
+		msg = "Pong! Your latency hasn't been measured yet, try again in a bit."
+	} else {
+		msg = "Pong! Latency can't be measured over legacy (TCP) connections."
+	}
+	if last := c.LastKeepalive(); !last.IsZero() {
+		msg += fmt.Sprintf("\nLast keepalive: %v ago.", time.Since(last).Round(time.Second))
+	}
+	msg += fmt.Sprintf("\nPackets waiting to be sent to you: %v.", c.QueueLen())
+	if avg, _, measured := srv.latencyStats(); measured > 0 {
+		msg += fmt.Sprintf("\nAverage latency in the server: %v.", avg.Round(time.Millisecond))
+	}
+	return msg, false
+}
+
+func (srv *SCServer) cmdStats(c *client.Client, args []string) (string, bool) {
+	st := srv.stats.Snapshot()
+	msg := fmt.Sprintf("\n>>> Server statistics <<<"+
+		"\nUptime: %v"+
+		"\nPlayers: %v/%v (peak: %v)"+
+		"\nJoins: %v"+
+		"\nIC messages: %v"+
+		"\nMod calls: %v",
+		st.Uptime.Round(time.Second), srv.clients.SizeJoined(), srv.config.MaxPlayers, st.PeakPlayers,
+		st.Joins, st.ICMessages, st.ModCalls)
+	if c.HasPerms(perms.HearModCalls) {
+		queued, largest := srv.queueStats()
+		avg, worst, measured := srv.latencyStats()
+		msg += fmt.Sprintf("\nConnections: %v"+
+			"\nOOC messages: %v"+
+			"\nCommands: %v"+
+			"\nSong changes: %v"+
+			"\nKicks: %v"+
+			"\nBans: %v"+
+			"\nQueued packets: %v (largest queue: %v)"+
+			"\nSlow clients evicted: %v"+
+			"\nLatency: %v average, %v worst (%v clients measured)",
+			srv.clients.Size(), st.OOCMessages, st.Commands, st.MusicChanges, st.Kicks, st.Bans,
+			queued, largest, st.Evictions, avg.Round(time.Millisecond), worst.Round(time.Millisecond), measured)
+	}
+	return msg, false
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoggedArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"login", []string{"mod", "hunter2", "123456"}, []string{"[redacted]"}},
+		{"login", []string{"hunter2"}, []string{"[redacted]"}},
+		{"passwd", []string{"old", "new"}, []string{"[redacted]"}},
+		{"serverpass", []string{"letmein"}, []string{"[redacted]"}},
+		{"adduser", []string{"mod", "hunter2", "Moderator"}, []string{"mod", "[redacted]"}},
+		{"join", []string{"Court", "Room", "secret"}, []string{"Court", "Room", "[redacted]"}},
+		{"lock", []string{"password", "secret"}, []string{"password", "[redacted]"}},
+		{"lock", []string{"locked"}, []string{"locked"}},
+		{"kick", []string{"uid", "1", "bye"}, []string{"uid", "1", "bye"}},
+		{"login", nil, nil},
+	} {
+		args := slices.Clone(tc.args)
+		if got := loggedArgs(tc.name, args); !slices.Equal(got, tc.want) {
+			t.Errorf("loggedArgs(%q, %q) = %q; want %q", tc.name, tc.args, got, tc.want)
+		}
+		if !slices.Equal(args, tc.args) {
+			t.Errorf("loggedArgs(%q, ...) changed the arguments to %q", tc.name, args)
+		}
+	}
+}
+
+func TestLoggedOOC(t *testing.T) {
+	for msg, want := range map[string]string{
+		"hello":                 "hello",
+		"/login mod hunter2":    "/login [redacted]",
+		" /join Courtroom pass": "/join Courtroom [redacted]",
+		"/ping":                 "/ping",
+	} {
+		if got := loggedOOC(msg); got != want {
+			t.Errorf("loggedOOC(%q) = %q; want %q", msg, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+)
+
+// How long a client has to /confirm an action.
+const confirmTimeout = 30 * time.Second
+
+// Actions waiting for their clients to /confirm them. Its methods can be called
+// from multiple goroutines.
+type confirmations struct {
+	pending map[*client.Client]pendingAction
+	mu      sync.Mutex
+}
+
+type pendingAction struct {
+	desc    string
+	do      func() string // returns the message for the client
+	expires time.Time
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{pending: make(map[*client.Client]pendingAction)}
+}
+
+// Sets the client's pending action, replacing any previous one.
+func (cs *confirmations) ask(c *client.Client, desc string, do func() string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.pending[c] = pendingAction{desc, do, time.Now().Add(confirmTimeout)}
+}
+
+// Takes the client's pending action, if it hasn't expired.
+func (cs *confirmations) take(c *client.Client) (action pendingAction, ok bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	action, ok = cs.pending[c]
+	delete(cs.pending, c)
+	if ok && time.Now().After(action.expires) {
+		return pendingAction{}, false
+	}
+	return action, ok
+}
+
+// Drops the client's pending action, e.g. when it disconnects.
+func (cs *confirmations) forget(c *client.Client) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.pending, c)
+}
+
+// Sets the client's pending action, described by the message with the passed key (e.g.
+// "remove the user 'x'"). Returns the message asking the client to confirm it.
+func (srv *SCServer) confirm(c *client.Client, key string, a []any, do func() string) string {
+	desc := srv.tr(c, key, a...)
+	srv.confirms.ask(c, desc, do)
+	return srv.tr(c, "confirm.ask", desc)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/lambdcalculus/scs/internal/events"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Subscribes the server's subsystems (room logs and histories, statistics and the webhook notifier)
+// to the events they care about.
+func (srv *SCServer) subscribeEvents() {
+	srv.events.Subscribe(srv.logRoomEvent, events.IC, events.OOC, events.Music, events.ModCall)
+	srv.events.Subscribe(srv.countEvent)
+	srv.events.Subscribe(func(e events.Event) {
+		e.Room.AddHistory(room.HistoryEntry{Time: e.Time, Char: e.Client.Charname(), Name: e.Name, Text: e.Text})
+	}, events.IC)
+	srv.events.Subscribe(srv.notifyEvent, events.ModCall, events.Ban)
+	srv.events.Subscribe(func(e events.Event) {
+		srv.logger.Infof("Client with UID %v (IPID: %v) left.", e.Client.UID(), e.Client.IPID())
+	}, events.Leave)
+}
+
+// Writes the event to the log of the room it happened in.
+func (srv *SCServer) logRoomEvent(e events.Event) {
+	r := e.Room
+	switch e.Kind {
+	case events.IC:
+		r.LogEvent(room.EventIC, "%s: %s | (from %s)", e.Name, e.Text, e.Client.LongString())
+	case events.OOC:
+		r.LogEvent(room.EventOOC, "%s: %s | (from %s)", e.Name, e.Text, e.Client.LongString())
+	case events.Music:
+		switch {
+		case e.Client == nil:
+			r.LogEvent(room.EventMusic, "Played %s from the queue.", e.Text)
+		case e.Text == packets.SongStop:
+			r.LogEvent(room.EventMusic, "%s stopped the music.", e.Client.LongString())
+		default:
+			r.LogEvent(room.EventMusic, "%s played %s.", e.Client.LongString(), e.Text)
+		}
+	case events.ModCall:
+		r.LogEvent(room.EventMod, "Mod called by %s. Reason: %s", e.Client.LongString(), e.Text)
+	}
+}
+
+// Updates the server's statistics.
+func (srv *SCServer) countEvent(e events.Event) {
+	switch e.Kind {
+	case events.Join:
+		srv.stats.AddJoin(srv.clients.SizeJoined())
+	case events.IC:
+		srv.stats.AddIC()
+	case events.OOC:
+		srv.stats.AddOOC()
+	case events.Music:
+		srv.stats.AddMusic()
+	case events.ModCall:
+		srv.stats.AddModCall()
+	case events.Kick:
+		srv.stats.AddKick()
+	case events.Ban:
+		srv.stats.AddBan()
+	}
+}
+
+// Posts the event to the webhook.
+func (srv *SCServer) notifyEvent(e events.Event) {
+	switch e.Kind {
+	case events.ModCall:
+		srv.webhook.ModCall(fmt.Sprintf("[%v] %s", e.Room.ID(), e.Room.Name()), e.Client.LongString(), e.Text)
+	case events.Ban:
+		srv.webhook.Ban(e.Target, e.Actor, e.Text, e.Duration)
+	}
+}
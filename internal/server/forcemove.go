@@ -0,0 +1,56 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Moves the client to `dst` on behalf of `by`, bypassing move cooldowns, adjacency and
+// locks. Returns `false` if the room is full of spectators.
+func (srv *SCServer) forceMove(c *client.Client, dst *room.Room, by *client.Client) bool {
+	src := c.Room()
+	if !srv.enterRoom(c, dst) {
+		return false
+	}
+	srv.tell(c, "move.forced", dst.ID(), dst.Name())
+	src.LogEvent(room.EventMod, "%s moved %s to [%v] %s.", by.LongString(), c.LongString(), dst.ID(), dst.Name())
+	dst.LogEvent(room.EventMod, "%s moved %s here from [%v] %s.", by.LongString(), c.LongString(), src.ID(), src.Name())
+	srv.logger.Infof("%s moved %s from [%v] %s to [%v] %s.", by.LongString(), c.LongString(), src.ID(), src.Name(), dst.ID(), dst.Name())
+	return true
+}
+
+// Moves the user with the UID in `arg` to `dst` on behalf of `c`, for /forcemove
+// and /summon.
+func (srv *SCServer) forceMoveUID(c *client.Client, arg string, dst *room.Room) string {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return srv.tr(c, "cmd.bad_uid", arg)
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return srv.tr(c, "cmd.no_uid", id)
+	}
+	if target.Room() == dst {
+		return srv.tr(c, "forcemove.already", id, dst.ID(), dst.Name())
+	}
+	if !srv.forceMove(target, dst, c) {
+		return srv.tr(c, "forcemove.full", id, dst.ID(), dst.Name())
+	}
+	return srv.tr(c, "forcemove.moved", id, dst.ID(), dst.Name())
+}
+
+func (srv *SCServer) cmdForceMove(c *client.Client, args []string) (string, bool) {
+	query := strings.Join(args[1:], " ")
+	dst := srv.findRoom(query)
+	if dst == nil {
+		return srv.tr(c, "cmd.no_room", query), false
+	}
+	return srv.forceMoveUID(c, args[0], dst), false
+}
+
+func (srv *SCServer) cmdSummon(c *client.Client, args []string) (string, bool) {
+	return srv.forceMoveUID(c, args[0], c.Room()), false
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Sets the steps a client that just joined has to take before it can play, and tells
+// it about the first of them.
+func (srv *SCServer) gateClient(c *client.Client) {
+	var g client.Gate
+	if srv.config.JoinPassword != "" {
+		g |= client.GatePassword
+	}
+	if srv.raid.active() && !srv.isStaff(c) {
+		g |= client.GateRaid
+	}
+	if srv.config.RequireAgree && srv.config.Rules != "" {
+		g |= client.GateRules
+	}
+	c.SetGates(g)
+	srv.tellGate(c, g)
+}
+
+// Tells the client about the first step it still has to take.
+func (srv *SCServer) tellGate(c *client.Client, g client.Gate) {
+	switch {
+	case g&client.GatePassword != 0:
+		srv.tell(c, "gate.password")
+	case g&client.GateRaid != 0 && srv.config.Raid.Password != "":
+		srv.tell(c, "gate.raid_pass")
+	case g&client.GateRaid != 0:
+		srv.tell(c, "gate.raid")
+	case g&client.GateRules != 0:
+		srv.tell(c, "gate.rules")
+	}
+}
+
+// Checks whether the client can pick a character. If not, tells it why.
+func (srv *SCServer) canPickChar(c *client.Client) bool {
+	if g := c.Gates(); g != 0 {
+		srv.tellGate(c, g)
+		return false
+	}
+	return true
+}
+
+// Checks whether the client can chat in OOC or play music, which it can't until it has
+// entered the server's password and, in raid mode, logged in. If not, tells it why.
+func (srv *SCServer) canSpeak(c *client.Client) bool {
+	if g := c.Gates(); g&(client.GatePassword|client.GateRaid) != 0 {
+		srv.tellGate(c, g)
+		return false
+	}
+	return true
+}
+
+func (srv *SCServer) cmdServerPass(c *client.Client, args []string) (string, bool) {
+	// The join password comes first, then the raid password.
+	var gate client.Gate
+	var want string
+	switch g := c.Gates(); {
+	case g&client.GatePassword != 0:
+		gate, want = client.GatePassword, srv.config.JoinPassword
+	case g&client.GateRaid != 0 && srv.config.Raid.Password != "":
+		gate, want = client.GateRaid, srv.config.Raid.Password
+	default:
+		return srv.tr(c, "gate.no_password"), false
+	}
+	if !srv.joins.allow(c.IPID()) {
+		return srv.tr(c, "gate.too_many"), false
+	}
+	pw := strings.Join(args, " ")
+	if subtle.ConstantTimeCompare([]byte(pw), []byte(want)) != 1 {
+		srv.joins.fail(c.IPID())
+		c.Room().LogEvent(room.EventFail, "%s entered a wrong server password.", c.LongString())
+		return srv.tr(c, "gate.wrong"), false
+	}
+	srv.passGate(c, gate)
+	return "", false
+}
+
+func (srv *SCServer) cmdAgree(c *client.Client, args []string) (string, bool) {
+	if c.Gates()&client.GateRules == 0 {
+		return srv.tr(c, "gate.agreed"), false
+	}
+	c.Room().LogEvent(room.EventEnter, "%s agreed to the rules.", c.LongString())
+	srv.passGate(c, client.GateRules)
+	return "", false
+}
+
+// Marks the step as taken, and tells the client what's next.
+func (srv *SCServer) passGate(c *client.Client, g client.Gate) {
+	if left := c.PassGate(g); left != 0 {
+		srv.tellGate(c, left)
+		return
+	}
+	srv.tell(c, "gate.done")
+}
+
+// Announces the client in its room the first time it picks a character, i.e. when it
+// goes from spectating to playing.
+func (srv *SCServer) announcePicked(c *client.Client) {
+	if c.CharPicked() || c.CID() == room.SpectatorCID {
+		return
+	}
+	srv.tellRoom(c.Room(), "server.joined", c.ShortString())
+	c.Room().LogEvent(room.EventEnter, "%s joined the server.", c.LongString())
+	c.SetCharPicked(true)
+}
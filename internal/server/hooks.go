@@ -0,0 +1,77 @@
+package server
+
+import "github.com/lambdcalculus/scs/internal/client"
+
+// Hooks let extensions (e.g. in forks of the server) observe and change events without
+// patching the handlers. Any of the functions can be nil. Hooks that return `false`
+// veto the event, and hooks that take pointers can change the event before it goes
+// through. Hooks are called from the client's goroutine, so they shouldn't block.
+type Hooks struct {
+	// Called when a client commits to joining, before it's given a UID. Vetoing it
+	// disconnects the client.
+	OnJoin func(c *client.Client) bool
+	// Called when a joined client leaves, before it's taken out of its room.
+	OnLeave func(c *client.Client)
+	// Called for a valid IC message, before it's sent to the room.
+	OnICMessage func(c *client.Client, msg *ICMessage) bool
+	// Called before a command runs, after its permissions were checked.
+	OnCommand func(c *client.Client, name string, args *[]string) bool
+	// Called for a mod call, before moderators are notified.
+	OnModcall func(c *client.Client, reason *string) bool
+}
+
+// The parts of an IC message hooks can change.
+type ICMessage struct {
+	Text     string
+	Showname string
+}
+
+// Registers the hooks. Hooks run in the order they were added, and the first veto
+// stops the event. Should be called before [SCServer.Run].
+func (srv *SCServer) AddHooks(h Hooks) {
+	srv.hooks = append(srv.hooks, h)
+}
+
+func (srv *SCServer) hookJoin(c *client.Client) bool {
+	for _, h := range srv.hooks {
+		if h.OnJoin != nil && !h.OnJoin(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (srv *SCServer) hookLeave(c *client.Client) {
+	for _, h := range srv.hooks {
+		if h.OnLeave != nil {
+			h.OnLeave(c)
+		}
+	}
+}
+
+func (srv *SCServer) hookIC(c *client.Client, msg *ICMessage) bool {
+	for _, h := range srv.hooks {
+		if h.OnICMessage != nil && !h.OnICMessage(c, msg) {
+			return false
+		}
+	}
+	return true
+}
+
+func (srv *SCServer) hookCommand(c *client.Client, name string, args *[]string) bool {
+	for _, h := range srv.hooks {
+		if h.OnCommand != nil && !h.OnCommand(c, name, args) {
+			return false
+		}
+	}
+	return true
+}
+
+func (srv *SCServer) hookModcall(c *client.Client, reason *string) bool {
+	for _, h := range srv.hooks {
+		if h.OnModcall != nil && !h.OnModcall(c, reason) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/i18n"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Returns the message with the passed key in the client's language.
+func (srv *SCServer) tr(c *client.Client, key string, a ...any) string {
+	return srv.catalog.Message(c.Language(), key, a...)
+}
+
+// Sends the message with the passed key to the client, in its language. Returns the
+// message in the built-in language, e.g. for logging.
+func (srv *SCServer) tell(c *client.Client, key string, a ...any) string {
+	srv.sendServerMessage(c, "%s", srv.tr(c, key, a...))
+	return srv.catalog.Message(i18n.Builtin, key, a...)
+}
+
+// Sends the message with the passed key to all clients in the room, each in its own language.
+func (srv *SCServer) tellRoom(r *room.Room, key string, a ...any) {
+	srv.broadcast.fanOut(srv.getClientsInRoom(r), func(c *client.Client) {
+		c.SendOOCMessage(srv.config.Username, srv.tr(c, key, a...), true)
+	})
+}
+
+func (srv *SCServer) cmdLang(c *client.Client, args []string) (string, bool) {
+	available := strings.Join(srv.catalog.Languages(), ", ")
+	if len(args) == 0 {
+		lang := c.Language()
+		if lang == "" {
+			lang = srv.config.Language
+		}
+		return srv.tr(c, "lang.current", lang, available), false
+	}
+	if !srv.catalog.Has(args[0]) {
+		return srv.tr(c, "lang.unknown", args[0], available), false
+	}
+	c.SetLanguage(args[0])
+	return srv.tr(c, "lang.set", args[0]), false
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/db"
+)
+
+// The active bans on ranges of raw IPs, kept parsed in memory so connections can be
+// checked as they're accepted without querying the database. Its methods can be called
+// from multiple goroutines.
+type ipBanCache struct {
+	bans  []cachedIPBan
+	timer *time.Timer // refreshes the cache when the earliest ban ends
+	mu    sync.RWMutex
+}
+
+type cachedIPBan struct {
+	ban    db.IPBan
+	prefix netip.Prefix
+}
+
+func newIPBanCache() *ipBanCache {
+	return &ipBanCache{}
+}
+
+// Returns the ban whose range contains the IP, if any.
+func (c *ipBanCache) find(ip netip.Addr) (db.IPBan, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := time.Now()
+	for _, b := range c.bans {
+		if b.prefix.Contains(ip) && b.ban.End.After(now) {
+			return b.ban, true
+		}
+	}
+	return db.IPBan{}, false
+}
+
+// Reloads the active IP range bans from the database. Should be called whenever one is
+// added or lifted. It's also called again when the earliest ban ends, to drop it.
+func (srv *SCServer) refreshIPBans() error {
+	bans, err := srv.db.GetIPBans()
+	if err != nil {
+		return fmt.Errorf("server: Couldn't load IP bans (%w).", err)
+	}
+	cached := make([]cachedIPBan, 0, len(bans))
+	var earliest time.Time
+	for _, ban := range bans {
+		prefix, err := netip.ParsePrefix(ban.CIDR)
+		if err != nil {
+			srv.logger.Warnf("server: IP ban #%v has invalid range '%v'.", ban.BanID, ban.CIDR)
+			continue
+		}
+		cached = append(cached, cachedIPBan{ban, prefix.Masked()})
+		if earliest.IsZero() || ban.End.Before(earliest) {
+			earliest = ban.End
+		}
+	}
+
+	c := srv.ipBans
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bans = cached
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if !earliest.IsZero() {
+		c.timer = time.AfterFunc(time.Until(earliest), func() {
+			if err := srv.refreshIPBans(); err != nil {
+				srv.reportError("%v", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Lifts the IP range ban with the ID.
+func (srv *SCServer) liftIPBan(id int) error {
+	if err := srv.db.NullIPBan(id); err != nil {
+		return err
+	}
+	return srv.refreshIPBans()
+}
+
+// Checks whether the raw IP of the address (formatted as "host:port") is in a banned range.
+// This is done when accepting connections, before the IP is ever hashed into an IPID.
+func (srv *SCServer) checkIPBan(addr string) (db.IPBan, bool) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return db.IPBan{}, false
+	}
+	return srv.ipBans.find(ap.Addr().Unmap())
+}
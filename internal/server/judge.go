@@ -0,0 +1,43 @@
+package server
+
+import (
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Splash animations, as sent in AO's RT packet.
+const (
+	splashTestimony = "testimony1"
+	splashCross     = "testimony2"
+	splashRuling    = "judgeruling" // followed by "0" for not guilty or "1" for guilty
+)
+
+// Checks whether the client can use judge actions (splashes, penalties and verdicts) in
+// its room. If not, returns the reason, to be sent to the client.
+func (srv *SCServer) canJudge(c *client.Client) (ok bool, reason string) {
+	if c.MuteState()&client.MutedJudge != 0 {
+		return false, "You are currently blocked from using judge commands."
+	}
+	if (c.Room().LockState() == room.LockSpec) && !c.Room().IsInvited(c.UID()) && !c.HasPerms(perms.Judge) {
+		return false, "You are only allowed to spectate in this area."
+	}
+	return true, ""
+}
+
+// Sets one of the room's HP bars and shows it to everyone in the room.
+func (srv *SCServer) setBar(r *room.Room, bar packets.BarSelect, val packets.BarHP) {
+	r.SetBar(bar, val)
+	for _, cl := range srv.getClientsInRoom(r) {
+		cl.UpdateBars()
+	}
+}
+
+// Returns the name of the side an HP bar belongs to.
+func barName(bar packets.BarSelect) string {
+	if bar == packets.BarPro {
+		return "prosecution"
+	}
+	return "defense"
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits how many failed attempts (e.g. wrong passwords) a key, such as an IPID, can
+// make within a window of time. Its methods can be called from multiple goroutines.
+type attemptLimiter struct {
+	max      int
+	window   time.Duration
+	failures map[string][]time.Time
+	mu       sync.Mutex
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		failures: make(map[string][]time.Time),
+	}
+}
+
+// Returns whether the key can make another attempt.
+func (l *attemptLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.recent(key)) < l.max
+}
+
+// Records a failed attempt by the key.
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures[key] = append(l.recent(key), time.Now())
+}
+
+// Returns the key's failures within the window, forgetting older ones.
+func (l *attemptLimiter) recent(key string) []time.Time {
+	times := l.failures[key]
+	i := 0
+	for i < len(times) && time.Since(times[i]) >= l.window {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = times
+	return times
+}
+
+const (
+	// The wait after a key's first failed login. It doubles with each further failure.
+	loginBackoff = time.Second
+	// The longest wait between failed logins, before the key is locked out.
+	maxLoginBackoff = 30 * time.Second
+)
+
+// Throttles logins by key (e.g. IPID), so passwords can't be brute-forced and every
+// attempt doesn't cost a bcrypt comparison. After each failure, the key has to wait
+// exponentially longer before trying again, and after `max` failures it's locked out.
+// Its methods can be called from multiple goroutines.
+type loginThrottle struct {
+	max     int
+	lockout time.Duration
+	states  map[string]*loginState
+	mu      sync.Mutex
+}
+
+type loginState struct {
+	failures int
+	next     time.Time // when the next attempt is allowed
+}
+
+func newLoginThrottle(max int, lockout time.Duration) *loginThrottle {
+	return &loginThrottle{
+		max:     max,
+		lockout: lockout,
+		states:  make(map[string]*loginState),
+	}
+}
+
+// Returns how long the key has to wait before its next attempt, or 0 if it can try now.
+func (t *loginThrottle) wait(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.states[key]
+	if !ok {
+		return 0
+	}
+	return max(time.Until(st.next), 0)
+}
+
+// Records a failed login by the key. Returns whether the key is now locked out.
+func (t *loginThrottle) fail(key string) (locked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cleanup()
+	st, ok := t.states[key]
+	if !ok {
+		st = &loginState{}
+		t.states[key] = st
+	}
+	st.failures++
+	if st.failures >= t.max {
+		st.failures = 0
+		st.next = time.Now().Add(t.lockout)
+		return true
+	}
+	st.next = time.Now().Add(min(loginBackoff<<(st.failures-1), maxLoginBackoff))
+	return false
+}
+
+// Forgets the key's failures after a successful login.
+func (t *loginThrottle) succeed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, key)
+}
+
+// Forgets keys that haven't failed for as long as a lockout.
+func (t *loginThrottle) cleanup() {
+	for k, st := range t.states {
+		if time.Since(st.next) >= t.lockout {
+			delete(t.states, k)
+		}
+	}
+}
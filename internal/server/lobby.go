@@ -0,0 +1,54 @@
+package server
+
+import (
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Commands that can't be used in lobbies, since they change IC settings, the music lock
+// or the room's lock.
+var lobbyBlocked = map[string]struct{}{
+	"verdict":    {},
+	"penalty":    {},
+	"iniswap":    {},
+	"blankpost":  {},
+	"shouts":     {},
+	"immediate":  {},
+	"colors":     {},
+	"slowmode":   {},
+	"transcript": {},
+	"musiclock":  {},
+	"lock":       {},
+}
+
+// Checks whether the type of the client's room allows speaking IC. If not, tells the
+// client why.
+func (srv *SCServer) roomAllowsIC(c *client.Client) bool {
+	if c.Room().IsLobby() {
+		c.Room().LogEvent(room.EventFail, "%s tried to speak IC in a lobby.", c.LongString())
+		srv.tell(c, "ic.lobby")
+		return false
+	}
+	return true
+}
+
+// Checks whether the type of the client's room allows changing the music. In lobbies,
+// only users with the music permission can, regardless of invites.
+func (srv *SCServer) roomAllowsMusic(c *client.Client) (ok bool, reason string) {
+	if c.Room().IsLobby() && !c.HasPerms(perms.Music) {
+		return false, srv.tr(c, "music.lobby")
+	}
+	return true, ""
+}
+
+// Checks whether the type of the client's room allows the command. If not, tells the
+// client why.
+func (srv *SCServer) roomAllowsCommand(c *client.Client, name string) bool {
+	if _, blocked := lobbyBlocked[name]; blocked && c.Room().IsLobby() {
+		c.Room().LogEvent(room.EventFail, "%s tried running command '/%s' in a lobby.", c.LongString(), name)
+		srv.tell(c, "cmd.lobby", name)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// The server-wide lockdown, for responding to raids: while it's on, every room is
+// locked and only staff can join. Its methods can be called from multiple goroutines.
+type lockdown struct {
+	on    bool
+	locks map[*room.Room]room.LockState // the locks rooms had before, restored when it's lifted
+	mu    sync.Mutex
+}
+
+func newLockdown() *lockdown {
+	return &lockdown{}
+}
+
+// Starts the lockdown, locking every room. Returns `false` if it was already on.
+func (l *lockdown) start(rooms []*room.Room) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.on {
+		return false
+	}
+	l.on = true
+	l.locks = make(map[*room.Room]room.LockState, len(rooms))
+	for _, r := range rooms {
+		l.locks[r] = r.LockState()
+		r.SetLockState(room.LockLocked)
+	}
+	return true
+}
+
+// Lifts the lockdown, giving every room back the lock it had before. Returns `false`
+// if it wasn't on.
+func (l *lockdown) lift() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.on {
+		return false
+	}
+	for r, lock := range l.locks {
+		r.SetLockState(lock)
+	}
+	l.on = false
+	l.locks = nil
+	return true
+}
+
+func (l *lockdown) active() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.on
+}
+
+// Checks whether the client is kept from joining by a lockdown. Staff always get in.
+func (srv *SCServer) lockedOut(c *client.Client) bool {
+	return srv.lockdown.active() && !srv.isStaff(c)
+}
+
+// Checks whether the client is staff, i.e. it has any permission beyond the room ones,
+// or it may use reserved slots.
+func (srv *SCServer) hasStaffPerms(c *client.Client) bool {
+	return c.Perms()&^perms.RoomMask != 0 || srv.isStaff(c)
+}
+
+func (srv *SCServer) cmdLockdown(c *client.Client, args []string) (string, bool) {
+	if len(args) == 0 {
+		if srv.lockdown.active() {
+			return srv.tr(c, "lockdown.on"), false
+		}
+		return srv.tr(c, "lockdown.off"), false
+	}
+	switch args[0] {
+	case "on":
+		if srv.lockdown.active() {
+			return srv.tr(c, "lockdown.already"), false
+		}
+		return srv.confirm(c, "lockdown.confirm", nil, func() string {
+			if !srv.lockdown.start(srv.rooms) {
+				return srv.tr(c, "lockdown.already")
+			}
+			srv.sendRoomUpdateAll(packets.UpdateLock)
+			for cl := range srv.clients.ClientsJoined() {
+				srv.tell(cl, "lockdown.started")
+			}
+			srv.logger.Infof("%s locked down the server.", c.LongString())
+			c.Room().LogEvent(room.EventMod, "%s locked down the server.", c.LongString())
+			return srv.tr(c, "lockdown.done")
+		}), false
+	case "off":
+		if !srv.lockdown.lift() {
+			return srv.tr(c, "lockdown.off"), false
+		}
+		srv.sendRoomUpdateAll(packets.UpdateLock)
+		for cl := range srv.clients.ClientsJoined() {
+			srv.tell(cl, "lockdown.lifted")
+		}
+		srv.logger.Infof("%s lifted the lockdown.", c.LongString())
+		c.Room().LogEvent(room.EventMod, "%s lifted the lockdown.", c.LongString())
+		return "", false
+	}
+	return "", true
+}
+
+func (srv *SCServer) cmdKickRoom(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) > 0 {
+		query := strings.Join(args, " ")
+		if r = srv.findRoom(query); r == nil {
+			return srv.tr(c, "cmd.no_room", query), false
+		}
+	}
+	return srv.confirm(c, "kickroom.confirm", []any{r.ID(), r.Name()}, func() string {
+		var kicked int
+		for _, cl := range srv.getClientsInRoom(r) {
+			if srv.hasStaffPerms(cl) {
+				continue
+			}
+			srv.kickClient(cl, srv.tr(cl, "kickroom.reason"), c.String())
+			kicked++
+		}
+		srv.logger.Infof("%s kicked %v user(s) from [%v] %v.", c.LongString(), kicked, r.ID(), r.Name())
+		r.LogEvent(room.EventMod, "%s kicked %v user(s) from the room.", c.LongString(), kicked)
+		return srv.tr(c, "kickroom.done", kicked, r.ID(), r.Name())
+	}), false
+}
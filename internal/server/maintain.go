@@ -0,0 +1,64 @@
+package server
+
+import (
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/db"
+)
+
+// How often old records are pruned, if any retention is set.
+const pruneInterval = 24 * time.Hour
+
+// Returns the configured retention.
+func (srv *SCServer) retention() db.Retention {
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+	r := srv.config.Retention
+	return db.Retention{
+		Mutes:         days(r.Mutes),
+		Bans:          days(r.Bans),
+		IPBans:        days(r.IPBans),
+		RoleGrants:    days(r.RoleGrants),
+		ModCalls:      days(r.ModCalls),
+		Warnings:      days(r.Warnings),
+		LoginFailures: days(r.LoginFailures),
+	}
+}
+
+// Prunes old records at startup and then once a day, if any retention is set.
+func (srv *SCServer) pruneRecords() {
+	if srv.retention() == (db.Retention{}) {
+		return
+	}
+	for {
+		srv.pruneDB()
+		time.Sleep(pruneInterval)
+	}
+}
+
+// Deletes the records older than the configured retention.
+func (srv *SCServer) pruneDB() (db.Pruned, error) {
+	pruned, err := srv.db.Prune(srv.retention())
+	if err != nil {
+		srv.reportError("%v", err)
+		return pruned, err
+	}
+	if n := pruned.Total(); n > 0 {
+		srv.logger.Infof("Pruned %v old record(s) from the database.", n)
+	}
+	return pruned, nil
+}
+
+// Prunes old records and then vacuums the database.
+func (srv *SCServer) maintainDB() (db.Pruned, error) {
+	pruned, err := srv.pruneDB()
+	if err != nil {
+		return pruned, err
+	}
+	start := time.Now()
+	if err := srv.db.Vacuum(); err != nil {
+		srv.reportError("%v", err)
+		return pruned, err
+	}
+	srv.logger.Infof("Vacuumed the database in %v.", time.Since(start).Round(time.Millisecond))
+	return pruned, nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// How many unacknowledged mod calls are kept. When the queue is full, the oldest call is dropped.
+const maxPendingModCalls = 50
+
+// A mod call that hasn't been acknowledged by a moderator yet.
+type modCall struct {
+	id     int
+	room   string // formatted as "[{ID}] {name}"
+	caller string
+	reason string
+	time   time.Time
+}
+
+// Returns a one-line summary of the mod call, as shown to moderators.
+func (m modCall) String() string {
+	return fmt.Sprintf("#%v (%s ago) in %s by %s. Reason: %s",
+		m.id, time.Since(m.time).Round(time.Second), m.room, m.caller, m.reason)
+}
+
+// Holds the pending mod calls. Its methods can be called from multiple goroutines.
+type modCallQueue struct {
+	calls  []modCall
+	nextID int
+	mu     sync.Mutex
+}
+
+func newModCallQueue() *modCallQueue {
+	return &modCallQueue{nextID: 1}
+}
+
+// Adds a new mod call to the queue, returning it with its assigned ID.
+func (q *modCallQueue) add(room string, caller string, reason string) modCall {
+	return q.addAt(room, caller, reason, time.Now())
+}
+
+// Like [modCallQueue.add], for a mod call made at time `t`, e.g. one recorded before the
+// server restarted.
+func (q *modCallQueue) addAt(room string, caller string, reason string, t time.Time) modCall {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	call := modCall{
+		id:     q.nextID,
+		room:   room,
+		caller: caller,
+		reason: reason,
+		time:   t,
+	}
+	q.nextID++
+	if len(q.calls) >= maxPendingModCalls {
+		q.calls = q.calls[1:]
+	}
+	q.calls = append(q.calls, call)
+	return call
+}
+
+// Removes the mod call with the passed ID from the queue. If it isn't pending, `ok` is `false`.
+func (q *modCallQueue) ack(id int) (call modCall, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, c := range q.calls {
+		if c.id == id {
+			q.calls = append(q.calls[:i], q.calls[i+1:]...)
+			return c, true
+		}
+	}
+	return modCall{}, false
+}
+
+// Returns a copy of the pending mod calls, oldest first.
+func (q *modCallQueue) pending() []modCall {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	calls := make([]modCall, len(q.calls))
+	copy(calls, q.calls)
+	return calls
+}
+
+// Queues the mod calls recorded while no moderator was online, so they survive restarts.
+// They stay recorded until a moderator logs in and sees them.
+func (srv *SCServer) loadModCalls() error {
+	calls, err := srv.db.GetModCalls()
+	if err != nil {
+		return err
+	}
+	for _, m := range calls {
+		srv.modcalls.addAt(m.Room, m.Caller, m.Reason, m.Time)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/lambdcalculus/scs/internal/client"
+)
+
+// The key the MOTD is stored under in the database when it's changed at runtime.
+const motdSetting = "motd"
+
+// The message of the day. It starts as the configured one, unless it was changed
+// with /setmotd, in which case the change is kept in the database.
+type motd struct {
+	text string
+	mu   sync.Mutex
+}
+
+func (m *motd) get() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.text
+}
+
+func (m *motd) set(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.text = text
+}
+
+// Sends the welcome messages to the client if its IPID has never been seen before.
+func (srv *SCServer) welcome(c *client.Client) {
+	first, err := srv.db.SeeIPID(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("%v", err)
+		return
+	}
+	if !first || !srv.config.Welcome.Enabled {
+		return
+	}
+	srv.logger.Infof("%s is here for the first time.", c.LongString())
+	for _, msg := range srv.config.Welcome.Messages {
+		srv.sendServerMessage(c, "%s", msg)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Keeps track of when the songs playing in each room end, so the next song in the
+// room's queue can be played.
+type musicTimers struct {
+	timers map[*room.Room]*songTimer
+	mu     sync.Mutex
+}
+
+type songTimer struct {
+	timer *time.Timer
+}
+
+// Checks whether the client can change the music in its room. If not, returns the
+// reason, to be sent to the client.
+func (srv *SCServer) canPlayMusic(c *client.Client) (ok bool, reason string) {
+	r := c.Room()
+	if c.MuteState()&client.MutedMusic != 0 {
+		return false, "You are muted from playing music."
+	}
+	if ok, reason := srv.roomAllowsMusic(c); !ok {
+		return false, reason
+	}
+	if (r.LockState() == room.LockSpec) && !r.IsInvited(c.UID()) {
+		return false, "You are only allowed to spectate in this area."
+	}
+	if r.MusicLocked() && !r.IsInvited(c.UID()) && c.EffectivePerms()&(perms.Music|perms.Play) == 0 {
+		return false, "The music in this room is locked."
+	}
+	return true, ""
+}
+
+// Plays a song in the room as the passed CID and showname, then schedules the next
+// song in the queue for when it would loop.
+func (srv *SCServer) playSong(r *room.Room, song string, cid int, showname string, effects packets.SongEffect) {
+	r.SetSong(song)
+	srv.writeToRoomAO(r, "MC", song, strconv.Itoa(cid), showname, "1", "0", strconv.Itoa(int(effects)))
+	srv.scheduleQueue(r, song)
+}
+
+// Plays the next song in the room's queue. Returns `false` if the queue is empty.
+func (srv *SCServer) playNext(r *room.Room) bool {
+	song, ok := r.Dequeue()
+	if !ok {
+		return false
+	}
+	// As in [client.Client.UpdateSong], the room itself plays the song.
+	srv.playSong(r, song, room.SpectatorCID, r.Name(), packets.EffectDefault)
+	srv.tellRoom(r, "music.from_queue", song)
+	srv.events.Publish(events.Event{Kind: events.Music, Room: r, Name: r.Name(), Text: song})
+	return true
+}
+
+// Replaces the room's timer with one for the end of the passed song. If the song's
+// length isn't known, the queue waits for a skip.
+func (srv *SCServer) scheduleQueue(r *room.Room, song string) {
+	srv.music.mu.Lock()
+	defer srv.music.mu.Unlock()
+	if old, ok := srv.music.timers[r]; ok {
+		old.timer.Stop()
+		delete(srv.music.timers, r)
+	}
+	length, ok := srv.songLengths[song]
+	if !ok || song == packets.SongStop {
+		return
+	}
+	st := &songTimer{}
+	st.timer = time.AfterFunc(length, func() { srv.songEnded(r, st) })
+	srv.music.timers[r] = st
+}
+
+// Called when a song would loop. Plays the next queued song, or lets the song
+// loop and waits for it to end again.
+func (srv *SCServer) songEnded(r *room.Room, st *songTimer) {
+	srv.music.mu.Lock()
+	current := srv.music.timers[r] == st
+	srv.music.mu.Unlock()
+	if !current {
+		// Another song was played in the meantime.
+		return
+	}
+	if !srv.playNext(r) {
+		srv.scheduleQueue(r, r.Song())
+	}
+}
+
+// Searches for songs matching the query in the list, ignoring case. An exact match is
+// returned alone. Otherwise, songs containing the query are returned, and if there
+// are none, songs containing the query's characters in order (e.g. "pwobj" matches
+// "PW Objection.opus"). Categories are never matched.
+func searchSongs(list []string, query string) []string {
+	query = strings.ToLower(query)
+	var substr, subseq []string
+	for _, s := range list {
+		if !strings.Contains(s, ".") {
+			continue
+		}
+		lower := strings.ToLower(s)
+		switch {
+		case lower == query:
+			return []string{s}
+		case strings.Contains(lower, query):
+			substr = append(substr, s)
+		case isSubsequence(query, lower):
+			subseq = append(subseq, s)
+		}
+	}
+	if len(substr) > 0 {
+		return substr
+	}
+	return subseq
+}
+
+// Checks whether all runes of `sub` appear in `s`, in order.
+func isSubsequence(sub string, s string) bool {
+	rs := []rune(sub)
+	if len(rs) == 0 {
+		return true
+	}
+	for _, r := range s {
+		if r == rs[0] {
+			rs = rs[1:]
+			if len(rs) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// The kinds of mutes that can be passed to /mute, and how they're called in notices.
+var muteKinds = map[string]struct {
+	state client.MuteState
+	name  string
+}{
+	"ic":    {client.MutedIC, "IC"},
+	"ooc":   {client.MutedOOC, "OOC"},
+	"music": {client.MutedMusic, "music"},
+	"judge": {client.MutedJudge, "judge"},
+	"all":   {client.MutedIC | client.MutedOOC | client.MutedMusic | client.MutedJudge, "fully"},
+}
+
+func (srv *SCServer) cmdMute(c *client.Client, args []string) (string, bool) {
+	shadow := false
+	if args[0] == "--shadow" {
+		shadow = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return "", true
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return srv.tr(c, "cmd.bad_uid", args[0]), false
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return srv.tr(c, "cmd.no_uid", id), false
+	}
+	args = args[1:]
+
+	kind := "all"
+	if !shadow && len(args) > 0 {
+		if _, ok := muteKinds[strings.ToLower(args[0])]; ok {
+			kind = strings.ToLower(args[0])
+			args = args[1:]
+		}
+	}
+	var dur time.Duration
+	if def := srv.config.Durations.DefaultMute; def != "" {
+		// Checked when the server was made.
+		dur, _ = srv.banDuration(def)
+	}
+	if len(args) > 0 {
+		if d, err := srv.banDuration(args[0]); err == nil {
+			dur = d
+			args = args[1:]
+		}
+	}
+	reason := strings.Join(args, " ")
+	if reason == "" {
+		reason = "No reason given."
+	}
+
+	m, name := muteKinds[kind].state, muteKinds[kind].name
+	if shadow {
+		m, name = client.MutedShadow, "shadow"
+	}
+	var end time.Time
+	if dur > 0 {
+		end = time.Now().Add(dur)
+	}
+	target.MuteUntil(m, end)
+	if err := srv.db.AddMute(target.IPID(), name, shadow, reason, c.String(), dur); err != nil {
+		srv.logger.Warnf("server: Couldn't record mute (%s).", err)
+	}
+	if !shadow {
+		srv.sendServerMessage(target, "%s", srv.fillNotice(srv.config.Templates.Mute,
+			notice{reason: reason, moderator: c.String(), kind: name, duration: dur, forever: dur == 0}))
+	}
+
+	length := "until unmuted"
+	if dur > 0 {
+		length = "for " + dur.String()
+	}
+	target.Room().LogEvent(room.EventMod, "%s %v muted %s %v. Reason: %s", c.LongString(), name, target.LongString(), length, reason)
+	if dur > 0 {
+		srv.alertMods("alert.muted_for", c.String(), name, target.LongString(), dur, reason)
+		return srv.tr(c, "mute.muted_for", strings.ToUpper(name[:1])+name[1:], id, dur), false
+	}
+	srv.alertMods("alert.muted", c.String(), name, target.LongString(), reason)
+	return srv.tr(c, "mute.muted", strings.ToUpper(name[:1])+name[1:], id), false
+}
+
+func (srv *SCServer) cmdUnmute(c *client.Client, args []string) (string, bool) {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return srv.tr(c, "cmd.bad_uid", args[0]), false
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return srv.tr(c, "cmd.no_uid", id), false
+	}
+	if target.MuteState() == client.Unmuted {
+		return srv.tr(c, "mute.not_muted", id), false
+	}
+	shadow := target.MuteState()&client.MutedShadow != 0
+	target.SetMute(client.Unmuted)
+	if err := srv.db.LiftMutes(target.IPID()); err != nil {
+		srv.logger.Warnf("server: Couldn't record unmute (%s).", err)
+	}
+	if !shadow {
+		srv.tell(target, "mute.unmuted")
+	}
+	target.Room().LogEvent(room.EventMod, "%s unmuted %s.", c.LongString(), target.LongString())
+	return srv.tr(c, "mute.unmuted_uid", id), false
+}
+
+// Returns the mute state recorded under a mute's name, e.g. "IC" or "shadow".
+func muteState(name string) client.MuteState {
+	if name == "shadow" {
+		return client.MutedShadow
+	}
+	for _, k := range muteKinds {
+		if k.name == name {
+			return k.state
+		}
+	}
+	return client.Unmuted
+}
+
+// Mutes a joining client again with the mutes its IPID still has, so they can't be
+// escaped by reconnecting.
+func (srv *SCServer) restoreMutes(c *client.Client) {
+	mutes, err := srv.db.ActiveMutes(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Couldn't check mutes (%s).", err)
+		return
+	}
+	for _, m := range mutes {
+		c.MuteUntil(muteState(m.Kind), m.End)
+	}
+	if len(mutes) > 0 {
+		c.Room().LogEvent(room.EventMod, "%s is still %v muted.", c.LongString(), muteNames(c.MuteState()))
+	}
+}
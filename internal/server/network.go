@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
 	"github.com/lambdcalculus/scs/pkg/logger"
 	"github.com/lambdcalculus/scs/pkg/packets"
 )
@@ -19,7 +21,7 @@ import (
 func (srv *SCServer) listenTCP() {
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%v", srv.config.PortTCP))
 	if err != nil {
-		srv.logger.Errorf("Couldn't listen on TCP (%v).", err)
+		srv.reportError("Couldn't listen on TCP (%v).", err)
 		return
 	}
 	srv.logger.Infof("Listening TCP on port %v.", srv.config.PortTCP)
@@ -31,6 +33,11 @@ func (srv *SCServer) listenTCP() {
 			logger.Errorf("TCP listener error (%v).", err)
 			break
 		}
+		if ban, ok := srv.checkIPBan(conn.RemoteAddr().String()); ok {
+			srv.logger.Debugf("Refused TCP connection from %v (IP ban #%v).", conn.RemoteAddr(), ban.BanID)
+			conn.Close()
+			continue
+		}
 		c := client.NewTCPClient(conn, srv.logger)
 		srv.logger.Debugf("New TCP connection from %v (IPID: %v).", c.Addr(), c.IPID())
 
@@ -38,15 +45,87 @@ func (srv *SCServer) listenTCP() {
 	}
 }
 
+// Checks whether the client is allowed to connect by the country/ASN policy.
+func (srv *SCServer) checkGeo(c *client.Client) bool {
+	host, _, err := net.SplitHostPort(c.Addr())
+	if err != nil {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	ok, reason, err := srv.geo.Check(ip, c.IPID())
+	if err != nil {
+		srv.logger.Debugf("server: Error looking up %v (IPID: %v) (%v).", c.Addr(), c.IPID(), err)
+	}
+	if !ok {
+		srv.logger.Infof("Refused connection from %v (IPID: %v): %s.", c.Addr(), c.IPID(), reason)
+	}
+	return ok
+}
+
+// Bans a range of raw IPs and disconnects the clients in it. `cidr` may be a single IP
+// or a range in CIDR notation. Returns the ID of the new ban.
+func (srv *SCServer) banIPRange(cidr string, duration string, reason string, moderator string) (int, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return 0, fmt.Errorf("server: '%v' is not a valid IP or CIDR range.", cidr)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, fmt.Errorf("server: '%v' is not a valid IP or CIDR range.", cidr)
+	}
+	dur, err := srv.banDuration(duration)
+	if err != nil {
+		return 0, fmt.Errorf("server: '%v' is not a valid duration.", duration)
+	}
+	id, err := srv.db.AddIPBan(ipnet.String(), reason, moderator, dur)
+	if err != nil {
+		return 0, err
+	}
+	if err := srv.refreshIPBans(); err != nil {
+		srv.reportError("%v", err)
+	}
+	srv.logger.Infof("%v banned the IP range %v for %v (ban #%v). Reason: %s", moderator, ipnet, dur, id, reason)
+	srv.events.Publish(events.Event{Kind: events.Ban, Actor: moderator, Target: fmt.Sprintf("IP range ban #%v", id),
+		Text: reason, Duration: dur})
+
+	for c := range srv.clients.Clients() {
+		host, _, err := net.SplitHostPort(c.Addr())
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && ipnet.Contains(ip) {
+			srv.kickBanned(c, reason, moderator, dur)
+		}
+	}
+	return id, nil
+}
+
 // Handles new raw TCP connections. Only used by legacy (AO) clients.
 func (srv *SCServer) handleTCPClient(c *client.Client) {
 	srv.clients.Add(c)
 	defer srv.removeClient(c)
+	if !srv.checkGeo(c) {
+		return
+	}
 
 	// to this day, this is part of the handshake. lovely.
 	c.WriteAO("decryptor", "DEPRECATED")
 	for {
 		p, err := c.ReadAO()
+		if client.IsTimeout(err) {
+			srv.logger.Debugf("Connection from %v (IPID: %v) timed out.", c.Addr(), c.IPID())
+			break
+		}
 		if err != nil {
 			srv.logger.Debugf("Error in connection from %v (IPID: %v): %s.", c.Addr(), c.IPID(), err)
 		}
@@ -57,7 +136,10 @@ func (srv *SCServer) handleTCPClient(c *client.Client) {
 			break
 		}
 		srv.logger.Tracef("Received message from %v (IPID: %v) via TCP: %#v", c.Addr(), c.IPID(), *p)
-		go srv.handlePacketAO(c, *p)
+		// Packets are handled in the read loop, so each client's packets are processed in
+		// the order they were sent. Writes are queued, so this doesn't wait on the network,
+		// and other clients are handled concurrently by their own loops.
+		srv.handlePacketAO(c, *p)
 	}
 }
 
@@ -71,6 +153,16 @@ var (
 func (srv *SCServer) listenWS() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/DATA", srv.dataEndpoint)
+	mux.HandleFunc("/healthz", srv.healthEndpoint)
+	if srv.config.TranscriptURL != "" {
+		mux.Handle("/transcripts/", http.StripPrefix("/transcripts/", http.FileServer(transcriptDir{})))
+	}
+	if srv.config.API.Token != "" {
+		srv.handleAPI(mux)
+	}
+	if srv.config.Admin.Enabled && srv.config.Admin.Port == 0 {
+		srv.handleAdmin(mux, "/admin/")
+	}
 	mux.HandleFunc("/", srv.wsEndpoint)
 	wsServer := &http.Server{
 		Addr:           fmt.Sprintf(":%v", srv.config.PortWS),
@@ -81,7 +173,7 @@ func (srv *SCServer) listenWS() {
 	}
 	// TODO: add a file server
 	srv.logger.Infof("Listening WS on port %v.", srv.config.PortWS)
-	srv.logger.Errorf("Stopped serving WS: %v.", wsServer.ListenAndServe())
+	srv.reportError("Stopped serving WS: %v.", wsServer.ListenAndServe())
 }
 
 // The handler for the '/' endpoint, for WebSocket connections to the server by
@@ -89,6 +181,11 @@ func (srv *SCServer) listenWS() {
 func (srv *SCServer) wsEndpoint(w http.ResponseWriter, r *http.Request) {
 	// TODO: set deadline for IO ops?
 	// TODO: actually check the origin
+	if ban, ok := srv.checkIPBan(r.RemoteAddr); ok {
+		srv.logger.Debugf("WS: (/) Refused connection from %v (IP ban #%v).", r.RemoteAddr, ban.BanID)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -107,39 +204,76 @@ func (srv *SCServer) wsEndpoint(w http.ResponseWriter, r *http.Request) {
 func (srv *SCServer) handleWSClient(c *client.Client) {
 	srv.clients.Add(c)
 	defer srv.removeClient(c)
+	if !srv.checkGeo(c) {
+		return
+	}
 	if err := srv.validateClient(c); err != nil {
 		srv.logger.Debugf("Couldn't determine client type from %v (IPID: %v) (%v). Disconnecting.", c.Addr(), c.IPID(), err)
 		return
 	}
 
+	// As with TCP clients, packets are handled in order, in the read loop.
 	switch c.Type() {
 	case client.AOClient:
 		for {
 			p, err := c.ReadAO()
 			if err != nil {
-				srv.logger.Debugf("Error in connection to %v (IPID: %v): %v.", c.Addr(), c.IPID(), err)
+				srv.logWSReadError(c, err)
 				return
 			}
 			srv.logger.Tracef("Received message from %v (IPID: %v) via WS: %#v", c.Addr(), c.IPID(), *p)
-			go srv.handlePacketAO(c, *p)
+			srv.handlePacketAO(c, *p)
 		}
 	case client.SCClient:
-		for {
-			p, err := c.ReadSC()
-			if err != nil {
-				if errors.Is(err, &json.SyntaxError{}) || errors.Is(err, &json.UnmarshalTypeError{}) {
-					srv.logger.Debugf("Bad JSON by %v (IPID: %v) (%v).", c.Addr(), c.IPID(), err)
-					continue
+		// Packets are read in their own goroutine, so the loop can also run the tasks
+		// posted to the client (e.g. its admission from the wait queue) in between them.
+		read := make(chan *packets.PacketSC)
+		failed := make(chan error, 1)
+		go func() {
+			for {
+				p, err := c.ReadSC()
+				if err != nil {
+					if errors.Is(err, &json.SyntaxError{}) || errors.Is(err, &json.UnmarshalTypeError{}) {
+						srv.logger.Debugf("Bad JSON by %v (IPID: %v) (%v).", c.Addr(), c.IPID(), err)
+						continue
+					}
+					failed <- err
+					return
 				}
-				srv.logger.Debugf("Error in connection to %v (IPID: %v): %v.", c.Addr(), c.IPID(), err)
-				break
+				read <- p
+			}
+		}()
+		for {
+			select {
+			case p := <-read:
+				srv.logger.Tracef("Received message from %v (IPID: %v) via WS: %#v", c.Addr(), c.IPID(), *p)
+				srv.handlePacketSC(c, *p)
+			case f := <-c.Tasks():
+				f()
+			case err := <-failed:
+				srv.logWSReadError(c, err)
+				return
 			}
-			srv.logger.Tracef("Received message from %v (IPID: %v) via WS: %#v", c.Addr(), c.IPID(), *p)
-			go srv.handlePacketSC(c, *p)
 		}
 	}
 }
 
+// Logs why reading from a WebSocket client failed, telling clean exits apart from
+// timeouts and network errors.
+func (srv *SCServer) logWSReadError(c *client.Client, err error) {
+	var closeErr *websocket.CloseError
+	switch {
+	case client.IsTimeout(err):
+		srv.logger.Debugf("Connection to %v (IPID: %v) timed out.", c.Addr(), c.IPID())
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway):
+		srv.logger.Debugf("%v (IPID: %v) closed the connection.", c.Addr(), c.IPID())
+	case errors.As(err, &closeErr):
+		srv.logger.Debugf("%v (IPID: %v) closed the connection with code %v (%s).", c.Addr(), c.IPID(), closeErr.Code, closeErr.Text)
+	default:
+		srv.logger.Debugf("Error in connection to %v (IPID: %v): %v.", c.Addr(), c.IPID(), err)
+	}
+}
+
 // Validates a client as an AO or SC client.
 // Returns an error if the type can't be identified.
 func (srv *SCServer) validateClient(c *client.Client) error {
@@ -180,17 +314,26 @@ loop:
 	}
 
 	if p := packets.MakeAOPacket(data); p.Header == "HI" {
+		if !srv.config.AllowAO {
+			c.WriteAO("BD", "This server doesn't accept Attorney Online clients.")
+			c.SetCloseReason(client.ClosePolicy, "This server doesn't accept Attorney Online clients.")
+			return fmt.Errorf("AO clients aren't allowed.")
+		}
 		c.SetType(client.AOClient)
 		srv.logger.Tracef("Received message from %v (IPID: %v) via WS: %s", c.Addr(), c.IPID(), data)
-		go srv.handlePacketAO(c, p)
+		srv.handlePacketAO(c, p)
 		return nil
 	}
 
 	p, err := packets.MakeSCPacket(data)
 	if err == nil && p.Header == "hello" {
+		if !srv.config.AllowSC {
+			c.SetCloseReason(client.ClosePolicy, "This server doesn't accept SpriteChat clients.")
+			return fmt.Errorf("SC clients aren't allowed.")
+		}
 		c.SetType(client.SCClient)
 		srv.logger.Tracef("Received message from %v (IPID: %v) via WS: %#v", c.Addr(), c.IPID(), p)
-		go srv.handlePacketSC(c, p)
+		srv.handlePacketSC(c, p)
 		return nil
 	}
 	return fmt.Errorf("Client is neither AO nor SC (%v).", err)
@@ -199,6 +342,10 @@ loop:
 // Handles the '/DATA' endpoint used by the SpriteChat client. It sends the server
 // data and disconnects.
 func (srv *SCServer) dataEndpoint(w http.ResponseWriter, r *http.Request) {
+	if !srv.config.AllowSC {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -211,8 +358,8 @@ func (srv *SCServer) dataEndpoint(w http.ResponseWriter, r *http.Request) {
 	reply := packets.PacketSC{
 		Header: "SERVERHELLO",
 		Data: packets.DataHelloServer{
-			App:      "scs",
-			Version:  "alpha",
+			App:      appName,
+			Version:  appVersion,
 			Name:     srv.config.Name,
 			Desc:     srv.config.Desc,
 			Players:  srv.clients.SizeJoined(),
@@ -229,3 +376,33 @@ func (srv *SCServer) dataEndpoint(w http.ResponseWriter, r *http.Request) {
 	}
 	srv.logger.Debugf("WS: (/DATA) Sent data to %s.", r.RemoteAddr)
 }
+
+// The reply to the '/healthz' endpoint.
+type healthReply struct {
+	Status     string `json:"status"`
+	Uptime     int64  `json:"uptime"` // in seconds
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"`
+	Version    string `json:"version"`
+}
+
+// Handles the '/healthz' endpoint, which lets hosting panels and container orchestrators
+// check if the server is alive through plain HTTP.
+func (srv *SCServer) healthEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	reply := healthReply{
+		Status:     "ok",
+		Uptime:     int64(time.Since(srv.start).Seconds()),
+		Players:    srv.clients.SizeJoined(),
+		MaxPlayers: srv.config.MaxPlayers,
+		Version:    appName + " " + appVersion,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		srv.logger.Debugf("HTTP: (/healthz) Error writing response to %s (%v).", r.RemoteAddr, err)
+	}
+}
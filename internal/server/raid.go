@@ -0,0 +1,157 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Raid mode: while it's on, new players must log in (or enter the raid password) before
+// playing, IPIDs never seen before may be turned away, and the cooldowns and spam limits
+// are stricter. Its methods can be called from multiple goroutines.
+type raidMode struct {
+	on    bool
+	until time.Time // zero if it lasts until it's turned off
+	timer *time.Timer
+	mu    sync.Mutex
+}
+
+func newRaidMode() *raidMode {
+	return &raidMode{}
+}
+
+// Turns raid mode on, replacing any previous duration. If `dur` is positive, it's turned
+// off after that long and `expire` is called.
+func (m *raidMode) start(dur time.Duration, expire func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.on = true
+	m.until = time.Time{}
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	if dur <= 0 {
+		return
+	}
+	m.until = time.Now().Add(dur)
+	var t *time.Timer
+	t = time.AfterFunc(dur, func() {
+		m.mu.Lock()
+		current := m.timer == t
+		m.mu.Unlock()
+		// A timer stopped too late, after raid mode was restarted, does nothing.
+		if current && m.stop() {
+			expire()
+		}
+	})
+	m.timer = t
+}
+
+// Turns raid mode off. Returns `false` if it wasn't on.
+func (m *raidMode) stop() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.on {
+		return false
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.on = false
+	m.until = time.Time{}
+	return true
+}
+
+// Returns whether raid mode is on, and when it ends (zero if it lasts until turned off).
+func (m *raidMode) state() (on bool, until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.on, m.until
+}
+
+func (m *raidMode) active() bool {
+	on, _ := m.state()
+	return on
+}
+
+// Checks whether raid mode turns the client away: if set to, IPIDs never seen before
+// can't join. Staff always get in.
+func (srv *SCServer) raidRejects(c *client.Client) bool {
+	if !srv.raid.active() || !srv.config.Raid.RejectNew || srv.isStaff(c) {
+		return false
+	}
+	known, err := srv.db.KnownIPID(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Error checking IPID (%s).", err)
+		return false
+	}
+	return !known
+}
+
+// Returns the cooldown of `secs` seconds, made stricter while raid mode is on.
+func (srv *SCServer) cooldown(secs int) time.Duration {
+	d := time.Duration(secs) * time.Second
+	if srv.raid.active() {
+		d *= time.Duration(max(srv.config.Raid.CooldownFactor, 1))
+	}
+	return d
+}
+
+// Returns the spam settings, with the limits made stricter while raid mode is on.
+// Limits that are off stay off.
+func (srv *SCServer) spamLimits() config.Spam {
+	conf := srv.config.Spam
+	if !srv.raid.active() {
+		return conf
+	}
+	factor := max(srv.config.Raid.CooldownFactor, 1)
+	for _, limit := range []*int{&conf.MaxRepeats, &conf.MaxSimilar, &conf.MaxCaps} {
+		if *limit > 0 {
+			*limit = max(*limit/factor, 1)
+		}
+	}
+	return conf
+}
+
+func (srv *SCServer) cmdRaidMode(c *client.Client, args []string) (string, bool) {
+	if len(args) == 0 {
+		on, until := srv.raid.state()
+		switch {
+		case !on:
+			return srv.tr(c, "raid.off"), false
+		case until.IsZero():
+			return srv.tr(c, "raid.on"), false
+		}
+		return srv.tr(c, "raid.on_until", until.Format(time.DateTime)), false
+	}
+
+	var dur time.Duration
+	switch args[0] {
+	case "off":
+		if !srv.raid.stop() {
+			return srv.tr(c, "raid.not_on"), false
+		}
+		srv.alertMods("alert.raid_off", c.String())
+		c.Room().LogEvent(room.EventMod, "%s turned raid mode off.", c.LongString())
+		return "", false
+	case "on":
+	default:
+		var err error
+		if dur, err = parseDuration(args[0]); err != nil {
+			return "", true
+		}
+	}
+	srv.raid.start(dur, func() { srv.alertMods("alert.raid_ended") })
+	if dur > 0 {
+		srv.alertMods("alert.raid_on_for", c.String(), dur)
+	} else {
+		srv.alertMods("alert.raid_on", c.String())
+	}
+	c.Room().LogEvent(room.EventMod, "%s turned raid mode on.", c.LongString())
+	return "", false
+}
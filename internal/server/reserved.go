@@ -0,0 +1,35 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/lambdcalculus/scs/internal/client"
+)
+
+// Checks whether the server is too full for the client to join. Once `max_players`
+// is reached, only staff may take the reserved slots.
+func (srv *SCServer) serverFull(c *client.Client) bool {
+	// Clients admitted from the queue have a slot waiting for them.
+	joined := srv.clients.SizeJoined() + srv.waits.held(c)
+	if joined < srv.config.MaxPlayers {
+		return false
+	}
+	if joined >= srv.config.MaxPlayers+srv.config.ReservedSlots {
+		return true
+	}
+	return !srv.isStaff(c)
+}
+
+// Checks whether the client may use a reserved slot: either its IPID is listed in
+// the config, or it has an active role grant. Clients can't log in before joining,
+// so these are the only ways to tell staff apart.
+func (srv *SCServer) isStaff(c *client.Client) bool {
+	if slices.Contains(srv.config.ReservedIPIDs, c.IPID()) {
+		return true
+	}
+	_, ok, err := srv.db.ActiveRoleGrant(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Error checking role grant (%s).", err)
+	}
+	return ok
+}
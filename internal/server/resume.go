@@ -0,0 +1,259 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/internal/uid"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// The sessions of SC clients that lost their connection, by session token. Until their
+// grace period ends, their UIDs stay taken, and a client reconnecting with the token
+// gets their state back.
+type resumeSessions struct {
+	sessions map[string]*suspended
+	mu       sync.Mutex
+}
+
+// The state of a disconnected SC client.
+type suspended struct {
+	ipid     string
+	uid      int
+	room     *room.Room
+	cid      int
+	charname string
+	showname string
+	username string
+	login    string
+	auth     *perms.Role
+	manager  *perms.Role
+	gates    client.Gate
+	mutes    map[client.MuteState]time.Time
+	timer    *time.Timer
+}
+
+func newResumeSessions() *resumeSessions {
+	return &resumeSessions{sessions: make(map[string]*suspended)}
+}
+
+// Makes a new session token.
+func newSessionToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Keeps the state of a joined SC client that dropped its connection, so it can be
+// resumed within the grace period. Should be called while the client is being removed,
+// before it leaves its room. Returns `false` if the session can't be resumed, in which
+// case the client should be cleaned up as usual.
+func (srv *SCServer) suspendSession(c *client.Client) bool {
+	grace := time.Duration(srv.config.ResumeGrace) * time.Second
+	token := c.Session()
+	// Clients the server disconnected on purpose (e.g. kicks and bans) have a close code.
+	if grace <= 0 || token == "" || c.Type() != client.SCClient || c.UID() == uid.Unjoined || c.CloseCode() != 0 {
+		return false
+	}
+	s := &suspended{
+		ipid:     c.IPID(),
+		uid:      c.UID(),
+		room:     c.Room(),
+		cid:      c.CID(),
+		charname: c.Charname(),
+		showname: c.Showname(),
+		username: c.Username(),
+		login:    c.Login(),
+		auth:     c.Role(client.RoleAuth),
+		manager:  c.Role(client.RoleManager),
+		gates:    c.Gates(),
+		mutes:    c.MuteEnds(),
+	}
+	srv.resume.mu.Lock()
+	defer srv.resume.mu.Unlock()
+	srv.resume.sessions[token] = s
+	s.timer = time.AfterFunc(grace, func() { srv.expireSession(token, s) })
+	srv.logger.Debugf("Keeping the session of UID %v (IPID: %v) for %v.", s.uid, s.ipid, grace)
+	return true
+}
+
+// Ends a suspended session whose grace period is over, freeing its UID.
+func (srv *SCServer) expireSession(token string, s *suspended) {
+	srv.resume.mu.Lock()
+	if srv.resume.sessions[token] != s {
+		// Already resumed.
+		srv.resume.mu.Unlock()
+		return
+	}
+	delete(srv.resume.sessions, token)
+	srv.resume.mu.Unlock()
+
+	for _, r := range srv.rooms {
+		r.ForgetUID(s.uid)
+	}
+	srv.uidHeap.Free(s.uid)
+	srv.logger.Debugf("The session of UID %v (IPID: %v) expired.", s.uid, s.ipid)
+}
+
+// Takes the suspended session with the token, if it exists and belongs to the IPID.
+func (srv *SCServer) takeSession(token string, ipid string) (*suspended, bool) {
+	srv.resume.mu.Lock()
+	defer srv.resume.mu.Unlock()
+	s, ok := srv.resume.sessions[token]
+	if !ok || s.ipid != ipid {
+		return nil, false
+	}
+	delete(srv.resume.sessions, token)
+	s.timer.Stop()
+	return s, true
+}
+
+// Handles the SC 'join' packet, with which a client commits to joining after 'hello'.
+// If the packet has the token of a session that is still suspended, the session is
+// resumed; otherwise, the client joins as a spectator in the landing room.
+func (srv *SCServer) handleJoinSC(c *client.Client, data []byte) {
+	if c.UID() != uid.Unjoined {
+		return
+	}
+	var join packets.DataJoinClient
+	if err := json.Unmarshal(data, &join); err != nil {
+		srv.logger.Debugf("Bad 'join' from %v: %s", c.Addr(), data)
+		return
+	}
+	banned, _, err := srv.db.CheckBanned(c.IPID(), c.Ident())
+	if err != nil {
+		srv.logger.Warnf("server: Error checking ban (%s).", err)
+	}
+	if banned {
+		c.SetCloseReason(client.CloseBanned, "You are banned from this server.")
+		srv.removeClient(c)
+		return
+	}
+	if !srv.hookJoin(c) {
+		srv.logger.Infof("A client (IPID: %v) was stopped from joining by a hook.", c.IPID())
+		c.SetCloseReason(client.ClosePolicy, "You can't join this server.")
+		srv.removeClient(c)
+		return
+	}
+
+	if s, ok := srv.takeSession(join.Session, c.IPID()); ok {
+		srv.resumeSession(c, join.Session, s)
+		return
+	}
+	if srv.lockedOut(c) {
+		c.SetCloseReason(client.ClosePolicy, "The server is in lockdown.")
+		srv.removeClient(c)
+		return
+	}
+	if srv.raidRejects(c) {
+		srv.logger.Infof("A client (IPID: %v) was turned away by raid mode.", c.IPID())
+		c.SetCloseReason(client.ClosePolicy, "The server is in raid mode.")
+		srv.removeClient(c)
+		return
+	}
+
+	if srv.serverFull(c) {
+		// The slot may be freed by any other client, so the join is handed back to this
+		// client's own loop.
+		if srv.enqueue(c, func() { c.Post(func() { srv.handleJoinSC(c, data) }) }) {
+			return
+		}
+		srv.logger.Infof("A client (IPID: %v) couldn't join because the server is full.", c.IPID())
+		c.SetCloseReason(client.CloseFull, "The server is full.")
+		srv.removeClient(c)
+		return
+	}
+	id, err := srv.uidHeap.Take()
+	if err != nil {
+		srv.logger.Infof("A client (IPID: %v) couldn't join because the server is full.", c.IPID())
+		c.SetCloseReason(client.CloseFull, "The server is full.")
+		srv.removeClient(c)
+		return
+	}
+	if !srv.landing.Enter(room.SpectatorCID, id) {
+		srv.uidHeap.Free(id)
+		srv.logger.Infof("A client (IPID: %v) couldn't join because the landing room is full of spectators.", c.IPID())
+		c.SetCloseReason(client.CloseFull, "The server is full.")
+		srv.removeClient(c)
+		return
+	}
+	c.SetUID(id)
+	srv.waits.forget(c)
+	c.SetCID(room.SpectatorCID)
+	c.SetCharname("Spectator")
+	c.SetRoom(srv.landing)
+	c.SetSession(newSessionToken())
+	srv.sendJoined(c, false)
+	srv.logger.Debugf("A client has joined with UID %v.", id)
+	srv.events.Publish(events.Event{Kind: events.Join, Client: c, Room: srv.landing})
+	srv.sendRoomUpdateAll(packets.UpdateAll, srv.landing)
+	srv.afterJoin(c)
+}
+
+// Gives a reconnected client the state of its suspended session. If its character was
+// taken in the meantime, it becomes a spectator, and if its room is full, it goes back
+// to the landing room.
+func (srv *SCServer) resumeSession(c *client.Client, token string, s *suspended) {
+	r, cid, charname := s.room, s.cid, s.charname
+	if !r.Enter(cid, s.uid) {
+		cid, charname = room.SpectatorCID, "Spectator"
+		if !r.Enter(cid, s.uid) {
+			r = srv.landing
+			if !r.Enter(cid, s.uid) {
+				for _, rm := range srv.rooms {
+					rm.ForgetUID(s.uid)
+				}
+				srv.uidHeap.Free(s.uid)
+				c.SetCloseReason(client.CloseFull, "The server is full.")
+				srv.removeClient(c)
+				return
+			}
+		}
+	}
+	c.SetUID(s.uid)
+	srv.waits.forget(c)
+	c.SetCID(cid)
+	c.SetCharname(charname)
+	c.SetShowname(s.showname)
+	c.SetUsername(s.username)
+	c.SetRoom(r)
+	c.SetSession(token)
+	c.SetGates(s.gates)
+	c.SetLogin(s.login)
+	for m, end := range s.mutes {
+		c.MuteUntil(m, end)
+	}
+	if s.auth != nil {
+		srv.addRole(c, client.RoleAuth, s.auth)
+	}
+	if s.manager != nil {
+		srv.addRole(c, client.RoleManager, s.manager)
+	}
+	// Granted roles aren't kept in the session, as their expiry is tied to the client.
+	srv.restoreRoleGrant(c)
+	srv.trackChar(c)
+	srv.sendJoined(c, true)
+	srv.logger.Infof("Client with UID %v (IPID: %v) resumed its session.", s.uid, s.ipid)
+	srv.events.Publish(events.Event{Kind: events.Join, Client: c, Room: r})
+	r.LogEvent(room.EventEnter, "%s reconnected.", c.LongString())
+	srv.sendCharsCheck(r)
+	srv.sendRoomUpdateAll(packets.UpdatePlayer, r)
+}
+
+// Tells an SC client it has joined, and the state it joined with.
+func (srv *SCServer) sendJoined(c *client.Client, resumed bool) {
+	c.WriteSC("JOINED", packets.DataJoinServer{
+		UID:     c.UID(),
+		Room:    c.Room().ID(),
+		CID:     c.CID(),
+		Session: c.Session(),
+		Resumed: resumed,
+	})
+}
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/totp"
 	"github.com/lambdcalculus/scs/pkg/rpc"
 )
 
@@ -12,12 +15,12 @@ import (
 func (srv *SCServer) listenRPC() {
 	s, err := rpc.NewServer(srv, srv.config.PortRPC)
 	if err != nil {
-		srv.logger.Errorf("Couldn't create RPC server (%s).", err)
+		srv.reportError("Couldn't create RPC server (%s).", err)
 		return
 	}
 
 	srv.logger.Infof("Listening RPC on port %v.", srv.config.PortRPC)
-	srv.logger.Errorf("Stopped serving RPC (%v).", s.HTTP.ListenAndServe())
+	srv.reportError("Stopped serving RPC (%v).", s.HTTP.ListenAndServe())
 }
 
 // Adds an user to the auth table in the database.
@@ -43,3 +46,127 @@ func (srv *SCServer) RmAuth(args *rpc.RmAuthArgs, reply *int) error {
 	*reply = 0
 	return nil
 }
+
+// Changes the password of an user in the auth table. The password isn't logged.
+func (srv *SCServer) SetPassword(args *rpc.SetPasswordArgs, reply *int) error {
+	if err := srv.db.UpdatePassword(args.Username, args.Password); err != nil {
+		srv.logger.Infof("rpc: Failed SetPassword request for user '%v'.", args.Username)
+		*reply = 1
+		return err
+	}
+	srv.logger.Infof("rpc: Successful SetPassword request for user '%v'.", args.Username)
+	*reply = 0
+	return nil
+}
+
+// Sets up TOTP for an user in the auth table, replacing any previous secret. The reply
+// is the provisioning URI for the user's authenticator app. If `Disable` is set, the
+// user's secret is removed instead, and the reply is empty.
+func (srv *SCServer) SetupTOTP(args *rpc.SetupTOTPArgs, reply *string) error {
+	if args.Disable {
+		if err := srv.db.RemoveTOTPSecret(args.Username); err != nil {
+			srv.logger.Infof("rpc: Failed SetupTOTP request. Arguments: %#v.", *args)
+			return err
+		}
+		srv.logger.Infof("rpc: Successful SetupTOTP request. Arguments: %#v.", *args)
+		return nil
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return err
+	}
+	if err := srv.db.SetTOTPSecret(args.Username, secret); err != nil {
+		srv.logger.Infof("rpc: Failed SetupTOTP request. Arguments: %#v.", *args)
+		return err
+	}
+	*reply = totp.URI(secret, args.Username, srv.config.Name)
+	srv.logger.Infof("rpc: Successful SetupTOTP request. Arguments: %#v.", *args)
+	return nil
+}
+
+// Bans a range of raw IPs. The reply is the ID of the new ban.
+func (srv *SCServer) AddIPBan(args *rpc.AddIPBanArgs, reply *int) error {
+	id, err := srv.banIPRange(args.CIDR, args.Duration, args.Reason, "serverctl")
+	if err != nil {
+		srv.logger.Infof("rpc: Failed AddIPBan request. Arguments: %#v.", *args)
+		return err
+	}
+	*reply = id
+	srv.logger.Infof("rpc: Successful AddIPBan request. Arguments: %#v.", *args)
+	return nil
+}
+
+// Lifts a ban on a range of raw IPs.
+func (srv *SCServer) RmIPBan(args *rpc.RmIPBanArgs, reply *int) error {
+	if err := srv.liftIPBan(args.BanID); err != nil {
+		srv.logger.Infof("rpc: Failed RmIPBan request. Arguments: %#v.", *args)
+		*reply = 1
+		return err
+	}
+	srv.logger.Infof("rpc: Successful RmIPBan request. Arguments: %#v.", *args)
+	*reply = 0
+	return nil
+}
+
+// Gets the server's statistics since boot.
+func (srv *SCServer) Stats(args *rpc.StatsArgs, reply *rpc.StatsReply) error {
+	st := srv.stats.Snapshot()
+	queued, largest := srv.queueStats()
+	avg, worst, _ := srv.latencyStats()
+	*reply = rpc.StatsReply{
+		Uptime:       st.Uptime,
+		Players:      srv.clients.SizeJoined(),
+		MaxPlayers:   srv.config.MaxPlayers,
+		Connections:  srv.clients.Size(),
+		PeakPlayers:  st.PeakPlayers,
+		Joins:        st.Joins,
+		ICMessages:   st.ICMessages,
+		OOCMessages:  st.OOCMessages,
+		Commands:     st.Commands,
+		MusicChanges: st.MusicChanges,
+		ModCalls:     st.ModCalls,
+		Kicks:        st.Kicks,
+		Bans:         st.Bans,
+		Evictions:    st.Evictions,
+		Queued:       queued,
+		LargestQueue: largest,
+		AvgRTT:       avg,
+		MaxRTT:       worst,
+	}
+	return nil
+}
+
+// Gets how much each character was used in a room, or in every room.
+func (srv *SCServer) CharStats(args *rpc.CharStatsArgs, reply *rpc.CharStatsReply) error {
+	usage, err := srv.db.GetCharUsage(args.Room)
+	if err != nil {
+		srv.logger.Infof("rpc: Failed CharStats request. Arguments: %#v.", *args)
+		return err
+	}
+	reply.Chars = make([]rpc.CharUsage, len(usage))
+	for i, u := range usage {
+		reply.Chars[i] = rpc.CharUsage{Name: u.Name, Picks: u.Picks, Time: u.Time}
+	}
+	return nil
+}
+
+// Prunes old records from the database and vacuums it.
+func (srv *SCServer) MaintainDB(args *rpc.MaintainDBArgs, reply *rpc.MaintainDBReply) error {
+	start := time.Now()
+	pruned, err := srv.maintainDB()
+	if err != nil {
+		srv.logger.Infof("rpc: Failed MaintainDB request.")
+		return err
+	}
+	*reply = rpc.MaintainDBReply{
+		Mutes:         pruned.Mutes,
+		Bans:          pruned.Bans,
+		IPBans:        pruned.IPBans,
+		RoleGrants:    pruned.RoleGrants,
+		ModCalls:      pruned.ModCalls,
+		Warnings:      pruned.Warnings,
+		LoginFailures: pruned.LoginFailures,
+		Took:          time.Since(start),
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// How often timed mutes are checked for having ended.
+const muteCheckInterval = time.Second
+
+// How many past mutes /mysanctions lists, the latest first.
+const maxPastMutes = 10
+
+// Lifts timed mutes as they end, telling the clients. Shadow mutes are lifted silently.
+func (srv *SCServer) expireMutes() {
+	for now := range time.Tick(muteCheckInterval) {
+		for c := range srv.clients.ClientsJoined() {
+			lifted := c.ExpireMutes(now)
+			if lifted == 0 {
+				continue
+			}
+			c.Room().LogEvent(room.EventMod, "The %v mute of %s expired.", muteNames(lifted), c.LongString())
+			if lifted &^= client.MutedShadow; lifted != 0 {
+				srv.tell(c, "mute.expired", muteNames(lifted))
+			}
+		}
+	}
+}
+
+// Returns the names of the kinds of mutes in `m`, e.g. "IC, OOC".
+func muteNames(m client.MuteState) string {
+	var names []string
+	for bit := client.MutedIC; bit <= client.MutedShadow; bit <<= 1 {
+		if m&bit != 0 {
+			names = append(names, muteName(bit))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Returns the name of a single kind of mute.
+func muteName(m client.MuteState) string {
+	if m == client.MutedShadow {
+		return "shadow"
+	}
+	for key, k := range muteKinds {
+		if k.state == m && key != "all" {
+			return k.name
+		}
+	}
+	return "unknown"
+}
+
+func (srv *SCServer) cmdMySanctions(c *client.Client, args []string) (string, bool) {
+	var lines []string
+	now := time.Now()
+	ends := c.MuteEnds()
+	for bit := client.MutedIC; bit < client.MutedShadow; bit <<= 1 {
+		// Shadow mutes are never revealed.
+		end, ok := ends[bit]
+		switch {
+		case !ok:
+		case end.IsZero():
+			lines = append(lines, srv.tr(c, "mute.until", muteName(bit)))
+		default:
+			lines = append(lines, srv.tr(c, "mute.remaining", muteName(bit), end.Sub(now).Round(time.Second)))
+		}
+	}
+	// Past mutes, including ones from before the client reconnected, come from the
+	// database. The ones still active were already listed from the client's state.
+	mutes, err := srv.db.GetMutes(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Couldn't get mutes (%s).", err)
+	}
+	var past int
+	for _, m := range mutes {
+		if m.Shadow || m.End.IsZero() || m.End.After(now) {
+			continue
+		}
+		if past++; past > maxPastMutes {
+			break
+		}
+		lines = append(lines, srv.tr(c, "mute.past", muteNames(muteState(m.Kind)),
+			m.Start.UTC().Format(time.DateTime), m.End.Sub(m.Start).Round(time.Second), m.Reason))
+	}
+	// Bans on a connected client are rare, e.g. a ban on its HDID made after it joined.
+	_, bans, err := srv.db.CheckBanned(c.IPID(), c.Ident())
+	if err != nil {
+		srv.logger.Warnf("server: Error checking ban (%s).", err)
+	}
+	for _, ban := range bans {
+		lines = append(lines, srv.tr(c, "mute.ban", ban.BanID, ban.End.Sub(now).Round(time.Second), ban.Reason))
+	}
+	if len(lines) == 0 {
+		return srv.tr(c, "mute.none"), false
+	}
+	return "\n" + strings.Join(lines, "\n"), false
+}
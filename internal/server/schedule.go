@@ -0,0 +1,155 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// The actions scheduled tasks can run.
+const (
+	actionAnnounce    = "announce"     // sends the task's message to everyone
+	actionRotateLogs  = "rotate_logs"  // moves the server and room logs aside and starts new ones
+	actionResetStatus = "reset_status" // sets every room's status back to idle
+	actionRestart     = "restart"      // stops the server, for a supervisor to start it again
+	actionMaintainDB  = "maintain_db"  // prunes old records and vacuums the database
+)
+
+// A task from the configuration, ready to be scheduled.
+type task struct {
+	action   string
+	every    time.Duration // if zero, the task runs daily at `at`
+	at       time.Duration // since midnight
+	message  string
+	warnings []time.Duration
+}
+
+// Checks the configured tasks, so mistakes are found at startup.
+func parseTasks(confs []config.Task) ([]task, error) {
+	tasks := make([]task, len(confs))
+	for i, conf := range confs {
+		t := task{action: conf.Action, message: conf.Message}
+		switch conf.Action {
+		case actionAnnounce:
+			if conf.Message == "" {
+				return nil, fmt.Errorf("server: Scheduled task #%v announces nothing.", i+1)
+			}
+		case actionRotateLogs, actionResetStatus, actionRestart, actionMaintainDB:
+		default:
+			return nil, fmt.Errorf("server: Scheduled task #%v has unknown action '%v'.", i+1, conf.Action)
+		}
+		switch {
+		case conf.Every != "" && conf.At != "":
+			return nil, fmt.Errorf("server: Scheduled task #%v has both `every` and `at`.", i+1)
+		case conf.Every != "":
+			d, err := parseDuration(conf.Every)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("server: Scheduled task #%v has invalid `every` '%v'.", i+1, conf.Every)
+			}
+			t.every = d
+		case conf.At != "":
+			clock, err := time.Parse("15:04", conf.At)
+			if err != nil {
+				return nil, fmt.Errorf("server: Scheduled task #%v has invalid `at` '%v'.", i+1, conf.At)
+			}
+			t.at = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+		default:
+			return nil, fmt.Errorf("server: Scheduled task #%v needs either `every` or `at`.", i+1)
+		}
+		for _, w := range conf.Warnings {
+			t.warnings = append(t.warnings, time.Duration(w)*time.Second)
+		}
+		// The earliest warning comes first.
+		slices.SortFunc(t.warnings, func(a, b time.Duration) int { return cmp.Compare(b, a) })
+		tasks[i] = t
+	}
+	return tasks, nil
+}
+
+// Returns when the task should next run after `now`.
+func (t task) next(now time.Time) time.Time {
+	if t.every > 0 {
+		return now.Add(t.every)
+	}
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(t.at)
+	if !next.After(now) {
+		next = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Add(t.at)
+	}
+	return next
+}
+
+// Starts a goroutine for each scheduled task.
+func (srv *SCServer) startSchedule() {
+	for _, t := range srv.tasks {
+		go srv.runTask(t)
+	}
+}
+
+func (srv *SCServer) runTask(t task) {
+	for {
+		next := t.next(time.Now())
+		for _, w := range t.warnings {
+			if wait := time.Until(next.Add(-w)); wait >= 0 {
+				time.Sleep(wait)
+				srv.announce("%s in %v.", warningSubject(t), w)
+			}
+		}
+		time.Sleep(time.Until(next))
+		srv.logger.Infof("Running scheduled task '%v'.", t.action)
+		switch t.action {
+		case actionAnnounce:
+			srv.announce("%s", t.message)
+		case actionRotateLogs:
+			srv.rotateLogs()
+		case actionResetStatus:
+			for _, r := range srv.rooms {
+				r.SetStatus(room.StatusIdle)
+			}
+			srv.sendRoomUpdateAll(packets.UpdateStatus)
+		case actionMaintainDB:
+			srv.maintainDB()
+		case actionRestart:
+			if t.message != "" {
+				srv.announce("%s", t.message)
+			}
+			srv.fatal <- fmt.Errorf("server: Scheduled restart.")
+			return
+		}
+	}
+}
+
+// Returns what the warnings before the task should say is happening.
+func warningSubject(t task) string {
+	switch t.action {
+	case actionRestart:
+		return "The server will restart"
+	case actionResetStatus:
+		return "Room statuses will be reset"
+	}
+	return "A scheduled task will run"
+}
+
+// Sends a server message to every joined client.
+func (srv *SCServer) announce(format string, a ...any) {
+	for c := range srv.clients.ClientsJoined() {
+		srv.sendServerMessage(c, format, a...)
+	}
+}
+
+func (srv *SCServer) rotateLogs() {
+	if err := srv.logger.Rotate(); err != nil {
+		srv.reportError("%v", err)
+	}
+	for _, r := range srv.rooms {
+		if err := r.RotateLog(); err != nil {
+			srv.reportError("%v", err)
+		}
+	}
+	srv.logger.Info("Rotated logs.")
+}
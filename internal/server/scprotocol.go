@@ -12,6 +12,7 @@ type handleFuncSC func(srv *SCServer, c *client.Client, data []byte)
 
 var handlerMapSC = map[string]handleFuncSC{
 	"hello": (*SCServer).handleHello,
+	"join":  (*SCServer).handleJoinSC,
 }
 
 func (srv *SCServer) handlePacketSC(c *client.Client, pkt packets.PacketSC) {
@@ -36,20 +37,20 @@ func (srv *SCServer) handleHello(c *client.Client, data []byte) {
 
 	// c.ident = hello.Ident
 
-	taken := srv.rooms[0].Taken()
+	taken := srv.landing.Taken()
 	// TODO: consider pre-allocating instead of appending dynamically?
 	var takenList []string
-	for i, char := range srv.rooms[0].Chars() {
+	for i, char := range srv.landing.Chars() {
 		if taken[i] {
 			takenList = append(takenList, char)
 		}
 	}
-	c.WriteSC("CHARLIST", srv.rooms[0].Chars())
+	c.WriteSC("CHARLIST", srv.landing.Chars())
 	c.WriteSC("CHARLISTTAKEN", taken)
 
 	// TODO: better way to do this?
-	cats := make([]packets.MusicCategory, srv.rooms[0].CategoriesLen())
-	for i, c := range srv.rooms[0].Music() {
+	cats := make([]packets.MusicCategory, srv.landing.CategoriesLen())
+	for i, c := range srv.landing.Music() {
 		songs := make([]string, len(c.Songs))
 		for j, s := range c.Songs {
 			songs[j] = string(s)
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/script"
+)
+
+// Reads and compiles the scripts directory, adding its commands to the server's and its
+// reactions as hooks. Commands can't replace built-in ones.
+func (srv *SCServer) loadScripts() error {
+	scripts, err := config.ReadScripts()
+	if err != nil {
+		return err
+	}
+	engine, err := script.Load(scripts)
+	if err != nil {
+		return err
+	}
+	srv.scripts = make(map[string]cmdHandler, len(engine.Commands))
+	for _, cmd := range engine.Commands {
+		if _, ok := srv.command(cmd.Name); ok {
+			return fmt.Errorf("server: Script command /%v has the same name as another command.", cmd.Name)
+		}
+		srv.scripts[cmd.Name] = cmdHandler{
+			cmdFunc:  scriptCommand(cmd),
+			minArgs:  cmd.MinArgs,
+			reqPerms: cmd.Perms,
+			usage:    cmd.Usage,
+			detailed: cmd.Desc,
+		}
+	}
+	if len(engine.Reactions) > 0 {
+		srv.AddHooks(Hooks{
+			OnICMessage: func(c *client.Client, msg *ICMessage) bool {
+				return srv.react(engine, script.EventIC, c, msg.Text)
+			},
+			OnModcall: func(c *client.Client, reason *string) bool {
+				return srv.react(engine, script.EventModCall, c, *reason)
+			},
+		})
+	}
+	srv.logger.Infof("Loaded %v script command(s) and %v reaction(s).", len(engine.Commands), len(engine.Reactions))
+	return nil
+}
+
+func scriptCommand(cmd *script.Command) cmdFunc {
+	return func(srv *SCServer, c *client.Client, args []string) (string, bool) {
+		ctx := scriptContext(c)
+		ctx.Args = args
+		reply, err := cmd.Run(ctx)
+		if err != nil {
+			srv.logger.Warnf("%v", err)
+			return "This command failed. Please let the host know.", false
+		}
+		if cmd.Broadcast && reply != "" {
+			srv.sendServerMessageToRoom(c.Room(), "%s", reply)
+			return "", false
+		}
+		return reply, false
+	}
+}
+
+// Runs the reactions matching the event. Returns `false` if one of them blocks it.
+func (srv *SCServer) react(engine *script.Engine, event string, c *client.Client, text string) bool {
+	ok := true
+	for _, r := range engine.Reactions {
+		if !r.Matches(event, text) {
+			continue
+		}
+		ctx := scriptContext(c)
+		ctx.Text = text
+		reply, err := r.Run(ctx)
+		if err != nil {
+			srv.logger.Warnf("%v", err)
+			continue
+		}
+		if reply != "" {
+			if r.Broadcast {
+				srv.sendServerMessageToRoom(c.Room(), "%s", reply)
+			} else {
+				srv.sendServerMessage(c, "%s", reply)
+			}
+		}
+		if r.Block {
+			ok = false
+		}
+	}
+	return ok
+}
+
+func scriptContext(c *client.Client) script.Context {
+	name := c.Showname()
+	if name == "" {
+		name = c.Charname()
+	}
+	return script.Context{
+		User:  c.Username(),
+		Name:  name,
+		UID:   c.UID(),
+		CID:   c.CID(),
+		Room:  c.Room().Name(),
+		Perms: c.EffectivePerms(),
+	}
+}
@@ -6,29 +6,81 @@ package server
 // TODO: abstract all (or almost all) outbound packets into methods from package `client`.
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/lambdcalculus/scs/internal/client"
 	"github.com/lambdcalculus/scs/internal/config"
 	"github.com/lambdcalculus/scs/internal/db"
+	"github.com/lambdcalculus/scs/internal/events"
+	"github.com/lambdcalculus/scs/internal/geo"
+	"github.com/lambdcalculus/scs/internal/i18n"
 	"github.com/lambdcalculus/scs/internal/perms"
 	"github.com/lambdcalculus/scs/internal/room"
+	"github.com/lambdcalculus/scs/internal/sanitize"
+	"github.com/lambdcalculus/scs/internal/stats"
 	"github.com/lambdcalculus/scs/internal/uid"
+	"github.com/lambdcalculus/scs/internal/webhook"
 	"github.com/lambdcalculus/scs/pkg/logger"
 	"github.com/lambdcalculus/scs/pkg/packets"
 )
 
+// The server software's name and version, as reported to clients.
+const (
+	appName    = "scs"
+	appVersion = "alpha"
+)
+
 type SCServer struct {
 	config *config.Server
 	db     *db.Database
 
-	roles []perms.Role
-	rooms []*room.Room
-
-	uidHeap uid.UIDHeap
-	clients *client.List
+	roles       []perms.Role
+	rooms       []*room.Room
+	charsConf   *config.Characters  // for switching the rooms' character lists
+	backgrounds []config.Background // the valid backgrounds, nil if any is
+	landing     *room.Room          // where clients join
+	modcallRoom *room.Room          // where mod calls are also announced, if set
+
+	songLengths map[string]time.Duration
+	music       musicTimers
+
+	uidHeap   *uid.UIDHeap
+	clients   *client.List
+	broadcast *broadcaster
+
+	webhook  *webhook.Notifier
+	geo      *geo.Policy
+	stats    *stats.Stats
+	events   *events.Bus
+	catalog  *i18n.Catalog
+	sanitize *sanitize.Sanitizer
+	modcalls *modCallQueue
+	confirms *confirmations
+	lockdown *lockdown
+	raid     *raidMode
+	ipBans   *ipBanCache
+	joins    *attemptLimiter // failed room password attempts, by IPID
+	logins   *loginThrottle  // failed logins, by IPID
+	admin    *adminSessions
+	resume   *resumeSessions // suspended SC sessions, by token
+	watches  *watchList
+	waits    *waitlist
+	chars    *charTracker
+	hooks    []Hooks
+	scripts  map[string]cmdHandler // the commands from the scripts directory, by name
+	motd     motd
+	tasks    []task
+	features []featureAO // the FL features advertised to AO clients
 
 	fatal chan error
+	start time.Time
 
 	logger *logger.Logger
 }
@@ -52,57 +104,252 @@ func MakeServer(log *logger.Logger) (*SCServer, error) {
 	}
 	log.Debugf("Music config: %#v", musicConf)
 
-	rooms, err := room.MakeRooms(charsConf, musicConf)
+	roomConf, err := config.ReadRooms()
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't read room config (%w).", err)
+	}
+
+	rolesConf, err := config.ReadRoles()
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't read roles config (%w).", err)
+	}
+
+	if err := config.Validate(conf, roomConf, charsConf, musicConf, rolesConf); err != nil {
+		var problems config.Problems
+		if errors.As(err, &problems) {
+			for _, p := range problems {
+				log.Errorf("Config problem: %v", p)
+			}
+			return nil, fmt.Errorf("server: Found %v problem(s) in the configuration. Fix them and restart.", len(problems))
+		}
+		return nil, err
+	}
+
+	rooms, err := room.MakeRooms(roomConf, charsConf, musicConf)
 	if err != nil {
 		return nil, fmt.Errorf("server: Couldn't configure rooms (%w).", err)
 	}
+	landing := rooms[0]
+	if !conf.AllowAO && !conf.AllowSC {
+		return nil, fmt.Errorf("server: Neither AO nor SC clients are allowed.")
+	}
+	if conf.LandingRoom != "" {
+		if landing, err = findRoomByName(rooms, conf.LandingRoom); err != nil {
+			return nil, err
+		}
+	}
+	var modcallRoom *room.Room
+	if conf.ModCallRoom != "" {
+		if modcallRoom, err = findRoomByName(rooms, conf.ModCallRoom); err != nil {
+			return nil, err
+		}
+	}
 
-	roles, err := perms.MakeRoles()
+	roles, err := perms.MakeRoles(rolesConf)
 	if err != nil {
 		return nil, fmt.Errorf("server: Couldn't configure roles (%w).", err)
 	}
 
-	execDir, err := config.ExecDir()
+	for name, d := range conf.Durations.Presets {
+		if _, err := parseDuration(d); err != nil {
+			return nil, fmt.Errorf("server: Bad duration preset '%v' (%w).", name, err)
+		}
+	}
+	for _, d := range []string{conf.Durations.DefaultBan, conf.Durations.DefaultMute} {
+		if _, err := resolveDuration(conf.Durations.Presets, d); d != "" && err != nil {
+			return nil, fmt.Errorf("server: Bad default duration '%v' (%w).", d, err)
+		}
+	}
+
+	if conf.Clients.MinVersion != "" {
+		if _, err := client.ParseVersion(conf.Clients.MinVersion); err != nil {
+			return nil, fmt.Errorf("server: Bad minimum client version (%w).", err)
+		}
+	}
+
+	features, err := enabledFeaturesAO(conf.Clients.DisabledFeatures)
+	if err != nil {
+		return nil, err
+	}
+
+	bgConf, err := config.ReadBackgrounds()
 	if err != nil {
-		return nil, fmt.Errorf("server: Couldn't get executable directory (%w).", err)
+		return nil, fmt.Errorf("server: Couldn't read backgrounds config (%w).", err)
 	}
-	db, err := db.Init(execDir + "/database.sqlite")
+	var backgrounds []config.Background
+	if bgConf != nil {
+		backgrounds = bgConf.List
+		for _, r := range rooms {
+			if !slices.ContainsFunc(backgrounds, func(b config.Background) bool { return strings.EqualFold(b.Name, r.Background()) }) {
+				log.Warnf("Room '%v' has the background '%v', which isn't in the background list.", r.Name(), r.Background())
+			}
+		}
+	}
+
+	songLengths := make(map[string]time.Duration, len(musicConf.Lengths))
+	for song, secs := range musicConf.Lengths {
+		songLengths[song] = time.Duration(secs) * time.Second
+	}
+
+	langs, err := config.ReadLanguages()
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't read languages (%w).", err)
+	}
+	catalog, err := i18n.New(langs, conf.Language)
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't set up languages (%w).", err)
+	}
+
+	geoPolicy, err := geo.NewPolicy(conf.Geo)
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't configure connection policy (%w).", err)
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't get data directory (%w).", err)
+	}
+	db, err := db.Init(dataDir + "/database.sqlite")
 	if err != nil {
 		return nil, fmt.Errorf("server: Couldn't initialize database (%w).", err)
 	}
+	salt, err := db.IPIDSalt()
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't get IPID salt (%w).", err)
+	}
+	perms.SetManagerPerms(perms.FromNames(conf.ManagerPerms))
+	client.SetIPIDHashing(salt, conf.IPIDLength)
+	client.SetWriteQueueSize(conf.WriteQueueSize)
+	client.SetTimeouts(time.Duration(conf.ReadTimeout)*time.Second, time.Duration(conf.PingInterval)*time.Second)
+
+	tasks, err := parseTasks(conf.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	motdText, ok, err := db.Setting(motdSetting)
+	if err != nil {
+		return nil, fmt.Errorf("server: Couldn't get MOTD (%w).", err)
+	}
+	if !ok {
+		motdText = conf.MOTD
+	}
 
 	srv := &SCServer{
-		config:  conf,
-		db:      db,
-		roles:   roles,
-		rooms:   rooms,
-		uidHeap: *uid.CreateHeap(conf.MaxPlayers),
-		clients: client.NewList(),
-		fatal:   make(chan error),
-		logger:  log,
+		config:      conf,
+		db:          db,
+		roles:       roles,
+		rooms:       rooms,
+		charsConf:   charsConf,
+		backgrounds: backgrounds,
+		landing:     landing,
+		modcallRoom: modcallRoom,
+		songLengths: songLengths,
+		music:       musicTimers{timers: make(map[*room.Room]*songTimer)},
+		uidHeap:     uid.CreateHeap(conf.MaxPlayers + conf.ReservedSlots),
+		clients:     client.NewList(),
+		broadcast:   newBroadcaster(conf.BroadcastWorkers),
+		webhook:     webhook.New(conf.Webhooks, log),
+		modcalls:    newModCallQueue(),
+		confirms:    newConfirmations(),
+		lockdown:    newLockdown(),
+		raid:        newRaidMode(),
+		ipBans:      newIPBanCache(),
+		tasks:       tasks,
+		features:    features,
+		motd:        motd{text: motdText},
+		joins:       newAttemptLimiter(5, time.Minute),
+		logins:      newLoginThrottle(max(conf.LoginMaxFailures, 1), time.Duration(conf.LoginLockout)*time.Minute),
+		geo:         geoPolicy,
+		stats:       stats.New(),
+		events:      events.NewBus(),
+		catalog:     catalog,
+		sanitize:    sanitize.New(conf.Sanitize),
+		resume:      newResumeSessions(),
+		watches:     newWatchList(),
+		waits:       newWaitlist(),
+		chars:       newCharTracker(),
+		admin:       newAdminSessions(time.Duration(conf.Admin.Sessions) * time.Hour),
+		fatal:       make(chan error),
+		logger:      log,
+	}
+	srv.subscribeEvents()
+	if err := srv.refreshIPBans(); err != nil {
+		return nil, err
+	}
+	if err := srv.loadTitles(); err != nil {
+		return nil, fmt.Errorf("server: Couldn't get room titles (%w).", err)
+	}
+	if err := srv.loadModCalls(); err != nil {
+		return nil, fmt.Errorf("server: Couldn't get mod calls (%w).", err)
+	}
+	if conf.Scripts {
+		if err := srv.loadScripts(); err != nil {
+			return nil, fmt.Errorf("server: Couldn't load scripts (%w).", err)
+		}
 	}
 	srv.logger.Debugf("Successfully loaded server configuration: %#v", conf)
 	return srv, nil
 }
 
+// Stops a running server, disconnecting everyone. [SCServer.Run] then returns.
+func (srv *SCServer) Stop() {
+	srv.fatal <- fmt.Errorf("server: Stopped.")
+}
+
+// Finds the room with the name, for settings that refer to a room.
+func findRoomByName(rooms []*room.Room, name string) (*room.Room, error) {
+	i := slices.IndexFunc(rooms, func(r *room.Room) bool { return r.Name() == name })
+	if i < 0 {
+		return nil, fmt.Errorf("server: Room '%v' doesn't exist.", name)
+	}
+	return rooms[i], nil
+}
+
 // Starts and runs the server.
 func (srv *SCServer) Run() error {
 	srv.logger.Info("Starting server.")
+	srv.start = time.Now()
 	// TODO: don't panic if one of the listeners panics
 	if srv.config.PortWS > 0 {
 		go srv.listenWS()
 	}
 	if srv.config.PortTCP > 0 {
-		go srv.listenTCP()
+		if srv.config.AllowAO {
+			go srv.listenTCP()
+		} else {
+			srv.logger.Info("AO clients aren't allowed, so the legacy TCP port isn't used.")
+		}
 	}
 	if srv.config.PortRPC > 0 {
 		go srv.listenRPC()
 	}
+	if srv.config.Admin.Enabled && srv.config.Admin.Port > 0 {
+		go srv.listenAdmin()
+	}
+	srv.startSchedule()
+	if srv.config.WaitQueue > 0 {
+		go srv.updateWaiting()
+	}
+	go srv.expireMutes()
+	go srv.pruneRecords()
 
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	var err error
 	select {
-	case err := <-srv.fatal:
-		return err
+	case err = <-srv.fatal:
+	case sig := <-sigs:
+		err = fmt.Errorf("server: Received signal '%v'.", sig)
 	}
+	srv.disconnectAll(client.CloseShutdown, "The server is shutting down.")
+	return err
+}
+
+// Logs an error and reports it to the webhook, if one is configured.
+func (srv *SCServer) reportError(format string, a ...any) {
+	srv.logger.Errorf(format, a...)
+	srv.webhook.Error(format, a...)
 }
 
 // Looks for a client with the given UID. Returns `nil` if not found.
@@ -126,9 +373,88 @@ func (srv *SCServer) getByIPID(id string) []*client.Client {
 			clients = append(clients, c)
 		}
 	}
+	return clients
+}
+
+// Returns the configured role with the passed name. If there is none, returns `nil`.
+func (srv *SCServer) getRole(name string) *perms.Role {
+	for i := range srv.roles {
+		if srv.roles[i].Name == name {
+			return &srv.roles[i]
+		}
+	}
 	return nil
 }
 
+// Gives the client the role until `end`. When it expires, the role is taken away,
+// unless it has been replaced in the meantime (e.g. by another grant).
+func (srv *SCServer) grantRole(c *client.Client, r *perms.Role, end time.Time) {
+	srv.addRole(c, client.RoleBase, r)
+	time.AfterFunc(time.Until(end), func() {
+		if !c.Joined() || c.Role(client.RoleBase) != r {
+			return
+		}
+		srv.removeRole(c, client.RoleBase)
+		srv.tell(c, "server.role_expired", r.Name)
+		srv.logger.Infof("Temporary role '%v' of %s expired.", r.Name, c.LongString())
+	})
+}
+
+// Does what's due once a client has joined, through either protocol: greets it, holds it
+// at the gates it must pass, and gives it back the mutes and roles tied to its IPID.
+func (srv *SCServer) afterJoin(c *client.Client) {
+	if motd := srv.motd.get(); motd != "" {
+		srv.sendServerMessage(c, "%s", motd)
+	}
+	srv.welcome(c)
+	srv.gateClient(c)
+	srv.restoreMutes(c)
+	srv.remindWarnings(c)
+	srv.restoreRoleGrant(c)
+}
+
+// Gives the client the role granted to its IPID with /promote, if the grant is active.
+func (srv *SCServer) restoreRoleGrant(c *client.Client) {
+	grant, ok, err := srv.db.ActiveRoleGrant(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Error checking role grants (%s).", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if r := srv.getRole(grant.Role); r != nil {
+		srv.grantRole(c, r, grant.End)
+		srv.tell(c, "server.temp_role", r.Name, grant.End.UTC().Format(time.UnixDate))
+	}
+}
+
+// Puts the role in the client's slot, replacing whatever was there.
+func (srv *SCServer) addRole(c *client.Client, slot client.RoleSlot, r *perms.Role) {
+	before := c.Perms()
+	c.AddRole(slot, r)
+	updateGuard(c, before)
+}
+
+// Takes the role in the client's slot away. Returns the removed role, if any.
+func (srv *SCServer) removeRole(c *client.Client, slot client.RoleSlot) *perms.Role {
+	before := c.Perms()
+	r := c.RemoveRole(slot)
+	updateGuard(c, before)
+	return r
+}
+
+// Adds or removes the client's guard button if whether it can hear mod calls changed.
+func updateGuard(c *client.Client, before perms.Mask) {
+	had, has := before&perms.HearModCalls != 0, c.Perms()&perms.HearModCalls != 0
+	switch {
+	case has && !had:
+		c.AddGuard()
+	case had && !has:
+		c.RemoveGuard()
+	}
+}
+
 // Returns the room with the passed name. If there are none, returns `nil`.
 func (srv *SCServer) getRoomByName(name string) *room.Room {
 	for _, r := range srv.rooms {
@@ -152,20 +478,24 @@ func (srv *SCServer) getClientsInRoom(room *room.Room) []*client.Client {
 
 // Writes the specified packet to the specified room.
 func (srv *SCServer) writeToRoomAO(r *room.Room, header string, contents ...string) {
-	clients := srv.getClientsInRoom(r)
-	for _, c := range clients {
+	raw := packets.PacketAO{Header: header, Contents: contents}.Encoded()
+	srv.broadcast.fanOut(srv.getClientsInRoom(r), func(c *client.Client) {
 		if c.Type() == client.AOClient {
-			c.WriteAO(header, contents...)
+			c.WriteAORaw(raw)
 		}
-	}
+	})
+}
+
+// Sends the room's taken characters to the AO clients in it.
+func (srv *SCServer) sendCharsCheck(r *room.Room) {
+	srv.writeToRoomAO(r, "CharsCheck", r.TakenList()...)
 }
 
 // Sends an OOC message to all clients in the specified room.
 func (srv *SCServer) sendOOCMessageToRoom(r *room.Room, username string, msg string, server bool) {
-	clients := srv.getClientsInRoom(r)
-	for _, c := range clients {
+	srv.broadcast.fanOut(srv.getClientsInRoom(r), func(c *client.Client) {
 		c.SendOOCMessage(username, msg, server)
-	}
+	})
 }
 
 // Sends a server message to all clients in the specified room.
@@ -173,34 +503,130 @@ func (srv *SCServer) sendServerMessageToRoom(r *room.Room, format string, a ...a
 	srv.sendOOCMessageToRoom(r, srv.config.Username, fmt.Sprintf(format, a...), true)
 }
 
-func (srv *SCServer) kickClient(c *client.Client, reason string) {
-	c.NotifyKick(reason)
+func (srv *SCServer) kickClient(c *client.Client, reason string, moderator string) {
+	srv.events.Publish(events.Event{Kind: events.Kick, Client: c, Room: c.Room(), Actor: moderator, Text: reason})
+	msg := srv.fillNotice(srv.config.Templates.Kick, notice{reason: reason, moderator: moderator})
+	c.NotifyKick(msg)
+	c.SetCloseReason(client.CloseKicked, msg)
+	srv.removeClient(c)
+}
+
+// Disconnects a client that has just been banned.
+func (srv *SCServer) kickBanned(c *client.Client, reason string, moderator string, dur time.Duration) {
+	msg := srv.fillNotice(srv.config.Templates.Ban, notice{reason: reason, moderator: moderator, duration: dur})
+	c.NotifyBan(msg)
+	c.SetCloseReason(client.CloseBanned, msg)
 	srv.removeClient(c)
 }
 
-// Disconnects and cleans up a client.
+// Disconnects every client, e.g. because the server is shutting down. Returns once all
+// of their connections are closed, which happens for all of them at once.
+func (srv *SCServer) disconnectAll(code int, reason string) {
+	var all []*client.Client
+	for c := range srv.clients.Clients() {
+		c.SetCloseReason(code, reason)
+		srv.removeClient(c)
+		all = append(all, c)
+	}
+	for _, c := range all {
+		<-c.Done()
+	}
+}
+
+// Disconnects and cleans up a client. Only the first call for each client does anything.
 func (srv *SCServer) removeClient(c *client.Client) {
+	if c.MarkRemoved() {
+		return
+	}
+	left := c.Room()
+	kept := srv.suspendSession(c)
+	if c.UID() != uid.Unjoined {
+		srv.hookLeave(c)
+	}
 	if c.Room() != nil {
-		srv.sendServerMessageToRoom(c.Room(), fmt.Sprintf("%s has disconnected.", c.ShortString()))
+		srv.tellRoom(c.Room(), "server.disconnected", c.ShortString())
 		c.Room().LogEvent(room.EventExit, "%s disconnected.", c.LongString())
 		c.Room().Leave(c.UID())
 		c.SetRoom(nil)
 	}
 	if c.UID() != uid.Unjoined {
-		srv.uidHeap.Free(c.UID())
-		srv.logger.Infof("Client with UID %v (IPID: %v) left.", c.UID(), c.IPID())
+		srv.events.Publish(events.Event{Kind: events.Leave, Client: c, Room: left})
+		// A suspended session keeps its UID until it expires.
+		if !kept {
+			for _, r := range srv.rooms {
+				r.ForgetUID(c.UID())
+			}
+			srv.uidHeap.Free(c.UID())
+		}
 		c.SetUID(uid.Unjoined)
 	}
+	srv.confirms.forget(c)
+	srv.unwatchAll(c)
+	srv.waits.forget(c)
+	srv.untrackChar(c)
 	c.Disconnect()
 	srv.clients.Remove(c)
-	srv.sendRoomUpdateAllAO(packets.UpdatePlayer)
+	srv.admitWaiting()
+	if left != nil {
+		srv.sendCharsCheck(left)
+	}
+	if c.Evicted() {
+		srv.stats.AddEviction()
+	}
+	if left != nil {
+		srv.sendRoomUpdateAll(packets.UpdatePlayer, left)
+	}
+}
+
+// Returns the total amount of packets waiting in the clients' write queues,
+// and the size of the largest queue.
+func (srv *SCServer) queueStats() (queued int, largest int) {
+	for c := range srv.clients.Clients() {
+		n := c.QueueLen()
+		queued += n
+		largest = max(largest, n)
+	}
+	return queued, largest
+}
+
+// Returns the average and the worst round-trip time among the clients whose latency
+// could be measured, and how many they are.
+func (srv *SCServer) latencyStats() (avg time.Duration, worst time.Duration, measured int) {
+	var total time.Duration
+	for c := range srv.clients.Clients() {
+		if rtt, ok := c.RTT(); ok {
+			total += rtt
+			worst = max(worst, rtt)
+			measured++
+		}
+	}
+	if measured > 0 {
+		avg = total / time.Duration(measured)
+	}
+	return avg, worst, measured
 }
 
 // Writes a message to all AO clients.
 func (srv *SCServer) writeToAllAO(header string, contents ...string) {
+	raw := packets.PacketAO{Header: header, Contents: contents}.Encoded()
+	clients := make([]*client.Client, 0, srv.clients.Size())
 	for c := range srv.clients.Clients() {
 		if c.Type() == client.AOClient {
-			c.WriteAO(header, contents...)
+			clients = append(clients, c)
+		}
+	}
+	srv.broadcast.fanOut(clients, func(c *client.Client) {
+		c.WriteAORaw(raw)
+	})
+}
+
+// Sends the message with the passed key to every moderator who can hear mod calls, each in
+// their own language, and logs it.
+func (srv *SCServer) alertMods(key string, a ...any) {
+	srv.logger.Info(srv.catalog.Message(i18n.Builtin, key, a...))
+	for cl := range srv.clients.ClientsJoined() {
+		if cl.Perms()&perms.HearModCalls != 0 {
+			srv.tell(cl, key, a...)
 		}
 	}
 }
@@ -210,17 +636,45 @@ func (srv *SCServer) sendServerMessage(c *client.Client, format string, a ...any
 	c.SendOOCMessage(srv.config.Username, fmt.Sprintf(format, a...), true)
 }
 
-// Sends an ARUP to all AO clients.
-func (srv *SCServer) sendRoomUpdateAllAO(up packets.AreaUpdate) {
-	// since we're doing the whole thing per client, this might be
-	// really slow. we'll see if it matter. if it does, then TODO: make faster
-	clients := srv.clients.ClientsJoined()
-	for c := range clients {
-		switch c.Type() {
-		case client.AOClient:
-			c.SendRoomUpdateAO(up)
-		case client.SCClient:
-			// TODO
+// Sends room updates to the clients that can see any of the changed rooms (all clients, if
+// none are passed). AO clients get full ARUPs, while SpriteChat clients only get the state
+// of the changed rooms.
+func (srv *SCServer) sendRoomUpdateAll(up packets.AreaUpdate, changed ...*room.Room) {
+	// Clients in the same room see the same rooms, so the updates are computed once per room.
+	byRoom := make(map[*room.Room][]*client.Client)
+	for c := range srv.clients.ClientsJoined() {
+		if r := c.Room(); r != nil {
+			byRoom[r] = append(byRoom[r], c)
+		}
+	}
+
+	for r, clients := range byRoom {
+		vis := r.Visible()
+		scVis := vis
+		if len(changed) > 0 {
+			scVis = room.Intersect(vis, changed)
+			if len(scVis) == 0 {
+				continue
+			}
+		}
+
+		var aoPkts []packets.PacketAO
+		var scData packets.DataRoomUpdate
+		for _, c := range clients {
+			switch c.Type() {
+			case client.AOClient:
+				if aoPkts == nil {
+					aoPkts = room.AreaUpdateAO(vis, up)
+				}
+				for _, p := range aoPkts {
+					c.WriteAOPacket(p)
+				}
+			case client.SCClient:
+				if scData == nil {
+					scData = room.AreaUpdateSC(scVis, up)
+				}
+				c.WriteSC("ROOMUPDATE", scData)
+			}
 		}
 	}
 }
@@ -229,41 +683,81 @@ func (srv *SCServer) sendRoomUpdateAllAO(up packets.AreaUpdate) {
 func (srv *SCServer) moveClient(c *client.Client, dst *room.Room) {
 	currRoom := c.Room()
 	if currRoom == dst {
-		srv.sendServerMessage(c, "You are already in this room!")
+		srv.tell(c, "move.same_room")
+		return
+	}
+	cooldown := srv.cooldown(srv.config.MoveCooldown)
+	if wait := time.Until(c.LastMove().Add(cooldown)); wait > 0 && !c.HasPerms(perms.HearModCalls) {
+		srv.tell(c, "move.cooldown", wait.Round(100*time.Millisecond))
+		return
+	}
+	if (srv.config.AdjacentOnly || currRoom.AdjacentOnly()) && !currRoom.IsAdjacent(dst) && !c.HasPerms(perms.BypassLocks) {
+		currRoom.LogEvent(room.EventFail, "%s tried to move to non-adjacent room [%v] %s.", c.LongString(), dst.ID(), dst.Name())
+		srv.tell(c, "move.adjacent_only")
 		return
 	}
 	if (dst.LockState()&room.LockLocked != 0) && !dst.IsInvited(c.UID()) {
 		dst.LogEvent(room.EventFail, "%s tried to enter uninvited.", c.LongString())
-		srv.sendServerMessage(c, "You are not invited to this room!")
+		srv.tell(c, "move.not_invited")
 		return
 	}
+	if !srv.enterRoom(c, dst) {
+		srv.tell(c, "move.spectators_full")
+	}
+}
 
-	srv.sendServerMessage(c, "Moved to [%v] %s. Description: %s", dst.ID(), dst.Name(), dst.Desc())
+// Moves a client to room `dst`, without checking whether it may enter it. The client
+// keeps its character if it can, and becomes a spectator otherwise. Returns `false`
+// if the room is full of spectators, in which case the client isn't moved.
+func (srv *SCServer) enterRoom(c *client.Client, dst *room.Room) bool {
+	currRoom := c.Room()
 	newCID, ok := dst.GetCIDByName(currRoom.GetNameByCID(c.CID()))
-	if !ok {
-		srv.sendServerMessage(c, "Your character is not in this room's list. Changing to Spectator.")
+	restricted := ok && !dst.CanUse(newCID, dst.ApplyPerms(c.Perms()))
+	if !ok || restricted {
 		newCID = room.SpectatorCID
 	}
 	if !dst.Enter(newCID, c.UID()) {
-		srv.sendServerMessage(c, "Your character in this room is taken. Changing to Spectator.")
+		if newCID == room.SpectatorCID || !dst.Enter(room.SpectatorCID, c.UID()) {
+			return false
+		}
+		if dst.NeedsApproval(c.UID()) {
+			srv.tell(c, "move.needs_approval")
+		} else {
+			srv.tell(c, "move.char_taken")
+		}
 		newCID = room.SpectatorCID
-		dst.Enter(newCID, c.UID())
+	} else if restricted {
+		srv.tell(c, "move.char_restricted")
+	} else if !ok {
+		srv.tell(c, "move.char_not_listed")
+	}
+	c.SetLastMove(time.Now())
+	srv.tell(c, "move.moved", dst.ID(), dst.Name(), dst.Desc())
+	if title := dst.Title(); title != "" {
+		srv.tell(c, "move.title", title)
 	}
 	// TODO: autopass on/off or sneaking? see how other servers do it
-	srv.sendServerMessageToRoom(dst, "%s enters from [%v] %s.", c.ShortString(), currRoom.ID(), currRoom.Name())
+	srv.tellRoom(dst, "move.enters", c.ShortString(), currRoom.ID(), currRoom.Name())
 	dst.LogEvent(room.EventEnter, "%s enters from [%v] %s.", c.LongString(), currRoom.ID(), currRoom.Name())
 	c.SetRoom(dst)
 
 	currRoom.Leave(c.UID())
-	srv.sendServerMessageToRoom(currRoom, "%s leaves to [%v] %s.", c.ShortString(), dst.ID(), dst.Name())
+	srv.tellRoom(currRoom, "move.leaves", c.ShortString(), dst.ID(), dst.Name())
 	currRoom.LogEvent(room.EventExit, "%s leaves to [%v] %s.", c.LongString(), dst.ID(), dst.Name())
 
 	c.Update()
 	c.ChangeChar(newCID)
+	srv.trackChar(c)
+	srv.sendCharsCheck(currRoom)
+	srv.sendCharsCheck(dst)
 
-	if c.Type() == client.AOClient {
+	switch c.Type() {
+	case client.AOClient:
 		c.SendRoomUpdateAO(packets.UpdateAll & ^packets.UpdatePlayer)
+	case client.SCClient:
+		c.SendRoomUpdateSC(packets.UpdateAll & ^packets.UpdatePlayer)
 	}
 	// TODO: send only to adjacent rooms?
-	srv.sendRoomUpdateAllAO(packets.UpdatePlayer)
+	srv.sendRoomUpdateAll(packets.UpdatePlayer, currRoom, dst)
+	return true
 }
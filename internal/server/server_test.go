@@ -0,0 +1,223 @@
+package server_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/aotest"
+	"github.com/lambdcalculus/scs/internal/db"
+	"github.com/lambdcalculus/scs/internal/totp"
+	"github.com/lambdcalculus/scs/pkg/packets"
+)
+
+// Kicks clients while they disconnect by themselves, so both paths remove them at once.
+// Each must be removed only once. Run with -race.
+func TestKickRacesDisconnect(t *testing.T) {
+	const rounds = 20
+	s := aotest.StartServer(t, nil)
+	s.AddUser(t, "mod", "hunter2", "Moderator")
+
+	mod := s.DialTCP(t)
+	if err := mod.Join("mod", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := mod.Command("mod", "login mod hunter2"); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(reply, "Successfully authenticated") {
+		t.Fatalf("/login replied %q", reply)
+	}
+
+	joined := regexp.MustCompile(`^\[(\d+)\] Phoenix has joined the server!$`)
+	for i := 0; i < rounds; i++ {
+		victim := s.DialTCP(t)
+		if err := victim.Join("victim", "AO2", "2.10.0"); err != nil {
+			t.Fatal(err)
+		}
+		if err := victim.PickChar(0); err != nil {
+			t.Fatal(err)
+		}
+		p := expect(t, mod, "CT", func(p packets.PacketAO) bool {
+			return len(p.Contents) >= 2 && joined.MatchString(p.Contents[1])
+		})
+		uid := joined.FindStringSubmatch(p.Contents[1])[1]
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			mod.OOC("mod", "/kick uid "+uid+" race")
+		}()
+		go func() {
+			defer wg.Done()
+			victim.Close()
+		}()
+		wg.Wait()
+		expect(t, mod, "CT", func(p packets.PacketAO) bool {
+			return len(p.Contents) >= 2 && strings.HasSuffix(p.Contents[1], "has disconnected.")
+		})
+	}
+
+	// Anything left over from a second removal is in by the time this is answered.
+	if _, err := mod.Command("mod", "ping"); err != nil {
+		t.Fatal(err)
+	}
+	var left int
+	for _, p := range mod.Received() {
+		if p.Header == "CT" && len(p.Contents) >= 2 && strings.HasSuffix(p.Contents[1], "has disconnected.") {
+			left++
+		}
+	}
+	if left != rounds {
+		t.Errorf("got %v disconnection messages for %v clients", left, rounds)
+	}
+}
+
+// Returns the current one-time code for the secret, as an authenticator app would.
+func totpCode(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// A one-time code that was used once can't be used again, even while it's still valid.
+func TestTOTPReplay(t *testing.T) {
+	s := aotest.StartServer(t, nil)
+	s.AddUser(t, "mod", "hunter2", "Moderator")
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := db.Init(filepath.Join(s.Dir, "database.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.SetTOTPSecret("mod", secret); err != nil {
+		t.Fatal(err)
+	}
+
+	c := s.DialTCP(t)
+	if err := c.Join("mod", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	code := totpCode(t, secret)
+	for i, want := range []string{"Successfully authenticated", "Logged out", "Incorrect password or code"} {
+		cmd := "login mod hunter2 " + code
+		if i == 1 {
+			cmd = "logout"
+		}
+		reply, err := c.Command("mod", cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(reply, want) {
+			t.Fatalf("/%v replied %q; want a reply starting with %q", cmd, reply, want)
+		}
+	}
+}
+
+// A mod call made while no moderator is online survives a restart, until a moderator logs
+// in and sees it.
+func TestModCallSurvivesRestart(t *testing.T) {
+	s := aotest.StartServer(t, map[string]string{
+		"roles.toml": aotest.DefaultConfigs["roles.toml"] + `
+[[role]]
+name = "Listener"
+permissions = ["hear_modcall"]
+`,
+	})
+	s.AddUser(t, "listener", "hunter2", "Listener")
+	caller := s.DialTCP(t)
+	if err := caller.Join("hdid", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := caller.Send("ZZ", "help me"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, caller, "CT", func(p packets.PacketAO) bool {
+		return len(p.Contents) >= 2 && strings.HasPrefix(p.Contents[1], "No moderators are online")
+	})
+
+	for i, want := range []bool{true, false} {
+		s.Restart(t)
+		listener := s.DialTCP(t)
+		if err := listener.Join("hdid", "AO2", "2.10.0"); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := listener.Command("listener", "login listener hunter2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(reply, "help me"); got != want {
+			t.Errorf("login %v after the call: got the call %v; want %v (/login replied %q)", i+1, got, want, reply)
+		}
+	}
+}
+
+// A regular /ban is posted to the webhook, not only the bans made elsewhere.
+func TestBanPostsToWebhook(t *testing.T) {
+	posts := make(chan string, 8)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posts <- string(body)
+	}))
+	defer hook.Close()
+	s := aotest.StartServer(t, map[string]string{
+		"config.toml": aotest.DefaultConfigs["config.toml"] + fmt.Sprintf("\n[webhooks]\nurl = %q\nbans = true\n", hook.URL),
+	})
+	s.AddUser(t, "mod", "hunter2", "Moderator")
+
+	mod := s.DialTCP(t)
+	if err := mod.Join("mod", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mod.Command("mod", "login mod hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	victim := s.DialTCP(t)
+	if err := victim.Join("victim", "AO2", "2.10.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := victim.PickChar(0); err != nil {
+		t.Fatal(err)
+	}
+	joined := regexp.MustCompile(`^\[(\d+)\] Phoenix has joined the server!$`)
+	p := expect(t, mod, "CT", func(p packets.PacketAO) bool {
+		return len(p.Contents) >= 2 && joined.MatchString(p.Contents[1])
+	})
+	uid := joined.FindStringSubmatch(p.Contents[1])[1]
+
+	// Both clients have the same IPID, so the moderator is disconnected too.
+	if err := mod.OOC("mod", "/ban "+uid+" 3d griefing"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-posts:
+		if !strings.Contains(body, `"title":"Ban"`) || !strings.Contains(body, "griefing") {
+			t.Errorf("the webhook got %s; want the ban", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the webhook got nothing")
+	}
+}
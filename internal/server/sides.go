@@ -0,0 +1,62 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Sends the room's sides to everyone in it. Those whose side isn't available anymore
+// are moved to the default side.
+func (srv *SCServer) updateSides(r *room.Room) {
+	for _, c := range srv.getClientsInRoom(r) {
+		c.UpdateSides()
+		if c.Side() != "" && !r.HasSide(c.Side()) {
+			c.SetSide(r.DefaultSide())
+			c.UpdateSide()
+		}
+	}
+}
+
+func (srv *SCServer) cmdPos(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		return srv.tr(c, "side.current", c.Side(), strings.Join(r.Sides(), ", ")), false
+	}
+	side := args[0]
+	if !r.HasSide(side) {
+		return srv.tr(c, "side.unknown", side, strings.Join(r.Sides(), ", ")), false
+	}
+	if sides := r.CharSides(c.CID()); len(sides) > 0 && !slices.Contains(sides, side) {
+		return srv.tr(c, "side.restricted", strings.Join(sides, ", ")), false
+	}
+	c.SetSide(side)
+	c.UpdateSide()
+	return srv.tr(c, "side.set", side), false
+}
+
+func (srv *SCServer) cmdAddSide(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	side := args[0]
+	if !r.AddSide(side) {
+		return srv.tr(c, "side.exists", side), false
+	}
+	srv.updateSides(r)
+	r.LogEvent(room.EventMod, "%s added the side '%s'.", c.LongString(), side)
+	srv.tellRoom(r, "side.added", c.ShortString(), side)
+	return "", false
+}
+
+func (srv *SCServer) cmdRmSide(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	side := args[0]
+	if !r.RemoveSide(side) {
+		return srv.tr(c, "side.cant_remove", side), false
+	}
+	srv.updateSides(r)
+	r.LogEvent(room.EventMod, "%s removed the side '%s'.", c.LongString(), side)
+	srv.tellRoom(r, "side.removed", c.ShortString(), side)
+	return "", false
+}
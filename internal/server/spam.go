@@ -0,0 +1,173 @@
+package server
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// Checks whether the client is repeating the same IC message or preanim too many times
+// in a row. If so, the message is refused with a warning, and after enough warnings the
+// client is IC muted for a while. Returns whether the message can be sent.
+func (srv *SCServer) checkRepeats(c *client.Client, char string, preanim string, emoteMod string, msg string) bool {
+	conf := srv.spamLimits()
+	if conf.MaxRepeats <= 0 {
+		return true
+	}
+	if c.CountRepeat(repeatKey(char, preanim, emoteMod, msg)) <= conf.MaxRepeats {
+		return true
+	}
+
+	r := c.Room()
+	if c.AddSpamWarning() <= conf.Warnings {
+		srv.sendServerMessage(c, "Stop repeating yourself! You'll be muted if you keep going.")
+		r.LogEvent(room.EventFail, "%s was warned for repeating IC messages.", c.LongString())
+		return false
+	}
+	c.ClearSpamWarnings()
+	srv.autoMute(c, client.MutedIC, "repeating IC messages")
+	return false
+}
+
+// Checks whether the message makes the client flood: sending too many identical or
+// near-identical messages, or too many OOC messages in caps, within the configured
+// window. If so, the client is briefly muted and the moderators are notified. Returns
+// whether the message can be sent.
+func (srv *SCServer) checkFlood(c *client.Client, text string, ooc bool) bool {
+	conf := srv.spamLimits()
+	if conf.MaxSimilar <= 0 && conf.MaxCaps <= 0 {
+		return true
+	}
+	now := time.Now()
+	recent := c.RecordMessage(client.SentMessage{Text: text, Time: now, OOC: ooc}, time.Duration(conf.Window)*time.Second)
+
+	mute, channel := client.MutedIC, "IC"
+	if ooc {
+		mute, channel = client.MutedOOC, "OOC"
+	}
+	if conf.MaxSimilar > 0 {
+		similar := 0
+		for _, m := range recent {
+			if m.OOC == ooc && similarity(m.Text, text) >= conf.Similarity {
+				similar++
+			}
+		}
+		if similar > conf.MaxSimilar {
+			srv.autoMute(c, mute, "sending similar "+channel+" messages")
+			return false
+		}
+	}
+	if conf.MaxCaps > 0 && ooc && isCaps(text) {
+		caps := 0
+		for _, m := range recent {
+			if m.OOC && isCaps(m.Text) {
+				caps++
+			}
+		}
+		if caps > conf.MaxCaps {
+			srv.autoMute(c, client.MutedOOC, "flooding OOC in caps")
+			return false
+		}
+	}
+	return true
+}
+
+// Mutes the client for the configured duration, letting it and the moderators know why.
+func (srv *SCServer) autoMute(c *client.Client, m client.MuteState, why string) {
+	dur := time.Duration(srv.config.Spam.MuteDuration) * time.Second
+	c.MuteUntil(m, time.Now().Add(dur))
+
+	kind := "IC"
+	if m == client.MutedOOC {
+		kind = "OOC"
+	}
+	if err := srv.db.AddMute(c.IPID(), kind, false, why, srv.config.Username, dur); err != nil {
+		srv.logger.Warnf("server: Couldn't record mute (%s).", err)
+	}
+	srv.sendServerMessage(c, "%s", srv.fillNotice(srv.config.Templates.Mute,
+		notice{reason: why, moderator: srv.config.Username, kind: kind, duration: dur}))
+	c.Room().LogEvent(room.EventMod, "%s was automatically %v muted for %v for %v.", c.LongString(), kind, dur, why)
+	srv.alertMods("alert.auto_muted", c.LongString(), kind, dur, why)
+}
+
+// Messages shorter than this (in letters) never count as caps.
+const minCapsLetters = 8
+
+// Returns whether the message is mostly written in capital letters.
+func isCaps(text string) bool {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= minCapsLetters && upper*10 >= letters*8
+}
+
+// Returns how similar two messages are, from 0 (completely different) to 1 (identical
+// apart from case and surrounding spaces), according to their edit distance.
+func similarity(a string, b string) float64 {
+	ra := []rune(strings.ToLower(strings.TrimSpace(a)))
+	rb := []rune(strings.ToLower(strings.TrimSpace(b)))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// Returns the Levenshtein distance between the two strings.
+func editDistance(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// Returns what an IC message repeats: the preanim, if one is played, or otherwise the
+// message itself. Blankposts without preanims don't repeat anything, since switching
+// emotes with them is normal.
+func repeatKey(char string, preanim string, emoteMod string, msg string) string {
+	// Emote mods 1 and 2 play the preanim.
+	if (emoteMod == "1" || emoteMod == "2") && preanim != "" && preanim != "-" {
+		return "preanim:" + char + "/" + preanim
+	}
+	if msg == "" {
+		return ""
+	}
+	return "msg:" + msg
+}
+
+// Checks whether the client can speak IC under its room's slow mode. Users who can change
+// the room's settings are exempt. If not, tells the client how long to wait.
+func (srv *SCServer) checkSlowmode(c *client.Client) bool {
+	if c.HasPerms(perms.Settings) {
+		return true
+	}
+	if wait := c.Room().SlowmodeWait(c.UID()); wait > 0 {
+		// Round up, so the wait is never shown as 0s.
+		srv.tell(c, "ic.slowmode", (wait + time.Second - 1).Truncate(time.Second))
+		return false
+	}
+	return true
+}
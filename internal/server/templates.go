@@ -0,0 +1,40 @@
+package server
+
+import (
+	"strings"
+	"time"
+)
+
+// What can be filled into a moderation notice's template.
+type notice struct {
+	reason    string
+	moderator string
+	kind      string        // for mutes, e.g. "IC"
+	duration  time.Duration // 0 if not applicable
+	until     time.Time     // zero if not applicable
+	forever   bool          // for mutes without a duration
+}
+
+// Fills the template's placeholders ({reason}, {moderator}, {kind}, {duration}, {until}
+// and {appeal}) with the notice's information.
+func (srv *SCServer) fillNotice(template string, n notice) string {
+	var duration, until string
+	if n.duration > 0 {
+		duration = n.duration.String()
+	} else if n.forever {
+		duration = "an indefinite time"
+	}
+	if !n.until.IsZero() {
+		until = n.until.UTC().Format(time.UnixDate)
+	} else if n.duration > 0 {
+		until = time.Now().Add(n.duration).UTC().Format(time.UnixDate)
+	}
+	return strings.NewReplacer(
+		"{reason}", n.reason,
+		"{moderator}", n.moderator,
+		"{kind}", n.kind,
+		"{duration}", duration,
+		"{until}", until,
+		"{appeal}", srv.config.Templates.Appeal,
+	).Replace(template)
+}
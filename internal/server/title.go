@@ -0,0 +1,64 @@
+package server
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/perms"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// The titles of the rooms are kept in the database under this prefix and the room's
+// name, so they survive restarts.
+const titleSettingPrefix = "title:"
+
+// Restores the rooms' titles from the database.
+func (srv *SCServer) loadTitles() error {
+	for _, r := range srv.rooms {
+		title, ok, err := srv.db.Setting(titleSettingPrefix + r.Name())
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.SetTitle(title)
+		}
+	}
+	return nil
+}
+
+func (srv *SCServer) cmdTitle(c *client.Client, args []string) (string, bool) {
+	r := c.Room()
+	if len(args) == 0 {
+		if title := r.Title(); title != "" {
+			return srv.tr(c, "title.current", title), false
+		}
+		return srv.tr(c, "title.none"), false
+	}
+	if !c.HasPerms(perms.Description) {
+		return srv.tr(c, "cmd.no_perms", "title", perms.Description&^c.EffectivePerms()), false
+	}
+
+	if len(args) == 1 && args[0] == "clear" {
+		if err := srv.db.RemoveSetting(titleSettingPrefix + r.Name()); err != nil {
+			srv.logger.Warnf("%v", err)
+			return srv.tr(c, "title.clear_error"), false
+		}
+		r.SetTitle("")
+		r.LogEvent(room.EventMod, "%s cleared the title.", c.LongString())
+		srv.tellRoom(r, "title.cleared", c.ShortString())
+		return "", false
+	}
+	title := strings.Join(args, " ")
+	if utf8.RuneCountInString(title) > srv.config.MaxMsgSize {
+		return srv.tr(c, "title.too_long"), false
+	}
+	if err := srv.db.SetSetting(titleSettingPrefix+r.Name(), title); err != nil {
+		srv.logger.Warnf("%v", err)
+		return srv.tr(c, "title.error"), false
+	}
+	r.SetTitle(title)
+	r.LogEvent(room.EventMod, "%s changed the title to '%s'.", c.LongString(), title)
+	srv.tellRoom(r, "title.changed", c.ShortString(), title)
+	return "", false
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// How many random bytes are in a transcript's name. Transcripts are served without
+// authentication, so the name is all that keeps them private.
+const transcriptTokenLen = 16
+
+// Returns where transcripts are saved, the "transcripts" folder in the log directory.
+func transcriptPath() (string, error) {
+	logDir, err := config.LogDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(logDir, "transcripts"), nil
+}
+
+func (srv *SCServer) cmdTranscript(c *client.Client, args []string) (string, bool) {
+	var since time.Time
+	asHTML := false
+	for _, arg := range args {
+		if arg == "html" {
+			asHTML = true
+			continue
+		}
+		d, err := parseDuration(arg)
+		if err != nil {
+			return "", true
+		}
+		since = time.Now().Add(-d)
+	}
+
+	r := c.Room()
+	entries := r.History(since)
+	if len(entries) == 0 {
+		return srv.tr(c, "transcript.empty"), false
+	}
+	name, err := writeTranscript(r, entries, asHTML)
+	if err != nil {
+		srv.logger.Warnf("server: Couldn't save transcript (%v).", err)
+		return srv.tr(c, "transcript.error"), false
+	}
+	r.LogEvent(room.EventMod, "%s saved a transcript of %v messages to %v.", c.LongString(), len(entries), name)
+	if srv.config.TranscriptURL != "" {
+		return srv.tr(c, "transcript.saved", len(entries), strings.TrimSuffix(srv.config.TranscriptURL, "/")+"/"+name), false
+	}
+	// The path was found when the transcript was written.
+	dir, _ := transcriptPath()
+	return srv.tr(c, "transcript.saved_to", len(entries), filepath.Join(dir, name)), false
+}
+
+// Writes the messages to a new file in the transcripts folder, returning its name.
+// The name has a random part, so transcripts can't be guessed when they're served.
+func writeTranscript(r *room.Room, entries []room.HistoryEntry, asHTML bool) (string, error) {
+	dir, err := transcriptPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	token := make([]byte, transcriptTokenLen)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	ext := ".txt"
+	if asHTML {
+		ext = ".html"
+	}
+	name := fmt.Sprintf("%v-%v-%v%v", r.Slug(), time.Now().Format("20060102-150405"), hex.EncodeToString(token), ext)
+
+	var b strings.Builder
+	title := fmt.Sprintf("Transcript of [%v] %v", r.ID(), r.Name())
+	if asHTML {
+		fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%v</title></head>\n<body>\n<h1>%v</h1>\n",
+			html.EscapeString(title), html.EscapeString(title))
+		for _, e := range entries {
+			fmt.Fprintf(&b, "<p><small>%v</small> <b title=\"%v\">%v</b>: %v</p>\n", e.Time.UTC().Format(time.DateTime),
+				html.EscapeString(e.Char), html.EscapeString(e.Name), html.EscapeString(e.Text))
+		}
+		b.WriteString("</body>\n</html>\n")
+	} else {
+		fmt.Fprintf(&b, "%v\n\n", title)
+		for _, e := range entries {
+			fmt.Fprintf(&b, "[%v] %v (%v): %v\n", e.Time.UTC().Format(time.DateTime), e.Name, e.Char, e.Text)
+		}
+	}
+	return name, os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0644)
+}
+
+// Serves the transcripts folder, without listing it.
+type transcriptDir struct{}
+
+func (transcriptDir) Open(name string) (http.File, error) {
+	dir, err := transcriptPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := http.Dir(dir).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/client"
+)
+
+// How often waiting clients are reminded of their position in the queue.
+const waitUpdateInterval = 30 * time.Second
+
+// Clients waiting for a slot in a full server, in order, and the ones that were
+// given a slot but haven't joined yet.
+type waitlist struct {
+	queue    []waiter
+	admitted map[*client.Client]struct{}
+	mu       sync.Mutex
+}
+
+type waiter struct {
+	c     *client.Client
+	admit func() // resumes the client's handshake, see [SCServer.enqueue]
+}
+
+func newWaitlist() *waitlist {
+	return &waitlist{admitted: make(map[*client.Client]struct{})}
+}
+
+// Puts the client at the end of the queue, to call `admit` once a slot frees up.
+// Returns `false` if the queue is disabled or full. `admit` is called by whichever
+// client freed the slot, usually while it's being removed, so it should only write to
+// the waiting client, or [client.Client.Post] anything more to the client's own loop.
+func (srv *SCServer) enqueue(c *client.Client, admit func()) bool {
+	w := srv.waits
+	w.mu.Lock()
+	if len(w.queue) >= srv.config.WaitQueue {
+		w.mu.Unlock()
+		return false
+	}
+	w.queue = append(w.queue, waiter{c, admit})
+	pos := len(w.queue)
+	w.mu.Unlock()
+
+	srv.logger.Infof("A client (IPID: %v) is waiting for a slot (position %v).", c.IPID(), pos)
+	c.Notify(srv.tr(c, "server.queued", pos))
+	return true
+}
+
+// Returns how many clients other than the passed one were given a slot but haven't
+// joined yet. They count as joined when checking whether the server is full.
+func (w *waitlist) held(c *client.Client) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.admitted[c]; ok {
+		return len(w.admitted) - 1
+	}
+	return len(w.admitted)
+}
+
+// Removes the client from the queue and from the admitted clients, e.g. because it
+// joined or disconnected.
+func (w *waitlist) forget(c *client.Client) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.admitted, c)
+	for i, wt := range w.queue {
+		if wt.c == c {
+			w.queue = append(w.queue[:i], w.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// Admits waiting clients, in order, while there are free slots for them.
+func (srv *SCServer) admitWaiting() {
+	w := srv.waits
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		next := w.queue[0]
+		w.mu.Unlock()
+
+		if srv.serverFull(next.c) {
+			return
+		}
+
+		w.mu.Lock()
+		// It may have left or been admitted in the meantime.
+		if len(w.queue) == 0 || w.queue[0].c != next.c {
+			w.mu.Unlock()
+			continue
+		}
+		w.queue = w.queue[1:]
+		w.admitted[next.c] = struct{}{}
+		w.mu.Unlock()
+
+		srv.logger.Infof("A client (IPID: %v) was admitted from the queue.", next.c.IPID())
+		next.admit()
+	}
+}
+
+// Reminds the waiting clients of their position every [waitUpdateInterval].
+func (srv *SCServer) updateWaiting() {
+	for range time.Tick(waitUpdateInterval) {
+		srv.waits.mu.Lock()
+		queue := append([]waiter(nil), srv.waits.queue...)
+		srv.waits.mu.Unlock()
+		for i, wt := range queue {
+			wt.c.Notify(srv.tr(wt.c, "server.queued", i+1))
+		}
+	}
+}
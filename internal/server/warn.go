@@ -0,0 +1,61 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+func (srv *SCServer) cmdWarn(c *client.Client, args []string) (string, bool) {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return srv.tr(c, "cmd.bad_uid", args[0]), false
+	}
+	target := srv.getByUID(id)
+	if target == nil {
+		return srv.tr(c, "cmd.no_uid", id), false
+	}
+	reason := strings.Join(args[1:], " ")
+	unacked, err := srv.db.AddWarning(target.IPID(), reason, c.String())
+	if err != nil {
+		srv.logger.Warnf("server: Couldn't record warning (%s).", err)
+		return srv.tr(c, "warn.error"), false
+	}
+	target.Room().LogEvent(room.EventMod, "%s warned %s (%v unacknowledged). Reason: %s",
+		c.LongString(), target.LongString(), unacked, reason)
+
+	if limit := srv.config.WarnKickAfter; limit > 0 && unacked >= limit {
+		srv.kickClient(target, srv.tr(target, "warn.kick_reason", unacked, reason), c.String())
+		return srv.tr(c, "warn.kicked", id, unacked), false
+	}
+	target.Notify(srv.tr(target, "warn.warned", reason))
+	return srv.tr(c, "warn.done", id, unacked), false
+}
+
+// Acknowledges the client's pending warnings, for /ack without arguments.
+func (srv *SCServer) ackWarnings(c *client.Client) string {
+	n, err := srv.db.AckWarnings(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Couldn't acknowledge warnings (%s).", err)
+		return srv.tr(c, "warn.ack_error")
+	}
+	if n == 0 {
+		return srv.tr(c, "warn.ack_none")
+	}
+	c.Room().LogEvent(room.EventMod, "%s acknowledged %v warnings.", c.LongString(), n)
+	return srv.tr(c, "warn.acked", n)
+}
+
+// Reminds a client that just joined of its unacknowledged warnings, if any.
+func (srv *SCServer) remindWarnings(c *client.Client) {
+	n, err := srv.db.UnackedWarnings(c.IPID())
+	if err != nil {
+		srv.logger.Warnf("server: Couldn't count warnings (%s).", err)
+		return
+	}
+	if n > 0 {
+		c.Notify(srv.tr(c, "warn.reminder", n))
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lambdcalculus/scs/internal/client"
+	"github.com/lambdcalculus/scs/internal/events"
+	"github.com/lambdcalculus/scs/internal/room"
+)
+
+// The rooms each moderator is watching with /watch, and their subscriptions to the
+// rooms' events.
+type watchList struct {
+	subs map[*client.Client]map[*room.Room]events.Subscription
+	mu   sync.Mutex
+}
+
+func newWatchList() *watchList {
+	return &watchList{subs: make(map[*client.Client]map[*room.Room]events.Subscription)}
+}
+
+// Starts relaying the room's OOC messages and kicks to the client. Returns `false` if
+// it was already watching the room.
+func (srv *SCServer) watch(c *client.Client, r *room.Room) bool {
+	srv.watches.mu.Lock()
+	defer srv.watches.mu.Unlock()
+	if _, ok := srv.watches.subs[c][r]; ok {
+		return false
+	}
+	filter := func(e events.Event) bool {
+		// OOC messages in the watcher's own room already reach it.
+		return e.Room == r && !(e.Kind == events.OOC && c.Room() == r)
+	}
+	sub := srv.events.SubscribeFiltered(func(e events.Event) { srv.relayWatched(c, e) }, filter, events.OOC, events.Kick)
+	if srv.watches.subs[c] == nil {
+		srv.watches.subs[c] = make(map[*room.Room]events.Subscription)
+	}
+	srv.watches.subs[c][r] = sub
+	return true
+}
+
+// Stops relaying the room's events to the client. Returns `false` if it wasn't
+// watching the room.
+func (srv *SCServer) unwatch(c *client.Client, r *room.Room) bool {
+	srv.watches.mu.Lock()
+	defer srv.watches.mu.Unlock()
+	sub, ok := srv.watches.subs[c][r]
+	if !ok {
+		return false
+	}
+	srv.events.Unsubscribe(sub)
+	delete(srv.watches.subs[c], r)
+	if len(srv.watches.subs[c]) == 0 {
+		delete(srv.watches.subs, c)
+	}
+	return true
+}
+
+// Stops relaying every room's events to the client, e.g. because it disconnected.
+func (srv *SCServer) unwatchAll(c *client.Client) {
+	srv.watches.mu.Lock()
+	defer srv.watches.mu.Unlock()
+	for _, sub := range srv.watches.subs[c] {
+		srv.events.Unsubscribe(sub)
+	}
+	delete(srv.watches.subs, c)
+}
+
+// Returns the rooms the client is watching, sorted by ID.
+func (srv *SCServer) watched(c *client.Client) []*room.Room {
+	srv.watches.mu.Lock()
+	defer srv.watches.mu.Unlock()
+	rooms := make([]*room.Room, 0, len(srv.watches.subs[c]))
+	for r := range srv.watches.subs[c] {
+		rooms = append(rooms, r)
+	}
+	slices.SortFunc(rooms, func(a, b *room.Room) int { return a.ID() - b.ID() })
+	return rooms
+}
+
+func (srv *SCServer) relayWatched(c *client.Client, e events.Event) {
+	prefix := srv.tr(c, "watch.prefix", e.Room.ID(), e.Room.Name())
+	switch e.Kind {
+	case events.OOC:
+		srv.sendServerMessage(c, "%s %s: %s", prefix, e.Name, e.Text)
+	case events.Kick:
+		srv.sendServerMessage(c, "%s %s", prefix, srv.tr(c, "watch.kicked", e.Client.ShortString(), e.Actor, e.Text))
+	}
+}
+
+// Finds a room by ID or name among every room, regardless of visibility.
+func (srv *SCServer) findRoom(query string) *room.Room {
+	id, err := strconv.Atoi(query)
+	for _, r := range srv.rooms {
+		if (err == nil && r.ID() == id) || strings.EqualFold(r.Name(), query) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (srv *SCServer) cmdWatch(c *client.Client, args []string) (string, bool) {
+	if len(args) == 0 {
+		rooms := srv.watched(c)
+		if len(rooms) == 0 {
+			return srv.tr(c, "watch.none"), false
+		}
+		names := make([]string, len(rooms))
+		for i, r := range rooms {
+			names[i] = fmt.Sprintf("[%v] %s", r.ID(), r.Name())
+		}
+		return srv.tr(c, "watch.list", strings.Join(names, ", ")), false
+	}
+	query := strings.Join(args, " ")
+	r := srv.findRoom(query)
+	if r == nil {
+		return srv.tr(c, "cmd.no_room", query), false
+	}
+	if !srv.watch(c, r) {
+		return srv.tr(c, "watch.already", r.ID(), r.Name()), false
+	}
+	r.LogEvent(room.EventMod, "%s started watching the room.", c.LongString())
+	return srv.tr(c, "watch.started", r.ID(), r.Name()), false
+}
+
+func (srv *SCServer) cmdUnwatch(c *client.Client, args []string) (string, bool) {
+	query := strings.Join(args, " ")
+	if query == "" || query == "all" {
+		srv.unwatchAll(c)
+		return srv.tr(c, "watch.stopped_all"), false
+	}
+	r := srv.findRoom(query)
+	if r == nil {
+		return srv.tr(c, "cmd.no_room", query), false
+	}
+	if !srv.unwatch(c, r) {
+		return srv.tr(c, "watch.not_watching", r.ID(), r.Name()), false
+	}
+	return srv.tr(c, "watch.stopped", r.ID(), r.Name()), false
+}
@@ -0,0 +1,95 @@
+// Package `stats` keeps server-wide counters since boot.
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Holds the server's counters. Its methods can be called from multiple goroutines.
+type Stats struct {
+	start time.Time
+
+	ic       atomic.Int64
+	ooc      atomic.Int64
+	commands atomic.Int64
+	music    atomic.Int64
+	joins    atomic.Int64
+	modcalls atomic.Int64
+	kicks    atomic.Int64
+	bans     atomic.Int64
+	evicted  atomic.Int64
+	peak     atomic.Int64
+}
+
+// A copy of the counters at a point in time.
+type Snapshot struct {
+	Uptime       time.Duration
+	ICMessages   int64
+	OOCMessages  int64
+	Commands     int64
+	MusicChanges int64
+	Joins        int64
+	ModCalls     int64
+	Kicks        int64
+	Bans         int64
+	Evictions    int64
+	PeakPlayers  int64
+}
+
+// Creates a new set of counters, with the uptime counting from now.
+func New() *Stats {
+	return &Stats{start: time.Now()}
+}
+
+// Counts an IC message.
+func (s *Stats) AddIC() { s.ic.Add(1) }
+
+// Counts an OOC message.
+func (s *Stats) AddOOC() { s.ooc.Add(1) }
+
+// Counts a command.
+func (s *Stats) AddCommand() { s.commands.Add(1) }
+
+// Counts a song change.
+func (s *Stats) AddMusic() { s.music.Add(1) }
+
+// Counts a mod call.
+func (s *Stats) AddModCall() { s.modcalls.Add(1) }
+
+// Counts a kick.
+func (s *Stats) AddKick() { s.kicks.Add(1) }
+
+// Counts a ban.
+func (s *Stats) AddBan() { s.bans.Add(1) }
+
+// Counts a client evicted for not keeping up with its write queue.
+func (s *Stats) AddEviction() { s.evicted.Add(1) }
+
+// Counts a join, updating the peak of concurrent players with the amount of players after it.
+func (s *Stats) AddJoin(players int) {
+	s.joins.Add(1)
+	for {
+		peak := s.peak.Load()
+		if int64(players) <= peak || s.peak.CompareAndSwap(peak, int64(players)) {
+			return
+		}
+	}
+}
+
+// Returns a copy of the current counters.
+func (s *Stats) Snapshot() Snapshot {
+	return Snapshot{
+		Uptime:       time.Since(s.start),
+		ICMessages:   s.ic.Load(),
+		OOCMessages:  s.ooc.Load(),
+		Commands:     s.commands.Load(),
+		MusicChanges: s.music.Load(),
+		Joins:        s.joins.Load(),
+		ModCalls:     s.modcalls.Load(),
+		Kicks:        s.kicks.Load(),
+		Bans:         s.bans.Load(),
+		Evictions:    s.evicted.Load(),
+		PeakPlayers:  s.peak.Load(),
+	}
+}
@@ -0,0 +1,78 @@
+// Package `totp` implements time-based one-time passwords (RFC 6238), as used by
+// authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// How long each code is valid for.
+	period = 30 * time.Second
+	// How many digits codes have, and 10 to that power.
+	digits  = 6
+	modulus = 1000000
+	// How many periods before and after the current one are also accepted, to allow
+	// for clock drift and slow typing.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Generates a new random secret, encoded in base32.
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("totp: Couldn't generate secret (%w).", err)
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Checks whether the code is valid for the secret at time `t`, returning the time step
+// it belongs to. A code stays valid for a while, so callers should accept each step only
+// once per secret, and only after the last one accepted, so seen codes can't be replayed.
+func Validate(secret string, code string, t time.Time) (step int64, ok bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != digits {
+		return 0, false
+	}
+	now := t.Unix() / int64(period.Seconds())
+	for i := -skew; i <= skew; i++ {
+		want := generate(key, uint64(now+int64(i)))
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return now + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// Returns the provisioning URI for the secret, which authenticator apps can read
+// (usually from a QR code) to set the account up.
+func URI(secret string, account string, issuer string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// Generates the code for the counter, as in RFC 4226.
+func generate(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	n := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, n%modulus)
+}
@@ -0,0 +1,35 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := encoding.DecodeString(secret)
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / int64(period.Seconds())
+	for _, tc := range []struct {
+		desc  string
+		step  int64
+		valid bool
+	}{
+		{"current step", step, true},
+		{"previous step", step - 1, true},
+		{"next step", step + 1, true},
+		{"too old", step - 2, false},
+		{"too new", step + 2, false},
+	} {
+		got, ok := Validate(secret, generate(key, uint64(tc.step)), now)
+		if ok != tc.valid || (ok && got != tc.step) {
+			t.Errorf("%v: got step %v, %v; want %v, %v", tc.desc, got, ok, tc.step, tc.valid)
+		}
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("accepted a code that's too short")
+	}
+}
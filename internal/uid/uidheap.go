@@ -2,6 +2,7 @@
 package uid
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/lambdcalculus/scs/pkg/minheap"
@@ -12,11 +13,15 @@ const (
     Unjoined = 0
 )
 
+// Returned by [UIDHeap.Take] when every UID has been taken.
+var ErrExhausted = errors.New("uid: No UIDs available.")
+
 // The UIDHeap stores which UID values can be taken by new users.
 // Its methods can be called from multiple goroutines.
 type UIDHeap struct {
-	heap minheap.MinHeap
-	mu   sync.Mutex
+	heap  minheap.MinHeap
+	taken []bool // by UID - 1
+	mu    sync.Mutex
 }
 
 // Creates a new [UIDHeap] that can give up to `max` UIDs (1, 2, ..., max).
@@ -26,20 +31,34 @@ func CreateHeap(max int) *UIDHeap {
 		init[i] = i+1
 	}
 	return &UIDHeap{
-		heap: minheap.NewHeap(init),
+		heap:  minheap.NewHeap(init),
+		taken: make([]bool, max),
 	}
 }
 
 // Takes and returns the smallest available UID, popping it from the heap.
-func (u *UIDHeap) Take() int {
+// If no UIDs are left, returns [ErrExhausted].
+func (u *UIDHeap) Take() (int, error) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	return u.heap.Pop()
+	if u.heap.Len() == 0 {
+		return Unjoined, ErrExhausted
+	}
+	id := u.heap.Pop()
+	u.taken[id-1] = true
+	return id, nil
 }
 
-// Frees the passed UID, pushing it into the heap.
-func (u *UIDHeap) Free(id int) {
+// Frees the passed UID, pushing it into the heap. UIDs that aren't taken (including
+// [Unjoined] and UIDs already freed) are ignored, so freeing one twice can't give it to
+// two clients. Returns whether the UID was freed.
+func (u *UIDHeap) Free(id int) bool {
 	u.mu.Lock()
 	defer u.mu.Unlock()
+	if id < 1 || id > len(u.taken) || !u.taken[id-1] {
+		return false
+	}
+	u.taken[id-1] = false
 	u.heap.Push(id)
+	return true
 }
@@ -0,0 +1,104 @@
+package uid
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestTakeSmallestFirst(t *testing.T) {
+	u := CreateHeap(3)
+	for want := 1; want <= 3; want++ {
+		if got, err := u.Take(); err != nil || got != want {
+			t.Fatalf("Take() = %v, %v; want %v, nil", got, err, want)
+		}
+	}
+	if _, err := u.Take(); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("Take() on a full heap returned %v; want ErrExhausted", err)
+	}
+	u.Free(2)
+	if got, _ := u.Take(); got != 2 {
+		t.Fatalf("Take() after freeing 2 = %v; want 2", got)
+	}
+}
+
+func TestFreeTwice(t *testing.T) {
+	u := CreateHeap(3)
+	id, _ := u.Take()
+	if !u.Free(id) {
+		t.Fatalf("first Free(%v) = false; want true", id)
+	}
+	if u.Free(id) {
+		t.Fatalf("second Free(%v) = true; want false", id)
+	}
+	a, _ := u.Take()
+	b, _ := u.Take()
+	if a == b {
+		t.Fatalf("two Take() calls both returned %v after a double free", a)
+	}
+}
+
+func TestFreeUntaken(t *testing.T) {
+	u := CreateHeap(3)
+	for _, id := range []int{Unjoined, -1, 1, 4} {
+		if u.Free(id) {
+			t.Errorf("Free(%v) of an untaken UID = true; want false", id)
+		}
+	}
+}
+
+// Run with -race.
+func TestConcurrentTakeFree(t *testing.T) {
+	const (
+		max     = 16
+		workers = 32
+		rounds  = 200
+	)
+	u := CreateHeap(max)
+	var (
+		held = make(map[int]bool)
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				id, err := u.Take()
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				if held[id] {
+					mu.Unlock()
+					t.Errorf("UID %v was given to two holders at once", id)
+					return
+				}
+				held[id] = true
+				mu.Unlock()
+
+				mu.Lock()
+				delete(held, id)
+				mu.Unlock()
+				u.Free(id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for {
+		id, err := u.Take()
+		if err != nil {
+			break
+		}
+		if seen[id] {
+			t.Fatalf("UID %v is in the heap twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != max {
+		t.Fatalf("%v UIDs left after all were freed; want %v", len(seen), max)
+	}
+}
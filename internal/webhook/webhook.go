@@ -0,0 +1,179 @@
+// Package `webhook` posts server notifications to a Discord webhook.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lambdcalculus/scs/internal/config"
+	"github.com/lambdcalculus/scs/pkg/logger"
+)
+
+// Embed colors, as used by Discord (0xRRGGBB).
+const (
+	colorModCall = 0xf1c40f
+	colorBan     = 0xe74c3c
+	colorError   = 0x95a5a6
+)
+
+// How many posts can be waiting to be sent before new ones are dropped.
+const queueSize = 32
+
+// A Notifier sends embeds to a Discord webhook. Its methods can be called from
+// multiple goroutines. A nil or disabled Notifier silently ignores all calls.
+type Notifier struct {
+	conf   config.Webhooks
+	http   *http.Client
+	queue  chan payload
+	logger *logger.Logger
+
+	// timestamps of the posts sent in the last minute, for rate limiting
+	sent []time.Time
+	mu   sync.Mutex
+}
+
+type payload struct {
+	Username string  `json:"username,omitempty"`
+	Embeds   []embed `json:"embeds"`
+}
+
+type embed struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	Color       int     `json:"color"`
+	Fields      []field `json:"fields,omitempty"`
+	Timestamp   string  `json:"timestamp"`
+}
+
+type field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// Creates a new Notifier according to the configuration. If no URL is configured,
+// returns `nil`, which is a valid (disabled) Notifier.
+func New(conf config.Webhooks, log *logger.Logger) *Notifier {
+	if conf.URL == "" {
+		return nil
+	}
+	n := &Notifier{
+		conf:   conf,
+		http:   &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan payload, queueSize),
+		logger: log,
+	}
+	go n.run()
+	return n
+}
+
+// Posts a mod call, with the room, the caller and the reason.
+func (n *Notifier) ModCall(room string, caller string, reason string) {
+	if n == nil || !n.conf.ModCalls {
+		return
+	}
+	n.post(embed{
+		Title: "Mod call",
+		Color: colorModCall,
+		Fields: []field{
+			{"Room", room, true},
+			{"Caller", caller, true},
+			{"Reason", orNone(reason), false},
+		},
+	})
+}
+
+// Posts a ban, with the banned identifiers, the moderator, the reason and the duration.
+func (n *Notifier) Ban(target string, moderator string, reason string, duration time.Duration) {
+	if n == nil || !n.conf.Bans {
+		return
+	}
+	n.post(embed{
+		Title: "Ban",
+		Color: colorBan,
+		Fields: []field{
+			{"Target", target, true},
+			{"Moderator", moderator, true},
+			{"Duration", duration.String(), true},
+			{"Reason", orNone(reason), false},
+		},
+	})
+}
+
+// Posts a server error.
+func (n *Notifier) Error(format string, a ...any) {
+	if n == nil || !n.conf.Errors {
+		return
+	}
+	n.post(embed{
+		Title:       "Server error",
+		Description: fmt.Sprintf(format, a...),
+		Color:       colorError,
+	})
+}
+
+// Queues an embed for posting, unless the rate limit has been hit or the queue is full.
+func (n *Notifier) post(e embed) {
+	if !n.allow() {
+		n.logger.Debugf("webhook: Rate limit reached, dropping '%s' post.", e.Title)
+		return
+	}
+	e.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	select {
+	case n.queue <- payload{Username: n.conf.Username, Embeds: []embed{e}}:
+	default:
+		n.logger.Debugf("webhook: Queue is full, dropping '%s' post.", e.Title)
+	}
+}
+
+// Checks whether another post fits in the rate limit, and records it if so.
+func (n *Notifier) allow() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conf.RateLimit <= 0 {
+		return true
+	}
+	now := time.Now()
+	i := 0
+	for i < len(n.sent) && now.Sub(n.sent[i]) >= time.Minute {
+		i++
+	}
+	n.sent = n.sent[i:]
+	if len(n.sent) >= n.conf.RateLimit {
+		return false
+	}
+	n.sent = append(n.sent, now)
+	return true
+}
+
+// Sends queued posts one at a time, so a slow webhook never blocks the caller.
+func (n *Notifier) run() {
+	for p := range n.queue {
+		b, err := json.Marshal(p)
+		if err != nil {
+			n.logger.Warnf("webhook: Couldn't marshal payload (%v).", err)
+			continue
+		}
+		resp, err := n.http.Post(n.conf.URL, "application/json", bytes.NewReader(b))
+		if err != nil {
+			n.logger.Warnf("webhook: Couldn't post to webhook (%v).", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			n.logger.Warnf("webhook: Webhook responded with status %v.", resp.Status)
+		}
+	}
+}
+
+// Discord rejects empty field values.
+func orNone(s string) string {
+	if s == "" {
+		return "None given."
+	}
+	return s
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -54,6 +55,7 @@ type Logger struct {
 	fmt     FormatFunc
 	outputs []io.Writer
 	muxs    []sync.Mutex
+	paths   []string // the paths of the outputs that are files, or "" for other outputs
 }
 
 // DefaultLogger logs to stdout and logs at LevelInfo, with a [DefaultFormatter].
@@ -99,6 +101,7 @@ func NewLogger(fmt FormatFunc, lvl LogLevel, writers ...io.Writer) *Logger {
 // used.
 func NewLoggerOutputs(level LogLevel, fmt FormatFunc, outputs ...string) *Logger {
 	outs := []io.Writer{}
+	paths := []string{}
 	execPath, execErr := os.Executable()
 	if execErr != nil {
 		Errorf("logger: Couldn't get executable path (%v), unable to log to relative paths.", execErr.Error())
@@ -107,6 +110,7 @@ func NewLoggerOutputs(level LogLevel, fmt FormatFunc, outputs ...string) *Logger
 	for _, out := range outputs {
 		if out == "stdout" {
 			outs = append(outs, os.Stdout)
+			paths = append(paths, "")
 			continue
 		}
 
@@ -127,15 +131,66 @@ func NewLoggerOutputs(level LogLevel, fmt FormatFunc, outputs ...string) *Logger
 		// If this fails, opening the file will fail too.
 		os.MkdirAll(path.Dir(logPath), os.ModePerm)
 
-		logFile, err := os.OpenFile(logPath,
-			os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+		logFile, err := openLogFile(logPath)
 		if err != nil {
 			Errorf("logger: Couldn't open/create log file at %v (%v). Will not log to this file.", out, err.Error())
 			continue
 		}
 		outs = append(outs, logFile)
+		paths = append(paths, logPath)
 	}
-	return NewLogger(fmt, level, outs...)
+	logger := NewLogger(fmt, level, outs...)
+	logger.paths = paths
+	return logger
+}
+
+func openLogFile(logPath string) (*os.File, error) {
+	return os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+}
+
+// Files returns the paths of the files the Logger writes to, in the order their
+// outputs were passed to [NewLoggerOutputs].
+func (logger *Logger) Files() []string {
+	var files []string
+	for _, p := range logger.paths {
+		if p != "" {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// Rotate moves the Logger's log files aside, appending the current date and time to
+// their names, and starts new ones in their place. Outputs that aren't files made by
+// [NewLoggerOutputs] are left alone.
+func (logger *Logger) Rotate() error {
+	suffix := time.Now().Format("2006-01-02T15-04-05")
+	var errs []error
+	for i, p := range logger.paths {
+		if p == "" {
+			continue
+		}
+		logger.muxs[i].Lock()
+		if f, ok := logger.outputs[i].(*os.File); ok {
+			f.Close()
+		}
+		if err := os.Rename(p, p+"."+suffix); err != nil {
+			errs = append(errs, err)
+		}
+		// Even if renaming failed, the file has to be reopened to keep logging.
+		f, err := openLogFile(p)
+		if err != nil {
+			errs = append(errs, err)
+			logger.outputs[i] = io.Discard
+		} else {
+			logger.outputs[i] = f
+		}
+		logger.muxs[i].Unlock()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("logger: Couldn't rotate logs (%w).", errors.Join(errs...))
+	}
+	return nil
 }
 
 // Log formats a message and writes to the Logger's outputs if the level is appropriate.
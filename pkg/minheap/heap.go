@@ -28,6 +28,11 @@ func NewHeap(init []int) MinHeap {
     return MinHeap{heapImpl: &ih}
 }
 
+// Len returns the amount of elements in a [MinHeap].
+func (h MinHeap) Len() int {
+    return h.heapImpl.Len()
+}
+
 // Min returns the smallest element from a [MinHeap].
 // The time complexity is O(1).
 func (h MinHeap) Min() int {
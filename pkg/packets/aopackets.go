@@ -27,6 +27,10 @@ const (
     EffectFadeIn SongEffect = 1 << iota
     EffectFadeOut 
     EffectSync
+
+    // The effects used when none are specified.
+    EffectDefault = EffectFadeIn | EffectFadeOut
+    EffectAll = EffectFadeIn | EffectFadeOut | EffectSync
 )
 
 
@@ -73,6 +77,23 @@ func (p *PacketAO) Encode() {
     }
 }
 
+// Returns the packet encoded in the wire format, i.e. `HEADER#arg1#arg2#...#%`.
+// Unlike [PacketAO.Encode], this doesn't modify the packet.
+func (p PacketAO) Encoded() string {
+    var sb strings.Builder
+    sb.WriteString(p.Header)
+    sb.WriteString("#")
+    for _, s := range p.Contents {
+        sb.WriteString(encode(s))
+        sb.WriteString("#")
+    }
+    if len(p.Contents) == 0 {
+        sb.WriteString("#")
+    }
+    sb.WriteString("%")
+    return sb.String()
+}
+
 // Decodes an AO packet.
 func (p *PacketAO) Decode() {
     for i, s := range p.Contents {
@@ -23,6 +23,12 @@ type DataHelloClient struct {
 	Ident   string `json:"identifier"`
 }
 
+// Sent after 'hello' to join the server. If `Session` is the token of a session that was
+// lost within the server's grace period, the session is resumed.
+type DataJoinClient struct {
+	Session string `json:"session,omitempty"`
+}
+
 // Server packets
 
 type DataHelloServer struct {
@@ -35,6 +41,28 @@ type DataHelloServer struct {
 	Packages []string `json:"packages"`
 }
 
+// Sent when a client joins. The session token should be kept, so the session can be
+// resumed if the connection is lost.
+type DataJoinServer struct {
+	UID     int    `json:"uid"`
+	Room    int    `json:"room"`
+	CID     int    `json:"cid"`
+	Session string `json:"session"`
+	Resumed bool   `json:"resumed"`
+}
+
+// A room's state in the ROOMUPDATE packet. Besides the ID, only the fields that were
+// updated are present.
+type RoomState struct {
+	ID         int    `json:"id"`
+	Players    *int   `json:"players,omitempty"` // including spectators
+	Spectators *int   `json:"spectators,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Manager    string `json:"manager,omitempty"`
+	Lock       string `json:"lock,omitempty"`
+}
+type DataRoomUpdate []RoomState
+
 type DataCharList []string
 type DataCharListTaken []string
 
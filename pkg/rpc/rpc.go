@@ -18,6 +18,13 @@ import (
 type Implementation interface {
 	AddAuth(args *AddAuthArgs, reply *int) error
 	RmAuth(args *RmAuthArgs, reply *int) error
+	SetPassword(args *SetPasswordArgs, reply *int) error
+	SetupTOTP(args *SetupTOTPArgs, reply *string) error
+	AddIPBan(args *AddIPBanArgs, reply *int) error
+	RmIPBan(args *RmIPBanArgs, reply *int) error
+	Stats(args *StatsArgs, reply *StatsReply) error
+	CharStats(args *CharStatsArgs, reply *CharStatsReply) error
+	MaintainDB(args *MaintainDBArgs, reply *MaintainDBReply) error
 }
 
 // Wraps the HTTP server generated by the implementation.
@@ -38,6 +45,87 @@ type RmAuthArgs struct {
 	Username string
 }
 
+// Arguments for the SetPassword operation.
+type SetPasswordArgs struct {
+	Username string
+	Password string
+}
+
+// Arguments for the SetupTOTP operation.
+type SetupTOTPArgs struct {
+	Username string
+	Disable  bool // removes the user's secret instead of making a new one
+}
+
+// Arguments for the AddIPBan operation.
+type AddIPBanArgs struct {
+	CIDR     string // a single IP or a range in CIDR notation
+	Duration string // e.g. "12h", "3d", "2w"
+	Reason   string
+}
+
+// Arguments for the RmIPBan operation.
+type RmIPBanArgs struct {
+	BanID int
+}
+
+// Arguments for the Stats operation. There are none.
+type StatsArgs struct{}
+
+// Reply for the Stats operation.
+type StatsReply struct {
+	Uptime       time.Duration
+	Players      int
+	MaxPlayers   int
+	Connections  int
+	PeakPlayers  int64
+	Joins        int64
+	ICMessages   int64
+	OOCMessages  int64
+	Commands     int64
+	MusicChanges int64
+	ModCalls     int64
+	Kicks        int64
+	Bans         int64
+	Evictions    int64
+	Queued       int // packets waiting in write queues
+	LargestQueue int
+	AvgRTT       time.Duration // among the clients whose latency could be measured
+	MaxRTT       time.Duration
+}
+
+// Arguments for the CharStats operation.
+type CharStatsArgs struct {
+	Room string // the room's name, or "" for every room
+}
+
+// How much a character was used.
+type CharUsage struct {
+	Name  string
+	Picks int
+	Time  time.Duration
+}
+
+// Reply for the CharStats operation. The most used characters come first.
+type CharStatsReply struct {
+	Chars []CharUsage
+}
+
+// Arguments for the MaintainDB operation.
+type MaintainDBArgs struct{}
+
+// Reply for the MaintainDB operation: how many records of each kind were pruned.
+type MaintainDBReply struct {
+	Mutes         int64
+	Bans          int64
+	IPBans        int64
+	RoleGrants    int64
+	ModCalls      int64
+	Warnings      int64
+	LoginFailures int64
+	Took          time.Duration
+}
+
 // Returns an HTTP server that serves RPC in the passed port.
 // The "Impl" variables should be used to configure its operations
 // before running the server.
@@ -69,3 +157,38 @@ func (srv *Server) AddAuth(args *AddAuthArgs, reply *int) error {
 func (srv *Server) RmAuth(args *RmAuthArgs, reply *int) error {
 	return srv.impl.RmAuth(args, reply)
 }
+
+// Changes the password of an user in the auth table.
+func (srv *Server) SetPassword(args *SetPasswordArgs, reply *int) error {
+	return srv.impl.SetPassword(args, reply)
+}
+
+// Sets up TOTP for an user in the auth table. The reply is the provisioning URI.
+func (srv *Server) SetupTOTP(args *SetupTOTPArgs, reply *string) error {
+	return srv.impl.SetupTOTP(args, reply)
+}
+
+// Bans a range of raw IPs. The reply is the ID of the new ban.
+func (srv *Server) AddIPBan(args *AddIPBanArgs, reply *int) error {
+	return srv.impl.AddIPBan(args, reply)
+}
+
+// Lifts a ban on a range of raw IPs.
+func (srv *Server) RmIPBan(args *RmIPBanArgs, reply *int) error {
+	return srv.impl.RmIPBan(args, reply)
+}
+
+// Gets the server's statistics since boot.
+func (srv *Server) Stats(args *StatsArgs, reply *StatsReply) error {
+	return srv.impl.Stats(args, reply)
+}
+
+// Gets how much each character was used.
+func (srv *Server) CharStats(args *CharStatsArgs, reply *CharStatsReply) error {
+	return srv.impl.CharStats(args, reply)
+}
+
+// Prunes the database of records older than the configured retention and vacuums it.
+func (srv *Server) MaintainDB(args *MaintainDBArgs, reply *MaintainDBReply) error {
+	return srv.impl.MaintainDB(args, reply)
+}